@@ -18,88 +18,51 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/newsletters": {
-            "get": {
-                "description": "Retrieves a list of newsletters with optional search and pagination parameters",
+        "/attachments": {
+            "post": {
+                "description": "Stores a file in GridFS so that newsletters can attach it by ID. The file is streamed to storage without being held in memory, and is rejected if it breaks the attachment size or type limits",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "newsletters"
+                    "attachments"
                 ],
-                "summary": "Get a list of newsletters",
+                "summary": "Upload an attachment",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Name of the newsletter to search for",
-                        "name": "name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page number for pagination",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Number of items per page for pagination",
-                        "name": "pageSize",
-                        "in": "query"
+                        "type": "file",
+                        "description": "File to attach",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "400": {
-                        "description": "Bad Request",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/service.ErrorResponse"
+                            "$ref": "#/definitions/domain.Attachment"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
-                    }
-                }
-            },
-            "put": {
-                "description": "Allows an admin user to update an existing newsletter",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "newsletters"
-                ],
-                "summary": "Update an existing newsletter",
-                "parameters": [
-                    {
-                        "description": "Update newsletter details",
-                        "name": "updateRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/request.UpdateNewsletterRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "413": {
+                        "description": "Attachment too large",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "415": {
+                        "description": "Attachment type not allowed",
                         "schema": {
-                            "$ref": "#/definitions/service.ErrorResponse"
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
                         }
                     },
                     "500": {
@@ -109,39 +72,36 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Allows an admin user to create a new newsletter",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/attachments/{id}": {
+            "get": {
+                "description": "Streams the content of an uploaded attachment",
                 "produces": [
-                    "application/json"
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "newsletters"
+                    "attachments"
                 ],
-                "summary": "Create a new newsletter",
+                "summary": "Download an attachment",
                 "parameters": [
                     {
-                        "description": "Newsletter details",
-                        "name": "newsletter",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/domain.Newsletter"
-                        }
+                        "type": "string",
+                        "description": "ID of the attachment",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "Attachment content",
                         "schema": {
-                            "type": "string"
+                            "type": "file"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
@@ -155,33 +115,40 @@ const docTemplate = `{
                 }
             }
         },
-        "/newsletters/send/{newsletterID}": {
+        "/complaints": {
             "post": {
-                "description": "Allows an admin user to send a newsletter to a list of subscribers",
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Takes an abuse report in Abuse Reporting Format (RFC 5965), as sent by the feedback loop of a mailbox provider, as the raw message. A complaint is recorded on the delivery it is about, named by its VERP address or Message-ID, and the address of the delivery is unsubscribed from every category and suppressed. Reports that match no delivery, and those that are not complaints, such as not-spam, are accepted and ignored",
                 "consumes": [
-                    "application/json"
+                    "message/rfc822"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "newsletters"
+                    "complaints"
                 ],
-                "summary": "Send newsletter to subscribers",
+                "summary": "Ingest a spam complaint",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID of the newsletter to be sent",
-                        "name": "newsletterID",
-                        "in": "path",
-                        "required": true
+                        "description": "Abuse report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/response.ComplaintResponse"
                         }
                     },
                     "400": {
@@ -190,6 +157,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -199,9 +172,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/newsletters/{id}": {
-            "delete": {
-                "description": "Allows an admin user to delete a newsletter",
+        "/confirm/{token}": {
+            "get": {
+                "description": "Confirms the pending subscription named by a signed confirmation token, as sent in the confirmation email",
                 "consumes": [
                     "application/json"
                 ],
@@ -209,14 +182,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "newsletters"
+                    "subscribers"
                 ],
-                "summary": "Delete a newsletter",
+                "summary": "Confirm a subscription",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID of the newsletter to delete",
-                        "name": "id",
+                        "description": "Signed confirmation token",
+                        "name": "token",
                         "in": "path",
                         "required": true
                     }
@@ -229,7 +202,13 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid confirmation link",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Confirmation link has expired",
                         "schema": {
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
@@ -241,11 +220,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/subscribe/{email}/{category}": {
+            },
             "post": {
-                "description": "Allows a user to subscribe to the newsletter",
+                "description": "Confirms the pending subscription named by a signed confirmation token, as sent in the confirmation email",
                 "consumes": [
                     "application/json"
                 ],
@@ -255,19 +232,12 @@ const docTemplate = `{
                 "tags": [
                     "subscribers"
                 ],
-                "summary": "Subscribe to the newsletter",
+                "summary": "Confirm a subscription",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Email address to subscribe",
-                        "name": "email",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Category to subscribe to",
-                        "name": "category",
+                        "description": "Signed confirmation token",
+                        "name": "token",
                         "in": "path",
                         "required": true
                     }
@@ -280,7 +250,13 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid confirmation link",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Confirmation link has expired",
                         "schema": {
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
@@ -294,9 +270,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/subscribers": {
+        "/newsletters": {
             "get": {
-                "description": "Retrieves a list of subscribers with optional search and pagination parameters",
+                "description": "Retrieves a list of newsletters with optional search and pagination parameters",
                 "consumes": [
                     "application/json"
                 ],
@@ -304,20 +280,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscribers"
+                    "newsletters"
                 ],
-                "summary": "Get a list of subscribers",
+                "summary": "Get a list of newsletters",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Email address of the subscriber to search for",
-                        "name": "email",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Category of the subscriber to search for",
-                        "name": "category",
+                        "description": "Name of the newsletter to search for",
+                        "name": "name",
                         "in": "query"
                     },
                     {
@@ -347,11 +317,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/subscribers/{email}/{category}": {
-            "get": {
-                "description": "Get details of a subscriber by email address",
+            },
+            "put": {
+                "description": "Allows an admin user to update an existing newsletter",
                 "consumes": [
                     "application/json"
                 ],
@@ -359,38 +327,45 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscribers"
+                    "newsletters"
                 ],
-                "summary": "Get subscriber by email and category",
+                "summary": "Update an existing newsletter",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Email address to get details",
-                        "name": "email",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Category to subscribe to",
-                        "name": "category",
-                        "in": "path",
-                        "required": true
+                        "description": "Update newsletter details",
+                        "name": "updateRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.UpdateNewsletterRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/domain.Subscriber"
+                            "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Subscriber not found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
                     },
+                    "413": {
+                        "description": "Attachment too large",
+                        "schema": {
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Attachment type not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -398,11 +373,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/unsubscribe/{email}/{category}": {
-            "delete": {
-                "description": "Allows a user to unsubscribe from the newsletter",
+            },
+            "post": {
+                "description": "Allows an admin user to create a new newsletter",
                 "consumes": [
                     "application/json"
                 ],
@@ -410,28 +383,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscribers"
+                    "newsletters"
                 ],
-                "summary": "Unsubscribe from the newsletter",
+                "summary": "Create a new newsletter",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Email address to unsubscribe",
-                        "name": "email",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Category to subscribe to",
-                        "name": "category",
-                        "in": "path",
-                        "required": true
+                        "description": "Newsletter details",
+                        "name": "newsletter",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/domain.Newsletter"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "type": "string"
                         }
@@ -442,6 +410,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/service.ErrorResponse"
                         }
                     },
+                    "413": {
+                        "description": "Attachment too large",
+                        "schema": {
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Attachment type not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/response.AttachmentErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -450,20 +430,1371 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
+        },
+        "/newsletters/jobs/{jobID}": {
+            "get": {
+                "description": "Retrieves the status and progress of a newsletter send job",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "newsletters"
+                ],
+                "summary": "Get a send job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the send job",
+                        "name": "jobID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.SendJob"
+                        }
+                    },
+                    "404": {
+                        "description": "Send job not found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/newsletters/send/{newsletterID}": {
+            "post": {
+                "description": "Allows an admin user to queue a newsletter for sending to its subscribers. The newsletter is delivered in the background; use the returned job ID to follow its progress. Newsletters with an attachment that is infected or was not scanned are refused.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "newsletters"
+                ],
+                "summary": "Send newsletter to subscribers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the newsletter to be sent",
+                        "name": "newsletterID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/response.SendNewsletterResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "An attachment is infected or was not scanned",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/newsletters/{id}": {
+            "delete": {
+                "description": "Allows an admin user to delete a newsletter",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "newsletters"
+                ],
+                "summary": "Delete a newsletter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the newsletter to delete",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/newsletters/{id}/deliveries": {
+            "get": {
+                "description": "Retrieves the per-recipient delivery log of a newsletter with optional filters and pagination, plus a summary of deliveries per status",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "newsletters"
+                ],
+                "summary": "Get the deliveries of a newsletter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the newsletter",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Delivery status to filter by (queued, sent, failed, bounced, suppressed)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient email address to filter by",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of items per page for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.DeliveriesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/outbox": {
+            "get": {
+                "description": "Lists the messages written by the outbox email sender, newest first. Only available when emailProvider is outbox",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "List captured messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of items per page for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.OutboxMessagesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/outbox/{id}": {
+            "get": {
+                "description": "Returns the headers, decoded bodies and attachment list of a message written by the outbox email sender",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Get a captured message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the captured message",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.OutboxMessageDetail"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/outbox/{id}/html": {
+            "get": {
+                "description": "Renders the HTML body of a message written by the outbox email sender, sandboxed so that it cannot run scripts",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Preview a captured message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the captured message",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "HTML body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/outbox/{id}/raw": {
+            "get": {
+                "description": "Returns a message written by the outbox email sender exactly as it was stored, headers and attachments included",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Get the source of a captured message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the captured message",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "RFC 5322 message",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscribe/{email}/{category}": {
+            "post": {
+                "description": "Allows a user to subscribe to the newsletter. In categories that require double opt-in the subscriber stays pending until the link in the confirmation email is followed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Subscribe to the newsletter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address to subscribe",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category to subscribe to",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Optional custom attributes of the subscriber",
+                        "name": "subscribeRequest",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/request.SubscribeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "202": {
+                        "description": "Confirmation email sent",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "The address is on the suppression list",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "User is already subscribed",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscribers": {
+            "get": {
+                "description": "Retrieves a list of subscribers with optional search and pagination parameters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Get a list of subscribers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address of the subscriber to search for",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category of the subscriber to search for",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of items per page for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscribers/{email}/{category}": {
+            "get": {
+                "description": "Get details of a subscriber by email address",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Get subscriber by email and category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address to get details",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category to subscribe to",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Subscriber"
+                        }
+                    },
+                    "404": {
+                        "description": "Subscriber not found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/suppressions": {
+            "get": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Lists the suppression list with optional filters and pagination. Addresses are only kept as hashes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "List suppressed addresses",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address or address hash to look up",
+                        "name": "address",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category to filter by",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reason to filter by (unsubscribed, hard_bounce, complaint, manual)",
+                        "name": "reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number for pagination",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of items per page for pagination",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SuppressionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Adds an address to the suppression list, for one category or for all of them. Suppressed addresses are skipped by every send and cannot subscribe",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Suppress an address",
+                "parameters": [
+                    {
+                        "description": "Address to suppress",
+                        "name": "suppression",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.SuppressionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/suppressions/export": {
+            "get": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Streams the whole suppression list as CSV. Addresses are only exported as hashes",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Export suppressed addresses",
+                "responses": {
+                    "200": {
+                        "description": "CSV with the address_hash, category, reason, source, detail, created_at and updated_at columns",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/suppressions/import": {
+            "post": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Adds many addresses to the suppression list at once, as a JSON array or as CSV with a header row naming the email or address_hash, category, reason and detail columns. An export can be imported as it is. An invalid entry rejects the whole import",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Import suppressed addresses",
+                "parameters": [
+                    {
+                        "description": "Addresses to suppress",
+                        "name": "suppressions",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/request.SuppressionRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.SuppressionImportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/suppressions/{address}": {
+            "delete": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Removes an address from the suppression list in one category, or in every category when no category is given",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suppressions"
+                ],
+                "summary": "Remove a suppressed address",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address or address hash",
+                        "name": "address",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category to remove the suppression from",
+                        "name": "category",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/unsubscribe/one-click/{token}": {
+            "post": {
+                "description": "RFC 8058 one-click endpoint named in the List-Unsubscribe header of every newsletter, which mail providers call with a \"List-Unsubscribe=One-Click\" form body and no cookies; the body is not needed and is ignored. It only accepts POST, so that link scanners following the header do not unsubscribe.",
+                "consumes": [
+                    "application/x-www-form-urlencoded",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "One-click unsubscribe",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed unsubscribe token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid unsubscribe link",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Unsubscribe link has expired",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/unsubscribe/{email}/{category}": {
+            "delete": {
+                "security": [
+                    {
+                        "AdminKey": []
+                    }
+                ],
+                "description": "Allows an admin to unsubscribe an address from the newsletter. Subscribers unsubscribe with their signed link",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Unsubscribe from the newsletter",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email address to unsubscribe",
+                        "name": "email",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category to subscribe to",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/unsubscribe/{token}": {
+            "get": {
+                "description": "Unsubscribes the subscriber named by a signed unsubscribe token, as found in the unsubscribe links of the body of every newsletter. The List-Unsubscribe header names the one-click endpoint instead.",
+                "consumes": [
+                    "application/json",
+                    "application/x-www-form-urlencoded",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Unsubscribe with a signed link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed unsubscribe token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid unsubscribe link",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Unsubscribe link has expired",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Unsubscribes the subscriber named by a signed unsubscribe token, as found in the unsubscribe links of the body of every newsletter. The List-Unsubscribe header names the one-click endpoint instead.",
+                "consumes": [
+                    "application/json",
+                    "application/x-www-form-urlencoded",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscribers"
+                ],
+                "summary": "Unsubscribe with a signed link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Signed unsubscribe token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid unsubscribe link",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Unsubscribe link has expired",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{provider}": {
+            "post": {
+                "description": "Receives the event webhook of MailerSend, SendGrid, Amazon SES (through an SNS subscription) or Postmark, after checking its signature. Deliveries and opens are recorded on the delivery, bounces and complaints also update the suppression list. SNS subscription confirmations are confirmed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Receive the events of an email provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider (mailersend, sendgrid, ses, postmark)",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payload, as sent by the provider",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/response.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/service.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
         "domain.Attachment": {
             "type": "object",
             "properties": {
+                "checksum": {
+                    "type": "string"
+                },
+                "content_id": {
+                    "type": "string"
+                },
                 "data": {
+                    "description": "Data is the base64 content of attachments sent inline in the request\nor saved before attachments were kept in GridFS.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scan": {
+                    "description": "Scan is the result of scanning the content for malware when the\nnewsletter was last saved. It is nil for attachments never scanned.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.AttachmentScan"
+                        }
+                    ]
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.AttachmentScan": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "scanned_at": {
+                    "type": "string"
+                },
+                "scanner": {
+                    "type": "string"
+                },
+                "signature": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.AttachmentScanStatus"
+                }
+            }
+        },
+        "domain.AttachmentScanStatus": {
+            "type": "string",
+            "enum": [
+                "clean",
+                "infected",
+                "skipped",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "AttachmentScanClean",
+                "AttachmentScanInfected",
+                "AttachmentScanSkipped",
+                "AttachmentScanFailed"
+            ]
+        },
+        "domain.Bounce": {
+            "type": "object",
+            "properties": {
+                "diagnostic": {
+                    "type": "string"
+                },
+                "recipient": {
                     "type": "string"
                 },
-                "name": {
-                    "type": "string"
+                "reported_at": {
+                    "type": "string"
+                },
+                "reported_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/domain.BounceType"
+                }
+            }
+        },
+        "domain.BounceType": {
+            "type": "string",
+            "enum": [
+                "hard",
+                "soft"
+            ],
+            "x-enum-varnames": [
+                "BounceHard",
+                "BounceSoft"
+            ]
+        },
+        "domain.Complaint": {
+            "type": "object",
+            "properties": {
+                "feedback_type": {
+                    "type": "string"
+                },
+                "reported_at": {
+                    "type": "string"
+                },
+                "reported_by": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "bounce": {
+                    "$ref": "#/definitions/domain.Bounce"
+                },
+                "complaint": {
+                    "$ref": "#/definitions/domain.Complaint"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "last_open": {
+                    "$ref": "#/definitions/domain.Open"
+                },
+                "newsletter_id": {
+                    "type": "string"
+                },
+                "opened_at": {
+                    "type": "string"
+                },
+                "opens": {
+                    "type": "integer"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "soft_bounces": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.DeliveryStatus"
+                },
+                "subscriber_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.DeliveryStatus": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "sent",
+                "failed",
+                "bounced",
+                "suppressed"
+            ],
+            "x-enum-varnames": [
+                "DeliveryQueued",
+                "DeliverySent",
+                "DeliveryFailed",
+                "DeliveryBounced",
+                "DeliverySuppressed"
+            ]
+        },
+        "domain.DeliverySummary": {
+            "type": "object",
+            "properties": {
+                "bounced": {
+                    "type": "integer"
+                },
+                "complaint_rate": {
+                    "type": "number"
+                },
+                "complaints": {
+                    "description": "Complaints counts the deliveries the recipient marked as spam, and\nComplaintRate is their share of the sent deliveries.",
+                    "type": "integer"
                 },
-                "type": {
-                    "type": "string"
+                "failed": {
+                    "type": "integer"
+                },
+                "open_rate": {
+                    "type": "number"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "soft_bounces": {
+                    "description": "SoftBounces counts the temporary failures reported for the\nnewsletter's deliveries, which do not change their status.",
+                    "type": "integer"
+                },
+                "suppressed": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "total_opens": {
+                    "type": "integer"
+                },
+                "unique_opens": {
+                    "description": "UniqueOpens counts the deliveries opened at least once and TotalOpens\nevery open, and OpenRate is the share of the sent deliveries that were\nopened. Prefetches by Apple Mail Privacy Protection are included.",
+                    "type": "integer"
                 }
             }
         },
@@ -491,32 +1822,293 @@ const docTemplate = `{
                 },
                 "subject": {
                     "type": "string"
+                },
+                "text_content": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.Open": {
+            "type": "object",
+            "properties": {
+                "apple_mpp": {
+                    "type": "boolean"
+                },
+                "opened_at": {
+                    "type": "string"
+                },
+                "reported_by": {
+                    "type": "string"
+                },
+                "user_agent_class": {
+                    "$ref": "#/definitions/domain.UserAgentClass"
+                }
+            }
+        },
+        "domain.OutboxAttachment": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "domain.OutboxMessage": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "domain.OutboxMessageDetail": {
+            "type": "object",
+            "properties": {
+                "attachments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.OutboxAttachment"
+                    }
+                },
+                "date": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "html_body": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "subject": {
+                    "type": "string"
+                },
+                "text_body": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "domain.SendJob": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_subscriber_id": {
+                    "type": "string"
+                },
+                "newsletter_id": {
+                    "type": "string"
+                },
+                "resume_at": {
+                    "type": "string"
+                },
+                "sent": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/domain.SendJobStatus"
+                },
+                "suppressed": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
+        "domain.SendJobStatus": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "paused",
+                "completed",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "SendJobQueued",
+                "SendJobRunning",
+                "SendJobPaused",
+                "SendJobCompleted",
+                "SendJobFailed"
+            ]
+        },
         "domain.Subscriber": {
             "type": "object",
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
                 "category": {
                     "type": "string"
                 },
+                "confirmed_at": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
+                "expires_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "status": {
+                    "$ref": "#/definitions/domain.SubscriberStatus"
+                },
                 "subscription_date": {
                     "type": "string"
                 }
             }
         },
+        "domain.SubscriberStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "active"
+            ],
+            "x-enum-varnames": [
+                "SubscriberPending",
+                "SubscriberActive"
+            ]
+        },
+        "domain.Suppression": {
+            "type": "object",
+            "properties": {
+                "address_hash": {
+                    "type": "string"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "$ref": "#/definitions/domain.SuppressionReason"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.SuppressionReason": {
+            "type": "string",
+            "enum": [
+                "unsubscribed",
+                "hard_bounce",
+                "complaint",
+                "manual"
+            ],
+            "x-enum-varnames": [
+                "SuppressionUnsubscribed",
+                "SuppressionHardBounce",
+                "SuppressionComplaint",
+                "SuppressionManual"
+            ]
+        },
+        "domain.UserAgentClass": {
+            "type": "string",
+            "enum": [
+                "desktop",
+                "mobile",
+                "proxy",
+                "bot",
+                "unknown"
+            ],
+            "x-enum-varnames": [
+                "UserAgentDesktop",
+                "UserAgentMobile",
+                "UserAgentProxy",
+                "UserAgentBot",
+                "UserAgentUnknown"
+            ]
+        },
         "request.Attachment": {
             "type": "object",
             "properties": {
+                "content_id": {
+                    "type": "string"
+                },
                 "data": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -525,6 +2117,37 @@ const docTemplate = `{
                 }
             }
         },
+        "request.SubscribeRequest": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "request.SuppressionRequest": {
+            "type": "object",
+            "properties": {
+                "address_hash": {
+                    "type": "string"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "reason": {
+                    "$ref": "#/definitions/domain.SuppressionReason"
+                }
+            }
+        },
         "request.UpdateNewsletterRequest": {
             "type": "object",
             "properties": {
@@ -548,6 +2171,146 @@ const docTemplate = `{
                 },
                 "subject": {
                     "type": "string"
+                },
+                "text_content": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.AttachmentErrorResponse": {
+            "type": "object",
+            "properties": {
+                "attachment": {
+                    "type": "string"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "detected_type": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.ComplaintResponse": {
+            "type": "object",
+            "properties": {
+                "feedback_type": {
+                    "type": "string"
+                },
+                "newsletter_id": {
+                    "description": "NewsletterID is the newsletter the complaint was matched to, if any.",
+                    "type": "string"
+                },
+                "suppressed": {
+                    "description": "Suppressed counts the addresses unsubscribed and suppressed, none for\nreports that are not complaints.",
+                    "type": "integer"
+                }
+            }
+        },
+        "response.DeliveriesResponse": {
+            "type": "object",
+            "properties": {
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Delivery"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "summary": {
+                    "$ref": "#/definitions/domain.DeliverySummary"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.OutboxMessagesResponse": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.OutboxMessage"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.SendNewsletterResponse": {
+            "type": "object",
+            "properties": {
+                "jobId": {
+                    "description": "JobID is the send job that delivers the newsletter.",
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "response.SuppressionImportResponse": {
+            "type": "object",
+            "properties": {
+                "imported": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.SuppressionsResponse": {
+            "type": "object",
+            "properties": {
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "suppressions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Suppression"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "response.WebhookResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "description": "Events counts the events of the payload that were applied.",
+                    "type": "integer"
                 }
             }
         },
@@ -559,6 +2322,14 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "AdminKey": {
+            "description": "\"Bearer \" followed by adminApiKey",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
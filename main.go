@@ -22,6 +22,10 @@ import (
 // @host localhost:8080
 // @BasePath /api/v1
 // @schemes http
+// @securityDefinitions.apikey AdminKey
+// @in header
+// @name Authorization
+// @description "Bearer " followed by adminApiKey
 func main() {
 
 	err := godotenv.Load()
@@ -41,13 +45,17 @@ func main() {
 	}
 	defer mongodb.Disconnect()
 
-	router := v1.SetupRouter()
+	router, err := v1.SetupRouter()
+	if err != nil {
+		fmt.Println("Error en la configuración:", err)
+		return
+	}
 
 	router.PathPrefix("/docs/").Handler(httpSwagger.WrapHandler)
 
 	allowedOrigins := handlers.AllowedOrigins([]string{"*"})
 	allowedMethods := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
-	allowedHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Type", "Content-Language", "Origin"})
+	allowedHeaders := handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Type", "Content-Language", "Origin", "Authorization"})
 	router.Use(handlers.CORS(allowedOrigins, allowedMethods, allowedHeaders))
 
 	router.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"newsletter-app/pkg/service"
+	"strings"
+)
+
+// RequireAdminKey lets through to next only the requests that carry adminKey
+// as a bearer token in their Authorization header. Every request is refused
+// when adminKey is empty, so an unconfigured key never leaves a route open.
+func RequireAdminKey(adminKey string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if adminKey == "" || !found || subtle.ConstantTimeCompare([]byte(presented), []byte(adminKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			service.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
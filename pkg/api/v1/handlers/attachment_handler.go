@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Upload an attachment
+// @Description Stores a file in GridFS so that newsletters can attach it by ID. The file is streamed to storage without being held in memory, and is rejected if it breaks the attachment size or type limits
+// @Tags attachments
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "File to attach"
+// @Success 201 {object} domain.Attachment
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 413 {object} response.AttachmentErrorResponse "Attachment too large"
+// @Failure 415 {object} response.AttachmentErrorResponse "Attachment type not allowed"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /attachments [post]
+func UploadAttachmentHandler(attachmentService ports.AttachmentServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			service.RespondWithError(w, http.StatusBadRequest, "Expected a multipart/form-data request")
+			return
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				service.RespondWithError(w, http.StatusBadRequest, "Missing file field")
+				return
+			}
+			if err != nil {
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid multipart body")
+				return
+			}
+			if part.FormName() != "file" {
+				continue
+			}
+
+			attachment, err := attachmentService.UploadAttachment(part.FileName(), part.Header.Get("Content-Type"), part)
+			if err != nil {
+				fmt.Printf("Error uploading attachment: %s\n", err.Error())
+				if respondWithAttachmentError(w, err) {
+					return
+				}
+				if errors.Is(err, service.ErrAttachmentNameRequired) {
+					service.RespondWithError(w, http.StatusBadRequest, "The file field must have a file name")
+					return
+				}
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to store attachment")
+				return
+			}
+
+			service.RespondWithJSON(w, http.StatusCreated, attachment)
+			return
+		}
+	}
+}
+
+// @Summary Download an attachment
+// @Description Streams the content of an uploaded attachment
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path string true "ID of the attachment"
+// @Success 200 {file} file "Attachment content"
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /attachments/{id} [get]
+func DownloadAttachmentHandler(attachmentService ports.AttachmentServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, content, err := attachmentService.OpenAttachment(mux.Vars(r)["id"])
+		if err != nil {
+			if errors.Is(err, service.ErrAttachmentNotFound) {
+				service.RespondWithError(w, http.StatusNotFound, "Attachment not found")
+				return
+			}
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to read attachment")
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", attachment.Type)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(w, content); err != nil {
+			fmt.Printf("Error streaming attachment %s: %s\n", attachment.ID, err.Error())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+)
+
+// maxComplaintSize bounds an abuse report, which may quote the whole
+// reported message.
+const maxComplaintSize = 10 << 20
+
+// @Summary Ingest a spam complaint
+// @Description Takes an abuse report in Abuse Reporting Format (RFC 5965), as sent by the feedback loop of a mailbox provider, as the raw message. A complaint is recorded on the delivery it is about, named by its VERP address or Message-ID, and the address of the delivery is unsubscribed from every category and suppressed. Reports that match no delivery, and those that are not complaints, such as not-spam, are accepted and ignored
+// @Tags complaints
+// @Accept message/rfc822
+// @Produce json
+// @Param report body string true "Abuse report"
+// @Success 200 {object} response.ComplaintResponse
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /complaints [post]
+func IngestComplaintHandler(complaintService ports.ComplaintServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxComplaintSize))
+		if err != nil {
+			service.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		result, err := complaintService.IngestComplaint(raw)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidComplaint) {
+				service.RespondWithError(w, http.StatusBadRequest, "The request body is not an abuse report")
+				return
+			}
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to process complaint")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary Get the deliveries of a newsletter
+// @Description Retrieves the per-recipient delivery log of a newsletter with optional filters and pagination, plus a summary of deliveries per status
+// @Tags newsletters
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the newsletter"
+// @Param status query string false "Delivery status to filter by (queued, sent, failed, bounced, suppressed)"
+// @Param email query string false "Recipient email address to filter by"
+// @Param page query int false "Page number for pagination"
+// @Param pageSize query int false "Number of items per page for pagination"
+// @Success 200 {object} response.DeliveriesResponse
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /newsletters/{id}/deliveries [get]
+func GetDeliveriesHandler(deliveryService ports.DeliveryServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := domain.DeliveryFilter{
+			NewsletterID: mux.Vars(r)["id"],
+			Status:       domain.DeliveryStatus(r.URL.Query().Get("status")),
+			Email:        r.URL.Query().Get("email"),
+		}
+		if filter.Status != "" && !filter.Status.IsValid() {
+			service.RespondWithError(w, http.StatusBadRequest, "Invalid delivery status")
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+
+		deliveries, err := deliveryService.GetDeliveries(filter, page, pageSize)
+		if err != nil {
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve deliveries")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, deliveries)
+	}
+}
@@ -2,29 +2,34 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/domain/ports"
-	"newsletter-app/pkg/infrastructure/adapters/email"
 	"newsletter-app/pkg/service"
 	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/Dtos/response"
+	"newsletter-app/pkg/service/render"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // @Summary Send newsletter to subscribers
-// @Description Allows an admin user to send a newsletter to a list of subscribers
+// @Description Allows an admin user to queue a newsletter for sending to its subscribers. The newsletter is delivered in the background; use the returned job ID to follow its progress. Newsletters with an attachment that is infected or was not scanned are refused.
 // @Tags newsletters
 // @Accept json
 // @Produce json
 // @Param newsletterID path string true "ID of the newsletter to be sent"
-// @Success 200 {string} string "OK"
+// @Success 202 {object} response.SendNewsletterResponse "Accepted"
 // @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 409 {object} service.ErrorResponse "An attachment is infected or was not scanned"
 // @Failure 500 {object} service.ErrorResponse "Internal Server Error"
 // @Router /newsletters/send/{newsletterID} [post]
-func SendNewsletterHandler(subscriberService ports.SubscriberServicePort, newsletterService ports.NewsletterServicePort, emailSender email.EmailSender) http.HandlerFunc {
+func SendNewsletterHandler(newsletterService ports.NewsletterServicePort) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		newsletterID := mux.Vars(r)["newsletterID"]
 		if newsletterID == "" {
@@ -32,20 +37,67 @@ func SendNewsletterHandler(subscriberService ports.SubscriberServicePort, newsle
 			return
 		}
 
-		err := newsletterService.SendNewsletter(w, r, newsletterID, emailSender)
+		job, err := newsletterService.SendNewsletter(newsletterID)
 		if err != nil {
 			fmt.Printf("Error sending newsletter: %s\n", err.Error())
-			service.RespondWithError(w, http.StatusInternalServerError, "Failed to send newsletter")
+			var templateErr *render.TemplateError
+			switch {
+			case errors.As(err, &templateErr):
+				service.RespondWithError(w, http.StatusBadRequest, templateErr.Error())
+			case errors.Is(err, service.ErrNewsletterContentEmpty):
+				service.RespondWithError(w, http.StatusBadRequest, "Newsletter content is empty")
+			case errors.Is(err, service.ErrNoSubscribers):
+				service.RespondWithError(w, http.StatusBadRequest, "No subscribers to send the newsletter to")
+			case errors.Is(err, service.ErrInvalidAttachment):
+				service.RespondWithError(w, http.StatusBadRequest, "Failed to decode attachments")
+			case errors.Is(err, service.ErrAttachmentNotFound):
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, service.ErrAttachmentInfected), errors.Is(err, service.ErrAttachmentNotScanned):
+				service.RespondWithError(w, http.StatusConflict, err.Error())
+			default:
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to send newsletter")
+			}
 			return
 		}
 
-		service.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-			"status":  "OK",
-			"message": "Newsletter sent successfully",
+		w.Header().Set("Location", "/api/v1/newsletters/jobs/"+job.ID.Hex())
+		service.RespondWithJSON(w, http.StatusAccepted, response.SendNewsletterResponse{
+			Status:  "Accepted",
+			Message: "Newsletter queued for sending",
+			JobID:   job.ID.Hex(),
 		})
 	}
 }
 
+// @Summary Get a send job
+// @Description Retrieves the status and progress of a newsletter send job
+// @Tags newsletters
+// @Accept json
+// @Produce json
+// @Param jobID path string true "ID of the send job"
+// @Success 200 {object} domain.SendJob
+// @Failure 404 {object} service.ErrorResponse "Send job not found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /newsletters/jobs/{jobID} [get]
+func GetSendJobHandler(newsletterService ports.NewsletterServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobID := mux.Vars(r)["jobID"]
+
+		job, err := newsletterService.GetSendJob(jobID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments || err == primitive.ErrInvalidHex {
+				service.RespondWithError(w, http.StatusNotFound, "Send job not found")
+				return
+			}
+
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to get send job")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, job)
+	}
+}
+
 // @Summary Create a new newsletter
 // @Description Allows an admin user to create a new newsletter
 // @Tags newsletters
@@ -54,6 +106,8 @@ func SendNewsletterHandler(subscriberService ports.SubscriberServicePort, newsle
 // @Param newsletter body domain.Newsletter true "Newsletter details"
 // @Success 201 {string} string "Created"
 // @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 413 {object} response.AttachmentErrorResponse "Attachment too large"
+// @Failure 415 {object} response.AttachmentErrorResponse "Attachment type not allowed"
 // @Failure 500 {object} service.ErrorResponse "Internal Server Error"
 // @Router /newsletters [post]
 func CreateNewsletterHandler(newsletterService ports.NewsletterServicePort) http.HandlerFunc {
@@ -83,6 +137,19 @@ func CreateNewsletterHandler(newsletterService ports.NewsletterServicePort) http
 
 		err = newsletterService.SaveNewsletter(newNewsletter)
 		if err != nil {
+			var templateErr *render.TemplateError
+			if errors.As(err, &templateErr) {
+				service.RespondWithError(w, http.StatusBadRequest, templateErr.Error())
+				return
+			}
+			if respondWithAttachmentError(w, err) {
+				return
+			}
+			if isAttachmentError(err) {
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
 			service.RespondWithError(w, http.StatusInternalServerError, "Failed to create newsletter")
 			return
 		}
@@ -129,6 +196,8 @@ func GetNewslettersHandler(newsletterService ports.NewsletterServicePort) http.H
 // @Param updateRequest body request.UpdateNewsletterRequest true "Update newsletter details"
 // @Success 200 {string} string "OK"
 // @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 413 {object} response.AttachmentErrorResponse "Attachment too large"
+// @Failure 415 {object} response.AttachmentErrorResponse "Attachment type not allowed"
 // @Failure 500 {object} service.ErrorResponse "Internal Server Error"
 // @Router /newsletters [put]
 func UpdateNewsletterHandler(newsletterService ports.NewsletterServicePort) http.HandlerFunc {
@@ -142,6 +211,19 @@ func UpdateNewsletterHandler(newsletterService ports.NewsletterServicePort) http
 
 		err = newsletterService.UpdateNewsletter(updateRequest)
 		if err != nil {
+			var templateErr *render.TemplateError
+			if errors.As(err, &templateErr) {
+				service.RespondWithError(w, http.StatusBadRequest, templateErr.Error())
+				return
+			}
+			if respondWithAttachmentError(w, err) {
+				return
+			}
+			if isAttachmentError(err) {
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
 			service.RespondWithError(w, http.StatusInternalServerError, "Failed to update newsletter")
 			return
 		}
@@ -183,3 +265,39 @@ func DeleteNewsletterHandler(newsletterService ports.NewsletterServicePort) http
 		})
 	}
 }
+
+// isAttachmentError reports whether err is a problem with the attachments of
+// a newsletter in the request.
+func isAttachmentError(err error) bool {
+	return errors.Is(err, service.ErrInvalidAttachment) ||
+		errors.Is(err, service.ErrAttachmentNotFound) ||
+		errors.Is(err, service.ErrAttachmentNameRequired) ||
+		errors.Is(err, service.ErrInvalidContentID) ||
+		errors.Is(err, service.ErrDuplicateContentID) ||
+		errors.Is(err, service.ErrUnknownContentID)
+}
+
+// respondWithAttachmentError responds to an attachment rejected by the
+// attachment policy: 413 when it is too large and 415 when its type is not
+// allowed. It reports whether err was such a rejection.
+func respondWithAttachmentError(w http.ResponseWriter, err error) bool {
+	var attachmentErr *service.AttachmentError
+	if !errors.As(err, &attachmentErr) {
+		return false
+	}
+
+	status := http.StatusUnsupportedMediaType
+	if attachmentErr.Code == service.AttachmentTooLarge || attachmentErr.Code == service.MessageTooLarge {
+		status = http.StatusRequestEntityTooLarge
+	}
+	service.RespondWithJSON(w, status, response.AttachmentErrorResponse{
+		Error:        attachmentErr.Error(),
+		Code:         attachmentErr.Code,
+		Attachment:   attachmentErr.Attachment,
+		Type:         attachmentErr.Type,
+		DetectedType: attachmentErr.DetectedType,
+		Size:         attachmentErr.Size,
+		Limit:        attachmentErr.Limit,
+	})
+	return true
+}
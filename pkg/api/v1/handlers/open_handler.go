@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+
+	"github.com/gorilla/mux"
+)
+
+// transparentGIF is a transparent 1x1 GIF.
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x01, 0x44, 0x00, 0x3b,
+}
+
+// OpenPixelHandler serves the tracking pixel of GET /t/o/{token}.gif and
+// records the open. It is outside /api/v1, so it is not in the Swagger
+// documentation. The pixel is served whatever happens, so that a mail client
+// never shows a broken image, and must not be cached, so that every open
+// reaches the API.
+func OpenPixelHandler(openService ports.OpenServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remoteIP = r.RemoteAddr
+		}
+
+		err = openService.RecordPixelOpen(mux.Vars(r)["token"], r.UserAgent(), remoteIP)
+		if err != nil && !errors.Is(err, service.ErrInvalidOpenToken) {
+			fmt.Printf("Error recording open: %s\n", err.Error())
+		}
+
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+		w.Header().Set("Pragma", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(transparentGIF)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// outboxPreviewPolicy keeps previewed newsletters from running scripts or
+// loading anything but images and inline styles.
+const outboxPreviewPolicy = "sandbox; default-src 'none'; img-src * data:; style-src 'unsafe-inline'"
+
+// @Summary List captured messages
+// @Description Lists the messages written by the outbox email sender, newest first. Only available when emailProvider is outbox
+// @Tags outbox
+// @Produce json
+// @Param page query int false "Page number for pagination"
+// @Param pageSize query int false "Number of items per page for pagination"
+// @Success 200 {object} response.OutboxMessagesResponse
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /outbox [get]
+func GetOutboxMessagesHandler(outboxService ports.OutboxServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+
+		messages, err := outboxService.GetOutboxMessages(page, pageSize)
+		if err != nil {
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve outbox messages")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, messages)
+	}
+}
+
+// @Summary Get a captured message
+// @Description Returns the headers, decoded bodies and attachment list of a message written by the outbox email sender
+// @Tags outbox
+// @Produce json
+// @Param id path string true "ID of the captured message"
+// @Success 200 {object} domain.OutboxMessageDetail
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /outbox/{id} [get]
+func GetOutboxMessageHandler(outboxService ports.OutboxServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, err := outboxService.GetOutboxMessage(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithOutboxError(w, err)
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, message)
+	}
+}
+
+// @Summary Preview a captured message
+// @Description Renders the HTML body of a message written by the outbox email sender, sandboxed so that it cannot run scripts
+// @Tags outbox
+// @Produce html
+// @Param id path string true "ID of the captured message"
+// @Success 200 {string} string "HTML body"
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /outbox/{id}/html [get]
+func PreviewOutboxMessageHandler(outboxService ports.OutboxServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		message, err := outboxService.GetOutboxMessage(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithOutboxError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", outboxPreviewPolicy)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(message.HTMLBody))
+	}
+}
+
+// @Summary Get the source of a captured message
+// @Description Returns a message written by the outbox email sender exactly as it was stored, headers and attachments included
+// @Tags outbox
+// @Produce plain
+// @Param id path string true "ID of the captured message"
+// @Success 200 {string} string "RFC 5322 message"
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /outbox/{id}/raw [get]
+func GetRawOutboxMessageHandler(outboxService ports.OutboxServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw, err := outboxService.GetRawOutboxMessage(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithOutboxError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(raw)
+	}
+}
+
+func respondWithOutboxError(w http.ResponseWriter, err error) {
+	if err == service.ErrOutboxMessageNotFound {
+		service.RespondWithError(w, http.StatusNotFound, "Outbox message not found")
+		return
+	}
+	service.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve outbox message")
+}
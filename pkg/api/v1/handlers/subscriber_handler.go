@@ -1,24 +1,33 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/domain/ports"
 	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/request"
 	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
 // @Summary Subscribe to the newsletter
-// @Description Allows a user to subscribe to the newsletter
+// @Description Allows a user to subscribe to the newsletter. In categories that require double opt-in the subscriber stays pending until the link in the confirmation email is followed
 // @Tags subscribers
 // @Accept json
 // @Produce json
 // @Param email path string true "Email address to subscribe"
 // @Param category path string true "Category to subscribe to"
+// @Param subscribeRequest body request.SubscribeRequest false "Optional custom attributes of the subscriber"
 // @Success 200 {string} string "OK"
+// @Success 202 {string} string "Confirmation email sent"
 // @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 403 {object} service.ErrorResponse "The address is on the suppression list"
+// @Failure 409 {object} service.ErrorResponse "User is already subscribed"
 // @Failure 500 {object} service.ErrorResponse "Internal Server Error"
 // @Router /subscribe/{email}/{category} [post]
 func SubscribeHandler(subscriberService ports.SubscriberServicePort) http.HandlerFunc {
@@ -30,15 +39,27 @@ func SubscribeHandler(subscriberService ports.SubscriberServicePort) http.Handle
 			return
 		}
 
+		var subscribeRequest request.SubscribeRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&subscribeRequest); err != nil && err != io.EOF {
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+				return
+			}
+		}
+
 		existingSubscriber, err := subscriberService.GetSubscriberByEmail(email, category)
-		if err == nil && existingSubscriber != nil {
+		if err == nil && existingSubscriber != nil && existingSubscriber.Status != domain.SubscriberPending {
 			fmt.Println("Email is invalid or missing:", email)
 			service.RespondWithError(w, http.StatusConflict, "User is already subscribed")
 			return
 		}
 
-		err = subscriberService.Subscribe(email, category)
+		err = subscriberService.Subscribe(email, category, subscribeRequest.Attributes)
 		if err != nil {
+			if errors.Is(err, service.ErrSubscriberSuppressed) {
+				service.RespondWithError(w, http.StatusForbidden, "This address cannot be subscribed")
+				return
+			}
 			service.RespondWithError(w, http.StatusInternalServerError, "Failed to subscribe user")
 			return
 		}
@@ -49,6 +70,15 @@ func SubscribeHandler(subscriberService ports.SubscriberServicePort) http.Handle
 			return
 		}
 
+		if subscriber.Status == domain.SubscriberPending {
+			service.RespondWithJSON(w, http.StatusAccepted, map[string]interface{}{
+				"status":     "Pending",
+				"message":    "Confirmation email sent",
+				"subscriber": subscriber,
+			})
+			return
+		}
+
 		service.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
 			"status":     "OK",
 			"message":    "Subscription successful",
@@ -57,8 +87,44 @@ func SubscribeHandler(subscriberService ports.SubscriberServicePort) http.Handle
 	}
 }
 
+// @Summary Confirm a subscription
+// @Description Confirms the pending subscription named by a signed confirmation token, as sent in the confirmation email
+// @Tags subscribers
+// @Accept json
+// @Produce json
+// @Param token path string true "Signed confirmation token"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} service.ErrorResponse "Invalid confirmation link"
+// @Failure 410 {object} service.ErrorResponse "Confirmation link has expired"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /confirm/{token} [get]
+// @Router /confirm/{token} [post]
+func ConfirmSubscriptionHandler(subscriberService ports.SubscriberServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		confirmationToken := mux.Vars(r)["token"]
+
+		err := subscriberService.ConfirmSubscription(confirmationToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidConfirmationToken):
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid confirmation link")
+			case errors.Is(err, service.ErrExpiredConfirmationToken):
+				service.RespondWithError(w, http.StatusGone, "Confirmation link has expired")
+			default:
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to confirm subscription")
+			}
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "OK",
+			"message": "Subscription confirmed",
+		})
+	}
+}
+
 // @Summary Unsubscribe from the newsletter
-// @Description Allows a user to unsubscribe from the newsletter
+// @Description Allows an admin to unsubscribe an address from the newsletter. Subscribers unsubscribe with their signed link
 // @Tags subscribers
 // @Accept json
 // @Produce json
@@ -66,7 +132,9 @@ func SubscribeHandler(subscriberService ports.SubscriberServicePort) http.Handle
 // @Param category path string true "Category to subscribe to"
 // @Success 200 {string} string "OK"
 // @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
 // @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
 // @Router /unsubscribe/{email}/{category} [delete]
 func UnsubscribeHandler(subscriberService ports.SubscriberServicePort) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -90,6 +158,57 @@ func UnsubscribeHandler(subscriberService ports.SubscriberServicePort) http.Hand
 	}
 }
 
+// @Summary Unsubscribe with a signed link
+// @Description Unsubscribes the subscriber named by a signed unsubscribe token, as found in the unsubscribe links of the body of every newsletter. The List-Unsubscribe header names the one-click endpoint instead.
+// @Tags subscribers
+// @Accept json,x-www-form-urlencoded,mpfd
+// @Produce json
+// @Param token path string true "Signed unsubscribe token"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} service.ErrorResponse "Invalid unsubscribe link"
+// @Failure 410 {object} service.ErrorResponse "Unsubscribe link has expired"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /unsubscribe/{token} [get]
+// @Router /unsubscribe/{token} [post]
+func UnsubscribeByTokenHandler(subscriberService ports.SubscriberServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		unsubscribeToken := mux.Vars(r)["token"]
+
+		err := subscriberService.UnsubscribeByToken(unsubscribeToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidUnsubscribeToken):
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid unsubscribe link")
+			case errors.Is(err, service.ErrExpiredUnsubscribeToken):
+				service.RespondWithError(w, http.StatusGone, "Unsubscribe link has expired")
+			default:
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to unsubscribe user")
+			}
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "OK",
+			"message": "User unsubscribed successfully",
+		})
+	}
+}
+
+// @Summary One-click unsubscribe
+// @Description RFC 8058 one-click endpoint named in the List-Unsubscribe header of every newsletter, which mail providers call with a "List-Unsubscribe=One-Click" form body and no cookies; the body is not needed and is ignored. It only accepts POST, so that link scanners following the header do not unsubscribe.
+// @Tags subscribers
+// @Accept x-www-form-urlencoded,mpfd
+// @Produce json
+// @Param token path string true "Signed unsubscribe token"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} service.ErrorResponse "Invalid unsubscribe link"
+// @Failure 410 {object} service.ErrorResponse "Unsubscribe link has expired"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /unsubscribe/one-click/{token} [post]
+func OneClickUnsubscribeHandler(subscriberService ports.SubscriberServicePort) http.HandlerFunc {
+	return UnsubscribeByTokenHandler(subscriberService)
+}
+
 // @Summary Get subscriber by email and category
 // @Description Get details of a subscriber by email address
 // @Tags subscribers
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/request"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxSuppressionImportSize bounds the body of a suppression import.
+const maxSuppressionImportSize = 32 << 20
+
+// suppressionCSVHeader are the columns of a suppression export, which can be
+// imported again as they are.
+var suppressionCSVHeader = []string{"address_hash", "category", "reason", "source", "detail", "created_at", "updated_at"}
+
+// @Summary List suppressed addresses
+// @Description Lists the suppression list with optional filters and pagination. Addresses are only kept as hashes
+// @Tags suppressions
+// @Produce json
+// @Param address query string false "Email address or address hash to look up"
+// @Param category query string false "Category to filter by"
+// @Param reason query string false "Reason to filter by (unsubscribed, hard_bounce, complaint, manual)"
+// @Param page query int false "Page number for pagination"
+// @Param pageSize query int false "Number of items per page for pagination"
+// @Success 200 {object} response.SuppressionsResponse
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /suppressions [get]
+func GetSuppressionsHandler(suppressionService ports.SuppressionServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		reason := domain.SuppressionReason(query.Get("reason"))
+		if reason != "" && !reason.IsValid() {
+			service.RespondWithError(w, http.StatusBadRequest, "Invalid suppression reason")
+			return
+		}
+
+		page, _ := strconv.Atoi(query.Get("page"))
+		pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+
+		suppressions, err := suppressionService.GetSuppressions(query.Get("address"), query.Get("category"), reason, page, pageSize)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidSuppression) {
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid email address or address hash")
+				return
+			}
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve suppressions")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, suppressions)
+	}
+}
+
+// @Summary Suppress an address
+// @Description Adds an address to the suppression list, for one category or for all of them. Suppressed addresses are skipped by every send and cannot subscribe
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param suppression body request.SuppressionRequest true "Address to suppress"
+// @Success 201 {string} string "Created"
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /suppressions [post]
+func AddSuppressionHandler(suppressionService ports.SuppressionServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var suppressionRequest request.SuppressionRequest
+		if err := json.NewDecoder(r.Body).Decode(&suppressionRequest); err != nil {
+			service.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		suppression, err := suppressionService.AddSuppression(suppressionRequest)
+		if err != nil {
+			if isSuppressionRequestError(err) {
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to suppress address")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"status":       "OK",
+			"message":      "Address suppressed",
+			"address_hash": suppression.AddressHash,
+		})
+	}
+}
+
+// @Summary Import suppressed addresses
+// @Description Adds many addresses to the suppression list at once, as a JSON array or as CSV with a header row naming the email or address_hash, category, reason and detail columns. An export can be imported as it is. An invalid entry rejects the whole import
+// @Tags suppressions
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param suppressions body []request.SuppressionRequest true "Addresses to suppress"
+// @Success 200 {object} response.SuppressionImportResponse
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /suppressions/import [post]
+func ImportSuppressionsHandler(suppressionService ports.SuppressionServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := http.MaxBytesReader(w, r.Body, maxSuppressionImportSize)
+
+		var suppressionRequests []request.SuppressionRequest
+		var err error
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+			suppressionRequests, err = readSuppressionCSV(body)
+		} else {
+			err = json.NewDecoder(body).Decode(&suppressionRequests)
+		}
+		if err != nil {
+			service.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid import: %s", err.Error()))
+			return
+		}
+
+		imported, err := suppressionService.ImportSuppressions(suppressionRequests)
+		if err != nil {
+			if isSuppressionRequestError(err) {
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			service.RespondWithError(w, http.StatusInternalServerError, "Failed to import suppressions")
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, imported)
+	}
+}
+
+// @Summary Export suppressed addresses
+// @Description Streams the whole suppression list as CSV. Addresses are only exported as hashes
+// @Tags suppressions
+// @Produce text/csv
+// @Success 200 {file} file "CSV with the address_hash, category, reason, source, detail, created_at and updated_at columns"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /suppressions/export [get]
+func ExportSuppressionsHandler(suppressionService ports.SuppressionServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "suppressions.csv"}))
+		w.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(w)
+		writer.Write(suppressionCSVHeader)
+		err := suppressionService.ExportSuppressions(func(suppression domain.Suppression) error {
+			return writer.Write([]string{
+				suppression.AddressHash,
+				suppression.Category,
+				string(suppression.Reason),
+				suppression.Source,
+				suppression.Detail,
+				suppression.CreatedAt.UTC().Format(time.RFC3339),
+				suppression.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		})
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+		if err != nil {
+			fmt.Printf("Error exporting suppressions: %s\n", err.Error())
+		}
+	}
+}
+
+// @Summary Remove a suppressed address
+// @Description Removes an address from the suppression list in one category, or in every category when no category is given
+// @Tags suppressions
+// @Produce json
+// @Param address path string true "Email address or address hash"
+// @Param category query string false "Category to remove the suppression from"
+// @Success 200 {string} string "OK"
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Security AdminKey
+// @Router /suppressions/{address} [delete]
+func DeleteSuppressionsHandler(suppressionService ports.SuppressionServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := suppressionService.DeleteSuppressions(mux.Vars(r)["address"], r.URL.Query().Get("category"))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidSuppression):
+				service.RespondWithError(w, http.StatusBadRequest, "Invalid email address or address hash")
+			case errors.Is(err, service.ErrSuppressionNotFound):
+				service.RespondWithError(w, http.StatusNotFound, "Suppression not found")
+			default:
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to remove suppression")
+			}
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"status":  "OK",
+			"message": "Suppression removed",
+		})
+	}
+}
+
+// readSuppressionCSV reads suppressions from CSV whose header row names the
+// columns. Unknown columns, such as those of an export, are ignored.
+func readSuppressionCSV(body io.Reader) ([]request.SuppressionRequest, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasEmail := columns["email"]
+	_, hasHash := columns["address_hash"]
+	if !hasEmail && !hasHash {
+		return nil, errors.New("the header row must have an email or address_hash column")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var suppressionRequests []request.SuppressionRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return suppressionRequests, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		suppressionRequests = append(suppressionRequests, request.SuppressionRequest{
+			Email:       field(record, "email"),
+			AddressHash: field(record, "address_hash"),
+			Category:    field(record, "category"),
+			Reason:      domain.SuppressionReason(field(record, "reason")),
+			Detail:      field(record, "detail"),
+		})
+	}
+}
+
+// isSuppressionRequestError reports whether err is a problem with the
+// suppressions in the request.
+func isSuppressionRequestError(err error) bool {
+	return errors.Is(err, service.ErrInvalidSuppression) || errors.Is(err, service.ErrInvalidSuppressionReason)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+// maxWebhookSize bounds a webhook payload. SendGrid batches up to a few
+// thousand events in one request.
+const maxWebhookSize = 10 << 20
+
+// @Summary Receive the events of an email provider
+// @Description Receives the event webhook of MailerSend, SendGrid, Amazon SES (through an SNS subscription) or Postmark, after checking its signature. Deliveries and opens are recorded on the delivery, bounces and complaints also update the suppression list. SNS subscription confirmations are confirmed
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider (mailersend, sendgrid, ses, postmark)"
+// @Param payload body string true "Payload, as sent by the provider"
+// @Success 200 {object} response.WebhookResponse
+// @Failure 400 {object} service.ErrorResponse "Bad Request"
+// @Failure 401 {object} service.ErrorResponse "Unauthorized"
+// @Failure 404 {object} service.ErrorResponse "Not Found"
+// @Failure 500 {object} service.ErrorResponse "Internal Server Error"
+// @Router /webhooks/{provider} [post]
+func WebhookHandler(webhookService ports.WebhookServicePort) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+		if err != nil {
+			service.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+
+		result, err := webhookService.HandleWebhook(mux.Vars(r)["provider"], r.Header, body)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrUnknownWebhookProvider):
+				service.RespondWithError(w, http.StatusNotFound, "Unknown webhook provider")
+			case errors.Is(err, webhook.ErrInvalidSignature):
+				service.RespondWithError(w, http.StatusUnauthorized, "Invalid webhook signature")
+			case errors.Is(err, webhook.ErrInvalidPayload):
+				service.RespondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				service.RespondWithError(w, http.StatusInternalServerError, "Failed to process webhook")
+			}
+			return
+		}
+
+		service.RespondWithJSON(w, http.StatusOK, result)
+	}
+}
@@ -1,38 +1,218 @@
 package v1
 
 import (
+	"context"
+	"fmt"
 	"newsletter-app/pkg/api/v1/handlers"
+	"newsletter-app/pkg/config"
 	"newsletter-app/pkg/domain/ports"
 	"newsletter-app/pkg/infrastructure/adapters/email"
+	"newsletter-app/pkg/infrastructure/adapters/mailbox"
 	"newsletter-app/pkg/infrastructure/adapters/mongodb"
+	"newsletter-app/pkg/infrastructure/adapters/scanner"
 	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/token"
+	"os"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRouter() *mux.Router {
+func SetupRouter() (*mux.Router, error) {
 	r := mux.NewRouter()
 
+	tokenSigner, err := token.NewSignerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	publicURLs, err := config.NewPublicURLsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	unsubscribeTokens := service.NewUnsubscribeTokensFromEnv(tokenSigner, publicURLs)
+	subscriptionConfirmations := service.NewSubscriptionConfirmationsFromEnv(tokenSigner, publicURLs)
+
+	// Routes that act on any address are only open to the admin
+	adminKey := os.Getenv("adminApiKey")
+	if adminKey == "" {
+		fmt.Println("Warning: adminApiKey is not set, admin-only routes refuse every request")
+	}
+
 	subscriberRepo := mongodb.NewSubscriberRepository()
+	if err := subscriberRepo.CreateIndexes(); err != nil {
+		fmt.Println("Error creating subscriber indexes:", err)
+	}
 	newsletterRepo := mongodb.NewNewsletterRepository()
+	sendJobRepo := mongodb.NewSendJobRepository()
+	deliveryRepo := mongodb.NewDeliveryRepository()
+	if err := deliveryRepo.CreateIndexes(); err != nil {
+		fmt.Println("Error creating delivery indexes:", err)
+	}
+	attachmentRepo := mongodb.NewAttachmentRepository()
+	suppressionRepo := mongodb.NewSuppressionRepository()
+	if err := suppressionRepo.CreateIndexes(); err != nil {
+		fmt.Println("Error creating suppression indexes:", err)
+	}
+	rateLimitRepo := mongodb.NewRateLimitRepository()
+	if err := rateLimitRepo.CreateIndexes(); err != nil {
+		fmt.Println("Error creating rate limit indexes:", err)
+	}
+	suppressionService, err := service.NewSuppressionServiceFromEnv(suppressionRepo)
+	if err != nil {
+		return nil, err
+	}
+	bounceTracking := service.NewBounceTrackingFromEnv()
+	openTracking, err := service.NewOpenTrackingFromEnv(publicURLs)
+	if err != nil {
+		return nil, err
+	}
+	attachmentPolicy := service.NewAttachmentPolicyFromEnv()
+	attachmentScanner, err := scanner.NewAttachmentScannerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Retries go through the rate limiter, so every attempt counts against the
+	// provider's budgets.
+	emailSender, err := email.NewEmailSenderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	outbox, capturesEmail := emailSender.(*email.OutboxEmailSender)
+	emailSender = email.NewRateLimitedEmailSender(emailSender, email.NewRateLimitsFromEnv(), rateLimitRepo)
+	emailSender = email.NewRetryingEmailSender(emailSender, email.NewRetryPolicyFromEnv())
+
+	var subscriberService ports.SubscriberServicePort = service.NewSubscriberService(subscriberRepo, emailSender, unsubscribeTokens, subscriptionConfirmations, suppressionService)
+	var newsletterService ports.NewsletterServicePort = service.NewNewsletterService(newsletterRepo, subscriberRepo, sendJobRepo, attachmentRepo, attachmentPolicy, attachmentScanner)
+	var deliveryService ports.DeliveryServicePort = service.NewDeliveryService(deliveryRepo)
+	var attachmentService ports.AttachmentServicePort = service.NewAttachmentService(attachmentRepo, attachmentPolicy)
+	var openService ports.OpenServicePort = service.NewOpenService(deliveryRepo, openTracking)
+
+	sendWorkers, _ := strconv.Atoi(os.Getenv("sendWorkers"))
+	sendWorkerPool := service.NewSendWorkerPool(sendJobRepo, newsletterRepo, subscriberRepo, deliveryRepo, attachmentRepo, emailSender, unsubscribeTokens, publicURLs, suppressionService, bounceTracking, openTracking, sendWorkers)
+	sendWorkerPool.Start(context.Background())
+
+	// Bounces are read from the mailbox they are returned to, when one is set
+	bounceMailbox, err := mailbox.NewMailboxFromEnv("bounceMailbox", "bounceMailboxFormat")
+	if err != nil {
+		return nil, err
+	}
+	bounceProcessor := service.NewBounceProcessorFromEnv(bounceMailbox, deliveryRepo, suppressionService, bounceTracking)
+	if bounceMailbox != nil {
+		bounceProcessor.Start(context.Background())
+	}
+
+	// Complaints are posted to the API or read from the mailbox the feedback
+	// loops send them to, when one is set
+	complaintMailbox, err := mailbox.NewMailboxFromEnv("complaintMailbox", "complaintMailboxFormat")
+	if err != nil {
+		return nil, err
+	}
+	complaintService := service.NewComplaintServiceFromEnv(complaintMailbox, deliveryRepo, subscriberRepo, suppressionService, bounceTracking)
+	if complaintMailbox != nil {
+		complaintService.Start(context.Background())
+	}
+
+	// Unsubscribe requests sent to the mailto address of the List-Unsubscribe
+	// header are read from its mailbox, when one is set
+	unsubscribeMailbox, err := mailbox.NewMailboxFromEnv("unsubscribeMailboxPath", "unsubscribeMailboxFormat")
+	if err != nil {
+		return nil, err
+	}
+	if unsubscribeMailbox != nil {
+		service.NewUnsubscribeMailProcessorFromEnv(unsubscribeMailbox, subscriberService).Start(context.Background())
+	}
+
+	// API providers report bounces, complaints, deliveries and opens through
+	// their webhooks instead
+	webhookService, err := service.NewWebhookServiceFromEnv(deliveryRepo, bounceProcessor, complaintService, bounceTracking)
+	if err != nil {
+		return nil, err
+	}
 
-	var subscriberService ports.SubscriberServicePort = service.NewSubscriberService(subscriberRepo)
-	var newsletterService ports.NewsletterServicePort = service.NewNewsletterService(newsletterRepo, subscriberRepo)
+	var outboxService ports.OutboxServicePort
+	if capturesEmail {
+		outboxService = service.NewOutboxService(outbox)
+	}
 
-	var emailSender email.EmailSender = email.NewMailerSendEmailSender()
+	RegisterRoutes(r, adminKey, Services{
+		Subscribers:  subscriberService,
+		Newsletters:  newsletterService,
+		Deliveries:   deliveryService,
+		Attachments:  attachmentService,
+		Suppressions: suppressionService,
+		Complaints:   complaintService,
+		Webhooks:     webhookService,
+		Opens:        openService,
+		Outbox:       outboxService,
+	})
 
+	return r, nil
+}
+
+// Services are the services the routes of the API are served by.
+type Services struct {
+	Subscribers  ports.SubscriberServicePort
+	Newsletters  ports.NewsletterServicePort
+	Deliveries   ports.DeliveryServicePort
+	Attachments  ports.AttachmentServicePort
+	Suppressions ports.SuppressionServicePort
+	Complaints   ports.ComplaintServicePort
+	Webhooks     ports.WebhookServicePort
+	Opens        ports.OpenServicePort
+	// Outbox is nil unless the outbox sender keeps the messages.
+	Outbox ports.OutboxServicePort
+}
+
+// RegisterRoutes adds the routes of the API to r. Routes that act on any
+// address are only served to requests carrying adminKey.
+func RegisterRoutes(r *mux.Router, adminKey string, services Services) {
 	// Routes configuration for subscribers
-	r.HandleFunc("/api/v1/subscribe/{email}/{category}", handlers.SubscribeHandler(subscriberService)).Methods("POST")
-	r.HandleFunc("/api/v1/unsubscribe/{email}/{category}", handlers.UnsubscribeHandler(subscriberService)).Methods("DELETE")
-	r.HandleFunc("/api/v1/subscribers/{email}/{category}", handlers.GetSubscriberHandler(subscriberService)).Methods("GET")
-	r.HandleFunc("/api/v1/subscribers", handlers.GetSubscribersHandler(subscriberService)).Methods("GET")
+	r.HandleFunc("/api/v1/subscribe/{email}/{category}", handlers.SubscribeHandler(services.Subscribers)).Methods("POST")
+	r.HandleFunc("/api/v1/confirm/{token}", handlers.ConfirmSubscriptionHandler(services.Subscribers)).Methods("GET", "POST")
+	r.HandleFunc("/api/v1/unsubscribe/one-click/{token}", handlers.OneClickUnsubscribeHandler(services.Subscribers)).Methods("POST")
+	r.HandleFunc("/api/v1/unsubscribe/{email}/{category}", handlers.RequireAdminKey(adminKey, handlers.UnsubscribeHandler(services.Subscribers))).Methods("DELETE")
+	r.HandleFunc("/api/v1/unsubscribe/{token}", handlers.UnsubscribeByTokenHandler(services.Subscribers)).Methods("GET", "POST")
+	r.HandleFunc("/api/v1/subscribers/{email}/{category}", handlers.GetSubscriberHandler(services.Subscribers)).Methods("GET")
+	r.HandleFunc("/api/v1/subscribers", handlers.GetSubscribersHandler(services.Subscribers)).Methods("GET")
 
 	// Routes configuration for newsletters
-	r.HandleFunc("/api/v1/newsletters/send/{newsletterID}", handlers.SendNewsletterHandler(subscriberService, newsletterService, emailSender)).Methods("POST")
-	r.HandleFunc("/api/v1/newsletters", handlers.CreateNewsletterHandler(newsletterService)).Methods("POST")
-	r.HandleFunc("/api/v1/newsletters", handlers.GetNewslettersHandler(newsletterService)).Methods("GET")
-	r.HandleFunc("/api/v1/newsletters", handlers.UpdateNewsletterHandler(newsletterService)).Methods("PUT")
-	r.HandleFunc("/api/v1/newsletters/{id}", handlers.DeleteNewsletterHandler(newsletterService)).Methods("DELETE")
+	r.HandleFunc("/api/v1/newsletters/send/{newsletterID}", handlers.SendNewsletterHandler(services.Newsletters)).Methods("POST")
+	r.HandleFunc("/api/v1/newsletters/jobs/{jobID}", handlers.GetSendJobHandler(services.Newsletters)).Methods("GET")
+	r.HandleFunc("/api/v1/newsletters", handlers.CreateNewsletterHandler(services.Newsletters)).Methods("POST")
+	r.HandleFunc("/api/v1/newsletters", handlers.GetNewslettersHandler(services.Newsletters)).Methods("GET")
+	r.HandleFunc("/api/v1/newsletters", handlers.UpdateNewsletterHandler(services.Newsletters)).Methods("PUT")
+	r.HandleFunc("/api/v1/newsletters/{id}", handlers.DeleteNewsletterHandler(services.Newsletters)).Methods("DELETE")
+	r.HandleFunc("/api/v1/newsletters/{id}/deliveries", handlers.GetDeliveriesHandler(services.Deliveries)).Methods("GET")
+
+	// Routes configuration for attachments
+	r.HandleFunc("/api/v1/attachments", handlers.UploadAttachmentHandler(services.Attachments)).Methods("POST")
+	r.HandleFunc("/api/v1/attachments/{id}", handlers.DownloadAttachmentHandler(services.Attachments)).Methods("GET")
+
+	// Routes configuration for the suppression list, which only the admin
+	// may read or change
+	r.HandleFunc("/api/v1/suppressions", handlers.RequireAdminKey(adminKey, handlers.GetSuppressionsHandler(services.Suppressions))).Methods("GET")
+	r.HandleFunc("/api/v1/suppressions", handlers.RequireAdminKey(adminKey, handlers.AddSuppressionHandler(services.Suppressions))).Methods("POST")
+	r.HandleFunc("/api/v1/suppressions/import", handlers.RequireAdminKey(adminKey, handlers.ImportSuppressionsHandler(services.Suppressions))).Methods("POST")
+	r.HandleFunc("/api/v1/suppressions/export", handlers.RequireAdminKey(adminKey, handlers.ExportSuppressionsHandler(services.Suppressions))).Methods("GET")
+	r.HandleFunc("/api/v1/suppressions/{address}", handlers.RequireAdminKey(adminKey, handlers.DeleteSuppressionsHandler(services.Suppressions))).Methods("DELETE")
+
+	// Routes configuration for spam complaints
+	r.HandleFunc("/api/v1/complaints", handlers.RequireAdminKey(adminKey, handlers.IngestComplaintHandler(services.Complaints))).Methods("POST")
+
+	// Routes configuration for provider webhooks
+	r.HandleFunc("/api/v1/webhooks/{provider}", handlers.WebhookHandler(services.Webhooks)).Methods("POST")
+
+	// Route of the open tracking pixel, kept short and outside the API since
+	// it is in every newsletter
+	r.HandleFunc("/t/o/{token}.gif", handlers.OpenPixelHandler(services.Opens)).Methods("GET")
 
-	return r
+	// Routes configuration for the outbox, whose sender keeps messages on disk
+	// instead of sending them, when it is used
+	if services.Outbox != nil {
+		r.HandleFunc("/api/v1/outbox", handlers.GetOutboxMessagesHandler(services.Outbox)).Methods("GET")
+		r.HandleFunc("/api/v1/outbox/{id}", handlers.GetOutboxMessageHandler(services.Outbox)).Methods("GET")
+		r.HandleFunc("/api/v1/outbox/{id}/html", handlers.PreviewOutboxMessageHandler(services.Outbox)).Methods("GET")
+		r.HandleFunc("/api/v1/outbox/{id}/raw", handlers.GetRawOutboxMessageHandler(services.Outbox)).Methods("GET")
+	}
 }
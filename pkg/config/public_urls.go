@@ -0,0 +1,181 @@
+// Package config holds typed settings shared by several layers of the app.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	defaultFrontendBaseURL  = "http://localhost:4200"
+	defaultAPIBaseURL       = "http://localhost:8080"
+	defaultPreferencesPath  = "/preferences/"
+	unsubscribePath         = "/api/v1/unsubscribe/"
+	oneClickUnsubscribePath = "/api/v1/unsubscribe/one-click/"
+	confirmPath             = "/api/v1/confirm/"
+	openPixelPath           = "/t/o/"
+)
+
+// URLs are the public addresses links sent to subscribers point to.
+// PreferencesPath is served by the frontend. The paths of the API links are
+// those of its routes, so a proxy serving the API under another path has to
+// be named in APIBaseURL, such as https://example.com/newsletter.
+type URLs struct {
+	FrontendBaseURL string `json:"frontendBaseUrl"`
+	APIBaseURL      string `json:"apiBaseUrl"`
+	PreferencesPath string `json:"preferencesPath"`
+}
+
+// PublicURLs resolves the URLs of a category, falling back to the defaults for
+// every value a category does not override.
+type PublicURLs struct {
+	defaults   URLs
+	categories map[string]URLs
+}
+
+// NewPublicURLs validates defaults and the per-category overrides. Empty
+// defaults are replaced by the local development addresses.
+func NewPublicURLs(defaults URLs, overrides map[string]URLs) (*PublicURLs, error) {
+	defaults = defaults.merge(URLs{
+		FrontendBaseURL: defaultFrontendBaseURL,
+		APIBaseURL:      defaultAPIBaseURL,
+		PreferencesPath: defaultPreferencesPath,
+	})
+
+	normalized, err := defaults.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	publicURLs := &PublicURLs{defaults: normalized, categories: make(map[string]URLs)}
+	for category, override := range overrides {
+		urls, err := override.merge(normalized).normalize()
+		if err != nil {
+			return nil, fmt.Errorf("category %q: %w", category, err)
+		}
+		publicURLs.categories[category] = urls
+	}
+
+	return publicURLs, nil
+}
+
+// NewPublicURLsFromEnv reads publicFrontendUrl, apiBaseUrl and
+// preferencesPath, and per-category overrides from publicUrlOverrides, a
+// JSON object such as {"Tech": {"frontendBaseUrl": "https://tech.example.com"}}.
+// publicFrontendUrl and apiBaseUrl are required unless appEnv is development,
+// so that links sent to subscribers never point to localhost by mistake.
+func NewPublicURLsFromEnv() (*PublicURLs, error) {
+	defaults := URLs{
+		FrontendBaseURL: os.Getenv("publicFrontendUrl"),
+		APIBaseURL:      os.Getenv("apiBaseUrl"),
+		PreferencesPath: os.Getenv("preferencesPath"),
+	}
+	if os.Getenv("appEnv") != "development" && (defaults.FrontendBaseURL == "" || defaults.APIBaseURL == "") {
+		return nil, errors.New("publicFrontendUrl and apiBaseUrl are required unless appEnv is development")
+	}
+
+	var overrides map[string]URLs
+	if raw := os.Getenv("publicUrlOverrides"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			return nil, fmt.Errorf("publicUrlOverrides: %w", err)
+		}
+	}
+
+	publicURLs, err := NewPublicURLs(defaults, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("public URLs: %w", err)
+	}
+	return publicURLs, nil
+}
+
+// For returns the URLs used in links for subscribers of category.
+func (p *PublicURLs) For(category string) URLs {
+	if urls, ok := p.categories[category]; ok {
+		return urls
+	}
+	return p.defaults
+}
+
+// HostDomain is the frontend base URL with a trailing slash, as the
+// {hostDomain} placeholder has always been.
+func (u URLs) HostDomain() string {
+	return u.FrontendBaseURL + "/"
+}
+
+// UnsubscribeURL returns the API link that unsubscribes with a token.
+func (u URLs) UnsubscribeURL(unsubscribeToken string) string {
+	return u.APIBaseURL + unsubscribePath + unsubscribeToken
+}
+
+// OneClickUnsubscribeURL returns the API link of the RFC 8058 one-click
+// unsubscribe for a token, which only unsubscribes on POST.
+func (u URLs) OneClickUnsubscribeURL(unsubscribeToken string) string {
+	return u.APIBaseURL + oneClickUnsubscribePath + unsubscribeToken
+}
+
+// PreferencesURL returns the preference center page for a token.
+func (u URLs) PreferencesURL(unsubscribeToken string) string {
+	return u.FrontendBaseURL + u.PreferencesPath + unsubscribeToken
+}
+
+// ConfirmURL returns the API link that confirms a pending subscription.
+func (u URLs) ConfirmURL(confirmationToken string) string {
+	return u.APIBaseURL + confirmPath + confirmationToken
+}
+
+// OpenPixelURL returns the API link of the tracking pixel for a token.
+func (u URLs) OpenPixelURL(openToken string) string {
+	return u.APIBaseURL + openPixelPath + openToken + ".gif"
+}
+
+// merge fills the empty values of u from fallback.
+func (u URLs) merge(fallback URLs) URLs {
+	if u.FrontendBaseURL == "" {
+		u.FrontendBaseURL = fallback.FrontendBaseURL
+	}
+	if u.APIBaseURL == "" {
+		u.APIBaseURL = fallback.APIBaseURL
+	}
+	if u.PreferencesPath == "" {
+		u.PreferencesPath = fallback.PreferencesPath
+	}
+	return u
+}
+
+// normalize checks that the base URLs are absolute http(s) URLs and gives
+// them no trailing slash, and gives paths a leading and trailing slash, so
+// the link builders can join them by concatenation.
+func (u URLs) normalize() (URLs, error) {
+	var err error
+	if u.FrontendBaseURL, err = normalizeBaseURL("frontendBaseUrl", u.FrontendBaseURL); err != nil {
+		return URLs{}, err
+	}
+	if u.APIBaseURL, err = normalizeBaseURL("apiBaseUrl", u.APIBaseURL); err != nil {
+		return URLs{}, err
+	}
+	u.PreferencesPath = normalizePath(u.PreferencesPath)
+	return u, nil
+}
+
+func normalizeBaseURL(name, raw string) (string, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("%s %q must be an absolute http or https URL", name, raw)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("%s %q must not have a query or fragment", name, raw)
+	}
+	return strings.TrimSuffix(raw, "/"), nil
+}
+
+func normalizePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return "/" + path + "/"
+}
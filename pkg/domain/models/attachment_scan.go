@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// AttachmentScanStatus is the verdict of scanning an attachment for malware.
+type AttachmentScanStatus string
+
+const (
+	AttachmentScanClean    AttachmentScanStatus = "clean"
+	AttachmentScanInfected AttachmentScanStatus = "infected"
+	// AttachmentScanSkipped is given when no scanner is configured.
+	AttachmentScanSkipped AttachmentScanStatus = "skipped"
+	// AttachmentScanFailed is given when the scanner could not scan the
+	// content, for example because it was unreachable.
+	AttachmentScanFailed AttachmentScanStatus = "failed"
+)
+
+// represents the result of scanning an attachment for malware.
+// Signature names the malware found in an infected attachment, and Error
+// tells why a failed scan failed.
+// swagger:model
+type AttachmentScan struct {
+	Status    AttachmentScanStatus `json:"status"`
+	Scanner   string               `json:"scanner,omitempty"`
+	Signature string               `json:"signature,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	ScannedAt time.Time            `json:"scanned_at"`
+}
+
+// AllowsSending reports whether an attachment with this scan result may be
+// sent: it was found clean, or scanning is turned off. An attachment that was
+// never scanned has no result and may not be sent.
+func (s *AttachmentScan) AllowsSending() bool {
+	return s != nil && (s.Status == AttachmentScanClean || s.Status == AttachmentScanSkipped)
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+// BounceType tells whether a bounce means the address cannot receive mail.
+type BounceType string
+
+const (
+	// BounceHard is a permanent failure, such as an unknown mailbox. The
+	// address is suppressed.
+	BounceHard BounceType = "hard"
+	// BounceSoft is a temporary failure, such as a full mailbox. It is only
+	// counted.
+	BounceSoft BounceType = "soft"
+)
+
+// represents a delivery failure reported after the message was accepted for
+// delivery, from a delivery status notification.
+// swagger:model
+type Bounce struct {
+	Type       BounceType `json:"type"`
+	Recipient  string     `json:"recipient"`
+	Status     string     `json:"status"`
+	Diagnostic string     `json:"diagnostic,omitempty"`
+	ReportedBy string     `json:"reported_by,omitempty"`
+	ReportedAt time.Time  `json:"reported_at"`
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// represents a spam complaint about a newsletter, reported by the
+// recipient's mailbox provider through its feedback loop.
+// swagger:model
+type Complaint struct {
+	FeedbackType string    `json:"feedback_type"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	ReportedBy   string    `json:"reported_by,omitempty"`
+	ReportedAt   time.Time `json:"reported_at"`
+}
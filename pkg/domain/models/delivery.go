@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeliveryStatus is the state of a newsletter delivery to one subscriber.
+type DeliveryStatus string
+
+const (
+	DeliveryQueued  DeliveryStatus = "queued"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryFailed  DeliveryStatus = "failed"
+	DeliveryBounced DeliveryStatus = "bounced"
+	// DeliverySuppressed is a subscriber skipped because the address is on
+	// the suppression list.
+	DeliverySuppressed DeliveryStatus = "suppressed"
+)
+
+// IsValid reports whether s is one of the known delivery statuses.
+func (s DeliveryStatus) IsValid() bool {
+	switch s {
+	case DeliveryQueued, DeliverySent, DeliveryFailed, DeliveryBounced, DeliverySuppressed:
+		return true
+	}
+	return false
+}
+
+// represents the delivery of a newsletter to a single subscriber.
+// There is one delivery per (newsletter, subscriber) pair.
+// SoftBounces counts the temporary failures reported after the message was
+// sent, Bounce is the last bounce reported and Complaint is set when the
+// recipient marked the message as spam. DeliveredAt is reported by the
+// webhooks of API providers. Opens counts the opens seen by the tracking pixel
+// or reported by the webhooks, OpenedAt is the time of the first one and
+// LastOpen the latest. OpenTokens are the tokens of the tracking pixels sent
+// for the delivery, one per send, which are never shown.
+// swagger:model
+type Delivery struct {
+	ID           primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	NewsletterID string             `json:"newsletter_id"`
+	SubscriberID primitive.ObjectID `json:"subscriber_id"`
+	JobID        primitive.ObjectID `json:"job_id"`
+	Email        string             `json:"email"`
+	Status       DeliveryStatus     `json:"status"`
+	Error        string             `json:"error,omitempty"`
+	Attempts     int                `json:"attempts"`
+	SoftBounces  int                `json:"soft_bounces"`
+	Bounce       *Bounce            `json:"bounce,omitempty"`
+	Complaint    *Complaint         `json:"complaint,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+	SentAt       time.Time          `json:"sent_at"`
+	DeliveredAt  time.Time          `json:"delivered_at"`
+	Opens        int                `json:"opens"`
+	OpenedAt     time.Time          `json:"opened_at"`
+	LastOpen     *Open              `json:"last_open,omitempty"`
+	OpenTokens   []string           `json:"-"`
+}
+
+// represents the filters accepted when listing deliveries.
+type DeliveryFilter struct {
+	NewsletterID string
+	Status       DeliveryStatus
+	Email        string
+}
+
+// represents the number of deliveries of a newsletter in each status.
+// swagger:model
+type DeliverySummary struct {
+	Total      int `json:"total"`
+	Queued     int `json:"queued"`
+	Sent       int `json:"sent"`
+	Failed     int `json:"failed"`
+	Bounced    int `json:"bounced"`
+	Suppressed int `json:"suppressed"`
+	// SoftBounces counts the temporary failures reported for the
+	// newsletter's deliveries, which do not change their status.
+	SoftBounces int `json:"soft_bounces"`
+	// Complaints counts the deliveries the recipient marked as spam, and
+	// ComplaintRate is their share of the sent deliveries.
+	Complaints    int     `json:"complaints"`
+	ComplaintRate float64 `json:"complaint_rate"`
+	// UniqueOpens counts the deliveries opened at least once and TotalOpens
+	// every open, and OpenRate is the share of the sent deliveries that were
+	// opened. Prefetches by Apple Mail Privacy Protection are included.
+	UniqueOpens int     `json:"unique_opens"`
+	TotalOpens  int     `json:"total_opens"`
+	OpenRate    float64 `json:"open_rate"`
+}
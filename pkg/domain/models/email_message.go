@@ -0,0 +1,20 @@
+package domain
+
+// EmailMessage is one message handed to an EmailSender. Body is HTML; when
+// TextBody is set the message is sent as multipart/alternative with both.
+// Headers are added to the ones the sender sets itself (From, To and
+// Subject), for example the List-Unsubscribe headers of a newsletter.
+// ReturnPath, when set, replaces the sender address as the envelope sender
+// that bounces are returned to. Only the SMTP sender uses it. Metadata is
+// attached to the message by the API providers and returned in their webhook
+// events; the SMTP sender ignores it.
+type EmailMessage struct {
+	Subject     string
+	Body        string
+	TextBody    string
+	To          []string
+	Attachments []*Attachment
+	Headers     map[string]string
+	ReturnPath  string
+	Metadata    map[string]string
+}
@@ -1,10 +1,16 @@
 package domain
 
 import (
+	"encoding/base64"
+	"io"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // represents a newsletter.
+// TextContent is an optional hand-written text version; when it is empty the
+// text part of the email is generated from Content.
 // swagger:model
 type Newsletter struct {
 	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty" example:""`
@@ -12,13 +18,40 @@ type Newsletter struct {
 	Category    string             `json:"category"`
 	Subject     string             `json:"subject"`
 	Content     string             `json:"content"`
+	TextContent string             `json:"text_content,omitempty"`
 	Attachments []Attachment       `json:"attachments"`
 }
 
 // represents a file attached to the newsletter.
+// The content is stored in GridFS under ID; the newsletter only keeps its
+// metadata. Size is in bytes and Checksum is the hex SHA-256 of the content.
+// An attachment with a ContentID is embedded inline instead of offered as a
+// download, and the content refers to it as cid:<ContentID>.
 // swagger:model
 type Attachment struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
-	Type string `json:"type"`
+	ID        string `json:"id,omitempty" bson:"id,omitempty"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Size      int64  `json:"size,omitempty" bson:"size,omitempty"`
+	Checksum  string `json:"checksum,omitempty" bson:"checksum,omitempty"`
+	ContentID string `json:"content_id,omitempty"`
+	// Data is the base64 content of attachments sent inline in the request
+	// or saved before attachments were kept in GridFS.
+	Data string `json:"data,omitempty" bson:"data,omitempty"`
+	// Scan is the result of scanning the content for malware when the
+	// newsletter was last saved. It is nil for attachments never scanned.
+	Scan *AttachmentScan `json:"scan,omitempty" bson:"scan,omitempty"`
+
+	// Content opens the stored content. It is set on the attachments of an
+	// EmailMessage and never persisted.
+	Content func() (io.ReadCloser, error) `json:"-" bson:"-"`
+}
+
+// Open returns the bytes of the attachment, streamed from Content when it is
+// set and decoded from Data otherwise.
+func (a *Attachment) Open() (io.ReadCloser, error) {
+	if a.Content != nil {
+		return a.Content()
+	}
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.Data))), nil
 }
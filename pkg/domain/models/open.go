@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+// UserAgentClass is the kind of client that opened a newsletter, told from
+// its user agent.
+type UserAgentClass string
+
+const (
+	UserAgentDesktop UserAgentClass = "desktop"
+	UserAgentMobile  UserAgentClass = "mobile"
+	// UserAgentProxy is an image proxy of a webmail, such as Gmail's, which
+	// hides the client of the reader.
+	UserAgentProxy   UserAgentClass = "proxy"
+	UserAgentBot     UserAgentClass = "bot"
+	UserAgentUnknown UserAgentClass = "unknown"
+)
+
+// represents an open of a newsletter, seen by the tracking pixel or reported
+// by the webhook of an API provider. AppleMPP is set when the open looks like
+// a prefetch by Apple Mail Privacy Protection, which loads the images of
+// every message when it is received, rather than the recipient reading it.
+// swagger:model
+type Open struct {
+	UserAgentClass UserAgentClass `json:"user_agent_class"`
+	AppleMPP       bool           `json:"apple_mpp"`
+	ReportedBy     string         `json:"reported_by,omitempty"`
+	OpenedAt       time.Time      `json:"opened_at"`
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// OutboxMessage summarizes a message captured by the outbox email sender
+// instead of being delivered.
+// swagger:model
+type OutboxMessage struct {
+	ID      string    `json:"id"`
+	From    string    `json:"from"`
+	To      []string  `json:"to"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Size    int       `json:"size"`
+}
+
+// OutboxAttachment describes a file attached to a captured message.
+// swagger:model
+type OutboxAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+}
+
+// OutboxMessageDetail is a captured message with its headers, decoded bodies
+// and attachments, for previewing.
+// swagger:model
+type OutboxMessageDetail struct {
+	OutboxMessage
+	Headers     map[string][]string `json:"headers"`
+	HTMLBody    string              `json:"html_body"`
+	TextBody    string              `json:"text_body"`
+	Attachments []OutboxAttachment  `json:"attachments"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SendPhase is the SMTP command a server refused, which tells whether the
+// recipient, the sender or the message was rejected.
+type SendPhase string
+
+const (
+	SendPhaseSender    SendPhase = "MAIL FROM"
+	SendPhaseRecipient SendPhase = "RCPT TO"
+	SendPhaseMessage   SendPhase = "DATA"
+)
+
+// SendError describes why an email sender could not deliver a message.
+// Permanent errors will not succeed on retry (for example an unknown
+// recipient), while the others are worth retrying later. Phase and Status,
+// the enhanced status code such as 5.1.1, are only known for SMTP replies
+// that carry them.
+type SendError struct {
+	Code      int
+	Status    string
+	Phase     SendPhase
+	Permanent bool
+	Attempts  int
+	Err       error
+}
+
+func (e *SendError) Error() string {
+	kind := "transient"
+	if e.Permanent {
+		kind = "permanent"
+	}
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s send error after %d attempts: %v", kind, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("%s send error: %v", kind, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanentSendError reports whether err is a SendError that should not be retried.
+func IsPermanentSendError(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent
+}
+
+// SendAttempts returns how many times a sender tried to deliver the message
+// that failed with err. Errors that do not carry a count are a single attempt.
+func SendAttempts(err error) int {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) && sendErr.Attempts > 0 {
+		return sendErr.Attempts
+	}
+	return 1
+}
+
+// RateLimitError is returned by email senders when the sending budget for the
+// current window is used up. Nothing should be sent before ResumeAt.
+type RateLimitError struct {
+	ResumeAt time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("sending budget exhausted until %s", e.ResumeAt.Format(time.RFC3339))
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SendJobStatus is the lifecycle state of a send job.
+type SendJobStatus string
+
+const (
+	SendJobQueued    SendJobStatus = "queued"
+	SendJobRunning   SendJobStatus = "running"
+	SendJobPaused    SendJobStatus = "paused"
+	SendJobCompleted SendJobStatus = "completed"
+	SendJobFailed    SendJobStatus = "failed"
+)
+
+// ErrSendJobLeaseLost is returned when saving a job held by another worker:
+// its lease expired and it was claimed again, so the worker that lost it must
+// stop sending.
+var ErrSendJobLeaseLost = errors.New("send job lease lost to another worker")
+
+// represents an asynchronous request to send a newsletter to its subscribers.
+// LastSubscriberID is the resume point: subscribers are processed in _id order,
+// so a job picked up again after a restart continues right after it.
+// A paused job ran out of sending budget and continues at ResumeAt.
+// swagger:model
+type SendJob struct {
+	ID               primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	NewsletterID     string             `json:"newsletter_id"`
+	Status           SendJobStatus      `json:"status"`
+	LastSubscriberID primitive.ObjectID `json:"last_subscriber_id"`
+	Sent             int                `json:"sent"`
+	Failed           int                `json:"failed"`
+	Suppressed       int                `json:"suppressed"`
+	Error            string             `json:"error,omitempty"`
+	ResumeAt         time.Time          `json:"resume_at"`
+	WorkerID         string             `json:"-"`
+	LeaseExpiresAt   time.Time          `json:"-"`
+	CreatedAt        time.Time          `json:"created_at"`
+	StartedAt        time.Time          `json:"started_at"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+	CompletedAt      time.Time          `json:"completed_at"`
+}
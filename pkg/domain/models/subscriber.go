@@ -6,13 +6,28 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// SubscriberStatus tells whether a subscriber has confirmed their subscription.
+// Subscribers stored before double opt-in existed have no status and are
+// treated as active.
+type SubscriberStatus string
+
+const (
+	SubscriberPending SubscriberStatus = "pending"
+	SubscriberActive  SubscriberStatus = "active"
+)
+
 // represents a newsletter subscriber.
+// Pending subscribers are removed once ExpiresAt passes without a confirmation.
 // swagger:model
 type Subscriber struct {
 	ID               primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
 	Email            string             `json:"email"`
 	SubscriptionDate time.Time          `json:"subscription_date"`
 	Category         string             `json:"category"`
+	Attributes       map[string]string  `json:"attributes,omitempty"`
+	Status           SubscriberStatus   `json:"status,omitempty"`
+	ExpiresAt        *time.Time         `json:"expires_at,omitempty"`
+	ConfirmedAt      *time.Time         `json:"confirmed_at,omitempty"`
 }
 
 type Subscribers []Subscriber
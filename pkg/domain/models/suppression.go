@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SuppressionReason tells why an address must not receive newsletters.
+type SuppressionReason string
+
+const (
+	SuppressionUnsubscribed SuppressionReason = "unsubscribed"
+	SuppressionHardBounce   SuppressionReason = "hard_bounce"
+	SuppressionComplaint    SuppressionReason = "complaint"
+	SuppressionManual       SuppressionReason = "manual"
+)
+
+// IsValid reports whether r is one of the known suppression reasons.
+func (r SuppressionReason) IsValid() bool {
+	switch r {
+	case SuppressionUnsubscribed, SuppressionHardBounce, SuppressionComplaint, SuppressionManual:
+		return true
+	}
+	return false
+}
+
+// represents an address that must not receive newsletters.
+// The address itself is not kept, only AddressHash, so the list can be
+// checked without holding the addresses of people who left. An empty Category
+// suppresses the address in every category. Source tells what added it, such
+// as an unsubscribe, an import or the admin API.
+// swagger:model
+type Suppression struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	AddressHash string             `json:"address_hash"`
+	Category    string             `json:"category,omitempty"`
+	Reason      SuppressionReason  `json:"reason"`
+	Source      string             `json:"source,omitempty"`
+	Detail      string             `json:"detail,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// represents the filters accepted when listing suppressions.
+type SuppressionFilter struct {
+	AddressHash string
+	Category    string
+	Reason      SuppressionReason
+}
@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"io"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// AttachmentRepositoryPort stores attachment content outside the newsletter
+// documents. SaveAttachment fills in the ID, Size and Checksum of the stored
+// file. GetAttachment returns nil when there is no attachment with that ID.
+type AttachmentRepositoryPort interface {
+	SaveAttachment(attachment domain.Attachment, content io.Reader) (*domain.Attachment, error)
+	GetAttachment(id string) (*domain.Attachment, error)
+	OpenAttachment(id string) (io.ReadCloser, error)
+}
@@ -0,0 +1,13 @@
+package ports
+
+import (
+	"io"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// AttachmentScanner checks the content of an attachment for malware. Scan
+// returns the verdict on content; an error means it could not be scanned.
+type AttachmentScanner interface {
+	Scan(content io.Reader) (*domain.AttachmentScan, error)
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"io"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+type AttachmentServicePort interface {
+	UploadAttachment(name, contentType string, content io.Reader) (*domain.Attachment, error)
+	OpenAttachment(id string) (*domain.Attachment, io.ReadCloser, error)
+}
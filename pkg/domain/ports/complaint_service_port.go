@@ -0,0 +1,8 @@
+package ports
+
+import "newsletter-app/pkg/service/Dtos/response"
+
+// ComplaintServicePort ingests the abuse reports of feedback loops.
+type ComplaintServicePort interface {
+	IngestComplaint(raw []byte) (*response.ComplaintResponse, error)
+}
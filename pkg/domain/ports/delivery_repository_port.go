@@ -0,0 +1,37 @@
+package ports
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DeliveryRepositoryPort interface {
+	QueueDelivery(delivery domain.Delivery) error
+	UpdateDeliveryStatus(newsletterID string, subscriberID primitive.ObjectID, status domain.DeliveryStatus, errorText string, attempts int) error
+	GetDeliveries(filter domain.DeliveryFilter, page, pageSize int) ([]domain.Delivery, int64, error)
+	CountDeliveriesByStatus(newsletterID string) (map[domain.DeliveryStatus]int, error)
+	// RecordBounce stores a bounce on the delivery: a hard bounce marks it
+	// bounced and a soft bounce is counted. It returns the updated delivery,
+	// or nil when there is no such delivery.
+	RecordBounce(newsletterID string, subscriberID primitive.ObjectID, bounce domain.Bounce) (*domain.Delivery, error)
+	// RecordUnmatchedBounce keeps a bounce that names no delivery, for an
+	// admin to review.
+	RecordUnmatchedBounce(bounce domain.Bounce) error
+	CountSoftBounces(newsletterID string) (int, error)
+	// RecordComplaint stores a spam complaint on the delivery. It returns the
+	// updated delivery, or nil when there is no such delivery.
+	RecordComplaint(newsletterID string, subscriberID primitive.ObjectID, complaint domain.Complaint) (*domain.Delivery, error)
+	CountComplaints(newsletterID string) (int, error)
+	RecordDelivered(newsletterID string, subscriberID primitive.ObjectID, deliveredAt time.Time) error
+	// RecordOpen counts an open of the delivery, keeps the time of the first
+	// one and stores the latest.
+	RecordOpen(newsletterID string, subscriberID primitive.ObjectID, open domain.Open) error
+	// RecordOpenByToken records an open like RecordOpen on the delivery
+	// carrying the open tracking token. It returns false when there is none.
+	RecordOpenByToken(openToken string, open domain.Open) (bool, error)
+	// CountOpens returns the number of deliveries of a newsletter opened at
+	// least once, and the number of opens.
+	CountOpens(newsletterID string) (int, int, error)
+}
@@ -0,0 +1,11 @@
+package ports
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+type DeliveryServicePort interface {
+	GetDeliveries(filter domain.DeliveryFilter, page, pageSize int) (*response.DeliveriesResponse, error)
+	GetDeliverySummary(newsletterID string) (*domain.DeliverySummary, error)
+}
@@ -0,0 +1,10 @@
+package ports
+
+// Mailbox is a local mailbox that mail for the application is delivered to,
+// such as bounces. Process calls handle with every message that was not
+// processed yet, oldest first, and marks a message processed once handle
+// returns nil. It stops at the first error, which leaves that message and the
+// following ones for the next call.
+type Mailbox interface {
+	Process(handle func(message []byte) error) error
+}
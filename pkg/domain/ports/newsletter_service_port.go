@@ -1,9 +1,7 @@
 package ports
 
 import (
-	"net/http"
 	domain "newsletter-app/pkg/domain/models"
-	"newsletter-app/pkg/infrastructure/adapters/email"
 	"newsletter-app/pkg/service/Dtos/request"
 )
 
@@ -12,7 +10,8 @@ type NewsletterServicePort interface {
 	GetNewsletterByCategory(category string) (*domain.Newsletter, error)
 	GetNewsletterByID(newsletterID string) (*domain.Newsletter, error)
 	GetNewsletters(searchName string, page int, pageSize int) ([]domain.Newsletter, error)
-	SendNewsletter(w http.ResponseWriter, r *http.Request, newsletterID string, emailSender email.EmailSender) error
+	SendNewsletter(newsletterID string) (*domain.SendJob, error)
+	GetSendJob(jobID string) (*domain.SendJob, error)
 	UpdateNewsletter(updateRequest request.UpdateNewsletterRequest) error
 	DeleteNewsletter(id string) error
 }
@@ -0,0 +1,6 @@
+package ports
+
+// OpenServicePort records the opens seen by the tracking pixel.
+type OpenServicePort interface {
+	RecordPixelOpen(openToken, userAgent, remoteIP string) error
+}
@@ -0,0 +1,8 @@
+package ports
+
+// OutboxRepositoryPort reads back the raw messages captured by the outbox
+// email sender, oldest first.
+type OutboxRepositoryPort interface {
+	ListOutboxMessages() ([]string, error)
+	GetOutboxMessage(id string) ([]byte, error)
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+type OutboxServicePort interface {
+	GetOutboxMessages(page, pageSize int) (*response.OutboxMessagesResponse, error)
+	GetOutboxMessage(id string) (*domain.OutboxMessageDetail, error)
+	GetRawOutboxMessage(id string) ([]byte, error)
+}
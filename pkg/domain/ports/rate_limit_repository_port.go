@@ -0,0 +1,15 @@
+package ports
+
+import "time"
+
+// RateLimitRepositoryPort counts the messages sent in the windows of the
+// provider's hourly and daily budgets, so that every instance of the
+// application shares them and they survive restarts.
+type RateLimitRepositoryPort interface {
+	// TakeRateLimit counts one message in the window named by key, unless
+	// limit messages were already counted in it, and reports whether it did.
+	// The count may be dropped once expiresAt has passed.
+	TakeRateLimit(key string, limit int, expiresAt time.Time) (bool, error)
+	// ReleaseRateLimit uncounts a message taken in the window named by key.
+	ReleaseRateLimit(key string) error
+}
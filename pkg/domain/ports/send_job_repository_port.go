@@ -0,0 +1,17 @@
+package ports
+
+import (
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+type SendJobRepositoryPort interface {
+	SaveSendJob(job domain.SendJob) error
+	GetSendJobByID(jobID string) (*domain.SendJob, error)
+	ClaimNextSendJob(workerID string, leaseDuration time.Duration) (*domain.SendJob, error)
+	// UpdateSendJob saves the progress of a job claimed by job.WorkerID. It
+	// returns domain.ErrSendJobLeaseLost when another worker has claimed it
+	// since.
+	UpdateSendJob(job domain.SendJob) error
+}
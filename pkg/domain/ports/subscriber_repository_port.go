@@ -1,11 +1,18 @@
 package ports
 
-import domain "newsletter-app/pkg/domain/models"
+import (
+	domain "newsletter-app/pkg/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type SubscriberRepositoryPort interface {
 	SaveSubscriber(subscriber domain.Subscriber) error
 	DeleteSubscriberByEmail(email, category string) error
 	GetSubscriberByEmailAndCategory(email, category string) (*domain.Subscriber, error)
+	GetSubscriberByID(subscriberID string) (*domain.Subscriber, error)
 	GetSubscribers(email, category string, page, pageSize int) ([]domain.Subscriber, error)
 	GetSubscribersByCategory(category string) ([]domain.Subscriber, error)
+	GetSubscribersByCategoryAfter(category string, afterID primitive.ObjectID, limit int) ([]domain.Subscriber, error)
+	ConfirmSubscriber(subscriberID primitive.ObjectID) error
 }
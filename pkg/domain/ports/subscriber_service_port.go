@@ -3,8 +3,10 @@ package ports
 import domain "newsletter-app/pkg/domain/models"
 
 type SubscriberServicePort interface {
-	Subscribe(email string, category string) error
+	Subscribe(email string, category string, attributes map[string]string) error
 	Unsubscribe(email, category string) error
+	UnsubscribeByToken(unsubscribeToken string) error
+	ConfirmSubscription(confirmationToken string) error
 	GetSubscriberByEmail(email, category string) (*domain.Subscriber, error)
 	GetSubscribers(email, category string, page, pageSize int) ([]domain.Subscriber, error)
 }
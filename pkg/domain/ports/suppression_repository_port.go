@@ -0,0 +1,18 @@
+package ports
+
+import domain "newsletter-app/pkg/domain/models"
+
+// SuppressionRepositoryPort stores the suppression list. There is at most one
+// suppression per address hash and category: SaveSuppressions replaces the
+// reason, source and detail of an existing one and keeps its CreatedAt.
+// FindSuppressions returns the suppressions of the given hashes that apply to
+// category, that is the ones for every category and the ones for category.
+// DeleteSuppressions removes the suppressions of a hash in category, or in
+// every category when category is empty, and returns how many it removed.
+type SuppressionRepositoryPort interface {
+	SaveSuppressions(suppressions []domain.Suppression) error
+	FindSuppressions(addressHashes []string, category string) ([]domain.Suppression, error)
+	GetSuppressions(filter domain.SuppressionFilter, page, pageSize int) ([]domain.Suppression, int64, error)
+	DeleteSuppressions(addressHash, category string) (int64, error)
+	EachSuppression(fn func(domain.Suppression) error) error
+}
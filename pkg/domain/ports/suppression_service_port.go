@@ -0,0 +1,17 @@
+package ports
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+// SuppressionServicePort manages the suppression list. Addresses are given as
+// an email address or as an address hash.
+type SuppressionServicePort interface {
+	AddSuppression(suppression request.SuppressionRequest) (*domain.Suppression, error)
+	ImportSuppressions(suppressions []request.SuppressionRequest) (*response.SuppressionImportResponse, error)
+	GetSuppressions(address, category string, reason domain.SuppressionReason, page, pageSize int) (*response.SuppressionsResponse, error)
+	DeleteSuppressions(address, category string) error
+	ExportSuppressions(fn func(domain.Suppression) error) error
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"net/http"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+// WebhookServicePort applies the event webhooks of email providers. The
+// request headers are given for the provider's signature check.
+type WebhookServicePort interface {
+	HandleWebhook(provider string, header http.Header, body []byte) (*response.WebhookResponse, error)
+}
@@ -0,0 +1,182 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// DefaultDKIMHeaders are the headers signed when dkimHeaders is not set.
+var DefaultDKIMHeaders = []string{
+	"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMKey is the private key published under Selector._domainkey.Domain.
+type DKIMKey struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer
+}
+
+// DKIMSigner adds a DKIM-Signature to messages, using the key of the domain
+// of their From address. Messages from other domains are left unsigned.
+type DKIMSigner struct {
+	keys    map[string]DKIMKey
+	headers []string
+}
+
+// NewDKIMSigner signs with one key per domain and covers headers, which must
+// include From.
+func NewDKIMSigner(keys []DKIMKey, headers []string) (*DKIMSigner, error) {
+	if len(headers) == 0 {
+		headers = DefaultDKIMHeaders
+	}
+
+	signsFrom := false
+	for _, header := range headers {
+		if strings.EqualFold(header, "From") {
+			signsFrom = true
+		}
+	}
+	if !signsFrom {
+		return nil, errors.New("DKIM signed headers must include From")
+	}
+
+	signer := &DKIMSigner{keys: make(map[string]DKIMKey), headers: headers}
+	for _, key := range keys {
+		domain := strings.ToLower(key.Domain)
+		if domain == "" || key.Selector == "" || key.Signer == nil {
+			return nil, fmt.Errorf("DKIM key for %q needs a domain, a selector and a private key", key.Domain)
+		}
+		if _, ok := signer.keys[domain]; ok {
+			return nil, fmt.Errorf("more than one DKIM key for domain %q", key.Domain)
+		}
+		signer.keys[domain] = key
+	}
+
+	return signer, nil
+}
+
+// NewDKIMSignerFromEnv reads dkimKeys, a comma separated list of
+// domain:selector:path entries where path is a PEM private key file, and
+// dkimHeaders, a comma separated list of headers to sign. It returns nil
+// when dkimKeys is unset, which leaves messages unsigned.
+func NewDKIMSignerFromEnv() (*DKIMSigner, error) {
+	var keys []DKIMKey
+	for i, entry := range strings.Split(os.Getenv("dkimKeys"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("dkimKeys: entry %d is not a domain:selector:path triple", i+1)
+		}
+
+		key, err := LoadDKIMKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("dkimKeys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	var headers []string
+	for _, header := range strings.Split(os.Getenv("dkimHeaders"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+
+	return NewDKIMSigner(keys, headers)
+}
+
+// LoadDKIMKey reads an RSA or Ed25519 private key from a PEM file, in PKCS#1
+// or PKCS#8 form.
+func LoadDKIMKey(domain, selector, path string) (DKIMKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DKIMKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return DKIMKey{}, fmt.Errorf("%s: no PEM private key found", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return DKIMKey{}, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return DKIMKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var signer crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 1024 {
+			return DKIMKey{}, fmt.Errorf("%s: RSA keys must be at least 1024 bits", path)
+		}
+		signer = key
+	case ed25519.PrivateKey:
+		signer = key
+	default:
+		return DKIMKey{}, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	return DKIMKey{Domain: domain, Selector: selector, Signer: signer}, nil
+}
+
+// Sign returns message with a DKIM-Signature header for the domain of from,
+// or message itself when there is no key for that domain.
+func (s *DKIMSigner) Sign(from string, message []byte) ([]byte, error) {
+	key, ok := s.keys[senderDomain(from)]
+	if !ok {
+		return message, nil
+	}
+
+	var signed bytes.Buffer
+	err := dkim.Sign(&signed, bytes.NewReader(message), &dkim.SignOptions{
+		Domain:                 key.Domain,
+		Selector:               key.Selector,
+		Signer:                 key.Signer,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             s.headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signed.Bytes(), nil
+}
+
+func senderDomain(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		return strings.ToLower(from[at+1:])
+	}
+	return ""
+}
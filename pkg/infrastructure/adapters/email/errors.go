@@ -0,0 +1,78 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// enhancedStatus matches the enhanced status code (RFC 3463) that starts the
+// text of many SMTP replies, as in "550 5.1.1 No such user".
+var enhancedStatus = regexp.MustCompile(`^[245]\.\d{1,3}\.\d{1,3}\b`)
+
+// phaseError records the command an SMTP server refused.
+type phaseError struct {
+	phase domain.SendPhase
+	err   error
+}
+
+func (e *phaseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.phase, e.err)
+}
+
+func (e *phaseError) Unwrap() error {
+	return e.err
+}
+
+// ClassifySMTPError wraps an error returned while talking to an SMTP server in
+// a SendError. 5xx replies and invalid recipient addresses are permanent; 4xx
+// replies, timeouts, resets and anything unrecognised are transient. The
+// refused command and the enhanced status code of the reply are kept, as
+// only the recipient is to blame for a rejected RCPT TO.
+func ClassifySMTPError(err error) *domain.SendError {
+	if err == nil {
+		return nil
+	}
+
+	var sendErr *domain.SendError
+	if errors.As(err, &sendErr) {
+		return sendErr
+	}
+
+	var phase domain.SendPhase
+	var phaseErr *phaseError
+	if errors.As(err, &phaseErr) {
+		phase = phaseErr.phase
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return &domain.SendError{
+			Code:      protoErr.Code,
+			Status:    enhancedStatus.FindString(protoErr.Msg),
+			Phase:     phase,
+			Permanent: protoErr.Code >= 500,
+			Err:       err,
+		}
+	}
+
+	if strings.HasPrefix(err.Error(), "gomail: invalid address") {
+		return &domain.SendError{Phase: domain.SendPhaseRecipient, Permanent: true, Err: err}
+	}
+
+	// Timeouts, connection resets and anything else are worth another try.
+	return &domain.SendError{Phase: phase, Err: err}
+}
+
+// classifyDialError wraps errors raised before a message transaction starts.
+// Connection and authentication problems say nothing about the recipient, so
+// they are always treated as transient.
+func classifyDialError(err error) *domain.SendError {
+	sendErr := ClassifySMTPError(err)
+	sendErr.Permanent = false
+	return sendErr
+}
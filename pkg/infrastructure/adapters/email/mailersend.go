@@ -0,0 +1,138 @@
+package email
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const mailerSendBaseURL = "https://api.mailersend.com"
+
+// MailerSendEmailSender sends messages through the MailerSend email API.
+type MailerSendEmailSender struct {
+	from    string
+	apiKey  string
+	baseURL string
+	api     *apiClient
+}
+
+func NewMailerSendEmailSender(config APIConfig) *MailerSendEmailSender {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = mailerSendBaseURL
+	}
+
+	api := newAPIClient(ProviderMailerSend, config.Client)
+	api.parseError = parseMailerSendError
+
+	return &MailerSendEmailSender{
+		from:    config.From,
+		apiKey:  config.APIKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		api:     api,
+	}
+}
+
+type mailerSendAddress struct {
+	Email string `json:"email"`
+}
+
+type mailerSendHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type mailerSendAttachment struct {
+	Content     string `json:"content"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ID          string `json:"id,omitempty"`
+}
+
+type mailerSendEmail struct {
+	From        mailerSendAddress      `json:"from"`
+	To          []mailerSendAddress    `json:"to"`
+	Subject     string                 `json:"subject"`
+	HTML        string                 `json:"html"`
+	Text        string                 `json:"text,omitempty"`
+	Headers     []mailerSendHeader     `json:"headers,omitempty"`
+	Attachments []mailerSendAttachment `json:"attachments,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+}
+
+func (s *MailerSendEmailSender) Send(message domain.EmailMessage) error {
+	payload := mailerSendEmail{
+		From:    mailerSendAddress{Email: s.from},
+		Subject: message.Subject,
+		HTML:    message.Body,
+		Text:    message.TextBody,
+	}
+	for _, to := range message.To {
+		payload.To = append(payload.To, mailerSendAddress{Email: to})
+	}
+	for _, name := range sortedHeaderNames(message.Headers) {
+		payload.Headers = append(payload.Headers, mailerSendHeader{Name: name, Value: message.Headers[name]})
+	}
+	for _, attachment := range message.Attachments {
+		content, err := attachmentContent(attachment)
+		if err != nil {
+			return err
+		}
+		payload.Attachments = append(payload.Attachments, mailerSendAttachment{
+			Content:     content,
+			Filename:    attachment.Name,
+			Disposition: attachmentDisposition(attachment),
+			ID:          attachment.ContentID,
+		})
+	}
+
+	// MailerSend has no custom fields that come back in webhooks, only tags,
+	// so the metadata values are sent as tags.
+	for _, name := range sortedHeaderNames(message.Metadata) {
+		payload.Tags = append(payload.Tags, message.Metadata[name])
+	}
+
+	req, _, err := newJSONRequest(s.baseURL+"/v1/email", payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	return s.api.do(req)
+}
+
+// parseMailerSendError reads {"message": "...", "errors": {"field": ["..."]}}.
+func parseMailerSendError(providerErr *ProviderError, _ http.Header, body []byte) {
+	var response struct {
+		Message string              `json:"message"`
+		Errors  map[string][]string `json:"errors"`
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return
+	}
+
+	messages := []string{response.Message}
+	fields := make([]string, 0, len(response.Errors))
+	for field := range response.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		messages = append(messages, field+": "+strings.Join(response.Errors[field], ", "))
+	}
+	providerErr.Message = strings.TrimPrefix(strings.Join(messages, "; "), "; ")
+}
+
+// sortedHeaderNames returns the names of headers in a stable order, so that
+// requests do not change with map iteration.
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,370 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+)
+
+var _ ports.OutboxRepositoryPort = (*OutboxEmailSender)(nil)
+
+// Outbox formats selectable with outboxFormat.
+const (
+	OutboxEML     = "eml"
+	OutboxMbox    = "mbox"
+	OutboxMaildir = "maildir"
+)
+
+// OutboxEmailSender writes messages to local files instead of delivering
+// them, so that full sends can run in development and staging without
+// emailing anyone. Messages are kept as .eml files in a directory, appended
+// to an mbox file or stored in a Maildir, and can be read back for previews.
+type OutboxEmailSender struct {
+	from  string
+	store outboxStore
+}
+
+// outboxStore keeps raw RFC 5322 messages. IDs are listed oldest first.
+type outboxStore interface {
+	write(from string, raw []byte) error
+	list() ([]string, error)
+	// read returns nil when there is no message with that ID.
+	read(id string) ([]byte, error)
+}
+
+// NewOutboxEmailSender stores messages sent from the given address at path,
+// a directory for the eml and maildir formats and a file for mbox. Missing
+// directories are created.
+func NewOutboxEmailSender(from, format, path string) (*OutboxEmailSender, error) {
+	if path == "" {
+		return nil, errors.New("outbox: outboxPath is required")
+	}
+
+	var store outboxStore
+	var err error
+	switch strings.ToLower(format) {
+	case "", OutboxEML:
+		store, err = newEMLStore(path)
+	case OutboxMbox:
+		store, err = newMboxStore(path)
+	case OutboxMaildir:
+		store, err = newMaildirStore(path)
+	default:
+		return nil, fmt.Errorf("outbox: outboxFormat %q must be one of eml, mbox or maildir", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+
+	return &OutboxEmailSender{from: from, store: store}, nil
+}
+
+// NewOutboxEmailSenderFromEnv reads emailSender, outboxFormat (defaults to
+// eml) and outboxPath.
+func NewOutboxEmailSenderFromEnv() (*OutboxEmailSender, error) {
+	from := os.Getenv("emailSender")
+	if from == "" {
+		return nil, errors.New("outbox: emailSender is required")
+	}
+	return NewOutboxEmailSender(from, os.Getenv("outboxFormat"), os.Getenv("outboxPath"))
+}
+
+func (s *OutboxEmailSender) Send(message domain.EmailMessage) error {
+	raw, err := buildMIME(s.from, message)
+	if err != nil {
+		return err
+	}
+	return s.store.write(s.from, raw)
+}
+
+func (s *OutboxEmailSender) ListOutboxMessages() ([]string, error) {
+	return s.store.list()
+}
+
+func (s *OutboxEmailSender) GetOutboxMessage(id string) ([]byte, error) {
+	return s.store.read(id)
+}
+
+// isPlainFileName rejects IDs that would reach outside the outbox directory.
+func isPlainFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// uniqueName returns a name that sorts by creation time.
+func uniqueName() string {
+	random := make([]byte, 4)
+	rand.Read(random)
+	return time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(random)
+}
+
+// writeFileAtomically writes data to a temporary file in tmpDir and renames
+// it to path, so readers never see a partial message.
+func writeFileAtomically(tmpDir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(tmpDir, ".outbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// emlStore keeps every message in its own .eml file.
+type emlStore struct {
+	dir string
+}
+
+func newEMLStore(dir string) (*emlStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &emlStore{dir: dir}, nil
+}
+
+func (s *emlStore) write(_ string, raw []byte) error {
+	return writeFileAtomically(s.dir, filepath.Join(s.dir, uniqueName()+".eml"), raw)
+}
+
+func (s *emlStore) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".eml") {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *emlStore) read(id string) ([]byte, error) {
+	if !isPlainFileName(id) || !strings.HasSuffix(id, ".eml") {
+		return nil, nil
+	}
+	return readIfExists(filepath.Join(s.dir, id))
+}
+
+func readIfExists(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// maildirStore delivers messages into the new/ folder of a Maildir, through
+// tmp/ as the format requires. Messages moved to cur/ by a mail client are
+// still listed.
+type maildirStore struct {
+	dir string
+}
+
+func newMaildirStore(dir string) (*maildirStore, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &maildirStore{dir: dir}, nil
+}
+
+func (s *maildirStore) write(_ string, raw []byte) error {
+	hostname, _ := os.Hostname()
+	hostname = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(hostname)
+	name := fmt.Sprintf("%s.P%d.%s", uniqueName(), os.Getpid(), hostname)
+	return writeFileAtomically(filepath.Join(s.dir, "tmp"), filepath.Join(s.dir, "new", name), raw)
+}
+
+func (s *maildirStore) list() ([]string, error) {
+	var ids []string
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				ids = append(ids, entry.Name())
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *maildirStore) read(id string) ([]byte, error) {
+	if !isPlainFileName(id) {
+		return nil, nil
+	}
+	for _, sub := range []string{"new", "cur"} {
+		data, err := readIfExists(filepath.Join(s.dir, sub, id))
+		if data != nil || err != nil {
+			return data, err
+		}
+	}
+	return nil, nil
+}
+
+// mboxStore appends messages to an mbox file in the mboxrd variant: each
+// message starts with a "From " line, and lines of the message that look
+// like one are quoted with ">". IDs are the 1-based position in the file.
+type mboxStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+func newMboxStore(path string) (*mboxStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	return &mboxStore{path: path}, nil
+}
+
+func (s *mboxStore) write(from string, raw []byte) error {
+	sender := senderAddress(from)
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+
+	body := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	body = mboxFromLine.ReplaceAll(body, []byte(">$1"))
+
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "From %s %s\n", sender, time.Now().UTC().Format(time.ANSIC))
+	entry.Write(body)
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		entry.WriteByte('\n')
+	}
+	entry.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(entry.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *mboxStore) list() ([]string, error) {
+	messages, err := s.messages()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = strconv.Itoa(i + 1)
+	}
+	return ids, nil
+}
+
+func (s *mboxStore) read(id string) ([]byte, error) {
+	position, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	messages, err := s.messages()
+	if err != nil || position < 1 || position > len(messages) {
+		return nil, err
+	}
+	return messages[position-1], nil
+}
+
+// messages splits the mbox into messages, removing the "From " lines and one
+// level of ">" quoting.
+func (s *mboxStore) messages() ([][]byte, error) {
+	s.mu.Lock()
+	data, err := os.ReadFile(s.path)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var messages [][]byte
+	var current *bytes.Buffer
+	blankBefore := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if blankBefore && strings.HasPrefix(line, "From ") {
+			if current != nil {
+				messages = append(messages, trimMboxSeparator(current.Bytes()))
+			}
+			current = &bytes.Buffer{}
+			blankBefore = false
+			continue
+		}
+		blankBefore = line == ""
+
+		if current == nil {
+			continue
+		}
+		if quoted := strings.TrimLeft(line, ">"); len(quoted) < len(line) && strings.HasPrefix(quoted, "From ") {
+			line = line[1:]
+		}
+		current.WriteString(line)
+		current.WriteString("\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		messages = append(messages, trimMboxSeparator(current.Bytes()))
+	}
+	return messages, nil
+}
+
+// trimMboxSeparator drops the blank line that separates a message from the
+// next "From " line.
+func trimMboxSeparator(message []byte) []byte {
+	return bytes.TrimSuffix(message, []byte("\r\n"))
+}
+
+func senderAddress(from string) string {
+	if address, err := mail.ParseAddress(from); err == nil {
+		return address.Address
+	}
+	return from
+}
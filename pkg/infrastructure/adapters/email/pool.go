@@ -0,0 +1,155 @@
+package email
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	defaultPoolSize              = 4
+	defaultMessagesPerConnection = 100
+	defaultPoolIdleTimeout       = 30 * time.Second
+)
+
+// Dialer opens authenticated connections to an SMTP server. *SMTPDialer and
+// *gomail.Dialer implement it.
+type Dialer interface {
+	Dial() (gomail.SendCloser, error)
+}
+
+// PoolConfig bounds the SMTP connections kept by a pooled sender.
+type PoolConfig struct {
+	// Size is the maximum number of connections open at the same time.
+	Size int
+	// MessagesPerConnection is how many messages are sent over a connection
+	// before it is closed and replaced, since servers often cap it.
+	MessagesPerConnection int
+	// IdleTimeout is how long an unused connection is kept. Servers drop idle
+	// clients, so older connections are treated as stale and redialed.
+	IdleTimeout time.Duration
+}
+
+// NewPoolConfigFromEnv reads smtpPoolSize, smtpMessagesPerConnection and
+// smtpPoolIdleTimeout, using the defaults for values that are unset or invalid.
+func NewPoolConfigFromEnv() PoolConfig {
+	config := PoolConfig{
+		Size:                  defaultPoolSize,
+		MessagesPerConnection: defaultMessagesPerConnection,
+		IdleTimeout:           defaultPoolIdleTimeout,
+	}
+
+	if size, err := strconv.Atoi(os.Getenv("smtpPoolSize")); err == nil && size > 0 {
+		config.Size = size
+	}
+	if perConnection, err := strconv.Atoi(os.Getenv("smtpMessagesPerConnection")); err == nil && perConnection > 0 {
+		config.MessagesPerConnection = perConnection
+	}
+	if idleTimeout, err := time.ParseDuration(os.Getenv("smtpPoolIdleTimeout")); err == nil && idleTimeout > 0 {
+		config.IdleTimeout = idleTimeout
+	}
+
+	return config
+}
+
+type pooledConnection struct {
+	sender   gomail.SendCloser
+	sent     int
+	lastUsed time.Time
+}
+
+// connectionPool hands out SMTP connections, dialing new ones while fewer than
+// Size are open and blocking callers once the limit is reached.
+type connectionPool struct {
+	dialer Dialer
+	config PoolConfig
+	slots  chan struct{}
+	idle   chan *pooledConnection
+}
+
+func newConnectionPool(dialer Dialer, config PoolConfig) *connectionPool {
+	if config.Size <= 0 {
+		config.Size = defaultPoolSize
+	}
+	if config.MessagesPerConnection <= 0 {
+		config.MessagesPerConnection = defaultMessagesPerConnection
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultPoolIdleTimeout
+	}
+
+	return &connectionPool{
+		dialer: dialer,
+		config: config,
+		slots:  make(chan struct{}, config.Size),
+		idle:   make(chan *pooledConnection, config.Size),
+	}
+}
+
+// get returns an idle connection that is still fresh, or dials a new one.
+func (p *connectionPool) get() (*pooledConnection, error) {
+	for {
+		// Prefer an idle connection over dialing a new one.
+		select {
+		case conn := <-p.idle:
+			if p.isFresh(conn) {
+				return conn, nil
+			}
+			p.discard(conn)
+			continue
+		default:
+		}
+
+		select {
+		case conn := <-p.idle:
+			if p.isFresh(conn) {
+				return conn, nil
+			}
+			p.discard(conn)
+		case p.slots <- struct{}{}:
+			sender, err := p.dialer.Dial()
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			return &pooledConnection{sender: sender}, nil
+		}
+	}
+}
+
+func (p *connectionPool) isFresh(conn *pooledConnection) bool {
+	return time.Since(conn.lastUsed) < p.config.IdleTimeout
+}
+
+// put returns a connection after a successful send, closing it instead once
+// it has carried MessagesPerConnection messages.
+func (p *connectionPool) put(conn *pooledConnection) {
+	conn.sent++
+	conn.lastUsed = time.Now()
+	if conn.sent >= p.config.MessagesPerConnection {
+		p.discard(conn)
+		return
+	}
+	p.idle <- conn
+}
+
+// discard closes a connection and frees its slot. It is used after any send
+// error, since the SMTP session may be left in the middle of a transaction.
+func (p *connectionPool) discard(conn *pooledConnection) {
+	conn.sender.Close()
+	<-p.slots
+}
+
+// close closes every idle connection.
+func (p *connectionPool) close() {
+	for {
+		select {
+		case conn := <-p.idle:
+			p.discard(conn)
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package email
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const (
+	postmarkBaseURL              = "https://api.postmarkapp.com"
+	defaultPostmarkMessageStream = "broadcasts"
+)
+
+// postmarkTransientCodes are Postmark error codes sent with 422 that concern
+// the account or server rather than the message, such as a missing or
+// unconfirmed sender signature or an account that is not yet approved.
+var postmarkTransientCodes = map[int]bool{
+	10:  true, // Bad or missing API token
+	400: true, // Sender signature not found
+	401: true, // Sender signature not confirmed
+	405: true, // Not allowed to send
+	412: true, // Account pending approval
+}
+
+// PostmarkEmailSender sends messages through the Postmark email API.
+type PostmarkEmailSender struct {
+	from          string
+	serverToken   string
+	baseURL       string
+	messageStream string
+	api           *apiClient
+}
+
+// NewPostmarkEmailSender sends with the server token in config.APIKey through
+// the given message stream (defaults to "broadcasts", the stream Postmark
+// creates for newsletters).
+func NewPostmarkEmailSender(config APIConfig, messageStream string) *PostmarkEmailSender {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = postmarkBaseURL
+	}
+	if messageStream == "" {
+		messageStream = defaultPostmarkMessageStream
+	}
+
+	api := newAPIClient(ProviderPostmark, config.Client)
+	api.parseError = parsePostmarkError
+	api.classify = classifyPostmarkError
+
+	return &PostmarkEmailSender{
+		from:          config.From,
+		serverToken:   config.APIKey,
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		messageStream: messageStream,
+		api:           api,
+	}
+}
+
+type postmarkHeader struct {
+	Name  string
+	Value string
+}
+
+type postmarkAttachment struct {
+	Name        string
+	Content     string
+	ContentType string
+	ContentID   string `json:",omitempty"`
+}
+
+type postmarkEmail struct {
+	From          string
+	To            string
+	Subject       string
+	HtmlBody      string
+	TextBody      string `json:",omitempty"`
+	Headers       []postmarkHeader
+	Attachments   []postmarkAttachment
+	MessageStream string
+	Metadata      map[string]string `json:",omitempty"`
+}
+
+func (s *PostmarkEmailSender) Send(message domain.EmailMessage) error {
+	payload := postmarkEmail{
+		From:          s.from,
+		To:            strings.Join(message.To, ", "),
+		Subject:       message.Subject,
+		HtmlBody:      message.Body,
+		TextBody:      message.TextBody,
+		Headers:       []postmarkHeader{},
+		Attachments:   []postmarkAttachment{},
+		MessageStream: s.messageStream,
+		Metadata:      message.Metadata,
+	}
+	for _, name := range sortedHeaderNames(message.Headers) {
+		payload.Headers = append(payload.Headers, postmarkHeader{Name: name, Value: message.Headers[name]})
+	}
+	for _, attachment := range message.Attachments {
+		content, err := attachmentContent(attachment)
+		if err != nil {
+			return err
+		}
+		part := postmarkAttachment{
+			Name:        attachment.Name,
+			Content:     content,
+			ContentType: attachmentType(attachment),
+		}
+		if attachment.ContentID != "" {
+			part.ContentID = "cid:" + attachment.ContentID
+		}
+		payload.Attachments = append(payload.Attachments, part)
+	}
+
+	req, _, err := newJSONRequest(s.baseURL+"/email", payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Postmark-Server-Token", s.serverToken)
+
+	return s.api.do(req)
+}
+
+// parsePostmarkError reads {"ErrorCode": 300, "Message": "..."}.
+func parsePostmarkError(providerErr *ProviderError, _ http.Header, body []byte) {
+	var response struct {
+		ErrorCode int
+		Message   string
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return
+	}
+
+	if response.ErrorCode != 0 {
+		providerErr.Code = strconv.Itoa(response.ErrorCode)
+	}
+	providerErr.Message = response.Message
+}
+
+// classifyPostmarkError treats 422 responses about the account or sender
+// signature as transient, since Postmark reports them with the same status as
+// invalid messages.
+func classifyPostmarkError(providerErr *ProviderError) *domain.SendError {
+	sendErr := ClassifyProviderError(providerErr)
+	if code, err := strconv.Atoi(providerErr.Code); err == nil && postmarkTransientCodes[code] {
+		sendErr.Permanent = false
+	}
+	return sendErr
+}
@@ -0,0 +1,202 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const (
+	defaultProviderTimeout = 30 * time.Second
+	// maxErrorBody bounds how much of an error response is read.
+	maxErrorBody = 64 << 10
+)
+
+// APIConfig configures an email provider reached over its HTTP API.
+type APIConfig struct {
+	// From is the sender address, which must be verified with the provider.
+	From   string
+	APIKey string
+	// BaseURL replaces the provider's API endpoint, for example for a
+	// regional endpoint or a stand-in in tests.
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewAPIConfigFromEnv reads emailSender, emailProviderApiKey,
+// emailProviderBaseUrl and emailProviderTimeout (defaults to 30s) for the
+// given provider.
+func NewAPIConfigFromEnv(provider string) (APIConfig, error) {
+	config := APIConfig{
+		From:    os.Getenv("emailSender"),
+		APIKey:  os.Getenv("emailProviderApiKey"),
+		BaseURL: os.Getenv("emailProviderBaseUrl"),
+	}
+	if config.From == "" {
+		return APIConfig{}, fmt.Errorf("%s: emailSender is required", provider)
+	}
+	if config.APIKey == "" {
+		return APIConfig{}, fmt.Errorf("%s: emailProviderApiKey is required", provider)
+	}
+
+	client, err := newProviderClientFromEnv(provider)
+	if err != nil {
+		return APIConfig{}, err
+	}
+	config.Client = client
+	return config, nil
+}
+
+func newProviderClientFromEnv(provider string) (*http.Client, error) {
+	timeout := defaultProviderTimeout
+	if raw := os.Getenv("emailProviderTimeout"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("%s: emailProviderTimeout %q is not a positive duration", provider, raw)
+		}
+		timeout = parsed
+	}
+	return &http.Client{Timeout: timeout}, nil
+}
+
+// ProviderError is an error response of an email provider's HTTP API. Code is
+// the provider's own error code, when it sends one.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	description := http.StatusText(e.StatusCode)
+	if e.Code != "" {
+		description = e.Code
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %d %s", e.Provider, e.StatusCode, description)
+	}
+	return fmt.Sprintf("%s: %d %s: %s", e.Provider, e.StatusCode, description, e.Message)
+}
+
+// ClassifyProviderError wraps a provider error response in a SendError. Like
+// 5xx SMTP replies, 4xx responses reject the message itself and are
+// permanent, except for timeouts, rate limiting and rejected credentials,
+// which say nothing about the message and are worth retrying. 5xx responses
+// are transient.
+func ClassifyProviderError(err *ProviderError) *domain.SendError {
+	permanent := err.StatusCode >= 400 && err.StatusCode < 500
+	switch err.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		permanent = false
+	}
+	return &domain.SendError{Code: err.StatusCode, Permanent: permanent, Err: err}
+}
+
+// apiClient posts JSON to a provider API and turns failed responses into
+// SendErrors.
+type apiClient struct {
+	provider string
+	client   *http.Client
+	// parseError fills the code and message of a ProviderError from an error
+	// response body.
+	parseError func(providerErr *ProviderError, header http.Header, body []byte)
+	// classify overrides ClassifyProviderError when set.
+	classify func(providerErr *ProviderError) *domain.SendError
+}
+
+func newAPIClient(provider string, client *http.Client) *apiClient {
+	if client == nil {
+		client = &http.Client{Timeout: defaultProviderTimeout}
+	}
+	return &apiClient{provider: provider, client: client}
+}
+
+// newJSONRequest returns a POST request with payload as its JSON body, and
+// the body itself for request signing.
+func newJSONRequest(url string, payload interface{}) (*http.Request, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req, body, nil
+}
+
+// do sends req and returns nil for a 2xx response. Network failures are
+// transient SendErrors; error responses are classified.
+func (c *apiClient) do(req *http.Request) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return &domain.SendError{Err: fmt.Errorf("%s: %w", c.provider, err)}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	providerErr := &ProviderError{Provider: c.provider, StatusCode: resp.StatusCode}
+	if c.parseError != nil {
+		c.parseError(providerErr, resp.Header, body)
+	}
+	if providerErr.Message == "" && !json.Valid(body) {
+		providerErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if c.classify != nil {
+		return c.classify(providerErr)
+	}
+	return ClassifyProviderError(providerErr)
+}
+
+// attachmentContent reads an attachment and returns it in the standard
+// base64 encoding providers expect in their JSON payloads.
+func attachmentContent(attachment *domain.Attachment) (string, error) {
+	content, err := attachment.Open()
+	if err != nil {
+		return "", fmt.Errorf("attachment %q: %w", attachment.Name, err)
+	}
+	defer content.Close()
+
+	var encoded strings.Builder
+	encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+	if _, err := io.Copy(encoder, content); err != nil {
+		return "", fmt.Errorf("attachment %q: %w", attachment.Name, err)
+	}
+	encoder.Close()
+	return encoded.String(), nil
+}
+
+// attachmentType returns the content type of an attachment, or
+// application/octet-stream when it is not known.
+func attachmentType(attachment *domain.Attachment) string {
+	if attachment.Type == "" {
+		return "application/octet-stream"
+	}
+	return attachment.Type
+}
+
+// attachmentDisposition returns inline for attachments the content refers to
+// by Content-ID, and attachment otherwise.
+func attachmentDisposition(attachment *domain.Attachment) string {
+	if attachment.ContentID != "" {
+		return "inline"
+	}
+	return "attachment"
+}
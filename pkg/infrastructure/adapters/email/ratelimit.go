@@ -0,0 +1,206 @@
+package email
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+)
+
+// rateLimitStoreRetryDelay is how long sending pauses when the shared
+// budgets cannot be read.
+const rateLimitStoreRetryDelay = time.Minute
+
+// RateLimits are the sending budgets allowed by the SMTP provider. A zero
+// value means there is no limit for that window.
+type RateLimits struct {
+	PerSecond int
+	PerMinute int
+	PerHour   int
+	PerDay    int
+}
+
+// NewRateLimitsFromEnv reads smtpRatePerSecond, smtpRatePerMinute,
+// smtpRatePerHour and smtpRatePerDay.
+func NewRateLimitsFromEnv() RateLimits {
+	limit := func(key string) int {
+		value, err := strconv.Atoi(os.Getenv(key))
+		if err != nil || value < 0 {
+			return 0
+		}
+		return value
+	}
+
+	return RateLimits{
+		PerSecond: limit("smtpRatePerSecond"),
+		PerMinute: limit("smtpRatePerMinute"),
+		PerHour:   limit("smtpRatePerHour"),
+		PerDay:    limit("smtpRatePerDay"),
+	}
+}
+
+// rateWindow counts the messages sent in a fixed window aligned to UTC, so
+// the daily budget renews at midnight UTC.
+type rateWindow struct {
+	name  string
+	size  time.Duration
+	limit int
+	start time.Time
+	count int
+}
+
+// key names the current window in a RateLimitRepositoryPort, such as
+// hour:2024-03-01T10:00:00Z.
+func (w *rateWindow) key() string {
+	return w.name + ":" + w.start.UTC().Format(time.RFC3339)
+}
+
+func (w *rateWindow) roll(now time.Time) {
+	if start := now.Truncate(w.size); !start.Equal(w.start) {
+		w.start = start
+		w.count = 0
+	}
+}
+
+func (w *rateWindow) exhausted() bool {
+	return w.count >= w.limit
+}
+
+func (w *rateWindow) end() time.Time {
+	return w.start.Add(w.size)
+}
+
+// RateLimitedEmailSender keeps another EmailSender within the provider's
+// budgets. When the per-second or per-minute budget is used up, Send waits
+// for the next window. Hourly and daily windows are too long to wait for, so
+// Send returns a RateLimitError telling the caller when to resume.
+//
+// Per-second and per-minute budgets are counted in memory, per process. The
+// hourly and daily budgets are counted in the store when one is given, so
+// they hold across restarts and across every instance sending through the
+// same provider, and in memory otherwise.
+type RateLimitedEmailSender struct {
+	sender      EmailSender
+	store       ports.RateLimitRepositoryPort
+	mu          sync.Mutex
+	waitWindows []*rateWindow
+	hardWindows []*rateWindow
+}
+
+// NewRateLimitedEmailSender returns a sender that keeps sender within limits.
+// store may be nil to count every budget in memory.
+func NewRateLimitedEmailSender(sender EmailSender, limits RateLimits, store ports.RateLimitRepositoryPort) EmailSender {
+	s := &RateLimitedEmailSender{sender: sender, store: store}
+
+	add := func(windows []*rateWindow, name string, size time.Duration, limit int) []*rateWindow {
+		if limit <= 0 {
+			return windows
+		}
+		return append(windows, &rateWindow{name: name, size: size, limit: limit})
+	}
+	s.waitWindows = add(s.waitWindows, "second", time.Second, limits.PerSecond)
+	s.waitWindows = add(s.waitWindows, "minute", time.Minute, limits.PerMinute)
+	s.hardWindows = add(s.hardWindows, "hour", time.Hour, limits.PerHour)
+	s.hardWindows = add(s.hardWindows, "day", 24*time.Hour, limits.PerDay)
+
+	return s
+}
+
+func (s *RateLimitedEmailSender) Send(message domain.EmailMessage) error {
+	if err := s.reserve(); err != nil {
+		return err
+	}
+	return s.sender.Send(message)
+}
+
+// reserve counts the message in the hourly and daily windows, then blocks
+// until the shorter windows allow it and counts it there too.
+func (s *RateLimitedEmailSender) reserve() error {
+	if err := s.reserveHard(); err != nil {
+		return err
+	}
+
+	for {
+		s.mu.Lock()
+		now := time.Now()
+
+		var wait time.Duration
+		for _, w := range s.waitWindows {
+			w.roll(now)
+			if w.exhausted() && w.end().Sub(now) > wait {
+				wait = w.end().Sub(now)
+			}
+		}
+		if wait == 0 {
+			for _, w := range s.waitWindows {
+				w.count++
+			}
+			s.mu.Unlock()
+			return nil
+		}
+
+		s.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// reserveHard counts the message in the hourly and daily windows, or returns
+// a RateLimitError resuming when the later of the used up windows ends.
+// Sending also pauses for a while when the store cannot be reached, since
+// the budgets left are then unknown.
+func (s *RateLimitedEmailSender) reserveHard() error {
+	if s.store == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		now := time.Now()
+		var resumeAt time.Time
+		for _, w := range s.hardWindows {
+			w.roll(now)
+			if w.exhausted() && w.end().After(resumeAt) {
+				resumeAt = w.end()
+			}
+		}
+		if !resumeAt.IsZero() {
+			return &domain.RateLimitError{ResumeAt: resumeAt}
+		}
+		for _, w := range s.hardWindows {
+			w.count++
+		}
+		return nil
+	}
+
+	now := time.Now()
+	var taken []rateWindow
+	var resumeAt time.Time
+	for _, hard := range s.hardWindows {
+		w := rateWindow{name: hard.name, size: hard.size, limit: hard.limit}
+		w.roll(now)
+
+		ok, err := s.store.TakeRateLimit(w.key(), w.limit, w.end())
+		if err != nil {
+			fmt.Printf("Error counting the %s sending budget: %s\n", w.name, err.Error())
+			resumeAt = now.Add(rateLimitStoreRetryDelay)
+			break
+		}
+		if ok {
+			taken = append(taken, w)
+		} else if w.end().After(resumeAt) {
+			resumeAt = w.end()
+		}
+	}
+	if resumeAt.IsZero() {
+		return nil
+	}
+
+	for _, w := range taken {
+		if err := s.store.ReleaseRateLimit(w.key()); err != nil {
+			fmt.Printf("Error releasing the %s sending budget: %s\n", w.name, err.Error())
+		}
+	}
+	return &domain.RateLimitError{ResumeAt: resumeAt}
+}
@@ -0,0 +1,99 @@
+package email
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy controls how often and how long a failed send is retried.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// NewRetryPolicyFromEnv reads smtpMaxAttempts, smtpRetryBaseDelay and
+// smtpRetryMaxDelay, using the defaults for values that are unset or invalid.
+func NewRetryPolicyFromEnv() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: defaultMaxAttempts,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+	}
+
+	if maxAttempts, err := strconv.Atoi(os.Getenv("smtpMaxAttempts")); err == nil && maxAttempts > 0 {
+		policy.MaxAttempts = maxAttempts
+	}
+	if baseDelay, err := time.ParseDuration(os.Getenv("smtpRetryBaseDelay")); err == nil && baseDelay > 0 {
+		policy.BaseDelay = baseDelay
+	}
+	if maxDelay, err := time.ParseDuration(os.Getenv("smtpRetryMaxDelay")); err == nil && maxDelay > 0 {
+		policy.MaxDelay = maxDelay
+	}
+
+	return policy
+}
+
+// Backoff returns the wait before the retry that follows the given attempt.
+// The delay doubles with every attempt up to MaxDelay, and a random "full
+// jitter" is applied so that workers failing together do not retry together.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 {
+		if exponential := p.BaseDelay << uint(attempt-1); exponential > 0 && exponential < p.MaxDelay {
+			delay = exponential
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// RetryingEmailSender retries transient failures of another EmailSender.
+// Permanent failures are returned straight away, and so are rate limit errors,
+// which carry their own resume time.
+type RetryingEmailSender struct {
+	sender EmailSender
+	policy RetryPolicy
+}
+
+func NewRetryingEmailSender(sender EmailSender, policy RetryPolicy) EmailSender {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 1
+	}
+	return &RetryingEmailSender{sender: sender, policy: policy}
+}
+
+func (s *RetryingEmailSender) Send(message domain.EmailMessage) error {
+	for attempt := 1; ; attempt++ {
+		err := s.sender.Send(message)
+		if err == nil {
+			return nil
+		}
+
+		var rateLimitErr *domain.RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			return err
+		}
+
+		sendErr := ClassifySMTPError(err)
+		sendErr.Attempts = attempt
+		if sendErr.Permanent || attempt >= s.policy.MaxAttempts {
+			return sendErr
+		}
+
+		time.Sleep(s.policy.Backoff(attempt))
+	}
+}
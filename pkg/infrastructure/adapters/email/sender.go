@@ -1,11 +1,11 @@
 package email
 
 import (
-	"crypto/tls"
-	"encoding/base64"
+	"bytes"
+	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"strings"
 
 	domain "newsletter-app/pkg/domain/models"
 
@@ -13,46 +13,105 @@ import (
 )
 
 type EmailSender interface {
-	Send(subject, body string, to []string, attachments []*domain.Attachment) error
+	Send(message domain.EmailMessage) error
 }
 
-type MailerSendEmailSender struct{}
+// Email providers selectable with emailProvider.
+const (
+	ProviderSMTP       = "smtp"
+	ProviderMailerSend = "mailersend"
+	ProviderSendGrid   = "sendgrid"
+	ProviderSES        = "ses"
+	ProviderPostmark   = "postmark"
+	ProviderOutbox     = "outbox"
+)
 
-func NewMailerSendEmailSender() EmailSender {
-	return &MailerSendEmailSender{}
+// NewEmailSenderFromEnv returns the sender of the provider named by
+// emailProvider, SMTP when it is unset. The provider's settings are read and
+// checked here, so a misconfigured provider fails at startup.
+func NewEmailSenderFromEnv() (EmailSender, error) {
+	provider := strings.ToLower(os.Getenv("emailProvider"))
+	switch provider {
+	case "", ProviderSMTP:
+		return NewSMTPEmailSender()
+	case ProviderMailerSend:
+		config, err := NewAPIConfigFromEnv(provider)
+		if err != nil {
+			return nil, err
+		}
+		return NewMailerSendEmailSender(config), nil
+	case ProviderSendGrid:
+		config, err := NewAPIConfigFromEnv(provider)
+		if err != nil {
+			return nil, err
+		}
+		return NewSendGridEmailSender(config), nil
+	case ProviderPostmark:
+		config, err := NewAPIConfigFromEnv(provider)
+		if err != nil {
+			return nil, err
+		}
+		return NewPostmarkEmailSender(config, os.Getenv("postmarkMessageStream")), nil
+	case ProviderSES:
+		config, err := NewSESConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewSESEmailSender(config), nil
+	case ProviderOutbox:
+		return NewOutboxEmailSenderFromEnv()
+	}
+	return nil, fmt.Errorf("emailProvider %q must be one of smtp, mailersend, sendgrid, ses, postmark or outbox", provider)
 }
 
-func (m *MailerSendEmailSender) Send(subject, body string, to []string, attachments []*domain.Attachment) error {
-	emailSender := os.Getenv("emailSender")
-	emailPass := os.Getenv("emailPass")
-	smtpServer := os.Getenv("smtpServer")
-	smtpPort := os.Getenv("smtpPort")
-
-	smtpPortInt, err := strconv.Atoi(smtpPort)
-	if err != nil {
-		return err
+// buildMIME renders message as a MIME document sent from the given address.
+// When TextBody is set it is multipart/alternative with the HTML part last,
+// since the last alternative is the preferred one. Attachments with a
+// ContentID are embedded in a multipart/related part next to the HTML. The
+// content of attachments is streamed into the message as it is written.
+func buildMIME(from string, message domain.EmailMessage) ([]byte, error) {
+	mailer := gomail.NewMessage()
+	for name, value := range message.Headers {
+		mailer.SetHeader(name, value)
+	}
+	mailer.SetHeader("From", from)
+	// SetHeader encodes the values in place, and the message may be shared
+	// by callers sending it from several goroutines at once.
+	mailer.SetHeader("To", append([]string(nil), message.To...)...)
+	mailer.SetHeader("Subject", message.Subject)
+	if message.TextBody != "" {
+		mailer.SetBody("text/plain", message.TextBody)
+		mailer.AddAlternative("text/html", message.Body)
+	} else {
+		mailer.SetBody("text/html", message.Body)
 	}
 
-	d := gomail.NewDialer(smtpServer, smtpPortInt, emailSender, emailPass)
-	d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	for _, attachment := range message.Attachments {
+		attachment := attachment
+		copyData := gomail.SetCopyFunc(func(w io.Writer) error {
+			content, err := attachment.Open()
+			if err != nil {
+				return fmt.Errorf("attachment %q: %w", attachment.Name, err)
+			}
+			defer content.Close()
 
-	mailer := gomail.NewMessage()
-	mailer.SetHeader("From", emailSender)
-	mailer.SetHeader("To", to...)
-	mailer.SetHeader("Subject", subject)
-	mailer.SetBody("text/html", body)
-
-	for _, attachment := range attachments {
-		data, err := base64.StdEncoding.DecodeString(attachment.Data)
-		if err != nil {
-			return err
+			if _, err := io.Copy(w, content); err != nil {
+				return fmt.Errorf("attachment %q: %w", attachment.Name, err)
+			}
+			return nil
+		})
+		if attachment.ContentID != "" {
+			mailer.Embed(attachment.Name, copyData, gomail.SetHeader(map[string][]string{
+				"Content-ID": {"<" + attachment.ContentID + ">"},
+			}))
+			continue
 		}
-
-		mailer.Attach(attachment.Name, gomail.SetCopyFunc(func(w io.Writer) error {
-			_, err := w.Write(data)
-			return err
-		}))
+		mailer.Attach(attachment.Name, copyData)
 	}
 
-	return d.DialAndSend(mailer)
+	var raw bytes.Buffer
+	if _, err := mailer.WriteTo(&raw); err != nil {
+		return nil, err
+	}
+	return raw.Bytes(), nil
 }
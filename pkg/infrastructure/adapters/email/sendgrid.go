@@ -0,0 +1,134 @@
+package email
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const sendGridBaseURL = "https://api.sendgrid.com"
+
+// SendGridEmailSender sends messages through the SendGrid v3 Mail Send API.
+type SendGridEmailSender struct {
+	from    string
+	apiKey  string
+	baseURL string
+	api     *apiClient
+}
+
+func NewSendGridEmailSender(config APIConfig) *SendGridEmailSender {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = sendGridBaseURL
+	}
+
+	api := newAPIClient(ProviderSendGrid, config.Client)
+	api.parseError = parseSendGridError
+
+	return &SendGridEmailSender{
+		from:    config.From,
+		apiKey:  config.APIKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		api:     api,
+	}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	CustomArgs       map[string]string         `json:"custom_args,omitempty"`
+}
+
+func (s *SendGridEmailSender) Send(message domain.EmailMessage) error {
+	// All recipients share one personalization, as they share the To header
+	// of an SMTP message.
+	personalization := sendGridPersonalization{}
+	for _, to := range message.To {
+		personalization.To = append(personalization.To, sendGridAddress{Email: to})
+	}
+
+	payload := sendGridMail{
+		Personalizations: []sendGridPersonalization{personalization},
+		From:             sendGridAddress{Email: s.from},
+		Subject:          message.Subject,
+		Headers:          message.Headers,
+		CustomArgs:       message.Metadata,
+	}
+	// SendGrid requires text/plain to come before text/html.
+	if message.TextBody != "" {
+		payload.Content = append(payload.Content, sendGridContent{Type: "text/plain", Value: message.TextBody})
+	}
+	payload.Content = append(payload.Content, sendGridContent{Type: "text/html", Value: message.Body})
+
+	for _, attachment := range message.Attachments {
+		content, err := attachmentContent(attachment)
+		if err != nil {
+			return err
+		}
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     content,
+			Type:        attachmentType(attachment),
+			Filename:    attachment.Name,
+			Disposition: attachmentDisposition(attachment),
+			ContentID:   attachment.ContentID,
+		})
+	}
+
+	req, _, err := newJSONRequest(s.baseURL+"/v3/mail/send", payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	return s.api.do(req)
+}
+
+// parseSendGridError reads {"errors": [{"message": "...", "field": "..."}]}.
+func parseSendGridError(providerErr *ProviderError, _ http.Header, body []byte) {
+	var response struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Field   string `json:"field"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &response) != nil {
+		return
+	}
+
+	messages := make([]string, 0, len(response.Errors))
+	for _, e := range response.Errors {
+		if e.Field != "" {
+			messages = append(messages, e.Field+": "+e.Message)
+		} else {
+			messages = append(messages, e.Message)
+		}
+	}
+	providerErr.Message = strings.Join(messages, "; ")
+}
@@ -0,0 +1,195 @@
+package email
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email/sigv4"
+)
+
+// sesTransientErrors are SES error types about the account or its sending
+// quota rather than the message.
+var sesTransientErrors = map[string]bool{
+	"AccountSuspendedException":          true,
+	"LimitExceededException":             true,
+	"MailFromDomainNotVerifiedException": true,
+	"SendingPausedException":             true,
+	"TooManyRequestsException":           true,
+}
+
+// SESConfig configures the Amazon SES v2 sender.
+type SESConfig struct {
+	From        string
+	Region      string
+	Credentials sigv4.Credentials
+	// ConfigurationSet names the SES configuration set messages are sent
+	// with, for event publishing. Optional.
+	ConfigurationSet string
+	// BaseURL replaces https://email.<Region>.amazonaws.com.
+	BaseURL string
+	Client  *http.Client
+	// Now is the clock requests are signed with (defaults to time.Now).
+	Now func() time.Time
+}
+
+// NewSESConfigFromEnv reads emailSender, sesRegion, sesAccessKeyId,
+// sesSecretAccessKey, sesSessionToken and sesConfigurationSet, falling back
+// to the standard AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN variables, and emailProviderBaseUrl and
+// emailProviderTimeout.
+func NewSESConfigFromEnv() (SESConfig, error) {
+	config := SESConfig{
+		From:   os.Getenv("emailSender"),
+		Region: envOr("sesRegion", "AWS_REGION"),
+		Credentials: sigv4.Credentials{
+			AccessKeyID:     envOr("sesAccessKeyId", "AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: envOr("sesSecretAccessKey", "AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    envOr("sesSessionToken", "AWS_SESSION_TOKEN"),
+		},
+		ConfigurationSet: os.Getenv("sesConfigurationSet"),
+		BaseURL:          os.Getenv("emailProviderBaseUrl"),
+	}
+
+	switch {
+	case config.From == "":
+		return SESConfig{}, fmt.Errorf("%s: emailSender is required", ProviderSES)
+	case config.Region == "":
+		return SESConfig{}, fmt.Errorf("%s: sesRegion or AWS_REGION is required", ProviderSES)
+	case config.Credentials.AccessKeyID == "" || config.Credentials.SecretAccessKey == "":
+		return SESConfig{}, fmt.Errorf("%s: sesAccessKeyId and sesSecretAccessKey (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY) are required", ProviderSES)
+	}
+
+	client, err := newProviderClientFromEnv(ProviderSES)
+	if err != nil {
+		return SESConfig{}, err
+	}
+	config.Client = client
+	return config, nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return os.Getenv(fallback)
+}
+
+// SESEmailSender sends messages through the Amazon SES v2 SendEmail API. The
+// message is sent as raw MIME, built like the SMTP sender builds it, so
+// headers, the text alternative and attachments are kept as they are.
+type SESEmailSender struct {
+	config  SESConfig
+	baseURL string
+	api     *apiClient
+}
+
+func NewSESEmailSender(config SESConfig) *SESEmailSender {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = "https://email." + config.Region + ".amazonaws.com"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	api := newAPIClient(ProviderSES, config.Client)
+	api.parseError = parseSESError
+	api.classify = classifySESError
+
+	return &SESEmailSender{
+		config:  config,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		api:     api,
+	}
+}
+
+type sesRawMessage struct {
+	// Data is the MIME message, base64 encoded by encoding/json.
+	Data []byte
+}
+
+type sesContent struct {
+	Raw sesRawMessage
+}
+
+type sesDestination struct {
+	ToAddresses []string
+}
+
+type sesMessageTag struct {
+	Name  string
+	Value string
+}
+
+type sesSendEmailRequest struct {
+	FromEmailAddress     string
+	Destination          sesDestination
+	Content              sesContent
+	ConfigurationSetName string          `json:",omitempty"`
+	EmailTags            []sesMessageTag `json:",omitempty"`
+}
+
+func (s *SESEmailSender) Send(message domain.EmailMessage) error {
+	raw, err := buildMIME(s.config.From, message)
+	if err != nil {
+		return err
+	}
+
+	payload := sesSendEmailRequest{
+		FromEmailAddress:     s.config.From,
+		Destination:          sesDestination{ToAddresses: message.To},
+		Content:              sesContent{Raw: sesRawMessage{Data: raw}},
+		ConfigurationSetName: s.config.ConfigurationSet,
+	}
+	for _, name := range sortedHeaderNames(message.Metadata) {
+		payload.EmailTags = append(payload.EmailTags, sesMessageTag{Name: name, Value: message.Metadata[name]})
+	}
+
+	req, body, err := newJSONRequest(s.baseURL+"/v2/email/outbound-emails", payload)
+	if err != nil {
+		return err
+	}
+	sigv4.Sign(req, body, s.config.Credentials, s.config.Region, "ses", s.config.Now())
+
+	return s.api.do(req)
+}
+
+// parseSESError reads the error type from the X-Amzn-ErrorType header, such
+// as "MessageRejected:http://internal.amazon.com/...", and the message from
+// the {"message": "..."} body.
+func parseSESError(providerErr *ProviderError, header http.Header, body []byte) {
+	errorType := header.Get("X-Amzn-ErrorType")
+	if i := strings.Index(errorType, ":"); i >= 0 {
+		errorType = errorType[:i]
+	}
+
+	var response struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &response) == nil {
+		if errorType == "" {
+			errorType = response.Type
+			if i := strings.LastIndex(errorType, "#"); i >= 0 {
+				errorType = errorType[i+1:]
+			}
+		}
+		providerErr.Message = response.Message
+	}
+	providerErr.Code = errorType
+}
+
+// classifySESError treats throttling and account level errors as transient,
+// since SES reports some of them with 400 like rejected messages.
+func classifySESError(providerErr *ProviderError) *domain.SendError {
+	sendErr := ClassifyProviderError(providerErr)
+	if sesTransientErrors[providerErr.Code] {
+		sendErr.Permanent = false
+	}
+	return sendErr
+}
@@ -0,0 +1,369 @@
+package email
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+
+	"gopkg.in/gomail.v2"
+)
+
+// TLSMode is how the connection to the SMTP server is secured.
+type TLSMode string
+
+const (
+	// TLSImplicit opens a TLS connection straight away, usually on port 465.
+	TLSImplicit TLSMode = "implicit"
+	// TLSStartTLS upgrades the connection with STARTTLS and fails when the
+	// server does not offer it.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSOpportunistic upgrades the connection when the server offers STARTTLS
+	// and sends in plain text otherwise.
+	TLSOpportunistic TLSMode = "opportunistic"
+	// TLSNone never uses TLS. It is meant for local relays and test servers.
+	TLSNone TLSMode = "none"
+)
+
+const (
+	defaultSMTPDialTimeout    = 10 * time.Second
+	defaultSMTPCommandTimeout = 2 * time.Minute
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// SMTPConfig is where and how SMTPDialer connects.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLSMode  TLSMode
+	// TLSConfig is used for implicit TLS and STARTTLS. Its ServerName
+	// defaults to Host.
+	TLSConfig *tls.Config
+	// LocalName is sent in EHLO (defaults to "localhost").
+	LocalName string
+	// Timeout bounds opening the connection (defaults to 10s).
+	Timeout time.Duration
+	// CommandTimeout bounds the handshake, the sending of each message and
+	// QUIT, as net/smtp sets no deadline of its own and would wait forever on
+	// a server that stops answering (defaults to 2m).
+	CommandTimeout time.Duration
+}
+
+// NewSMTPConfigFromEnv reads smtpServer, smtpPort, emailSender, emailPass and
+// the TLS settings: smtpTLSMode (defaults to implicit on port 465 and
+// starttls otherwise), smtpTLSCAFile, a PEM bundle trusted besides the
+// system roots, smtpTLSMinVersion (defaults to 1.2) and
+// smtpTLSInsecureSkipVerify, which turns off certificate checks and is only
+// meant for development, and smtpCommandTimeout, which falls back to the
+// default when unset or invalid. Other invalid settings are returned as
+// errors.
+func NewSMTPConfigFromEnv() (SMTPConfig, error) {
+	config := SMTPConfig{
+		Host:     os.Getenv("smtpServer"),
+		Username: os.Getenv("emailSender"),
+		Password: os.Getenv("emailPass"),
+	}
+	if config.Host == "" {
+		return SMTPConfig{}, errors.New("smtpServer is required")
+	}
+
+	port, err := strconv.Atoi(os.Getenv("smtpPort"))
+	if err != nil || port <= 0 || port > 65535 {
+		return SMTPConfig{}, fmt.Errorf("smtpPort %q is not a valid port", os.Getenv("smtpPort"))
+	}
+	config.Port = port
+
+	if commandTimeout, err := time.ParseDuration(os.Getenv("smtpCommandTimeout")); err == nil && commandTimeout > 0 {
+		config.CommandTimeout = commandTimeout
+	}
+
+	config.TLSMode = TLSMode(strings.ToLower(os.Getenv("smtpTLSMode")))
+	if config.TLSMode == "" {
+		config.TLSMode = TLSStartTLS
+		if port == 465 {
+			config.TLSMode = TLSImplicit
+		}
+	}
+
+	config.TLSConfig, err = newTLSConfig(
+		os.Getenv("smtpTLSCAFile"),
+		os.Getenv("smtpTLSMinVersion"),
+		os.Getenv("smtpTLSInsecureSkipVerify"),
+	)
+	if err != nil {
+		return SMTPConfig{}, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return SMTPConfig{}, err
+	}
+	return config, nil
+}
+
+func newTLSConfig(caFile, minVersion, insecureSkipVerify string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("smtpTLSMinVersion %q must be one of 1.0, 1.1, 1.2 or 1.3", minVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if caFile != "" {
+		bundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("smtpTLSCAFile: %w", err)
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("smtpTLSCAFile: no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if insecureSkipVerify != "" {
+		skip, err := strconv.ParseBool(insecureSkipVerify)
+		if err != nil {
+			return nil, fmt.Errorf("smtpTLSInsecureSkipVerify %q is not a boolean", insecureSkipVerify)
+		}
+		if skip {
+			fmt.Println("Warning: SMTP TLS certificate verification is disabled (smtpTLSInsecureSkipVerify)")
+		}
+		tlsConfig.InsecureSkipVerify = skip
+	}
+
+	return tlsConfig, nil
+}
+
+// Validate checks the TLS mode.
+func (c SMTPConfig) Validate() error {
+	switch c.TLSMode {
+	case TLSImplicit, TLSStartTLS, TLSOpportunistic, TLSNone:
+		return nil
+	}
+	return fmt.Errorf("smtpTLSMode %q must be one of implicit, starttls, opportunistic or none", c.TLSMode)
+}
+
+// SMTPDialer opens SMTP connections following an SMTPConfig. Unlike
+// gomail.Dialer it can require STARTTLS or avoid TLS altogether.
+type SMTPDialer struct {
+	config SMTPConfig
+}
+
+func NewSMTPDialer(config SMTPConfig) (*SMTPDialer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSConfig != nil {
+		tlsConfig = config.TLSConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = config.Host
+	}
+	config.TLSConfig = tlsConfig
+
+	if config.LocalName == "" {
+		config.LocalName = "localhost"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultSMTPDialTimeout
+	}
+	if config.CommandTimeout <= 0 {
+		config.CommandTimeout = defaultSMTPCommandTimeout
+	}
+
+	return &SMTPDialer{config: config}, nil
+}
+
+func (d *SMTPDialer) Dial() (gomail.SendCloser, error) {
+	address := net.JoinHostPort(d.config.Host, strconv.Itoa(d.config.Port))
+	conn, err := net.DialTimeout("tcp", address, d.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.config.TLSMode == TLSImplicit {
+		conn = tls.Client(conn, d.config.TLSConfig)
+	}
+
+	// The deadline also covers the connection once STARTTLS wraps it.
+	if err := conn.SetDeadline(time.Now().Add(d.config.CommandTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, d.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := d.handshake(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &smtpSender{client: client, conn: conn, timeout: d.config.CommandTimeout}, nil
+}
+
+// handshake greets the server, upgrades the connection as the TLS mode asks
+// and authenticates.
+func (d *SMTPDialer) handshake(client *smtp.Client) error {
+	if err := client.Hello(d.config.LocalName); err != nil {
+		return err
+	}
+
+	if d.config.TLSMode == TLSStartTLS || d.config.TLSMode == TLSOpportunistic {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(d.config.TLSConfig); err != nil {
+				return err
+			}
+		} else if d.config.TLSMode == TLSStartTLS {
+			return fmt.Errorf("smtp: %s does not support STARTTLS, which smtpTLSMode starttls requires", d.config.Host)
+		}
+	}
+
+	if d.config.Username == "" {
+		return nil
+	}
+
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok {
+		return fmt.Errorf("smtp: %s does not support authentication", d.config.Host)
+	}
+	return client.Auth(d.auth(mechanisms))
+}
+
+// auth picks CRAM-MD5, PLAIN or LOGIN, in that order, from the mechanisms
+// the server offers. PLAIN and LOGIN refuse to send the password over a
+// connection without TLS, except to localhost.
+func (d *SMTPDialer) auth(mechanisms string) smtp.Auth {
+	offered := strings.Fields(strings.ToUpper(mechanisms))
+	has := func(mechanism string) bool {
+		for _, m := range offered {
+			if m == mechanism {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has("CRAM-MD5"):
+		return smtp.CRAMMD5Auth(d.config.Username, d.config.Password)
+	case !has("PLAIN") && has("LOGIN"):
+		return &loginAuth{username: d.config.Username, password: d.config.Password, host: d.config.Host}
+	default:
+		return smtp.PlainAuth("", d.config.Username, d.config.Password, d.config.Host)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism, which some providers offer
+// instead of PLAIN.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("smtp: refusing to send the password over an unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("smtp: unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// smtpSender sends messages over one SMTP session. Each message and the QUIT
+// must be done within timeout.
+type smtpSender struct {
+	client  *smtp.Client
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (s *smtpSender) Send(from string, to []string, message io.WriterTo) error {
+	if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		return err
+	}
+	if err := s.client.Mail(from); err != nil {
+		return &phaseError{phase: domain.SendPhaseSender, err: err}
+	}
+
+	for _, recipient := range to {
+		if err := s.client.Rcpt(recipient); err != nil {
+			return &phaseError{phase: domain.SendPhaseRecipient, err: err}
+		}
+	}
+
+	w, err := s.client.Data()
+	if err != nil {
+		return &phaseError{phase: domain.SendPhaseMessage, err: err}
+	}
+
+	if _, err := message.WriteTo(w); err != nil {
+		w.Close()
+		return &phaseError{phase: domain.SendPhaseMessage, err: err}
+	}
+	if err := w.Close(); err != nil {
+		return &phaseError{phase: domain.SendPhaseMessage, err: err}
+	}
+	return nil
+}
+
+// Close sends QUIT, and drops the connection when the server does not answer
+// it in time, which Quit alone would leave open.
+func (s *smtpSender) Close() error {
+	err := s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if err == nil {
+		err = s.client.Quit()
+	}
+	if err != nil {
+		s.client.Close()
+	}
+	return err
+}
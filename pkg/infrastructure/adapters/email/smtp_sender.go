@@ -0,0 +1,86 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// SMTPEmailSender sends messages over SMTP, reusing a bounded pool of
+// connections instead of dialing the server for every message. Messages are
+// DKIM-signed when a DKIMSigner is configured.
+type SMTPEmailSender struct {
+	from string
+	pool *connectionPool
+	dkim *DKIMSigner
+}
+
+// NewSMTPEmailSender returns an error when the SMTP or TLS settings are
+// invalid or the DKIM keys cannot be loaded, so a misconfigured server fails
+// at startup instead of on the first send.
+func NewSMTPEmailSender() (*SMTPEmailSender, error) {
+	smtpConfig, err := NewSMTPConfigFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("smtp: %w", err)
+	}
+
+	dialer, err := NewSMTPDialer(smtpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: %w", err)
+	}
+
+	dkimSigner, err := NewDKIMSignerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPooledEmailSender(dialer, smtpConfig.Username, NewPoolConfigFromEnv(), dkimSigner), nil
+}
+
+// NewPooledEmailSender returns an SMTP sender that sends from the given address
+// over connections opened by dialer. dkimSigner may be nil to send unsigned.
+func NewPooledEmailSender(dialer Dialer, from string, config PoolConfig, dkimSigner *DKIMSigner) *SMTPEmailSender {
+	return &SMTPEmailSender{
+		from: from,
+		pool: newConnectionPool(dialer, config),
+		dkim: dkimSigner,
+	}
+}
+
+func (m *SMTPEmailSender) Send(message domain.EmailMessage) error {
+	raw, err := buildMIME(m.from, message)
+	if err != nil {
+		return err
+	}
+
+	if m.dkim != nil {
+		if raw, err = m.dkim.Sign(m.from, raw); err != nil {
+			return err
+		}
+	}
+
+	conn, err := m.pool.get()
+	if err != nil {
+		return classifyDialError(err)
+	}
+
+	envelopeFrom := m.from
+	if message.ReturnPath != "" {
+		envelopeFrom = message.ReturnPath
+	}
+	if err := conn.sender.Send(envelopeFrom, message.To, bytes.NewBuffer(raw)); err != nil {
+		m.pool.discard(conn)
+		return ClassifySMTPError(err)
+	}
+
+	m.pool.put(conn)
+	return nil
+}
+
+// Close closes the idle SMTP connections.
+func (m *SMTPEmailSender) Close() {
+	if m.pool != nil {
+		m.pool.close()
+	}
+}
@@ -0,0 +1,140 @@
+package smtptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Part is a leaf MIME part of a message, with its body decoded from its
+// Content-Transfer-Encoding.
+type Part struct {
+	Header      textproto.MIMEHeader
+	ContentType string
+	// Filename is set for parts with a Content-Disposition or name parameter
+	// naming a file.
+	Filename string
+	// Attachment reports a Content-Disposition of attachment.
+	Attachment bool
+	Body       []byte
+}
+
+// Parsed is a message split into its headers and leaf parts, in order.
+type Parsed struct {
+	Header mail.Header
+	Parts  []Part
+}
+
+// Parse reads the headers and MIME parts of the message.
+func (m Message) Parse() (*Parsed, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &Parsed{Header: msg.Header}
+	if err := parsed.walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// Header returns the decoded value of a header of the message, or "" when it
+// cannot be parsed.
+func (m Message) Header(name string) string {
+	parsed, err := m.Parse()
+	if err != nil {
+		return ""
+	}
+	return parsed.HeaderValue(name)
+}
+
+// HeaderValue returns a header with RFC 2047 encoded words decoded.
+func (p *Parsed) HeaderValue(name string) string {
+	value := p.Header.Get(name)
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// Body returns the first part with the given content type that is not an
+// attachment, or nil.
+func (p *Parsed) Body(contentType string) *Part {
+	for i := range p.Parts {
+		if p.Parts[i].ContentType == contentType && !p.Parts[i].Attachment {
+			return &p.Parts[i]
+		}
+	}
+	return nil
+}
+
+// Attachments returns the parts sent as attachments.
+func (p *Parsed) Attachments() []Part {
+	var attachments []Part
+	for _, part := range p.Parts {
+		if part.Attachment {
+			attachments = append(attachments, part)
+		}
+	}
+	return attachments
+}
+
+func (p *Parsed) walk(header textproto.MIMEHeader, body io.Reader) error {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("content type %q: %w", contentType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	decoded, err := io.ReadAll(decode(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	part := Part{Header: header, ContentType: mediaType, Filename: params["name"], Body: decoded}
+	if disposition, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.Attachment = disposition == "attachment"
+		if filename := dispositionParams["filename"]; filename != "" {
+			part.Filename = filename
+		}
+	}
+	p.Parts = append(p.Parts, part)
+	return nil
+}
+
+func decode(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
@@ -0,0 +1,582 @@
+// Package smtptest provides an in-process SMTP server for tests, in the
+// spirit of net/http/httptest. It speaks enough ESMTP for real clients:
+// STARTTLS, implicit TLS and AUTH PLAIN, LOGIN and CRAM-MD5, and it records
+// every message it accepts together with its envelope.
+package smtptest
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reply is an SMTP reply a hook can send instead of accepting a command. The
+// zero Reply accepts it.
+type Reply struct {
+	Code int
+	Text string
+}
+
+// Message is a message accepted by the server.
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as received, with dot-stuffing removed and CRLF
+	// line endings.
+	Data []byte
+	// TLS reports whether the message was received over TLS.
+	TLS bool
+	// AuthUser is the user that authenticated, if any.
+	AuthUser string
+}
+
+// Server is an SMTP server listening on a loopback address. Configure its
+// exported fields between NewUnstartedServer and Start or StartTLS.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	// Users enables AUTH and requires it before MAIL. It maps user names to
+	// passwords.
+	Users map[string]string
+	// AuthMechanisms are the AUTH mechanisms offered (defaults to PLAIN and
+	// LOGIN). CRAM-MD5 is also supported.
+	AuthMechanisms []string
+	// StartTLS offers the STARTTLS extension. It is set by NewServer.
+	StartTLS bool
+	// RequireTLS rejects MAIL until the connection uses TLS.
+	RequireTLS bool
+	// TLS is the server certificate configuration. When nil, a self-signed
+	// certificate for 127.0.0.1 and localhost is generated.
+	TLS *tls.Config
+
+	// RecipientReply, when set, decides the reply to every RCPT TO.
+	RecipientReply func(address string) Reply
+	// DataReply, when set, decides the reply once a message has been
+	// received. Messages are only recorded when it accepts them.
+	DataReply func(message Message) Reply
+
+	listener    net.Listener
+	certificate *x509.Certificate
+	implicitTLS bool
+	connections int64
+	wg          sync.WaitGroup
+
+	mu       sync.Mutex
+	messages []Message
+	closed   bool
+	conns    map[net.Conn]bool
+}
+
+// NewServer starts a server that offers STARTTLS but does not require it.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.StartTLS = true
+	s.Start()
+	return s
+}
+
+// NewTLSServer starts a server that speaks TLS from the first byte, like SMTP
+// servers on port 465.
+func NewTLSServer() *Server {
+	s := NewUnstartedServer()
+	s.StartWithImplicitTLS()
+	return s
+}
+
+// NewUnstartedServer returns a server that does not listen yet, so that its
+// fields can be set first.
+func NewUnstartedServer() *Server {
+	return &Server{conns: make(map[net.Conn]bool)}
+}
+
+// Start starts listening for plain text connections, offering STARTTLS when
+// StartTLS is set.
+func (s *Server) Start() {
+	s.start(false)
+}
+
+// StartWithImplicitTLS starts listening for TLS connections.
+func (s *Server) StartWithImplicitTLS() {
+	s.start(true)
+}
+
+func (s *Server) start(implicitTLS bool) {
+	if s.listener != nil {
+		panic("smtptest: server already started")
+	}
+
+	if s.TLS == nil {
+		config, certificate, err := selfSignedTLSConfig()
+		if err != nil {
+			panic("smtptest: " + err.Error())
+		}
+		s.TLS, s.certificate = config, certificate
+	} else if len(s.TLS.Certificates) > 0 && len(s.TLS.Certificates[0].Certificate) > 0 {
+		s.certificate, _ = x509.ParseCertificate(s.TLS.Certificates[0].Certificate[0])
+	}
+	if len(s.AuthMechanisms) == 0 {
+		s.AuthMechanisms = []string{"PLAIN", "LOGIN"}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("smtptest: failed to listen: " + err.Error())
+	}
+	s.implicitTLS = implicitTLS
+	if implicitTLS {
+		listener = tls.NewListener(listener, s.TLS)
+	}
+	s.listener = listener
+	s.Addr = listener.Addr().String()
+
+	go s.serve()
+}
+
+// Host is the host clients connect to.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port is the port clients connect to.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Certificate returns the certificate of the server.
+func (s *Server) Certificate() *x509.Certificate {
+	return s.certificate
+}
+
+// ClientTLSConfig returns a client configuration that trusts the server.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	roots := x509.NewCertPool()
+	if s.certificate != nil {
+		roots.AddCert(s.certificate)
+	}
+	return &tls.Config{RootCAs: roots, ServerName: s.Host()}
+}
+
+// Messages returns the messages accepted so far, oldest first.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// LastMessage returns the last accepted message, or nil.
+func (s *Server) LastMessage() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		return nil
+	}
+	message := s.messages[len(s.messages)-1]
+	return &message
+}
+
+// Connections returns how many connections were accepted so far.
+func (s *Server) Connections() int {
+	return int(atomic.LoadInt64(&s.connections))
+}
+
+// Reset forgets the accepted messages.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// Close stops listening, closes open connections and waits for them to end.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		atomic.AddInt64(&s.connections, 1)
+		go s.handle(conn)
+	}
+}
+
+// session is the state of one SMTP connection.
+type session struct {
+	server   *Server
+	conn     net.Conn
+	reader   *bufio.Reader
+	tls      bool
+	greeted  bool
+	authUser string
+	from     string
+	to       []string
+	inMail   bool
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	sess := &session{server: s, conn: conn, reader: bufio.NewReader(conn), tls: s.implicitTLS}
+	sess.reply(220, "localhost ESMTP smtptest")
+	for {
+		line, err := sess.readLine()
+		if err != nil {
+			return
+		}
+		if !sess.command(line) {
+			return
+		}
+	}
+}
+
+func (sess *session) readLine() (string, error) {
+	sess.conn.SetReadDeadline(time.Now().Add(time.Minute))
+	line, err := sess.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (sess *session) reply(code int, lines ...string) {
+	var b strings.Builder
+	for i, line := range lines {
+		separator := " "
+		if i < len(lines)-1 {
+			separator = "-"
+		}
+		fmt.Fprintf(&b, "%d%s%s\r\n", code, separator, line)
+	}
+	sess.conn.Write([]byte(b.String()))
+}
+
+func (sess *session) send(reply Reply) {
+	sess.reply(reply.Code, reply.Text)
+}
+
+// command handles one command line and returns false when the connection
+// should be closed.
+func (sess *session) command(line string) bool {
+	verb, arg := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch strings.ToUpper(verb) {
+	case "EHLO":
+		sess.greeted = true
+		sess.resetMail()
+		sess.reply(250, sess.extensions()...)
+	case "HELO":
+		sess.greeted = true
+		sess.resetMail()
+		sess.reply(250, "localhost")
+	case "STARTTLS":
+		return sess.startTLS()
+	case "AUTH":
+		sess.auth(arg)
+	case "MAIL":
+		sess.mail(arg)
+	case "RCPT":
+		sess.rcpt(arg)
+	case "DATA":
+		return sess.data()
+	case "RSET":
+		sess.resetMail()
+		sess.reply(250, "2.0.0 OK")
+	case "NOOP":
+		sess.reply(250, "2.0.0 OK")
+	case "QUIT":
+		sess.reply(221, "2.0.0 Bye")
+		return false
+	default:
+		sess.reply(502, "5.5.2 Command not recognized")
+	}
+	return true
+}
+
+func (sess *session) extensions() []string {
+	extensions := []string{"localhost", "8BITMIME", "PIPELINING"}
+	if sess.server.StartTLS && !sess.tls {
+		extensions = append(extensions, "STARTTLS")
+	}
+	if sess.server.Users != nil {
+		extensions = append(extensions, "AUTH "+strings.Join(sess.server.AuthMechanisms, " "))
+	}
+	return extensions
+}
+
+func (sess *session) resetMail() {
+	sess.from, sess.to, sess.inMail = "", nil, false
+}
+
+func (sess *session) startTLS() bool {
+	if !sess.server.StartTLS || sess.tls {
+		sess.reply(502, "5.5.1 STARTTLS not available")
+		return true
+	}
+
+	sess.reply(220, "2.0.0 Ready to start TLS")
+	tlsConn := tls.Server(sess.conn, sess.server.TLS)
+	tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	// RFC 3207: the client starts over after the handshake.
+	sess.conn, sess.reader, sess.tls = tlsConn, bufio.NewReader(tlsConn), true
+	sess.greeted, sess.authUser = false, ""
+	sess.resetMail()
+	return true
+}
+
+func (sess *session) auth(arg string) {
+	if sess.server.Users == nil {
+		sess.reply(502, "5.5.1 AUTH not available")
+		return
+	}
+	if sess.authUser != "" {
+		sess.reply(503, "5.5.1 Already authenticated")
+		return
+	}
+
+	mechanism, initial := arg, ""
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		mechanism, initial = arg[:i], arg[i+1:]
+	}
+	mechanism = strings.ToUpper(mechanism)
+	if !sess.offers(mechanism) {
+		sess.reply(504, "5.5.4 Unrecognized authentication type")
+		return
+	}
+
+	var user, password string
+	ok := false
+	switch mechanism {
+	case "PLAIN":
+		response, err := sess.challenge("", initial)
+		if err != nil {
+			return
+		}
+		parts := strings.Split(string(response), "\x00")
+		if len(parts) == 3 {
+			user, password = parts[1], parts[2]
+			ok = sess.checkPassword(user, password)
+		}
+	case "LOGIN":
+		username, err := sess.challenge("Username:", initial)
+		if err != nil {
+			return
+		}
+		secret, err := sess.challenge("Password:", "")
+		if err != nil {
+			return
+		}
+		user = string(username)
+		ok = sess.checkPassword(user, string(secret))
+	case "CRAM-MD5":
+		challenge := fmt.Sprintf("<%d.%d@localhost>", time.Now().UnixNano(), atomic.LoadInt64(&sess.server.connections))
+		response, err := sess.challenge(challenge, "")
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(string(response))
+		if len(fields) == 2 {
+			user = fields[0]
+			expected, known := sess.server.Users[user]
+			mac := hmac.New(md5.New, []byte(expected))
+			mac.Write([]byte(challenge))
+			ok = known && hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(fields[1]))
+		}
+	}
+
+	if !ok {
+		sess.reply(535, "5.7.8 Authentication credentials invalid")
+		return
+	}
+	sess.authUser = user
+	sess.reply(235, "2.7.0 Authentication successful")
+}
+
+func (sess *session) offers(mechanism string) bool {
+	for _, offered := range sess.server.AuthMechanisms {
+		if strings.EqualFold(offered, mechanism) {
+			return true
+		}
+	}
+	return false
+}
+
+// challenge returns the decoded initial response, or sends prompt and reads
+// the client's answer.
+func (sess *session) challenge(prompt, initial string) ([]byte, error) {
+	if initial == "" || initial == "=" {
+		sess.reply(334, base64.StdEncoding.EncodeToString([]byte(prompt)))
+		line, err := sess.readLine()
+		if err != nil {
+			return nil, err
+		}
+		initial = line
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(initial)
+	if err != nil {
+		sess.reply(501, "5.5.2 Cannot decode response")
+	}
+	return decoded, err
+}
+
+func (sess *session) checkPassword(user, password string) bool {
+	expected, ok := sess.server.Users[user]
+	return ok && hmac.Equal([]byte(expected), []byte(password))
+}
+
+func (sess *session) mail(arg string) {
+	switch {
+	case !sess.greeted:
+		sess.reply(503, "5.5.1 Send EHLO first")
+	case sess.server.RequireTLS && !sess.tls:
+		sess.reply(530, "5.7.0 Must issue a STARTTLS command first")
+	case sess.server.Users != nil && sess.authUser == "":
+		sess.reply(530, "5.7.0 Authentication required")
+	case sess.inMail:
+		sess.reply(503, "5.5.1 Nested MAIL command")
+	default:
+		address, ok := pathArgument(arg, "FROM:")
+		if !ok {
+			sess.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+			return
+		}
+		sess.from, sess.inMail = address, true
+		sess.reply(250, "2.1.0 OK")
+	}
+}
+
+func (sess *session) rcpt(arg string) {
+	if !sess.inMail {
+		sess.reply(503, "5.5.1 Need MAIL before RCPT")
+		return
+	}
+
+	address, ok := pathArgument(arg, "TO:")
+	if !ok || address == "" {
+		sess.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+
+	if sess.server.RecipientReply != nil {
+		if reply := sess.server.RecipientReply(address); reply.Code != 0 {
+			sess.send(reply)
+			return
+		}
+	}
+	sess.to = append(sess.to, address)
+	sess.reply(250, "2.1.5 OK")
+}
+
+func (sess *session) data() bool {
+	if !sess.inMail || len(sess.to) == 0 {
+		sess.reply(503, "5.5.1 Need RCPT before DATA")
+		return true
+	}
+
+	sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+	var data strings.Builder
+	for {
+		line, err := sess.readLine()
+		if err != nil {
+			return false
+		}
+		if line == "." {
+			break
+		}
+		// Undo dot-stuffing (RFC 5321, section 4.5.2).
+		line = strings.TrimPrefix(line, ".")
+		data.WriteString(line)
+		data.WriteString("\r\n")
+	}
+
+	message := Message{
+		From:     sess.from,
+		To:       sess.to,
+		Data:     []byte(data.String()),
+		TLS:      sess.tls,
+		AuthUser: sess.authUser,
+	}
+	sess.resetMail()
+
+	if sess.server.DataReply != nil {
+		if reply := sess.server.DataReply(message); reply.Code != 0 {
+			sess.send(reply)
+			return true
+		}
+	}
+
+	sess.server.mu.Lock()
+	sess.server.messages = append(sess.server.messages, message)
+	sess.server.mu.Unlock()
+	sess.reply(250, "2.0.0 OK: queued")
+	return true
+}
+
+// pathArgument reads the address of "FROM:<address> PARAMS".
+func pathArgument(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(path, '>')
+	if end < 0 {
+		return "", false
+	}
+	return path[1:end], true
+}
@@ -0,0 +1,48 @@
+// Package mailbox reads the mail delivered to local mailboxes, in the Maildir
+// or mbox format, so that the application can act on it and mark it
+// processed.
+package mailbox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Mailbox interface {
+	Process(handle func(message []byte) error) error
+}
+
+// Mailbox formats.
+const (
+	FormatMaildir = "maildir"
+	FormatMbox    = "mbox"
+)
+
+// NewMailbox opens the mailbox at path, a directory for maildir and a file
+// for mbox.
+func NewMailbox(format, path string) (Mailbox, error) {
+	switch strings.ToLower(format) {
+	case "", FormatMaildir:
+		return NewMaildir(path)
+	case FormatMbox:
+		return NewMbox(path)
+	}
+	return nil, fmt.Errorf("mailbox: format %q must be one of maildir or mbox", format)
+}
+
+// NewMailboxFromEnv opens the mailbox whose path is in the pathVariable
+// environment variable and whose format is in formatVariable (defaults to
+// maildir). It returns nil when the path is not set.
+func NewMailboxFromEnv(pathVariable, formatVariable string) (Mailbox, error) {
+	path := os.Getenv(pathVariable)
+	if path == "" {
+		return nil, nil
+	}
+
+	mailbox, err := NewMailbox(os.Getenv(formatVariable), path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", pathVariable, err)
+	}
+	return mailbox, nil
+}
@@ -0,0 +1,111 @@
+package mailbox
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Maildir reads a Maildir. A processed message is moved from new/ to cur/
+// with the S (seen) flag, as a mail client does when it is read. Messages a
+// mail client moved to cur/ without reading them are processed too.
+type Maildir struct {
+	dir string
+}
+
+// NewMaildir reads the Maildir at dir, creating its folders if missing.
+func NewMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &Maildir{dir: dir}, nil
+}
+
+type maildirEntry struct {
+	sub     string
+	name    string
+	modTime time.Time
+}
+
+func (m *Maildir) Process(handle func(message []byte) error) error {
+	entries, err := m.unseen()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(m.dir, entry.sub, entry.name)
+		message, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := handle(message); err != nil {
+			return err
+		}
+
+		err = os.Rename(path, filepath.Join(m.dir, "cur", seen(entry.name)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// unseen lists the messages without the S flag, oldest first.
+func (m *Maildir) unseen() ([]maildirEntry, error) {
+	var entries []maildirEntry
+	for _, sub := range []string{"new", "cur"} {
+		dirEntries, err := os.ReadDir(filepath.Join(m.dir, sub))
+		if err != nil {
+			return nil, err
+		}
+		for _, dirEntry := range dirEntries {
+			name := dirEntry.Name()
+			if !dirEntry.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.Contains(flags(name), "S") {
+				continue
+			}
+			info, err := dirEntry.Info()
+			if err != nil {
+				continue
+			}
+			entries = append(entries, maildirEntry{sub: sub, name: name, modTime: info.ModTime()})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].modTime.Equal(entries[j].modTime) {
+			return entries[i].modTime.Before(entries[j].modTime)
+		}
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// flags returns the flags in the info part of a message name, such as "RS"
+// for "1710338531.M1P2.host:2,RS".
+func flags(name string) string {
+	_, info, found := strings.Cut(name, ":2,")
+	if !found {
+		return ""
+	}
+	return info
+}
+
+// seen returns the name of a message with the S flag added. Flags are kept in
+// ASCII order, as the format requires.
+func seen(name string) string {
+	base, info, _ := strings.Cut(name, ":2,")
+	letters := strings.Split(info+"S", "")
+	sort.Strings(letters)
+	return base + ":2," + strings.Join(letters, "")
+}
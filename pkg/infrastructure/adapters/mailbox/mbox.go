@@ -0,0 +1,205 @@
+package mailbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	lockTimeout  = 30 * time.Second
+	lockRetry    = 100 * time.Millisecond
+	staleLockAge = 5 * time.Minute
+)
+
+// Mbox reads an mbox file. Processed messages are removed from it. The file
+// is read and rewritten holding the dotlock (the file name with ".lock"
+// appended) that mail delivery agents take before appending to it, and mail
+// appended while messages were being processed is kept.
+type Mbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewMbox reads the mbox file at path. The file does not need to exist yet.
+func NewMbox(path string) (*Mbox, error) {
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return &Mbox{path: path}, nil
+}
+
+// mboxMessage is a message of an mbox file, and the bytes of the file it
+// takes up, including its "From " line.
+type mboxMessage struct {
+	raw []byte
+	end int
+}
+
+func (m *Mbox) Process(handle func(message []byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := m.read()
+	if err != nil {
+		return err
+	}
+
+	processed := 0
+	var handleErr error
+	for _, message := range splitMbox(data) {
+		if handleErr = handle(message.raw); handleErr != nil {
+			break
+		}
+		processed = message.end
+	}
+
+	if processed > 0 {
+		if err := m.remove(data[:processed]); err != nil {
+			return err
+		}
+	}
+	return handleErr
+}
+
+func (m *Mbox) read() ([]byte, error) {
+	unlock, err := dotlock(m.path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// remove removes the processed messages from the start of the file, unless
+// the file was rewritten since it was read.
+func (m *Mbox) remove(processed []byte) error {
+	unlock, err := dotlock(m.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(data, processed) {
+		return nil
+	}
+
+	info, err := os.Stat(m.path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), ".mbox-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data[len(processed):]); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), m.path)
+}
+
+// dotlock takes the lock file of path, waiting for another holder to release
+// it and breaking locks left behind by a process that died.
+func dotlock(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("mailbox: %s is locked", path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// splitMbox splits an mbox file into its messages. A message starts with a
+// "From " line at the start of the file or after a blank line. The "From "
+// line and the blank line that ends a message are dropped, and one level of
+// ">" quoting is removed from lines that look like a "From " line.
+func splitMbox(data []byte) []mboxMessage {
+	var messages []mboxMessage
+	var current *bytes.Buffer
+	blankBefore := true
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += offset + 1
+		}
+		line := data[offset:end]
+		content := bytes.TrimRight(line, "\r\n")
+
+		if blankBefore && bytes.HasPrefix(content, []byte("From ")) {
+			if current != nil {
+				messages = append(messages, mboxMessage{raw: trimSeparator(current.Bytes()), end: offset})
+			}
+			current = &bytes.Buffer{}
+			blankBefore = false
+			offset = end
+			continue
+		}
+		blankBefore = len(content) == 0
+
+		if current != nil {
+			if quoted := bytes.TrimLeft(line, ">"); len(quoted) < len(line) && bytes.HasPrefix(quoted, []byte("From ")) {
+				line = line[1:]
+			}
+			current.Write(line)
+		}
+		offset = end
+	}
+	if current != nil {
+		messages = append(messages, mboxMessage{raw: trimSeparator(current.Bytes()), end: len(data)})
+	}
+	return messages
+}
+
+// trimSeparator drops the blank line that separates a message from the next
+// "From " line.
+func trimSeparator(message []byte) []byte {
+	if trimmed := bytes.TrimSuffix(message, []byte("\r\n\r\n")); len(trimmed) < len(message) {
+		return append(trimmed, '\r', '\n')
+	}
+	if trimmed := bytes.TrimSuffix(message, []byte("\n\n")); len(trimmed) < len(message) {
+		return append(trimmed, '\n')
+	}
+	return message
+}
@@ -0,0 +1,137 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	domain "newsletter-app/pkg/domain/models"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AttachmentRepository keeps attachment content in a GridFS bucket. The
+// content type and checksum are stored in the metadata of each file.
+type AttachmentRepository struct {
+	database   *mongo.Database
+	bucketName string
+}
+
+func NewAttachmentRepository() *AttachmentRepository {
+	mongoDb := os.Getenv("mongoDb")
+
+	return &AttachmentRepository{
+		database:   client.Database(mongoDb),
+		bucketName: collectionName("mongoAttachmentBucket", "attachments"),
+	}
+}
+
+// attachmentFile is a document of the bucket's files collection.
+type attachmentFile struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Length   int64              `bson:"length"`
+	Filename string             `bson:"filename"`
+	Metadata struct {
+		ContentType string `bson:"contentType"`
+		Checksum    string `bson:"checksum"`
+	} `bson:"metadata"`
+}
+
+// bucket returns a new handle on the GridFS bucket. Buckets reuse one buffer
+// for every upload, so they are not shared between goroutines.
+func (r *AttachmentRepository) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(r.database, options.GridFSBucket().SetName(r.bucketName))
+}
+
+func (r *AttachmentRepository) files() *mongo.Collection {
+	return r.database.Collection(r.bucketName + ".files")
+}
+
+// SaveAttachment streams content into GridFS and records its size and
+// SHA-256 checksum, which are only known once the upload is complete.
+func (r *AttachmentRepository) SaveAttachment(attachment domain.Attachment, content io.Reader) (*domain.Attachment, error) {
+	bucket, err := r.bucket()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	counter := &countingReader{reader: io.TeeReader(content, hash)}
+	uploadOptions := options.GridFSUpload().SetMetadata(bson.M{"contentType": attachment.Type})
+	id, err := bucket.UploadFromStream(attachment.Name, counter, uploadOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	_, err = r.files().UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"metadata.checksum": checksum}})
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.ID = id.Hex()
+	attachment.Size = counter.n
+	attachment.Checksum = checksum
+	attachment.Data = ""
+	return &attachment, nil
+}
+
+func (r *AttachmentRepository) GetAttachment(id string) (*domain.Attachment, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	var file attachmentFile
+	err = r.files().FindOne(context.TODO(), bson.M{"_id": objectID}).Decode(&file)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &domain.Attachment{
+		ID:       file.ID.Hex(),
+		Name:     file.Filename,
+		Type:     file.Metadata.ContentType,
+		Size:     file.Length,
+		Checksum: file.Metadata.Checksum,
+	}, nil
+}
+
+// OpenAttachment returns a stream of the stored content, read from GridFS
+// chunk by chunk.
+func (r *AttachmentRepository) OpenAttachment(id string) (io.ReadCloser, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := r.bucket()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := bucket.OpenDownloadStream(objectID)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,320 @@
+package mongodb
+
+import (
+	"context"
+	domain "newsletter-app/pkg/domain/models"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeliveryRepository struct {
+	deliveryCollection        *mongo.Collection
+	unmatchedBounceCollection *mongo.Collection
+}
+
+func NewDeliveryRepository() *DeliveryRepository {
+	mongoDb := os.Getenv("mongoDb")
+	mongoDeliveryCollection := collectionName("mongoDeliveryCollection", "deliveries")
+	mongoUnmatchedBounceCollection := collectionName("mongoUnmatchedBounceCollection", "unmatchedBounces")
+
+	return &DeliveryRepository{
+		deliveryCollection:        client.Database(mongoDb).Collection(mongoDeliveryCollection),
+		unmatchedBounceCollection: client.Database(mongoDb).Collection(mongoUnmatchedBounceCollection),
+	}
+}
+
+// CreateIndexes makes (newsletter, subscriber) unique and supports the
+// status filter used when listing deliveries and the lookup of the opens of
+// the tracking pixel.
+func (r *DeliveryRepository) CreateIndexes() error {
+	_, err := r.deliveryCollection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "newsletterid", Value: 1}, {Key: "subscriberid", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "newsletterid", Value: 1}, {Key: "status", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "opentokens", Value: 1}},
+		},
+	})
+	return err
+}
+
+// QueueDelivery creates the delivery for a (newsletter, subscriber) pair, or
+// puts an existing one back in the queued state when the newsletter is sent
+// again. The attempt count is kept across sends, and so are the open tracking
+// tokens, so the pixels of every send keep counting opens.
+func (r *DeliveryRepository) QueueDelivery(delivery domain.Delivery) error {
+	now := time.Now()
+	filter := bson.M{"newsletterid": delivery.NewsletterID, "subscriberid": delivery.SubscriberID}
+
+	update := bson.M{
+		"$set": bson.M{
+			"jobid":     delivery.JobID,
+			"email":     delivery.Email,
+			"status":    domain.DeliveryQueued,
+			"error":     "",
+			"updatedat": now,
+		},
+		"$setOnInsert": bson.M{
+			"attempts":  0,
+			"createdat": now,
+		},
+	}
+	if len(delivery.OpenTokens) > 0 {
+		update["$addToSet"] = bson.M{"opentokens": bson.M{"$each": delivery.OpenTokens}}
+	}
+
+	_, err := r.deliveryCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *DeliveryRepository) UpdateDeliveryStatus(newsletterID string, subscriberID primitive.ObjectID, status domain.DeliveryStatus, errorText string, attempts int) error {
+	now := time.Now()
+	filter := bson.M{"newsletterid": newsletterID, "subscriberid": subscriberID}
+
+	set := bson.M{
+		"status":    status,
+		"error":     errorText,
+		"updatedat": now,
+	}
+	if status == domain.DeliverySent {
+		set["sentat"] = now
+	}
+
+	update := bson.M{"$set": set, "$inc": bson.M{"attempts": attempts}}
+
+	_, err := r.deliveryCollection.UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+// RecordBounce marks the delivery bounced on a hard bounce, and counts a soft
+// bounce without changing its status.
+func (r *DeliveryRepository) RecordBounce(newsletterID string, subscriberID primitive.ObjectID, bounce domain.Bounce) (*domain.Delivery, error) {
+	filter := bson.M{"newsletterid": newsletterID, "subscriberid": subscriberID}
+
+	set := bson.M{
+		"bounce":    bounce,
+		"updatedat": time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if bounce.Type == domain.BounceHard {
+		set["status"] = domain.DeliveryBounced
+		set["error"] = bounce.Diagnostic
+	} else {
+		update["$inc"] = bson.M{"softbounces": 1}
+	}
+
+	var delivery domain.Delivery
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.deliveryCollection.FindOneAndUpdate(context.TODO(), filter, update, findOptions).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *DeliveryRepository) RecordUnmatchedBounce(bounce domain.Bounce) error {
+	_, err := r.unmatchedBounceCollection.InsertOne(context.TODO(), bounce)
+	return err
+}
+
+func (r *DeliveryRepository) CountSoftBounces(newsletterID string) (int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"newsletterid": newsletterID, "softbounces": bson.M{"$gt": 0}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "count": bson.M{"$sum": "$softbounces"}}}},
+	}
+
+	cursor, err := r.deliveryCollection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var result struct {
+		Count int `bson:"count"`
+	}
+	if cursor.Next(context.TODO()) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Count, cursor.Err()
+}
+
+// RecordComplaint stores a spam complaint on the delivery without changing
+// its status.
+func (r *DeliveryRepository) RecordComplaint(newsletterID string, subscriberID primitive.ObjectID, complaint domain.Complaint) (*domain.Delivery, error) {
+	filter := bson.M{"newsletterid": newsletterID, "subscriberid": subscriberID}
+	update := bson.M{"$set": bson.M{
+		"complaint": complaint,
+		"updatedat": time.Now(),
+	}}
+
+	var delivery domain.Delivery
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.deliveryCollection.FindOneAndUpdate(context.TODO(), filter, update, findOptions).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *DeliveryRepository) CountComplaints(newsletterID string) (int, error) {
+	count, err := r.deliveryCollection.CountDocuments(context.TODO(), bson.M{"newsletterid": newsletterID, "complaint": bson.M{"$ne": nil}})
+	return int(count), err
+}
+
+func (r *DeliveryRepository) RecordDelivered(newsletterID string, subscriberID primitive.ObjectID, deliveredAt time.Time) error {
+	filter := bson.M{"newsletterid": newsletterID, "subscriberid": subscriberID}
+	update := bson.M{"$set": bson.M{
+		"deliveredat": deliveredAt,
+		"updatedat":   time.Now(),
+	}}
+
+	_, err := r.deliveryCollection.UpdateOne(context.TODO(), filter, update)
+	return err
+}
+
+// RecordOpen counts an open, keeps the time of the first one and stores the
+// latest. Deliveries are stored with the zero time until they are opened, so a
+// plain $min would keep it, and webhooks may report opens out of order.
+func (r *DeliveryRepository) RecordOpen(newsletterID string, subscriberID primitive.ObjectID, open domain.Open) error {
+	_, err := r.recordOpen(bson.M{"newsletterid": newsletterID, "subscriberid": subscriberID}, open)
+	return err
+}
+
+// RecordOpenByToken records an open on the delivery whose pixel carried
+// openToken, and reports whether there is one.
+func (r *DeliveryRepository) RecordOpenByToken(openToken string, open domain.Open) (bool, error) {
+	return r.recordOpen(bson.M{"opentokens": openToken}, open)
+}
+
+func (r *DeliveryRepository) recordOpen(filter bson.M, open domain.Open) (bool, error) {
+	firstOpen := bson.M{"$or": bson.A{
+		bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$openedat", time.Time{}}}, time.Time{}}},
+		bson.M{"$gt": bson.A{"$openedat", open.OpenedAt}},
+	}}
+	lastOpen := bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$lastopen.openedat", time.Time{}}}, open.OpenedAt}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"opens":     bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$opens", 0}}, 1}},
+		"openedat":  bson.M{"$cond": bson.A{firstOpen, open.OpenedAt, "$openedat"}},
+		"lastopen":  bson.M{"$cond": bson.A{lastOpen, bson.M{"$literal": open}, "$lastopen"}},
+		"updatedat": time.Now(),
+	}}}}
+
+	result, err := r.deliveryCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (r *DeliveryRepository) CountOpens(newsletterID string) (int, int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"newsletterid": newsletterID, "opens": bson.M{"$gt": 0}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "unique": bson.M{"$sum": 1}, "total": bson.M{"$sum": "$opens"}}}},
+	}
+
+	cursor, err := r.deliveryCollection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var result struct {
+		Unique int `bson:"unique"`
+		Total  int `bson:"total"`
+	}
+	if cursor.Next(context.TODO()) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, 0, err
+		}
+	}
+	return result.Unique, result.Total, cursor.Err()
+}
+
+func (r *DeliveryRepository) GetDeliveries(filter domain.DeliveryFilter, page, pageSize int) ([]domain.Delivery, int64, error) {
+	query := bson.M{"newsletterid": filter.NewsletterID}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+
+	total, err := r.deliveryCollection.CountDocuments(context.TODO(), query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	findOptions.SetSkip(int64((page - 1) * pageSize))
+	findOptions.SetLimit(int64(pageSize))
+
+	cursor, err := r.deliveryCollection.Find(context.TODO(), query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(context.TODO())
+
+	deliveries := []domain.Delivery{}
+	for cursor.Next(context.TODO()) {
+		var delivery domain.Delivery
+		if err := cursor.Decode(&delivery); err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+func (r *DeliveryRepository) CountDeliveriesByStatus(newsletterID string) (map[domain.DeliveryStatus]int, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"newsletterid": newsletterID}}},
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := r.deliveryCollection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	counts := make(map[domain.DeliveryStatus]int)
+	for cursor.Next(context.TODO()) {
+		var result struct {
+			Status domain.DeliveryStatus `bson:"_id"`
+			Count  int                   `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		counts[result.Status] = result.Count
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
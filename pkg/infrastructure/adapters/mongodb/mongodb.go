@@ -3,6 +3,7 @@ package mongodb
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -39,3 +40,12 @@ func Disconnect() error {
 	}
 	return nil
 }
+
+// collectionName reads a collection name from the environment, falling back to
+// a default so that new collections do not require a configuration change.
+func collectionName(envKey, fallback string) string {
+	if name := os.Getenv(envKey); name != "" {
+		return name
+	}
+	return fallback
+}
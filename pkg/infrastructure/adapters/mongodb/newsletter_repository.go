@@ -51,6 +51,7 @@ func (r *NewsletterRepository) UpdateNewsletter(newsletter domain.Newsletter) er
 		"category":    newsletter.Category,
 		"subject":     newsletter.Subject,
 		"content":     newsletter.Content,
+		"textcontent": newsletter.TextContent,
 		"attachments": newsletter.Attachments,
 	}}
 
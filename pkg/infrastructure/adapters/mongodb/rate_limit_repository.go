@@ -0,0 +1,64 @@
+package mongodb
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitRepository keeps one document per budget window, with the number
+// of messages sent in it, which is removed once the window is over.
+type RateLimitRepository struct {
+	rateLimitCollection *mongo.Collection
+}
+
+func NewRateLimitRepository() *RateLimitRepository {
+	mongoDb := os.Getenv("mongoDb")
+	mongoRateLimitCollection := collectionName("mongoRateLimitCollection", "rateLimits")
+
+	return &RateLimitRepository{
+		rateLimitCollection: client.Database(mongoDb).Collection(mongoRateLimitCollection),
+	}
+}
+
+// CreateIndexes removes the windows that are over.
+func (r *RateLimitRepository) CreateIndexes() error {
+	_, err := r.rateLimitCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresat", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// TakeRateLimit increments the count of the window only while it is below
+// limit. When the window is full the filter matches nothing and the upsert
+// collides with the existing document, which is reported as a duplicate key.
+// Two instances creating the same window at once collide the same way, so the
+// loser tries once more against the document the other one created.
+func (r *RateLimitRepository) TakeRateLimit(key string, limit int, expiresAt time.Time) (bool, error) {
+	filter := bson.M{"_id": key, "count": bson.M{"$lt": limit}}
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expiresat": expiresAt},
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := r.rateLimitCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+		if err == nil {
+			return true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+func (r *RateLimitRepository) ReleaseRateLimit(key string) error {
+	_, err := r.rateLimitCollection.UpdateOne(context.TODO(), bson.M{"_id": key, "count": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
@@ -0,0 +1,109 @@
+package mongodb
+
+import (
+	"context"
+	domain "newsletter-app/pkg/domain/models"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SendJobRepository struct {
+	sendJobCollection *mongo.Collection
+}
+
+func NewSendJobRepository() *SendJobRepository {
+	mongoDb := os.Getenv("mongoDb")
+	mongoSendJobCollection := collectionName("mongoSendJobCollection", "sendJobs")
+
+	return &SendJobRepository{
+		sendJobCollection: client.Database(mongoDb).Collection(mongoSendJobCollection),
+	}
+}
+
+func (r *SendJobRepository) SaveSendJob(job domain.SendJob) error {
+	_, err := r.sendJobCollection.InsertOne(context.TODO(), job)
+	return err
+}
+
+func (r *SendJobRepository) GetSendJobByID(jobID string) (*domain.SendJob, error) {
+	var job domain.SendJob
+	objectID, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.sendJobCollection.FindOne(context.TODO(), bson.M{"_id": objectID}).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ClaimNextSendJob atomically hands the oldest runnable job to a worker. A job
+// is runnable when it is queued, when it is paused and its resume time has
+// come, or when it is running but its lease expired because the worker
+// holding it stopped (for example on a restart).
+func (r *SendJobRepository) ClaimNextSendJob(workerID string, leaseDuration time.Duration) (*domain.SendJob, error) {
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": domain.SendJobQueued},
+		{"status": domain.SendJobPaused, "resumeat": bson.M{"$lte": now}},
+		{"status": domain.SendJobRunning, "leaseexpiresat": bson.M{"$lt": now}},
+	}}
+
+	update := bson.M{"$set": bson.M{
+		"status":         domain.SendJobRunning,
+		"workerid":       workerID,
+		"leaseexpiresat": now.Add(leaseDuration),
+		"updatedat":      now,
+	}}
+
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "createdat", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job domain.SendJob
+	err := r.sendJobCollection.FindOneAndUpdate(context.TODO(), filter, update, findOptions).Decode(&job)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// UpdateSendJob only updates the job while job.WorkerID still holds it, so a
+// worker whose lease expired cannot overwrite the progress of the new owner.
+func (r *SendJobRepository) UpdateSendJob(job domain.SendJob) error {
+	filter := bson.M{"_id": job.ID, "workerid": job.WorkerID}
+
+	update := bson.M{"$set": bson.M{
+		"status":           job.Status,
+		"lastsubscriberid": job.LastSubscriberID,
+		"sent":             job.Sent,
+		"failed":           job.Failed,
+		"suppressed":       job.Suppressed,
+		"error":            job.Error,
+		"resumeat":         job.ResumeAt,
+		"leaseexpiresat":   job.LeaseExpiresAt,
+		"startedat":        job.StartedAt,
+		"updatedat":        job.UpdatedAt,
+		"completedat":      job.CompletedAt,
+	}}
+
+	result, err := r.sendJobCollection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrSendJobLeaseLost
+	}
+	return nil
+}
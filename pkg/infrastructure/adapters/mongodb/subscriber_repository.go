@@ -4,9 +4,12 @@ import (
 	"context"
 	domain "newsletter-app/pkg/domain/models"
 	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type SubscriberRepository struct {
@@ -22,6 +25,20 @@ func NewSubscriberRepository() *SubscriberRepository {
 	}
 }
 
+// confirmedFilter leaves out subscribers that have not confirmed their
+// subscription yet. Subscribers stored without a status are confirmed.
+var confirmedFilter = bson.M{"$ne": domain.SubscriberPending}
+
+// CreateIndexes adds the TTL index that removes pending subscribers once
+// their expiresat date passes. Confirmed subscribers have no date and are kept.
+func (r *SubscriberRepository) CreateIndexes() error {
+	_, err := r.subscriberCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresat", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
 func (r *SubscriberRepository) SaveSubscriber(subscriber domain.Subscriber) error {
 	_, err := r.subscriberCollection.InsertOne(context.TODO(), subscriber)
 	return err
@@ -37,6 +54,24 @@ func (r *SubscriberRepository) GetSubscriberByEmailAndCategory(email, category s
 	return &subscriber, nil
 }
 
+// GetSubscriberByID returns nil without an error when the subscriber does not exist.
+func (r *SubscriberRepository) GetSubscriberByID(subscriberID string) (*domain.Subscriber, error) {
+	objectID, err := primitive.ObjectIDFromHex(subscriberID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriber domain.Subscriber
+	err = r.subscriberCollection.FindOne(context.TODO(), bson.M{"_id": objectID}).Decode(&subscriber)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &subscriber, nil
+}
+
 func (r *SubscriberRepository) GetSubscribers(email, category string, page, pageSize int) ([]domain.Subscriber, error) {
 	var subscribers []domain.Subscriber
 
@@ -76,8 +111,9 @@ func (r *SubscriberRepository) DeleteSubscriberByEmail(email, category string) e
 	return err
 }
 
+// GetSubscribersByCategory returns the confirmed subscribers of a category.
 func (r *SubscriberRepository) GetSubscribersByCategory(category string) ([]domain.Subscriber, error) {
-	filter := bson.M{"category": category}
+	filter := bson.M{"category": category, "status": confirmedFilter}
 
 	cursor, err := r.subscriberCollection.Find(context.TODO(), filter)
 	if err != nil {
@@ -100,3 +136,53 @@ func (r *SubscriberRepository) GetSubscribersByCategory(category string) ([]doma
 
 	return subscribers, nil
 }
+
+// GetSubscribersByCategoryAfter returns up to limit confirmed subscribers of a
+// category whose ID is greater than afterID, in ID order, so callers can page
+// through a category and resume from the last subscriber they handled.
+func (r *SubscriberRepository) GetSubscribersByCategoryAfter(category string, afterID primitive.ObjectID, limit int) ([]domain.Subscriber, error) {
+	filter := bson.M{"category": category, "status": confirmedFilter}
+	if !afterID.IsZero() {
+		filter["_id"] = bson.M{"$gt": afterID}
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	findOptions.SetLimit(int64(limit))
+
+	cursor, err := r.subscriberCollection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var subscribers []domain.Subscriber
+	for cursor.Next(context.TODO()) {
+		var subscriber domain.Subscriber
+		if err := cursor.Decode(&subscriber); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return subscribers, nil
+}
+
+// ConfirmSubscriber makes a pending subscriber active and clears its expiry,
+// so the TTL index no longer removes it.
+func (r *SubscriberRepository) ConfirmSubscriber(subscriberID primitive.ObjectID) error {
+	update := bson.M{
+		"$set": bson.M{
+			"status":      domain.SubscriberActive,
+			"expiresat":   nil,
+			"confirmedat": time.Now(),
+		},
+	}
+
+	_, err := r.subscriberCollection.UpdateByID(context.TODO(), subscriberID, update)
+	return err
+}
@@ -0,0 +1,160 @@
+package mongodb
+
+import (
+	"context"
+	domain "newsletter-app/pkg/domain/models"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SuppressionRepository struct {
+	suppressionCollection *mongo.Collection
+}
+
+func NewSuppressionRepository() *SuppressionRepository {
+	mongoDb := os.Getenv("mongoDb")
+	mongoSuppressionCollection := collectionName("mongoSuppressionCollection", "suppressions")
+
+	return &SuppressionRepository{
+		suppressionCollection: client.Database(mongoDb).Collection(mongoSuppressionCollection),
+	}
+}
+
+// CreateIndexes makes (address hash, category) unique, which also serves the
+// lookups made for every batch of recipients.
+func (r *SuppressionRepository) CreateIndexes() error {
+	_, err := r.suppressionCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "addresshash", Value: 1}, {Key: "category", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// SaveSuppressions upserts the suppressions in one bulk write.
+func (r *SuppressionRepository) SaveSuppressions(suppressions []domain.Suppression) error {
+	if len(suppressions) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, len(suppressions))
+	for i, suppression := range suppressions {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"addresshash": suppression.AddressHash, "category": suppression.Category}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"reason":    suppression.Reason,
+					"source":    suppression.Source,
+					"detail":    suppression.Detail,
+					"updatedat": now,
+				},
+				"$setOnInsert": bson.M{"createdat": now},
+			}).
+			SetUpsert(true)
+	}
+
+	_, err := r.suppressionCollection.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *SuppressionRepository) FindSuppressions(addressHashes []string, category string) ([]domain.Suppression, error) {
+	if len(addressHashes) == 0 {
+		return nil, nil
+	}
+
+	filter := bson.M{
+		"addresshash": bson.M{"$in": addressHashes},
+		"category":    bson.M{"$in": []string{"", category}},
+	}
+	return r.find(filter, options.Find())
+}
+
+func (r *SuppressionRepository) GetSuppressions(filter domain.SuppressionFilter, page, pageSize int) ([]domain.Suppression, int64, error) {
+	query := bson.M{}
+	if filter.AddressHash != "" {
+		query["addresshash"] = filter.AddressHash
+	}
+	if filter.Category != "" {
+		query["category"] = filter.Category
+	}
+	if filter.Reason != "" {
+		query["reason"] = filter.Reason
+	}
+
+	total, err := r.suppressionCollection.CountDocuments(context.TODO(), query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	findOptions.SetSkip(int64((page - 1) * pageSize))
+	findOptions.SetLimit(int64(pageSize))
+
+	suppressions, err := r.find(query, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	return suppressions, total, nil
+}
+
+func (r *SuppressionRepository) DeleteSuppressions(addressHash, category string) (int64, error) {
+	filter := bson.M{"addresshash": addressHash}
+	if category != "" {
+		filter["category"] = category
+	}
+
+	result, err := r.suppressionCollection.DeleteMany(context.TODO(), filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// EachSuppression calls fn with every suppression in insertion order, reading
+// them from a cursor instead of loading the whole list.
+func (r *SuppressionRepository) EachSuppression(fn func(domain.Suppression) error) error {
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.suppressionCollection.Find(context.TODO(), bson.M{}, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var suppression domain.Suppression
+		if err := cursor.Decode(&suppression); err != nil {
+			return err
+		}
+		if err := fn(suppression); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *SuppressionRepository) find(filter bson.M, findOptions *options.FindOptions) ([]domain.Suppression, error) {
+	cursor, err := r.suppressionCollection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	suppressions := []domain.Suppression{}
+	for cursor.Next(context.TODO()) {
+		var suppression domain.Suppression
+		if err := cursor.Decode(&suppression); err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, suppression)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return suppressions, nil
+}
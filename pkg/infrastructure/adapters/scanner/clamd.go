@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// clamdChunkSize is how much content is sent in each INSTREAM chunk.
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner scans attachments with a ClamAV daemon, streaming the content
+// with the INSTREAM command so that clamd does not need access to the files.
+// Each scan opens a new connection and must finish within the timeout.
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClamdScanner(network, address string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{network: network, address: address, timeout: timeout}
+}
+
+func (s *ClamdScanner) Scan(content io.Reader) (*domain.AttachmentScan, error) {
+	conn, err := net.DialTimeout(s.network, s.address, s.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	var writeErr *clamdWriteError
+	if err := instream(conn, content); errors.As(err, &writeErr) {
+		// clamd stops reading and says why when the stream goes over its
+		// StreamMaxLength, so its reply explains the failure better.
+		if reply, replyErr := readClamdReply(conn); replyErr == nil {
+			return parseClamdReply(reply)
+		}
+		return nil, fmt.Errorf("clamd: %w", writeErr.err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: reading reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// clamdWriteError is a failure to send to clamd, as opposed to a failure to
+// read the content being scanned.
+type clamdWriteError struct {
+	err error
+}
+
+func (e *clamdWriteError) Error() string {
+	return e.err.Error()
+}
+
+// instream sends content with the INSTREAM command: chunks prefixed by their
+// length as a 4-byte big-endian integer, ended by a zero length.
+func instream(conn net.Conn, content io.Reader) error {
+	if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+		return &clamdWriteError{err}
+	}
+
+	buffer := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(content, buffer[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buffer, uint32(n))
+			if _, writeErr := conn.Write(buffer[:4+n]); writeErr != nil {
+				return &clamdWriteError{writeErr}
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading attachment: %w", err)
+		}
+	}
+
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return &clamdWriteError{err}
+	}
+	return nil
+}
+
+// readClamdReply reads a reply, which ends with a NUL for commands prefixed
+// with z.
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", err
+	}
+	return strings.TrimRight(reply, "\x00\r\n"), nil
+}
+
+// parseClamdReply turns "stream: OK", "stream: <signature> FOUND" and
+// "... ERROR" replies into a scan result or an error.
+func parseClamdReply(reply string) (*domain.AttachmentScan, error) {
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return &domain.AttachmentScan{Status: domain.AttachmentScanClean, Scanner: ScannerClamd}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &domain.AttachmentScan{
+			Status:    domain.AttachmentScanInfected,
+			Scanner:   ScannerClamd,
+			Signature: strings.TrimSuffix(result, " FOUND"),
+		}, nil
+	}
+	return nil, fmt.Errorf("clamd: %s", strings.TrimSuffix(result, " ERROR"))
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+const (
+	defaultClamdAddress = "localhost:3310"
+	defaultClamdTimeout = time.Minute
+)
+
+type AttachmentScanner interface {
+	Scan(content io.Reader) (*domain.AttachmentScan, error)
+}
+
+// Attachment scanners selectable with attachmentScanner.
+const (
+	ScannerNone  = "none"
+	ScannerClamd = "clamd"
+)
+
+// NewAttachmentScannerFromEnv returns the scanner named by attachmentScanner,
+// the NoopScanner when it is unset. For clamd it reads clamdAddress, a
+// host:port or the path of a Unix socket (defaults to localhost:3310), and
+// clamdTimeout (defaults to 1m).
+func NewAttachmentScannerFromEnv() (AttachmentScanner, error) {
+	name := strings.ToLower(os.Getenv("attachmentScanner"))
+	switch name {
+	case "", ScannerNone:
+		return NoopScanner{}, nil
+	case ScannerClamd:
+		timeout := defaultClamdTimeout
+		if raw := os.Getenv("clamdTimeout"); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("clamd: clamdTimeout %q is not a positive duration", raw)
+			}
+			timeout = parsed
+		}
+
+		address := os.Getenv("clamdAddress")
+		if address == "" {
+			address = defaultClamdAddress
+		}
+		network := "tcp"
+		if strings.HasPrefix(address, "/") {
+			network = "unix"
+		}
+		return NewClamdScanner(network, address, timeout), nil
+	}
+	return nil, fmt.Errorf("attachmentScanner %q must be one of none or clamd", name)
+}
+
+// NoopScanner does not scan attachments. Their scan is skipped, which lets
+// them be sent.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(content io.Reader) (*domain.AttachmentScan, error) {
+	return &domain.AttachmentScan{Status: domain.AttachmentScanSkipped}, nil
+}
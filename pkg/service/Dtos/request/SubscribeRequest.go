@@ -0,0 +1,8 @@
+package request
+
+// SubscribeRequest represents the optional body of a subscription request.
+// Attributes are custom fields of the subscriber that newsletter templates can
+// use, for example {{index .Attributes "first_name"}}.
+type SubscribeRequest struct {
+	Attributes map[string]string `json:"attributes"`
+}
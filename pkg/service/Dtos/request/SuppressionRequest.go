@@ -0,0 +1,15 @@
+package request
+
+import domain "newsletter-app/pkg/domain/models"
+
+// SuppressionRequest represents an address to add to the suppression list,
+// given as its Email or as the AddressHash found in an export. An empty
+// Category suppresses the address in every category, and an empty Reason is
+// manual.
+type SuppressionRequest struct {
+	Email       string                   `json:"email,omitempty"`
+	AddressHash string                   `json:"address_hash,omitempty"`
+	Category    string                   `json:"category,omitempty"`
+	Reason      domain.SuppressionReason `json:"reason,omitempty"`
+	Detail      string                   `json:"detail,omitempty"`
+}
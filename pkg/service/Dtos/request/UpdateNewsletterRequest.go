@@ -9,13 +9,19 @@ type UpdateNewsletterRequest struct {
 	Category    string             `json:"category"`
 	Subject     string             `json:"subject"`
 	Content     string             `json:"content"`
+	TextContent string             `json:"text_content,omitempty"`
 	Attachments []Attachment       `json:"attachments"`
 }
 
-// represents a file attached to the newsletter.
+// represents a file attached to the newsletter: an attachment uploaded to
+// /api/v1/attachments, referenced by ID, or base64 Data to store.
+// An attachment with a ContentID is embedded inline instead of offered as a
+// download, and the content refers to it as cid:<ContentID>.
 // swagger:model
 type Attachment struct {
-	Name string `json:"name"`
-	Data string `json:"data"`
-	Type string `json:"type"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Data      string `json:"data,omitempty"`
+	Type      string `json:"type,omitempty"`
+	ContentID string `json:"content_id,omitempty"`
 }
@@ -0,0 +1,14 @@
+package response
+
+// AttachmentErrorResponse represents an attachment rejected by the attachment
+// policy. Code is attachment_too_large, message_too_large, type_not_allowed or
+// type_mismatch, and sizes are in bytes.
+type AttachmentErrorResponse struct {
+	Error        string `json:"error"`
+	Code         string `json:"code"`
+	Attachment   string `json:"attachment,omitempty"`
+	Type         string `json:"type,omitempty"`
+	DetectedType string `json:"detected_type,omitempty"`
+	Size         int64  `json:"size,omitempty"`
+	Limit        int64  `json:"limit,omitempty"`
+}
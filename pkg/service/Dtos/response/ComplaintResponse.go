@@ -0,0 +1,11 @@
+package response
+
+// ComplaintResponse represents the outcome of ingesting an abuse report.
+type ComplaintResponse struct {
+	FeedbackType string `json:"feedback_type"`
+	// NewsletterID is the newsletter the complaint was matched to, if any.
+	NewsletterID string `json:"newsletter_id,omitempty"`
+	// Suppressed counts the addresses unsubscribed and suppressed, none for
+	// reports that are not complaints.
+	Suppressed int `json:"suppressed"`
+}
@@ -0,0 +1,13 @@
+package response
+
+import domain "newsletter-app/pkg/domain/models"
+
+// DeliveriesResponse represents a page of deliveries of a newsletter together
+// with the per-status summary of all its deliveries.
+type DeliveriesResponse struct {
+	Deliveries []domain.Delivery      `json:"deliveries"`
+	Summary    domain.DeliverySummary `json:"summary"`
+	Page       int                    `json:"page"`
+	PageSize   int                    `json:"page_size"`
+	Total      int64                  `json:"total"`
+}
@@ -0,0 +1,11 @@
+package response
+
+import domain "newsletter-app/pkg/domain/models"
+
+// OutboxMessagesResponse represents a page of captured messages, newest first.
+type OutboxMessagesResponse struct {
+	Messages []domain.OutboxMessage `json:"messages"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"page_size"`
+	Total    int64                  `json:"total"`
+}
@@ -0,0 +1,9 @@
+package response
+
+// SendNewsletterResponse represents a newsletter queued for sending.
+type SendNewsletterResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// JobID is the send job that delivers the newsletter.
+	JobID string `json:"jobId"`
+}
@@ -0,0 +1,16 @@
+package response
+
+import domain "newsletter-app/pkg/domain/models"
+
+// SuppressionsResponse represents a page of the suppression list.
+type SuppressionsResponse struct {
+	Suppressions []domain.Suppression `json:"suppressions"`
+	Page         int                  `json:"page"`
+	PageSize     int                  `json:"page_size"`
+	Total        int64                `json:"total"`
+}
+
+// SuppressionImportResponse represents the outcome of a bulk import.
+type SuppressionImportResponse struct {
+	Imported int `json:"imported"`
+}
@@ -0,0 +1,7 @@
+package response
+
+// WebhookResponse represents the outcome of a provider webhook.
+type WebhookResponse struct {
+	// Events counts the events of the payload that were applied.
+	Events int `json:"events"`
+}
@@ -0,0 +1,228 @@
+// Package arf parses the abuse reports that mailbox providers send through
+// their feedback loops when a recipient marks a message as spam, in the Abuse
+// Reporting Format (RFC 5965).
+package arf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrNotARF is returned for messages that carry no feedback report.
+var ErrNotARF = errors.New("arf: not an abuse report")
+
+// maxDepth bounds how deeply nested multiparts are searched for the report.
+const maxDepth = 5
+
+// Feedback types (RFC 5965 and RFC 6591).
+const (
+	FeedbackAbuse       = "abuse"
+	FeedbackFraud       = "fraud"
+	FeedbackVirus       = "virus"
+	FeedbackOther       = "other"
+	FeedbackNotSpam     = "not-spam"
+	FeedbackAuthFailure = "auth-failure"
+)
+
+// Report is a parsed abuse report.
+type Report struct {
+	// FeedbackType is abuse for a spam complaint, or one of the other
+	// feedback types.
+	FeedbackType string
+	// UserAgent names the feedback loop, such as Yahoo!-Mail-Feedback/2.0.
+	UserAgent string
+	// OriginalMailFrom is the envelope sender of the reported message,
+	// which names the delivery when it is a VERP address.
+	OriginalMailFrom string
+	// OriginalRcptTo are the envelope recipients of the reported message.
+	// Many feedback loops leave them out or redact them.
+	OriginalRcptTo []string
+	ArrivalDate    time.Time
+	ReportingMTA   string
+	SourceIP       string
+	// OriginalHeaders are the headers of the reported message, when the
+	// report includes them.
+	OriginalHeaders textproto.MIMEHeader
+}
+
+// IsComplaint reports whether the recipient complained about the message,
+// as opposed to reports such as not-spam or auth-failure.
+func (r *Report) IsComplaint() bool {
+	switch r.FeedbackType {
+	case FeedbackAbuse, FeedbackFraud, FeedbackVirus:
+		return true
+	}
+	return false
+}
+
+// OriginalMessageID returns the Message-ID of the reported message without
+// its angle brackets, or "" when the report does not include it.
+func (r *Report) OriginalMessageID() string {
+	if r.OriginalHeaders == nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(r.OriginalHeaders.Get("Message-Id")), "<>")
+}
+
+// OriginalReturnPath returns the envelope sender of the reported message,
+// from the Original-Mail-From field or else from its Return-Path header.
+func (r *Report) OriginalReturnPath() string {
+	if r.OriginalMailFrom != "" {
+		return r.OriginalMailFrom
+	}
+	if r.OriginalHeaders == nil {
+		return ""
+	}
+	return address(r.OriginalHeaders.Get("Return-Path"))
+}
+
+// Recipients returns the addresses the reported message was sent to, from
+// the Original-Rcpt-To fields or else from its To header.
+func (r *Report) Recipients() []string {
+	if len(r.OriginalRcptTo) > 0 {
+		return r.OriginalRcptTo
+	}
+	if r.OriginalHeaders == nil {
+		return nil
+	}
+	list, err := mail.ParseAddressList(r.OriginalHeaders.Get("To"))
+	if err != nil {
+		return nil
+	}
+	recipients := make([]string, 0, len(list))
+	for _, to := range list {
+		recipients = append(recipients, to.Address)
+	}
+	return recipients
+}
+
+// Parse parses an abuse report. It returns ErrNotARF when the message has no
+// feedback report part.
+func Parse(raw []byte) (*Report, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("arf: %w", err)
+	}
+
+	p := &parser{report: &Report{}}
+	if err := p.part(textproto.MIMEHeader(message.Header), message.Body, 0); err != nil {
+		return nil, fmt.Errorf("arf: %w", err)
+	}
+	if !p.found {
+		return nil, ErrNotARF
+	}
+	return p.report, nil
+}
+
+type parser struct {
+	report *Report
+	found  bool
+}
+
+func (p *parser) part(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	body = decodeTransferEncoding(header, body)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxDepth || params["boundary"] == "" {
+			return nil
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.part(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+
+	case mediaType == "message/feedback-report":
+		if p.found {
+			return nil
+		}
+		p.found = true
+		return p.feedbackReport(body)
+
+	case mediaType == "text/rfc822-headers", mediaType == "message/rfc822",
+		mediaType == "message/global", mediaType == "message/global-headers":
+		if p.report.OriginalHeaders != nil {
+			return nil
+		}
+		headers, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+		if len(headers) > 0 {
+			p.report.OriginalHeaders = headers
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// feedbackReport reads the fields of the machine-readable part, a single
+// block of header fields.
+func (p *parser) feedbackReport(body io.Reader) error {
+	fields, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	p.report.FeedbackType = strings.ToLower(strings.TrimSpace(fields.Get("Feedback-Type")))
+	p.report.UserAgent = strings.TrimSpace(fields.Get("User-Agent"))
+	p.report.OriginalMailFrom = address(fields.Get("Original-Mail-From"))
+	for _, rcptTo := range fields.Values("Original-Rcpt-To") {
+		if rcptTo = address(rcptTo); rcptTo != "" {
+			p.report.OriginalRcptTo = append(p.report.OriginalRcptTo, rcptTo)
+		}
+	}
+	if arrivalDate, err := mail.ParseDate(strings.TrimSpace(fields.Get("Arrival-Date"))); err == nil {
+		p.report.ArrivalDate = arrivalDate
+	}
+	p.report.ReportingMTA = typedValue(fields.Get("Reporting-MTA"))
+	p.report.SourceIP = strings.TrimSpace(fields.Get("Source-Ip"))
+	return nil
+}
+
+// typedValue returns the value of a field such as "dns; mta.example.com"
+// without its type.
+func typedValue(value string) string {
+	if _, rest, found := strings.Cut(value, ";"); found {
+		return strings.TrimSpace(rest)
+	}
+	return strings.TrimSpace(value)
+}
+
+func address(value string) string {
+	return strings.Trim(value, "<> ")
+}
+
+func decodeTransferEncoding(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
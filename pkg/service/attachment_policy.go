@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultAttachmentMaxSize        = 10 << 20
+	defaultAttachmentMaxMessageSize = 20 << 20
+	// sniffLength is how much of an attachment is read to detect its type.
+	sniffLength = 512
+)
+
+// defaultAttachmentAllowedTypes are documents and raster images. Archives,
+// executables and SVG images, which can carry script and are refused by most
+// mail filters, have to be allowed explicitly.
+var defaultAttachmentAllowedTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"text/plain",
+	"text/csv",
+	"text/calendar",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.*",
+	"application/vnd.oasis.opendocument.*",
+}
+
+// Codes of an AttachmentError.
+const (
+	AttachmentTooLarge     = "attachment_too_large"
+	MessageTooLarge        = "message_too_large"
+	AttachmentTypeDenied   = "type_not_allowed"
+	AttachmentTypeMismatch = "type_mismatch"
+)
+
+// AttachmentError is an attachment rejected by the AttachmentPolicy. Code
+// tells which rule it broke. Sizes are in bytes.
+type AttachmentError struct {
+	Code         string
+	Attachment   string
+	Type         string
+	DetectedType string
+	Size         int64
+	Limit        int64
+}
+
+func (e *AttachmentError) Error() string {
+	switch e.Code {
+	case AttachmentTooLarge:
+		return fmt.Sprintf("attachment %q is larger than %d bytes", e.Attachment, e.Limit)
+	case MessageTooLarge:
+		return fmt.Sprintf("attachments add up to %d bytes, more than %d", e.Size, e.Limit)
+	case AttachmentTypeMismatch:
+		return fmt.Sprintf("attachment %q is declared as %s but looks like %s", e.Attachment, e.Type, e.DetectedType)
+	}
+	return fmt.Sprintf("attachment %q has type %s, which is not allowed", e.Attachment, e.Type)
+}
+
+// AttachmentPolicy limits the attachments of a newsletter. A zero MaxSize or
+// MaxMessageSize is no limit, and an empty AllowedTypes allows every type.
+// AllowedTypes are media types, or prefixes ending in "*" such as "image/*".
+type AttachmentPolicy struct {
+	MaxSize        int64
+	MaxMessageSize int64
+	AllowedTypes   []string
+}
+
+// NewAttachmentPolicyFromEnv reads attachmentMaxSize and
+// attachmentMaxMessageSize, in bytes, and attachmentAllowedTypes, a comma
+// separated list where "*" allows every type.
+func NewAttachmentPolicyFromEnv() AttachmentPolicy {
+	size := func(key string, fallback int64) int64 {
+		value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+		if err != nil || value < 0 {
+			return fallback
+		}
+		return value
+	}
+
+	policy := AttachmentPolicy{
+		MaxSize:        size("attachmentMaxSize", defaultAttachmentMaxSize),
+		MaxMessageSize: size("attachmentMaxMessageSize", defaultAttachmentMaxMessageSize),
+		AllowedTypes:   defaultAttachmentAllowedTypes,
+	}
+
+	if allowed := strings.TrimSpace(os.Getenv("attachmentAllowedTypes")); allowed != "" {
+		policy.AllowedTypes = nil
+		for _, contentType := range strings.Split(allowed, ",") {
+			contentType = strings.ToLower(strings.TrimSpace(contentType))
+			if contentType == "*" {
+				policy.AllowedTypes = nil
+				break
+			}
+			if contentType != "" {
+				policy.AllowedTypes = append(policy.AllowedTypes, contentType)
+			}
+		}
+	}
+	return policy
+}
+
+// checkSize rejects an attachment larger than MaxSize.
+func (p AttachmentPolicy) checkSize(name string, size int64) error {
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return &AttachmentError{Code: AttachmentTooLarge, Attachment: name, Size: size, Limit: p.MaxSize}
+	}
+	return nil
+}
+
+// checkMessageSize rejects attachments that add up to more than
+// MaxMessageSize.
+func (p AttachmentPolicy) checkMessageSize(total int64) error {
+	if p.MaxMessageSize > 0 && total > p.MaxMessageSize {
+		return &AttachmentError{Code: MessageTooLarge, Size: total, Limit: p.MaxMessageSize}
+	}
+	return nil
+}
+
+// checkType rejects a content type outside AllowedTypes and, when the first
+// bytes of the content are given, content that does not look like that type.
+func (p AttachmentPolicy) checkType(name, contentType string, head []byte) error {
+	mediaType := baseMediaType(contentType)
+	if !p.allows(mediaType) {
+		return &AttachmentError{Code: AttachmentTypeDenied, Attachment: name, Type: contentType}
+	}
+
+	if head != nil {
+		if detected := baseMediaType(http.DetectContentType(head)); !contentMatches(mediaType, detected) {
+			return &AttachmentError{Code: AttachmentTypeMismatch, Attachment: name, Type: contentType, DetectedType: detected}
+		}
+	}
+	return nil
+}
+
+func (p AttachmentPolicy) allows(mediaType string) bool {
+	if len(p.AllowedTypes) == 0 {
+		return true
+	}
+	if mediaType == "" {
+		return false
+	}
+	for _, allowed := range p.AllowedTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(mediaType, prefix) {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader reads an attachment being uploaded and fails with an
+// AttachmentError once it goes over MaxSize.
+func (p AttachmentPolicy) limitedReader(name string, content io.Reader) io.Reader {
+	if p.MaxSize <= 0 {
+		return content
+	}
+	return &sizeLimitedReader{policy: p, name: name, reader: content}
+}
+
+type sizeLimitedReader struct {
+	policy AttachmentPolicy
+	name   string
+	reader io.Reader
+	n      int64
+}
+
+func (r *sizeLimitedReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.n += int64(n)
+	if limitErr := r.policy.checkSize(r.name, r.n); limitErr != nil {
+		return n, limitErr
+	}
+	return n, err
+}
+
+// attachmentType returns the type to store for an attachment: the declared
+// type, unless it is missing or the generic application/octet-stream, in which
+// case it is guessed from the extension of name or from the content.
+func attachmentType(name, declared string, head []byte) string {
+	if declared != "" && baseMediaType(declared) != "application/octet-stream" {
+		return declared
+	}
+	if byExtension := mime.TypeByExtension(filepath.Ext(name)); byExtension != "" {
+		return byExtension
+	}
+	if head != nil {
+		return http.DetectContentType(head)
+	}
+	return "application/octet-stream"
+}
+
+func baseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// contentMatches reports whether content detected as detected, one of the
+// types of http.DetectContentType, can be of the declared type. Detection
+// only recognizes a few formats, so text and zip containers match the more
+// specific types built on them, and content it does not recognize matches
+// every type it cannot recognize either.
+func contentMatches(declared, detected string) bool {
+	if declared == detected {
+		return true
+	}
+
+	switch detected {
+	case "text/plain":
+		return strings.HasPrefix(declared, "text/") || isTextApplication(declared)
+	case "text/xml":
+		return declared == "application/xml" || strings.HasSuffix(declared, "+xml")
+	case "application/zip":
+		return strings.HasSuffix(declared, "+zip") ||
+			strings.HasPrefix(declared, "application/vnd.openxmlformats-officedocument.") ||
+			strings.HasPrefix(declared, "application/vnd.oasis.opendocument.") ||
+			declared == "application/x-zip-compressed" || declared == "application/java-archive"
+	case "application/x-gzip":
+		return declared == "application/gzip"
+	case "application/octet-stream":
+		return !isDetectable(declared)
+	}
+	return false
+}
+
+func isTextApplication(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/xml", "application/csv", "application/javascript":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// isDetectable reports whether http.DetectContentType recognizes content of
+// the media type, so that content it does not recognize cannot be of it.
+func isDetectable(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"), isTextApplication(mediaType):
+		return true
+	case mediaType == "image/png", mediaType == "image/jpeg", mediaType == "image/gif",
+		mediaType == "image/webp", mediaType == "image/bmp", mediaType == "image/x-icon":
+		return true
+	case mediaType == "application/pdf", mediaType == "application/zip",
+		mediaType == "application/x-gzip", mediaType == "application/gzip",
+		mediaType == "application/x-rar-compressed", mediaType == "application/wasm":
+		return true
+	}
+	return false
+}
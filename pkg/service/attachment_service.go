@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"strings"
+	"time"
+)
+
+var _ ports.AttachmentServicePort = (*AttachmentService)(nil)
+
+var (
+	ErrAttachmentNotFound     = errors.New("attachment not found")
+	ErrAttachmentNameRequired = errors.New("attachment name is required")
+	ErrAttachmentInfected     = errors.New("attachment is infected")
+	ErrAttachmentNotScanned   = errors.New("attachment has not been scanned")
+)
+
+type AttachmentService struct {
+	attachmentRepository ports.AttachmentRepositoryPort
+	policy               AttachmentPolicy
+}
+
+func NewAttachmentService(attachmentRepo ports.AttachmentRepositoryPort, policy AttachmentPolicy) *AttachmentService {
+	return &AttachmentService{
+		attachmentRepository: attachmentRepo,
+		policy:               policy,
+	}
+}
+
+// UploadAttachment stores the content of a file that newsletters can then
+// refer to by the returned attachment's ID. When contentType is empty or
+// application/octet-stream it is guessed from the extension of name or from
+// the content. The type and size are checked against the attachment policy
+// while the content is streamed; a violation is an *AttachmentError and
+// leaves nothing stored.
+func (s *AttachmentService) UploadAttachment(name, contentType string, content io.Reader) (*domain.Attachment, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrAttachmentNameRequired
+	}
+
+	buffered := bufio.NewReaderSize(content, sniffLength)
+	head, err := buffered.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	contentType = attachmentType(name, contentType, head)
+	if err := s.policy.checkType(name, contentType, head); err != nil {
+		return nil, err
+	}
+
+	return s.attachmentRepository.SaveAttachment(domain.Attachment{Name: name, Type: contentType}, s.policy.limitedReader(name, buffered))
+}
+
+// OpenAttachment returns the metadata of an attachment and a stream of its
+// content, which the caller must close.
+func (s *AttachmentService) OpenAttachment(id string) (*domain.Attachment, io.ReadCloser, error) {
+	attachment, err := s.attachmentRepository.GetAttachment(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment == nil {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	content, err := s.attachmentRepository.OpenAttachment(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// storeAttachments resolves the attachments of a newsletter being saved, so
+// that the newsletter only keeps their metadata. References to uploaded
+// attachments take the stored metadata, and base64 Data is decoded and moved
+// into the attachment repository. Every attachment is checked against the
+// policy, and nothing is stored until all of them pass. Then every attachment
+// is scanned and keeps the result, so that it can be sent only if it is clean.
+func storeAttachments(attachmentRepo ports.AttachmentRepositoryPort, policy AttachmentPolicy, scanner ports.AttachmentScanner, attachments []domain.Attachment) ([]domain.Attachment, error) {
+	resolved := make([]domain.Attachment, len(attachments))
+	decoded := make([][]byte, len(attachments))
+	var total int64
+
+	for i, attachment := range attachments {
+		if attachment.ID != "" {
+			stored, err := attachmentRepo.GetAttachment(attachment.ID)
+			if err != nil {
+				return nil, err
+			}
+			if stored == nil {
+				return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, attachment.ID)
+			}
+			// The content was checked when it was uploaded, but the policy
+			// may have changed since.
+			if err := policy.checkType(stored.Name, stored.Type, nil); err != nil {
+				return nil, err
+			}
+			if err := policy.checkSize(stored.Name, stored.Size); err != nil {
+				return nil, err
+			}
+			stored.ContentID = attachment.ContentID
+			resolved[i] = *stored
+			total += stored.Size
+			continue
+		}
+
+		if strings.TrimSpace(attachment.Name) == "" {
+			return nil, ErrAttachmentNameRequired
+		}
+		data, err := base64.StdEncoding.DecodeString(attachment.Data)
+		if err != nil {
+			return nil, ErrInvalidAttachment
+		}
+
+		head := data
+		if len(head) > sniffLength {
+			head = head[:sniffLength]
+		}
+		contentType := attachmentType(attachment.Name, attachment.Type, head)
+		if err := policy.checkType(attachment.Name, contentType, head); err != nil {
+			return nil, err
+		}
+		if err := policy.checkSize(attachment.Name, int64(len(data))); err != nil {
+			return nil, err
+		}
+
+		resolved[i] = domain.Attachment{Name: attachment.Name, Type: contentType, ContentID: attachment.ContentID}
+		decoded[i] = data
+		total += int64(len(data))
+	}
+
+	if err := policy.checkMessageSize(total); err != nil {
+		return nil, err
+	}
+
+	for i, attachment := range attachments {
+		var err error
+		if attachment.ID != "" {
+			resolved[i].Scan, err = scanAttachment(scanner, resolved[i].Name, func() (io.ReadCloser, error) {
+				return attachmentRepo.OpenAttachment(attachment.ID)
+			})
+		} else {
+			data := decoded[i]
+			resolved[i].Scan, err = scanAttachment(scanner, resolved[i].Name, func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, attachment := range attachments {
+		if attachment.ID != "" {
+			continue
+		}
+
+		stored, err := attachmentRepo.SaveAttachment(resolved[i], bytes.NewReader(decoded[i]))
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = *stored
+	}
+
+	if len(resolved) == 0 {
+		return nil, nil
+	}
+	return resolved, nil
+}
+
+// scanAttachment scans the content of an attachment. A scanner that fails
+// does not stop the newsletter from being saved: the attachment keeps a
+// failed scan, which blocks sending until the newsletter is saved again.
+func scanAttachment(scanner ports.AttachmentScanner, name string, open func() (io.ReadCloser, error)) (*domain.AttachmentScan, error) {
+	content, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	scan, err := scanner.Scan(content)
+	if err != nil {
+		fmt.Printf("Error scanning attachment %s: %s\n", name, err.Error())
+		scan = &domain.AttachmentScan{Status: domain.AttachmentScanFailed, Error: err.Error()}
+	}
+	scan.ScannedAt = time.Now()
+	return scan, nil
+}
+
+// checkAttachmentScan refuses to send an attachment that is infected or was
+// not scanned successfully.
+func checkAttachmentScan(attachment domain.Attachment) error {
+	if attachment.Scan.AllowsSending() {
+		return nil
+	}
+	if attachment.Scan != nil && attachment.Scan.Status == domain.AttachmentScanInfected {
+		return fmt.Errorf("%w: %s (%s)", ErrAttachmentInfected, attachment.Name, attachment.Scan.Signature)
+	}
+	return fmt.Errorf("%w: %s", ErrAttachmentNotScanned, attachment.Name)
+}
+
+// openAttachments prepares the attachments of a newsletter for sending.
+// Every attachment must have passed its scan. Stored attachments are streamed from the attachment repository when a
+// message is written. Attachments saved with base64 Data before they were
+// kept in GridFS are decoded once here rather than for every message.
+func openAttachments(attachmentRepo ports.AttachmentRepositoryPort, attachments []domain.Attachment) ([]*domain.Attachment, error) {
+	var opened []*domain.Attachment
+
+	for _, attachment := range attachments {
+		if err := checkAttachmentScan(attachment); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, attachment := range attachments {
+		attachment := attachment
+		if attachment.ID != "" {
+			stored, err := attachmentRepo.GetAttachment(attachment.ID)
+			if err != nil {
+				return nil, err
+			}
+			if stored == nil {
+				return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, attachment.ID)
+			}
+
+			id := attachment.ID
+			attachment.Content = func() (io.ReadCloser, error) {
+				return attachmentRepo.OpenAttachment(id)
+			}
+		} else {
+			data, err := base64.StdEncoding.DecodeString(attachment.Data)
+			if err != nil {
+				return nil, ErrInvalidAttachment
+			}
+
+			attachment.Data = ""
+			attachment.Content = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+
+		opened = append(opened, &attachment)
+	}
+
+	return opened, nil
+}
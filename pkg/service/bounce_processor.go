@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/dsn"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const defaultBouncePollInterval = time.Minute
+
+// BounceProcessor reads the delivery status notifications returned to the
+// sender from a local mailbox. Each bounce is linked to its delivery through
+// the VERP address or the Message-ID set by BounceTracking: a hard bounce
+// marks the delivery bounced and suppresses the address in every category,
+// and a soft bounce is counted on the delivery.
+type BounceProcessor struct {
+	mailbox            ports.Mailbox
+	deliveryRepository ports.DeliveryRepositoryPort
+	suppressions       *SuppressionService
+	bounceTracking     *BounceTracking
+	pollInterval       time.Duration
+}
+
+func NewBounceProcessor(
+	mailbox ports.Mailbox,
+	deliveryRepo ports.DeliveryRepositoryPort,
+	suppressions *SuppressionService,
+	bounceTracking *BounceTracking,
+	pollInterval time.Duration,
+) *BounceProcessor {
+	if pollInterval <= 0 {
+		pollInterval = defaultBouncePollInterval
+	}
+	return &BounceProcessor{
+		mailbox:            mailbox,
+		deliveryRepository: deliveryRepo,
+		suppressions:       suppressions,
+		bounceTracking:     bounceTracking,
+		pollInterval:       pollInterval,
+	}
+}
+
+// NewBounceProcessorFromEnv reads bouncePollInterval (defaults to 1m).
+func NewBounceProcessorFromEnv(mailbox ports.Mailbox, deliveryRepo ports.DeliveryRepositoryPort, suppressions *SuppressionService, bounceTracking *BounceTracking) *BounceProcessor {
+	pollInterval, _ := time.ParseDuration(os.Getenv("bouncePollInterval"))
+	return NewBounceProcessor(mailbox, deliveryRepo, suppressions, bounceTracking, pollInterval)
+}
+
+// Start reads the mailbox every poll interval until ctx is done.
+func (p *BounceProcessor) Start(ctx context.Context) {
+	go func() {
+		for {
+			if err := p.mailbox.Process(p.ProcessMessage); err != nil {
+				fmt.Printf("Error processing bounces: %s\n", err.Error())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.pollInterval):
+			}
+		}
+	}()
+}
+
+// ProcessMessage handles one message of the bounce mailbox. Messages that are
+// not delivery status notifications, such as autoreplies, and notifications
+// that cannot be parsed are skipped. Only errors storing the bounce are
+// returned, so that the message is processed again.
+func (p *BounceProcessor) ProcessMessage(message []byte) error {
+	report, err := dsn.Parse(message)
+	if errors.Is(err, dsn.ErrNotDSN) {
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("Error parsing bounce: %s\n", err.Error())
+		return nil
+	}
+
+	newsletterID, subscriberID, _ := p.bounceTracking.Match(report)
+	for _, recipient := range report.Recipients {
+		bounceType, ok := classifyBounce(recipient)
+		if !ok {
+			continue
+		}
+
+		bounce := domain.Bounce{
+			Type:       bounceType,
+			Recipient:  recipient.Address(),
+			Status:     recipient.Status,
+			Diagnostic: recipient.DiagnosticCode,
+			ReportedBy: report.ReportingMTA,
+			ReportedAt: time.Now(),
+		}
+		if err := p.RecordBounce(newsletterID, subscriberID, bounce); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordBounce stores a bounce on its delivery, and suppresses the address of
+// the delivery in every category when the bounce is hard. Anyone can send a
+// bounce, so one that could not be matched to a delivery, with a NilObjectID
+// subscriberID, or whose delivery does not exist, is only recorded for review
+// and never suppresses the address it names.
+func (p *BounceProcessor) RecordBounce(newsletterID string, subscriberID primitive.ObjectID, bounce domain.Bounce) error {
+	if subscriberID.IsZero() {
+		return p.deliveryRepository.RecordUnmatchedBounce(bounce)
+	}
+
+	delivery, err := p.deliveryRepository.RecordBounce(newsletterID, subscriberID, bounce)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return p.deliveryRepository.RecordUnmatchedBounce(bounce)
+	}
+
+	if bounce.Type == domain.BounceHard && IsValidEmail(delivery.Email) {
+		detail := strings.TrimSpace(bounce.Status + " " + bounce.Diagnostic)
+		if err := p.suppressions.Suppress(delivery.Email, "", domain.SuppressionHardBounce, SuppressionSourceBounce, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// classifyBounce tells whether a recipient of a notification bounced, and
+// how. Permanent failures (5.x.x) are hard bounces, except those that do not
+// mean the address is bad: a full mailbox (x.2.2), a message that is too
+// large (x.3.4) and rejections for policy or security reasons (x.7.x), which
+// are soft bounces like temporary failures and delays.
+func classifyBounce(recipient dsn.Recipient) (domain.BounceType, bool) {
+	switch recipient.Action {
+	case "failed":
+	case "delayed":
+		return domain.BounceSoft, true
+	default:
+		return "", false
+	}
+
+	if recipient.Class() != '5' {
+		return domain.BounceSoft, true
+	}
+	if isSoftFailureSubject(recipient.Subject()) {
+		return domain.BounceSoft, true
+	}
+	return domain.BounceHard, true
+}
+
+// isSoftFailureSubject reports whether the subject and detail of an enhanced
+// status code, such as 2.2 in 5.2.2, name a permanent failure that does not
+// mean the address is bad.
+func isSoftFailureSubject(subject string) bool {
+	return subject == "2.2" || subject == "3.4" || strings.HasPrefix(subject, "7.")
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"net/mail"
+	"newsletter-app/pkg/service/arf"
+	"newsletter-app/pkg/service/dsn"
+	"newsletter-app/pkg/service/webhook"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deliveryKeyPattern matches the newsletter and subscriber IDs a delivery is
+// tagged with, in VERP addresses and in Message-IDs.
+var deliveryKeyPattern = regexp.MustCompile(`^([0-9a-f]{24})[.-]([0-9a-f]{24})$`)
+
+// BounceTracking tags every newsletter message with the delivery it belongs
+// to, so that a bounce or a spam complaint can be linked back to it. The Message-ID names the
+// delivery, and with a bounce address set the envelope sender is a VERP
+// address such as bounces+<newsletter>-<subscriber>@example.com. Bounces go
+// to the envelope sender, so the address they arrive at names the delivery
+// even when the bounce does not quote the original message.
+type BounceTracking struct {
+	bounceLocal     string
+	bounceDomain    string
+	messageIDDomain string
+}
+
+// NewBounceTracking returns bounce tracking with VERP return paths built on
+// bounceAddress, or none when it is empty, and Message-IDs in
+// messageIDDomain.
+func NewBounceTracking(bounceAddress, messageIDDomain string) *BounceTracking {
+	tracking := &BounceTracking{messageIDDomain: messageIDDomain}
+	if local, domain, found := strings.Cut(addressOf(bounceAddress), "@"); found {
+		tracking.bounceLocal, tracking.bounceDomain = local, domain
+	}
+	if tracking.messageIDDomain == "" {
+		tracking.messageIDDomain = "localhost"
+	}
+	return tracking
+}
+
+// NewBounceTrackingFromEnv reads bounceAddress. Message-IDs use the domain
+// of the emailSender address.
+func NewBounceTrackingFromEnv() *BounceTracking {
+	_, senderDomain, _ := strings.Cut(addressOf(os.Getenv("emailSender")), "@")
+	return NewBounceTracking(os.Getenv("bounceAddress"), senderDomain)
+}
+
+func addressOf(value string) string {
+	if address, err := mail.ParseAddress(value); err == nil {
+		return address.Address
+	}
+	return strings.TrimSpace(value)
+}
+
+// ReturnPath returns the VERP envelope sender of a delivery, or "" when no
+// bounce address is set and the sender's own address is used.
+func (b *BounceTracking) ReturnPath(newsletterID string, subscriberID primitive.ObjectID) string {
+	if b.bounceLocal == "" {
+		return ""
+	}
+	return b.bounceLocal + "+" + newsletterID + "-" + subscriberID.Hex() + "@" + b.bounceDomain
+}
+
+// MessageID returns the Message-ID header of a delivery.
+func (b *BounceTracking) MessageID(newsletterID string, subscriberID primitive.ObjectID) string {
+	return "<" + newsletterID + "." + subscriberID.Hex() + "@" + b.messageIDDomain + ">"
+}
+
+// Metadata returns the metadata of a delivery's message, which names the
+// delivery as <newsletter>-<subscriber> so that the webhook events of API
+// providers can be linked back to it.
+func (b *BounceTracking) Metadata(newsletterID string, subscriberID primitive.ObjectID) map[string]string {
+	return map[string]string{webhook.DeliveryMetadataKey: newsletterID + "-" + subscriberID.Hex()}
+}
+
+// Match returns the delivery a bounce is about, from the VERP address it was
+// delivered to or from the Message-ID of the returned message.
+func (b *BounceTracking) Match(report *dsn.Report) (string, primitive.ObjectID, bool) {
+	return b.match(report.To, report.OriginalMessageID())
+}
+
+// MatchComplaint returns the delivery a spam complaint is about, from the
+// VERP envelope sender or the Message-ID of the reported message. Feedback
+// loops often redact the recipient, but keep these.
+func (b *BounceTracking) MatchComplaint(report *arf.Report) (string, primitive.ObjectID, bool) {
+	return b.match([]string{strings.ToLower(report.OriginalReturnPath())}, report.OriginalMessageID())
+}
+
+// MatchEvent returns the delivery a webhook event is about, from the
+// metadata it carries.
+func (b *BounceTracking) MatchEvent(event webhook.Event) (string, primitive.ObjectID, bool) {
+	for _, tag := range event.Tags {
+		if newsletterID, subscriberID, ok := parseDeliveryKey(tag); ok {
+			return newsletterID, subscriberID, true
+		}
+	}
+	return "", primitive.NilObjectID, false
+}
+
+func (b *BounceTracking) match(addresses []string, messageID string) (string, primitive.ObjectID, bool) {
+	if b.bounceLocal != "" {
+		prefix := strings.ToLower(b.bounceLocal) + "+"
+		suffix := "@" + strings.ToLower(b.bounceDomain)
+		for _, to := range addresses {
+			if strings.HasPrefix(to, prefix) && strings.HasSuffix(to, suffix) {
+				if newsletterID, subscriberID, ok := parseDeliveryKey(to[len(prefix) : len(to)-len(suffix)]); ok {
+					return newsletterID, subscriberID, true
+				}
+			}
+		}
+	}
+
+	if local, domain, found := strings.Cut(messageID, "@"); found && strings.EqualFold(domain, b.messageIDDomain) {
+		return parseDeliveryKey(local)
+	}
+	return "", primitive.NilObjectID, false
+}
+
+func parseDeliveryKey(key string) (string, primitive.ObjectID, bool) {
+	match := deliveryKeyPattern.FindStringSubmatch(strings.ToLower(key))
+	if match == nil {
+		return "", primitive.NilObjectID, false
+	}
+	subscriberID, err := primitive.ObjectIDFromHex(match[2])
+	if err != nil {
+		return "", primitive.NilObjectID, false
+	}
+	return match[1], subscriberID, true
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/Dtos/response"
+	"newsletter-app/pkg/service/arf"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var _ ports.ComplaintServicePort = (*ComplaintService)(nil)
+
+var ErrInvalidComplaint = errors.New("not an abuse report")
+
+const defaultComplaintPollInterval = time.Minute
+
+// ComplaintService handles the abuse reports (ARF) that mailbox providers
+// send when a recipient marks a newsletter as spam, posted to the API or read
+// from a local mailbox. Each complaint is linked to its delivery like a
+// bounce, and the complainer is unsubscribed from every category and
+// suppressed, so they are never sent a newsletter again. Anyone can send a
+// report, so only complaints about a delivery, named by its VERP address or
+// Message-ID, are acted on: the address of the delivery is unsubscribed, never
+// one named by the report.
+type ComplaintService struct {
+	mailbox              ports.Mailbox
+	deliveryRepository   ports.DeliveryRepositoryPort
+	subscriberRepository ports.SubscriberRepositoryPort
+	suppressions         *SuppressionService
+	bounceTracking       *BounceTracking
+	pollInterval         time.Duration
+}
+
+func NewComplaintService(
+	mailbox ports.Mailbox,
+	deliveryRepo ports.DeliveryRepositoryPort,
+	subscriberRepo ports.SubscriberRepositoryPort,
+	suppressions *SuppressionService,
+	bounceTracking *BounceTracking,
+	pollInterval time.Duration,
+) *ComplaintService {
+	if pollInterval <= 0 {
+		pollInterval = defaultComplaintPollInterval
+	}
+	return &ComplaintService{
+		mailbox:              mailbox,
+		deliveryRepository:   deliveryRepo,
+		subscriberRepository: subscriberRepo,
+		suppressions:         suppressions,
+		bounceTracking:       bounceTracking,
+		pollInterval:         pollInterval,
+	}
+}
+
+// NewComplaintServiceFromEnv reads complaintPollInterval (defaults to 1m).
+// The mailbox may be nil when complaints are only posted to the API.
+func NewComplaintServiceFromEnv(mailbox ports.Mailbox, deliveryRepo ports.DeliveryRepositoryPort, subscriberRepo ports.SubscriberRepositoryPort, suppressions *SuppressionService, bounceTracking *BounceTracking) *ComplaintService {
+	pollInterval, _ := time.ParseDuration(os.Getenv("complaintPollInterval"))
+	return NewComplaintService(mailbox, deliveryRepo, subscriberRepo, suppressions, bounceTracking, pollInterval)
+}
+
+// Start reads the mailbox every poll interval until ctx is done.
+func (s *ComplaintService) Start(ctx context.Context) {
+	go func() {
+		for {
+			if err := s.mailbox.Process(s.ProcessMessage); err != nil {
+				fmt.Printf("Error processing complaints: %s\n", err.Error())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.pollInterval):
+			}
+		}
+	}()
+}
+
+// ProcessMessage handles one message of the complaint mailbox. Messages that
+// are not abuse reports are skipped, and only errors storing the complaint
+// are returned, so that the message is processed again.
+func (s *ComplaintService) ProcessMessage(message []byte) error {
+	_, err := s.IngestComplaint(message)
+	if errors.Is(err, ErrInvalidComplaint) {
+		fmt.Printf("Error parsing complaint: %s\n", err.Error())
+		return nil
+	}
+	return err
+}
+
+// IngestComplaint handles an abuse report. Reports that are not complaints,
+// such as not-spam, are accepted and ignored.
+func (s *ComplaintService) IngestComplaint(raw []byte) (*response.ComplaintResponse, error) {
+	report, err := arf.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidComplaint, err.Error())
+	}
+
+	result := &response.ComplaintResponse{FeedbackType: report.FeedbackType}
+	if !report.IsComplaint() {
+		return result, nil
+	}
+
+	complaint := domain.Complaint{
+		FeedbackType: report.FeedbackType,
+		UserAgent:    report.UserAgent,
+		ReportedBy:   report.ReportingMTA,
+		ReportedAt:   time.Now(),
+	}
+
+	newsletterID, subscriberID, ok := s.bounceTracking.MatchComplaint(report)
+	if !ok {
+		fmt.Printf("Dropping complaint from %s about no known delivery (recipients %v)\n", report.ReportingMTA, report.Recipients())
+		return result, nil
+	}
+	result.NewsletterID = newsletterID
+	if result.Suppressed, err = s.RecordComplaint(newsletterID, subscriberID, complaint); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecordComplaint stores a complaint on its delivery, and unsubscribes and
+// suppresses the address of the delivery in every category. Complaints about a
+// delivery that does not exist are dropped. It returns the number of addresses
+// suppressed.
+func (s *ComplaintService) RecordComplaint(newsletterID string, subscriberID primitive.ObjectID, complaint domain.Complaint) (int, error) {
+	delivery, err := s.deliveryRepository.RecordComplaint(newsletterID, subscriberID, complaint)
+	if err != nil {
+		return 0, err
+	}
+	if delivery == nil || !IsValidEmail(delivery.Email) {
+		fmt.Printf("Dropping complaint about unknown delivery %s-%s\n", newsletterID, subscriberID.Hex())
+		return 0, nil
+	}
+
+	detail := strings.TrimSpace(complaint.FeedbackType + " " + complaint.UserAgent)
+	if err := s.subscriberRepository.DeleteSubscriberByEmail(delivery.Email, ""); err != nil {
+		return 0, err
+	}
+	if err := s.suppressions.Suppress(delivery.Email, "", domain.SuppressionComplaint, SuppressionSourceComplaint, detail); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
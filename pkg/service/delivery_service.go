@@ -0,0 +1,86 @@
+package service
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+var _ ports.DeliveryServicePort = (*DeliveryService)(nil)
+
+const (
+	defaultDeliveryPageSize = 20
+	maxDeliveryPageSize     = 100
+)
+
+type DeliveryService struct {
+	deliveryRepository ports.DeliveryRepositoryPort
+}
+
+func NewDeliveryService(deliveryRepo ports.DeliveryRepositoryPort) *DeliveryService {
+	return &DeliveryService{
+		deliveryRepository: deliveryRepo,
+	}
+}
+
+func (s *DeliveryService) GetDeliveries(filter domain.DeliveryFilter, page, pageSize int) (*response.DeliveriesResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultDeliveryPageSize
+	}
+	if pageSize > maxDeliveryPageSize {
+		pageSize = maxDeliveryPageSize
+	}
+
+	deliveries, total, err := s.deliveryRepository.GetDeliveries(filter, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := s.GetDeliverySummary(filter.NewsletterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.DeliveriesResponse{
+		Deliveries: deliveries,
+		Summary:    *summary,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+	}, nil
+}
+
+func (s *DeliveryService) GetDeliverySummary(newsletterID string) (*domain.DeliverySummary, error) {
+	counts, err := s.deliveryRepository.CountDeliveriesByStatus(newsletterID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &domain.DeliverySummary{
+		Queued:     counts[domain.DeliveryQueued],
+		Sent:       counts[domain.DeliverySent],
+		Failed:     counts[domain.DeliveryFailed],
+		Bounced:    counts[domain.DeliveryBounced],
+		Suppressed: counts[domain.DeliverySuppressed],
+	}
+	summary.Total = summary.Queued + summary.Sent + summary.Failed + summary.Bounced + summary.Suppressed
+
+	if summary.SoftBounces, err = s.deliveryRepository.CountSoftBounces(newsletterID); err != nil {
+		return nil, err
+	}
+	if summary.Complaints, err = s.deliveryRepository.CountComplaints(newsletterID); err != nil {
+		return nil, err
+	}
+	if summary.UniqueOpens, summary.TotalOpens, err = s.deliveryRepository.CountOpens(newsletterID); err != nil {
+		return nil, err
+	}
+	if summary.Sent > 0 {
+		summary.ComplaintRate = float64(summary.Complaints) / float64(summary.Sent)
+		summary.OpenRate = float64(summary.UniqueOpens) / float64(summary.Sent)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,281 @@
+// Package dsn parses delivery status notifications, the bounce messages that
+// mail servers send back to the envelope sender when they cannot deliver a
+// message (RFC 3464, and its internationalized form in RFC 6533).
+package dsn
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// ErrNotDSN is returned for messages that carry no delivery status, such as
+// autoreplies.
+var ErrNotDSN = errors.New("dsn: not a delivery status notification")
+
+// maxDepth bounds how deeply nested multiparts are searched for the report.
+const maxDepth = 5
+
+// Report is a parsed delivery status notification.
+type Report struct {
+	// To are the addresses the notification was delivered to, from its To,
+	// Delivered-To and X-Original-To headers. The notification goes to the
+	// envelope sender of the returned message, so with VERP they name it.
+	To []string
+	// ReportingMTA is the server that issued the notification.
+	ReportingMTA string
+	Recipients   []Recipient
+	// OriginalHeaders are the headers of the returned message, when the
+	// notification includes them.
+	OriginalHeaders textproto.MIMEHeader
+}
+
+// OriginalMessageID returns the Message-ID of the returned message without
+// its angle brackets, or "" when the notification does not include it.
+func (r *Report) OriginalMessageID() string {
+	if r.OriginalHeaders == nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(r.OriginalHeaders.Get("Message-Id")), "<>")
+}
+
+// Recipient is the delivery status of one recipient of the returned message.
+type Recipient struct {
+	// FinalRecipient is the address the reporting server tried to deliver
+	// to, which aliases or forwarding may have changed.
+	FinalRecipient string
+	// OriginalRecipient is the address as it was sent, when the reporting
+	// server knows it.
+	OriginalRecipient string
+	// Action is failed, delayed, delivered, relayed or expanded.
+	Action string
+	// Status is the enhanced status code, such as 5.1.1 (RFC 3463).
+	Status         string
+	DiagnosticCode string
+	RemoteMTA      string
+}
+
+// Address returns the address the message was sent to.
+func (r Recipient) Address() string {
+	if r.OriginalRecipient != "" {
+		return r.OriginalRecipient
+	}
+	return r.FinalRecipient
+}
+
+// Class returns the class digit of the status: '2' for success, '4' for a
+// temporary failure and '5' for a permanent one.
+func (r Recipient) Class() byte {
+	if r.Status == "" {
+		return 0
+	}
+	return r.Status[0]
+}
+
+// Subject returns the status without its class, such as "1.1" for 5.1.1.
+func (r Recipient) Subject() string {
+	if len(r.Status) < 2 {
+		return ""
+	}
+	return r.Status[2:]
+}
+
+var (
+	statusPattern = regexp.MustCompile(`^[245]\.\d{1,3}\.\d{1,3}$`)
+	// diagnosticStatusPattern finds an enhanced status code in an SMTP reply
+	// such as "550 5.1.1 <user@example.com>: Recipient address rejected" or
+	// "552-5.2.2 The email account ... is over quota".
+	diagnosticStatusPattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+	diagnosticReplyPattern  = regexp.MustCompile(`^([245])\d\d[ -]`)
+)
+
+// Parse parses a delivery status notification. It returns ErrNotDSN when the
+// message has no delivery status part.
+func Parse(raw []byte) (*Report, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("dsn: %w", err)
+	}
+
+	p := &parser{report: &Report{To: deliveredTo(message.Header)}}
+	if err := p.part(textproto.MIMEHeader(message.Header), message.Body, 0); err != nil {
+		return nil, fmt.Errorf("dsn: %w", err)
+	}
+	if !p.found {
+		return nil, ErrNotDSN
+	}
+	return p.report, nil
+}
+
+type parser struct {
+	report *Report
+	found  bool
+}
+
+func (p *parser) part(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	body = decodeTransferEncoding(header, body)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if depth >= maxDepth || params["boundary"] == "" {
+			return nil
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := p.part(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+
+	case mediaType == "message/delivery-status", mediaType == "message/global-delivery-status":
+		if p.found {
+			return nil
+		}
+		p.found = true
+		return p.deliveryStatus(body)
+
+	case mediaType == "text/rfc822-headers", mediaType == "message/rfc822",
+		mediaType == "message/global", mediaType == "message/global-headers":
+		if p.report.OriginalHeaders != nil {
+			return nil
+		}
+		headers, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+		if len(headers) > 0 {
+			p.report.OriginalHeaders = headers
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliveryStatus reads the per-message fields and then the fields of every
+// recipient, which are blocks of header fields separated by blank lines.
+func (p *parser) deliveryStatus(body io.Reader) error {
+	reader := textproto.NewReader(bufio.NewReader(body))
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 {
+			if fields.Get("Final-Recipient") != "" || fields.Get("Original-Recipient") != "" {
+				p.report.Recipients = append(p.report.Recipients, recipient(fields))
+			} else if p.report.ReportingMTA == "" {
+				p.report.ReportingMTA = typedValue(fields.Get("Reporting-MTA"))
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func recipient(fields textproto.MIMEHeader) Recipient {
+	r := Recipient{
+		FinalRecipient:    address(typedValue(fields.Get("Final-Recipient"))),
+		OriginalRecipient: address(typedValue(fields.Get("Original-Recipient"))),
+		Action:            strings.ToLower(firstWord(fields.Get("Action"))),
+		Status:            firstWord(fields.Get("Status")),
+		DiagnosticCode:    typedValue(fields.Get("Diagnostic-Code")),
+		RemoteMTA:         typedValue(fields.Get("Remote-MTA")),
+	}
+
+	// Some servers leave the status out or give a bare class. The SMTP reply
+	// usually has the precise code.
+	if !statusPattern.MatchString(r.Status) || strings.HasSuffix(r.Status, ".0.0") {
+		if match := diagnosticStatusPattern.FindStringSubmatch(r.DiagnosticCode); match != nil {
+			r.Status = match[1]
+		} else if !statusPattern.MatchString(r.Status) {
+			r.Status = ""
+			if match := diagnosticReplyPattern.FindStringSubmatch(r.DiagnosticCode); match != nil {
+				r.Status = match[1] + ".0.0"
+			} else if r.Action == "failed" {
+				r.Status = "5.0.0"
+			} else if r.Action == "delayed" {
+				r.Status = "4.0.0"
+			}
+		}
+	}
+	return r
+}
+
+// typedValue returns the value of a field such as "rfc822; user@example.com"
+// or "smtp; 550 5.1.1 User unknown" without its type.
+func typedValue(value string) string {
+	if _, rest, found := strings.Cut(value, ";"); found {
+		return strings.TrimSpace(rest)
+	}
+	return strings.TrimSpace(value)
+}
+
+func address(value string) string {
+	return strings.Trim(value, "<> ")
+}
+
+func firstWord(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// deliveredTo returns the addresses of the To, Delivered-To and X-Original-To
+// headers, skipping duplicates.
+func deliveredTo(header mail.Header) []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	add := func(address string) {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	if list, err := header.AddressList("To"); err == nil {
+		for _, to := range list {
+			add(to.Address)
+		}
+	}
+	for _, name := range []string{"Delivered-To", "X-Original-To"} {
+		for _, value := range header[textproto.CanonicalMIMEHeaderKey(name)] {
+			add(address(value))
+		}
+	}
+	return addresses
+}
+
+func decodeTransferEncoding(header textproto.MIMEHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
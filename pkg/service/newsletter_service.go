@@ -1,47 +1,80 @@
 package service
 
 import (
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/domain/ports"
-	"newsletter-app/pkg/infrastructure/adapters/email"
 	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/render"
+	"regexp"
 	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var _ ports.NewsletterServicePort = (*NewsletterService)(nil)
 
+var (
+	ErrNewsletterContentEmpty = errors.New("newsletter content is empty")
+	ErrNoSubscribers          = errors.New("no subscribers to send the newsletter to")
+	ErrInvalidAttachment      = errors.New("failed to decode attachment data")
+	ErrInvalidContentID       = errors.New("invalid inline attachment content ID")
+	ErrDuplicateContentID     = errors.New("duplicate inline attachment content ID")
+	ErrUnknownContentID       = errors.New("content refers to a missing inline attachment")
+)
+
 type NewsletterService struct {
 	newsletterRepository ports.NewsletterRepositoryPort
 	subscriberRepository ports.SubscriberRepositoryPort
+	sendJobRepository    ports.SendJobRepositoryPort
+	attachmentRepository ports.AttachmentRepositoryPort
+	attachmentPolicy     AttachmentPolicy
+	attachmentScanner    ports.AttachmentScanner
 }
 
 func NewNewsletterService(
 	newsletterRepo ports.NewsletterRepositoryPort,
 	subscriberRepo ports.SubscriberRepositoryPort,
+	sendJobRepo ports.SendJobRepositoryPort,
+	attachmentRepo ports.AttachmentRepositoryPort,
+	attachmentPolicy AttachmentPolicy,
+	attachmentScanner ports.AttachmentScanner,
 ) *NewsletterService {
 	return &NewsletterService{
 		newsletterRepository: newsletterRepo,
 		subscriberRepository: subscriberRepo,
+		sendJobRepository:    sendJobRepo,
+		attachmentRepository: attachmentRepo,
+		attachmentPolicy:     attachmentPolicy,
+		attachmentScanner:    attachmentScanner,
 	}
 }
 
+// SaveNewsletter stores a new newsletter. Its content and text content must
+// be valid templates; otherwise a *render.TemplateError is returned. Every
+// cid: reference in the content must name an inline attachment. Attachments
+// are references to uploaded files; base64 data sent instead is moved to the
+// attachment repository, so the newsletter only keeps metadata. Attachments
+// breaking the attachment policy are rejected with an *AttachmentError, and
+// the others are scanned for malware.
 func (s *NewsletterService) SaveNewsletter(newsletter domain.Newsletter) error {
-	var decodedAttachments []domain.Attachment
+	if err := validateTemplates(newsletter.Content, newsletter.TextContent); err != nil {
+		return err
+	}
 
-	for _, base64Attachment := range newsletter.Attachments {
-		attachment := domain.Attachment{
-			Name: base64Attachment.Name,
-			Data: base64Attachment.Data,
-			Type: base64Attachment.Type,
-		}
-		decodedAttachments = append(decodedAttachments, attachment)
+	if err := validateContentIDs(newsletter.Content, newsletter.Attachments); err != nil {
+		return err
+	}
+
+	attachments, err := storeAttachments(s.attachmentRepository, s.attachmentPolicy, s.attachmentScanner, newsletter.Attachments)
+	if err != nil {
+		return err
 	}
 
-	newsletter.Attachments = decodedAttachments
+	newsletter.Attachments = attachments
 	return s.newsletterRepository.SaveNewsletter(newsletter)
 }
 
@@ -62,77 +95,54 @@ func (s *NewsletterService) GetNewsletters(searchName string, page int, pageSize
 	return newsletters, nil
 }
 
-func (s *NewsletterService) SendNewsletter(w http.ResponseWriter, r *http.Request, newsletterID string, emailSender email.EmailSender) error {
+// SendNewsletter validates that the newsletter can be sent and queues a send
+// job for it. A newsletter with an attachment that is infected or was not
+// scanned cannot be sent. The actual delivery is done by the SendWorkerPool.
+func (s *NewsletterService) SendNewsletter(newsletterID string) (*domain.SendJob, error) {
 	newsletter, err := s.GetNewsletterByID(newsletterID)
 	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve newsletter")
-		return err
+		return nil, err
 	}
 
-	subscribers, err := s.subscriberRepository.GetSubscribersByCategory(newsletter.Category)
-	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve subscribers")
-		return err
+	if newsletter.Content == "" {
+		return nil, ErrNewsletterContentEmpty
 	}
 
-	if len(subscribers) == 0 {
-		RespondWithError(w, http.StatusBadRequest, "No subscribers to send the newsletter to")
-		return nil
+	if err := validateTemplates(newsletter.Content, newsletter.TextContent); err != nil {
+		return nil, err
 	}
 
-	decodedAttachments, err := DecodeAttachments(newsletter.Attachments)
-	if err != nil {
-		RespondWithError(w, http.StatusBadRequest, "Failed to decode attachments")
-		return err
+	if _, err := openAttachments(s.attachmentRepository, newsletter.Attachments); err != nil {
+		return nil, err
 	}
 
-	for _, subscriber := range subscribers {
-		fmt.Printf("Subscriber: %+v\n", subscriber)
-
-		if newsletter.Content == "" {
-			RespondWithError(w, http.StatusBadRequest, "Newsletter content is empty")
-			return nil
-		}
+	subscribers, err := s.subscriberRepository.GetSubscribersByCategoryAfter(newsletter.Category, primitive.NilObjectID, 1)
+	if err != nil {
+		return nil, err
+	}
 
-		emailCategoryConcatenation := fmt.Sprintf("%s|%s", subscriber.Email, subscriber.Category)
-		newsletterContent := strings.ReplaceAll(newsletter.Content, "{email}", emailCategoryConcatenation)
-		urlUnsubscribe := strings.ReplaceAll(newsletterContent, "{hostDomain}", "http://localhost:4200/")
+	if len(subscribers) == 0 {
+		return nil, ErrNoSubscribers
+	}
 
-		err = emailSender.Send(newsletter.Subject, urlUnsubscribe, []string{subscriber.Email}, decodedAttachments)
-		if err != nil {
-			fmt.Printf("Error sending newsletter to %s: %s\n", subscriber.Email, err.Error())
-			continue
-		}
+	now := time.Now()
+	job := domain.SendJob{
+		ID:           primitive.NewObjectID(),
+		NewsletterID: newsletterID,
+		Status:       domain.SendJobQueued,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
 
-		fmt.Printf("Newsletter sent to %s\n", subscriber.Email)
+	if err := s.sendJobRepository.SaveSendJob(job); err != nil {
+		return nil, err
 	}
 
-	RespondWithJSON(w, http.StatusOK, map[string]interface{}{
-		"status":  "OK",
-		"message": "Newsletter sent successfully",
-	})
-	return nil
+	return &job, nil
 }
 
-func DecodeAttachments(attachments []domain.Attachment) ([]*domain.Attachment, error) {
-	var decodedAttachments []*domain.Attachment
-
-	for _, attachment := range attachments {
-		_, err := base64.StdEncoding.DecodeString(attachment.Data)
-		if err != nil {
-			return nil, errors.New("failed to decode attachment data")
-		}
-
-		decodedAttachment := &domain.Attachment{
-			Name: attachment.Name,
-			Data: attachment.Data,
-			Type: attachment.Type,
-		}
-
-		decodedAttachments = append(decodedAttachments, decodedAttachment)
-	}
-
-	return decodedAttachments, nil
+func (s *NewsletterService) GetSendJob(jobID string) (*domain.SendJob, error) {
+	return s.sendJobRepository.GetSendJobByID(jobID)
 }
 
 func (s *NewsletterService) UpdateNewsletter(updateRequest request.UpdateNewsletterRequest) error {
@@ -140,6 +150,10 @@ func (s *NewsletterService) UpdateNewsletter(updateRequest request.UpdateNewslet
 		return errors.New("ID is required for update")
 	}
 
+	if err := validateTemplates(updateRequest.Content, updateRequest.TextContent); err != nil {
+		return err
+	}
+
 	existingNewsletter, err := s.GetNewsletterByID(updateRequest.ID.Hex())
 	if err != nil {
 		return err
@@ -149,23 +163,75 @@ func (s *NewsletterService) UpdateNewsletter(updateRequest request.UpdateNewslet
 	existingNewsletter.Category = updateRequest.Category
 	existingNewsletter.Subject = updateRequest.Subject
 	existingNewsletter.Content = updateRequest.Content
-
-	if len(updateRequest.Attachments) > 0 {
-		existingNewsletter.Attachments = make([]domain.Attachment, len(updateRequest.Attachments))
-		for i, attachment := range updateRequest.Attachments {
-			existingNewsletter.Attachments[i] = domain.Attachment{
-				Name: attachment.Name,
-				Data: attachment.Data,
-				Type: attachment.Type,
-			}
+	existingNewsletter.TextContent = updateRequest.TextContent
+
+	attachments := make([]domain.Attachment, len(updateRequest.Attachments))
+	for i, attachment := range updateRequest.Attachments {
+		attachments[i] = domain.Attachment{
+			ID:        attachment.ID,
+			Name:      attachment.Name,
+			Data:      attachment.Data,
+			Type:      attachment.Type,
+			ContentID: attachment.ContentID,
 		}
-	} else {
-		existingNewsletter.Attachments = nil
+	}
+
+	if err := validateContentIDs(existingNewsletter.Content, attachments); err != nil {
+		return err
+	}
+
+	existingNewsletter.Attachments, err = storeAttachments(s.attachmentRepository, s.attachmentPolicy, s.attachmentScanner, attachments)
+	if err != nil {
+		return err
 	}
 
 	return s.newsletterRepository.UpdateNewsletter(*existingNewsletter)
 }
 
+// validateTemplates parses the HTML content and, when there is one, the
+// hand-written text version of a newsletter.
+func validateTemplates(content, textContent string) error {
+	if _, err := render.Parse(content); err != nil {
+		return err
+	}
+	if textContent == "" {
+		return nil
+	}
+	_, err := render.ParseText(textContent)
+	return err
+}
+
+// cidReference matches a cid: URL (RFC 2392) in an attribute or a CSS url().
+var cidReference = regexp.MustCompile(`(?i)\bcid:([^"'\s<>()]+)`)
+
+// validateContentIDs checks that the content IDs of inline attachments are
+// well formed and unique, and that every cid: URL in the content names one of
+// them.
+func validateContentIDs(content string, attachments []domain.Attachment) error {
+	contentIDs := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.ContentID == "" {
+			continue
+		}
+		if strings.ContainsAny(attachment.ContentID, "<>\"() \t\r\n") {
+			return fmt.Errorf("%w: %q", ErrInvalidContentID, attachment.ContentID)
+		}
+		if contentIDs[attachment.ContentID] {
+			return fmt.Errorf("%w: %q", ErrDuplicateContentID, attachment.ContentID)
+		}
+		contentIDs[attachment.ContentID] = true
+	}
+
+	for _, match := range cidReference.FindAllStringSubmatch(content, -1) {
+		// cid: URLs are percent-encoded.
+		contentID, err := url.PathUnescape(match[1])
+		if err != nil || !contentIDs[contentID] {
+			return fmt.Errorf("%w: %q", ErrUnknownContentID, match[1])
+		}
+	}
+	return nil
+}
+
 func (s *NewsletterService) DeleteNewsletter(id string) error {
 	return s.newsletterRepository.DeleteNewsletterByID(id)
 }
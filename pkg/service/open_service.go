@@ -0,0 +1,51 @@
+package service
+
+import (
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"time"
+)
+
+var _ ports.OpenServicePort = (*OpenService)(nil)
+
+// OpenReportedByPixel is the ReportedBy of the opens seen by the tracking
+// pixel. Opens reported by webhooks name their provider.
+const OpenReportedByPixel = "pixel"
+
+// OpenService records the opens of the tracking pixel added by OpenTracking
+// on their delivery.
+type OpenService struct {
+	deliveryRepository ports.DeliveryRepositoryPort
+	openTracking       *OpenTracking
+}
+
+func NewOpenService(deliveryRepo ports.DeliveryRepositoryPort, openTracking *OpenTracking) *OpenService {
+	return &OpenService{
+		deliveryRepository: deliveryRepo,
+		openTracking:       openTracking,
+	}
+}
+
+// RecordPixelOpen records an open of the delivery the openToken was issued
+// for, by a client with userAgent at remoteIP. It returns ErrInvalidOpenToken
+// for tokens that no delivery carries.
+func (s *OpenService) RecordPixelOpen(openToken, userAgent, remoteIP string) error {
+	if !s.openTracking.ValidToken(openToken) {
+		return ErrInvalidOpenToken
+	}
+
+	userAgentClass, appleMPP := ClassifyOpen(userAgent, remoteIP)
+	found, err := s.deliveryRepository.RecordOpenByToken(openToken, domain.Open{
+		UserAgentClass: userAgentClass,
+		AppleMPP:       appleMPP,
+		ReportedBy:     OpenReportedByPixel,
+		OpenedAt:       time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrInvalidOpenToken
+	}
+	return nil
+}
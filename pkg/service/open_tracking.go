@@ -0,0 +1,126 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"newsletter-app/pkg/config"
+	domain "newsletter-app/pkg/domain/models"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidOpenToken = errors.New("invalid open tracking token")
+
+// openTokenPattern matches the tokens issued by OpenTracking: 16 random
+// bytes in unpadded base64url.
+var openTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+// appleNetwork is the address block Apple Mail Privacy Protection fetches
+// images from.
+var appleNetwork = &net.IPNet{IP: net.IPv4(17, 0, 0, 0), Mask: net.CIDRMask(8, 32)}
+
+var (
+	proxyUserAgents  = []string{"googleimageproxy", "ggpht.com", "yahoomailproxy"}
+	botUserAgents    = []string{"bot", "crawler", "spider", "curl/", "wget/", "python", "go-http-client", "java/"}
+	mobileUserAgents = []string{"iphone", "ipad", "android", "mobile"}
+	// Desktop mail clients are recognised by their platform, except
+	// Outlook and Thunderbird, which also run elsewhere.
+	desktopUserAgents = []string{"windows", "macintosh", "x11", "linux", "microsoft outlook", "thunderbird"}
+)
+
+// OpenTracking issues the tokens of the tracking pixel added to each
+// newsletter and adds the pixel to the HTML. A token is random and is stored
+// on its delivery, which it is looked up by, so it tells nothing about the
+// newsletter or the subscriber to whoever reads the message. It does nothing
+// unless it is enabled.
+type OpenTracking struct {
+	publicURLs *config.PublicURLs
+	enabled    bool
+}
+
+func NewOpenTracking(publicURLs *config.PublicURLs, enabled bool) *OpenTracking {
+	return &OpenTracking{publicURLs: publicURLs, enabled: enabled}
+}
+
+// NewOpenTrackingFromEnv reads openTracking, a boolean that defaults to false.
+func NewOpenTrackingFromEnv(publicURLs *config.PublicURLs) (*OpenTracking, error) {
+	enabled := false
+	if raw := os.Getenv("openTracking"); raw != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("openTracking %q is not a boolean", raw)
+		}
+	}
+	return NewOpenTracking(publicURLs, enabled), nil
+}
+
+func (t *OpenTracking) Enabled() bool {
+	return t.enabled
+}
+
+// NewToken returns a new random token for the pixel of a delivery, to be
+// stored on the delivery. It does not expire, since a newsletter can be
+// opened at any time.
+func (t *OpenTracking) NewToken() string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return base64.RawURLEncoding.EncodeToString(random)
+}
+
+// ValidToken reports whether openToken has the form of the tokens issued by
+// NewToken, so that other requests are turned away before looking them up.
+func (t *OpenTracking) ValidToken(openToken string) bool {
+	return openTokenPattern.MatchString(openToken)
+}
+
+// AddPixel adds the tracking pixel of a token issued for category at the end
+// of the body of content, or of content when it has no body tag.
+func (t *OpenTracking) AddPixel(content, category, openToken string) string {
+	pixel := `<img src="` + html.EscapeString(t.publicURLs.For(category).OpenPixelURL(openToken)) +
+		`" width="1" height="1" alt="" style="display:block;width:1px;height:1px;border:0">`
+
+	if end := strings.LastIndex(strings.ToLower(content), "</body"); end >= 0 {
+		return content[:end] + pixel + content[end:]
+	}
+	return content + pixel
+}
+
+// ClassifyOpen tells the kind of client that opened a newsletter from its
+// user agent, and whether the open looks like a prefetch by Apple Mail
+// Privacy Protection: those come from Apple's network, with a user agent of
+// just "Mozilla/5.0". remoteIP may be empty when it is not known.
+func ClassifyOpen(userAgent, remoteIP string) (domain.UserAgentClass, bool) {
+	userAgent = strings.TrimSpace(userAgent)
+	ip := net.ParseIP(remoteIP)
+	appleMPP := userAgent == "Mozilla/5.0" || (ip != nil && appleNetwork.Contains(ip))
+
+	lower := strings.ToLower(userAgent)
+	switch {
+	case lower == "" || userAgent == "Mozilla/5.0":
+		return domain.UserAgentUnknown, appleMPP
+	case containsAny(lower, proxyUserAgents):
+		return domain.UserAgentProxy, appleMPP
+	case containsAny(lower, botUserAgents):
+		return domain.UserAgentBot, appleMPP
+	case containsAny(lower, mobileUserAgents):
+		return domain.UserAgentMobile, appleMPP
+	case containsAny(lower, desktopUserAgents):
+		return domain.UserAgentDesktop, appleMPP
+	}
+	return domain.UserAgentUnknown, appleMPP
+}
+
+func containsAny(value string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(value, substring) {
+			return true
+		}
+	}
+	return false
+}
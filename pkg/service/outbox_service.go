@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/Dtos/response"
+)
+
+var _ ports.OutboxServicePort = (*OutboxService)(nil)
+
+var ErrOutboxMessageNotFound = errors.New("outbox message not found")
+
+const (
+	defaultOutboxPageSize = 20
+	maxOutboxPageSize     = 100
+)
+
+// OutboxService reads the messages captured by the outbox email sender for
+// the read-only outbox view.
+type OutboxService struct {
+	outboxRepository ports.OutboxRepositoryPort
+}
+
+func NewOutboxService(outboxRepo ports.OutboxRepositoryPort) *OutboxService {
+	return &OutboxService{
+		outboxRepository: outboxRepo,
+	}
+}
+
+// GetOutboxMessages returns a page of captured messages, newest first.
+func (s *OutboxService) GetOutboxMessages(page, pageSize int) (*response.OutboxMessagesResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultOutboxPageSize
+	}
+	if pageSize > maxOutboxPageSize {
+		pageSize = maxOutboxPageSize
+	}
+
+	ids, err := s.outboxRepository.ListOutboxMessages()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []domain.OutboxMessage{}
+	for i := len(ids) - 1 - (page-1)*pageSize; i >= 0 && len(messages) < pageSize; i-- {
+		raw, err := s.outboxRepository.GetOutboxMessage(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			// Removed since it was listed.
+			continue
+		}
+
+		summary, _ := parseOutboxMessage(ids[i], raw)
+		messages = append(messages, summary)
+	}
+
+	return &response.OutboxMessagesResponse{
+		Messages: messages,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    int64(len(ids)),
+	}, nil
+}
+
+// GetOutboxMessage returns a captured message with its decoded bodies. It
+// returns ErrOutboxMessageNotFound for unknown IDs.
+func (s *OutboxService) GetOutboxMessage(id string) (*domain.OutboxMessageDetail, error) {
+	raw, err := s.GetRawOutboxMessage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, msg := parseOutboxMessage(id, raw)
+	detail := &domain.OutboxMessageDetail{
+		OutboxMessage: summary,
+		Headers:       map[string][]string{},
+		Attachments:   []domain.OutboxAttachment{},
+	}
+	if msg == nil {
+		return detail, nil
+	}
+
+	for name, values := range msg.Header {
+		detail.Headers[name] = values
+	}
+	readPart(detail, msg.Header, msg.Body)
+	return detail, nil
+}
+
+// GetRawOutboxMessage returns a captured message as it was written. It
+// returns ErrOutboxMessageNotFound for unknown IDs.
+func (s *OutboxService) GetRawOutboxMessage(id string) ([]byte, error) {
+	raw, err := s.outboxRepository.GetOutboxMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrOutboxMessageNotFound
+	}
+	return raw, nil
+}
+
+var headerDecoder = &mime.WordDecoder{}
+
+// parseOutboxMessage reads the summary of a raw message. The parsed message
+// is nil when the headers cannot be read, in which case the summary only has
+// the ID and size.
+func parseOutboxMessage(id string, raw []byte) (domain.OutboxMessage, *mail.Message) {
+	summary := domain.OutboxMessage{ID: id, Size: len(raw), To: []string{}}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return summary, nil
+	}
+
+	summary.From = decodeHeader(msg.Header.Get("From"))
+	summary.Subject = decodeHeader(msg.Header.Get("Subject"))
+	if date, err := msg.Header.Date(); err == nil {
+		summary.Date = date
+	}
+	if to, err := msg.Header.AddressList("To"); err == nil {
+		for _, address := range to {
+			summary.To = append(summary.To, address.Address)
+		}
+	}
+	return summary, msg
+}
+
+func decodeHeader(value string) string {
+	if decoded, err := headerDecoder.DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
+
+// partHeader is the subset of a MIME header readPart needs, shared by the
+// message and its parts.
+type partHeader interface {
+	Get(key string) string
+}
+
+// readPart walks a MIME entity: the first text/html and text/plain parts that
+// are not attachments become the bodies, and every other leaf part is listed
+// as an attachment.
+func readPart(detail *domain.OutboxMessageDetail, header partHeader, body io.Reader) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err != nil {
+				return
+			}
+			readPart(detail, part.Header, part)
+		}
+	}
+
+	content, _ := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if disposition != "attachment" {
+		switch {
+		case mediaType == "text/html" && detail.HTMLBody == "":
+			detail.HTMLBody = string(content)
+			return
+		case mediaType == "text/plain" && detail.TextBody == "":
+			detail.TextBody = string(content)
+			return
+		}
+	}
+
+	name := dispositionParams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	detail.Attachments = append(detail.Attachments, domain.OutboxAttachment{
+		Name:        decodeHeader(name),
+		ContentType: mediaType,
+		Size:        len(content),
+	})
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
@@ -0,0 +1,283 @@
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PlainText converts rendered newsletter HTML into the text/plain alternative
+// of the email. Headings are underlined, list items keep their bullets or
+// numbers, and links are followed by their URL in parentheses, so the text
+// part keeps the structure and the links of the HTML one.
+func PlainText(content string) string {
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		// html.Parse only fails when reading fails, which a strings.Reader does not.
+		return content
+	}
+
+	w := &textWriter{}
+	w.children(doc)
+	return tidyText(w.String())
+}
+
+// skippedElements have no content a reader of the text part should see.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Style:    true,
+	atom.Script:   true,
+	atom.Noscript: true,
+	atom.Template: true,
+}
+
+// blockElements start on a new paragraph.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Center: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+	atom.Form: true, atom.Header: true, atom.Main: true, atom.Nav: true, atom.P: true,
+	atom.Section: true, atom.Table: true,
+}
+
+// lineElements start on a new line.
+var lineElements = map[atom.Atom]bool{
+	atom.Tr: true, atom.Thead: true, atom.Tbody: true, atom.Tfoot: true, atom.Caption: true,
+}
+
+var headingElements = map[atom.Atom]bool{
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+type list struct {
+	ordered bool
+	next    int
+}
+
+type textWriter struct {
+	b       strings.Builder
+	breaks  int
+	started bool
+	space   bool
+	pre     int
+	lists   []*list
+}
+
+func (w *textWriter) String() string {
+	return w.b.String()
+}
+
+// lineBreak asks for at least n newlines before the next text.
+func (w *textWriter) lineBreak(n int) {
+	if w.started && n > w.breaks {
+		w.breaks = n
+	}
+}
+
+func (w *textWriter) write(text string) {
+	if w.pre == 0 {
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			w.space = w.space || w.started
+			return
+		}
+	}
+	if text == "" {
+		return
+	}
+
+	if w.breaks > 0 {
+		w.b.WriteString(strings.Repeat("\n", w.breaks))
+		w.b.WriteString(strings.Repeat("  ", max(len(w.lists)-1, 0)))
+		w.breaks = 0
+	} else if w.space && w.pre == 0 {
+		w.b.WriteByte(' ')
+	}
+	w.space = false
+	w.started = true
+	w.b.WriteString(text)
+}
+
+// spaceBefore records whitespace around inline text, so that words from
+// neighbouring nodes are not glued together.
+func (w *textWriter) spaceBefore(text string) {
+	if w.pre == 0 && text != "" && strings.TrimLeft(text, " \t\r\n\f") != text {
+		w.space = w.space || w.started
+	}
+}
+
+func (w *textWriter) spaceAfter(text string) {
+	if w.pre == 0 && text != "" && strings.TrimRight(text, " \t\r\n\f") != text {
+		w.space = true
+	}
+}
+
+func (w *textWriter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		w.node(child)
+	}
+}
+
+func (w *textWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.spaceBefore(n.Data)
+		w.write(n.Data)
+		w.spaceAfter(n.Data)
+		return
+	case html.DocumentNode:
+		w.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if skippedElements[n.DataAtom] {
+		return
+	}
+
+	switch {
+	case headingElements[n.DataAtom]:
+		w.heading(n)
+	case n.DataAtom == atom.A:
+		w.link(n)
+	case n.DataAtom == atom.Img:
+		if alt := attr(n, "alt"); alt != "" {
+			w.write(alt)
+		}
+	case n.DataAtom == atom.Br:
+		w.b.WriteString(strings.Repeat("\n", w.breaks))
+		w.breaks = 0
+		w.b.WriteString("\n")
+		w.space = false
+	case n.DataAtom == atom.Hr:
+		w.lineBreak(2)
+		w.write("----------")
+		w.lineBreak(2)
+	case n.DataAtom == atom.Ul || n.DataAtom == atom.Ol:
+		// Nested lists continue their parent item on the next line.
+		breaks := 2
+		if len(w.lists) > 0 {
+			breaks = 1
+		}
+		w.lineBreak(breaks)
+		w.lists = append(w.lists, &list{ordered: n.DataAtom == atom.Ol, next: 1})
+		w.children(n)
+		w.lists = w.lists[:len(w.lists)-1]
+		w.lineBreak(breaks)
+	case n.DataAtom == atom.Li:
+		w.listItem(n)
+	case n.DataAtom == atom.Pre:
+		w.lineBreak(2)
+		w.pre++
+		w.children(n)
+		w.pre--
+		w.lineBreak(2)
+	case n.DataAtom == atom.Td || n.DataAtom == atom.Th:
+		w.space = w.space || w.started
+		w.children(n)
+		w.space = true
+	case blockElements[n.DataAtom]:
+		w.lineBreak(2)
+		w.children(n)
+		w.lineBreak(2)
+	case lineElements[n.DataAtom]:
+		w.lineBreak(1)
+		w.children(n)
+		w.lineBreak(1)
+	default:
+		w.children(n)
+	}
+}
+
+// heading writes the heading text underlined with "=" for h1 and "-" for the
+// other levels.
+func (w *textWriter) heading(n *html.Node) {
+	text := innerText(n)
+	if text == "" {
+		return
+	}
+
+	underline := "-"
+	if n.DataAtom == atom.H1 {
+		underline = "="
+	}
+
+	w.lineBreak(2)
+	w.write(text)
+	w.lineBreak(1)
+	w.write(strings.Repeat(underline, len([]rune(text))))
+	w.lineBreak(2)
+}
+
+// link writes the link text followed by its URL, or only the URL when the
+// text is empty or already the URL.
+func (w *textWriter) link(n *html.Node) {
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		w.children(n)
+		return
+	}
+
+	text := innerText(n)
+	shown := strings.TrimPrefix(href, "mailto:")
+	switch text {
+	case "":
+		w.write(href)
+	case href, shown:
+		w.write(text)
+	default:
+		w.write(text + " (" + href + ")")
+	}
+}
+
+func (w *textWriter) listItem(n *html.Node) {
+	marker := "-"
+	if len(w.lists) > 0 {
+		current := w.lists[len(w.lists)-1]
+		if current.ordered {
+			marker = strconv.Itoa(current.next) + "."
+			current.next++
+		}
+	}
+
+	w.lineBreak(1)
+	w.write(marker)
+	w.space = true
+	w.children(n)
+	w.lineBreak(1)
+}
+
+// innerText returns the collapsed text of a node, with image alt texts.
+func innerText(n *html.Node) string {
+	sub := &textWriter{}
+	sub.children(n)
+	return strings.Join(strings.Fields(sub.String()), " ")
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+var extraBlankLines = regexp.MustCompile(`\n{3,}`)
+
+// tidyText removes trailing spaces and runs of blank lines.
+func tidyText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = strings.Join(lines, "\n")
+	text = extraBlankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text) + "\n"
+}
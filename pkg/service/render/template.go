@@ -0,0 +1,169 @@
+// Package render turns newsletter content into the personalised HTML sent to
+// each subscriber. Content is an html/template, so every value inserted into
+// it is escaped for the context it appears in. The optional hand-written text
+// version of a newsletter is a text/template, since plain text needs no
+// escaping.
+package render
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+)
+
+// Data is what a newsletter template can refer to, for example
+// {{.Subscriber.Email}}, {{.Newsletter.Name}}, {{.UnsubscribeURL}},
+// {{.Date.Format "2 Jan 2006"}} or {{index .Attributes "first_name"}}.
+type Data struct {
+	Subscriber       domain.Subscriber
+	Newsletter       domain.Newsletter
+	UnsubscribeURL   string
+	PreferencesURL   string
+	UnsubscribeToken string
+	HostDomain       string
+	Date             time.Time
+	Attributes       map[string]string
+}
+
+// legacyPlaceholders maps the placeholders used before newsletters were
+// templates onto their template equivalent, so existing content keeps working.
+// {email} used to be the raw "email|category" unsubscribe key and is now the
+// signed unsubscribe token.
+var legacyPlaceholders = strings.NewReplacer(
+	"{email}", "{{.UnsubscribeToken}}",
+	"{hostDomain}", "{{.HostDomain}}",
+)
+
+var funcs = template.FuncMap{
+	// default returns value, or fallback when value is empty:
+	// {{index .Attributes "first_name" | default "reader"}}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// TemplateError reports a problem with newsletter content and the line it is
+// on. Text is set when the problem is in the text version.
+type TemplateError struct {
+	Line    int
+	Message string
+	Text    bool
+}
+
+func (e *TemplateError) Error() string {
+	kind := "template"
+	if e.Text {
+		kind = "text template"
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("invalid %s at line %d: %s", kind, e.Line, e.Message)
+	}
+	return fmt.Sprintf("invalid %s: %s", kind, e.Message)
+}
+
+// Template is parsed newsletter content, ready to be rendered for many subscribers.
+type Template struct {
+	tmpl executor
+}
+
+// executor is implemented by both *html/template.Template and
+// *text/template.Template.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+const templateName = "newsletter"
+
+// parseErrorPattern matches the "template: name:line[:col]: message" errors
+// returned by text/template's parser.
+var parseErrorPattern = regexp.MustCompile(`^template: [^:]*:(\d+):(?:\d+:)? ?(.*)$`)
+
+// Parse parses newsletter content and checks that it can be rendered, so that
+// escaping problems that html/template only finds on execution are reported
+// up front. Errors are returned as *TemplateError.
+func Parse(content string) (*Template, error) {
+	tmpl, err := template.New(templateName).
+		Funcs(funcs).
+		Option("missingkey=zero").
+		Parse(legacyPlaceholders.Replace(content))
+	if err != nil {
+		return nil, newTemplateError(err)
+	}
+
+	if err := tmpl.Execute(io.Discard, sampleData()); err != nil {
+		return nil, newTemplateError(err)
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// ParseText parses the text version of a newsletter. It accepts the same
+// fields, functions and legacy placeholders as Parse, but inserts values as
+// they are. Errors are returned as *TemplateError.
+func ParseText(content string) (*Template, error) {
+	tmpl, err := texttemplate.New(templateName).
+		Funcs(texttemplate.FuncMap(funcs)).
+		Option("missingkey=zero").
+		Parse(legacyPlaceholders.Replace(content))
+	if err != nil {
+		return nil, newTextTemplateError(err)
+	}
+
+	if err := tmpl.Execute(io.Discard, sampleData()); err != nil {
+		return nil, newTextTemplateError(err)
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Render executes the template for one subscriber.
+func (t *Template) Render(data Data) (string, error) {
+	var content strings.Builder
+	if err := t.tmpl.Execute(&content, data); err != nil {
+		return "", newTemplateError(err)
+	}
+	return content.String(), nil
+}
+
+func newTextTemplateError(err error) *TemplateError {
+	templateErr := newTemplateError(err)
+	templateErr.Text = true
+	return templateErr
+}
+
+func newTemplateError(err error) *TemplateError {
+	var escapeErr *template.Error
+	if errors.As(err, &escapeErr) {
+		return &TemplateError{Line: escapeErr.Line, Message: escapeErr.Description}
+	}
+
+	if match := parseErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return &TemplateError{Line: line, Message: match[2]}
+	}
+
+	return &TemplateError{Message: strings.TrimPrefix(err.Error(), "template: ")}
+}
+
+func sampleData() Data {
+	return Data{
+		Subscriber:       domain.Subscriber{Email: "subscriber@example.com", Category: "category"},
+		UnsubscribeURL:   "https://example.com/unsubscribe",
+		PreferencesURL:   "https://example.com/preferences",
+		UnsubscribeToken: "token",
+		HostDomain:       "https://example.com/",
+		Date:             time.Now(),
+		Attributes:       map[string]string{},
+	}
+}
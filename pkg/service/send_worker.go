@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"newsletter-app/pkg/config"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/render"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSendWorkers      = 2
+	defaultSendBatchSize    = 100
+	defaultSendPollInterval = 5 * time.Second
+	defaultSendJobLease     = 5 * time.Minute
+)
+
+// SendWorkerPool processes queued send jobs in the background. Progress is
+// written back to the job after every subscriber, which both renews the lease
+// and records the resume point used when the job is picked up again.
+type SendWorkerPool struct {
+	sendJobRepository    ports.SendJobRepositoryPort
+	newsletterRepository ports.NewsletterRepositoryPort
+	subscriberRepository ports.SubscriberRepositoryPort
+	deliveryRepository   ports.DeliveryRepositoryPort
+	attachmentRepository ports.AttachmentRepositoryPort
+	emailSender          ports.EmailSender
+	unsubscribeTokens    *UnsubscribeTokens
+	publicURLs           *config.PublicURLs
+	suppressions         *SuppressionService
+	bounceTracking       *BounceTracking
+	openTracking         *OpenTracking
+	workers              int
+	batchSize            int
+	pollInterval         time.Duration
+	leaseDuration        time.Duration
+}
+
+func NewSendWorkerPool(
+	sendJobRepo ports.SendJobRepositoryPort,
+	newsletterRepo ports.NewsletterRepositoryPort,
+	subscriberRepo ports.SubscriberRepositoryPort,
+	deliveryRepo ports.DeliveryRepositoryPort,
+	attachmentRepo ports.AttachmentRepositoryPort,
+	emailSender ports.EmailSender,
+	unsubscribeTokens *UnsubscribeTokens,
+	publicURLs *config.PublicURLs,
+	suppressions *SuppressionService,
+	bounceTracking *BounceTracking,
+	openTracking *OpenTracking,
+	workers int,
+) *SendWorkerPool {
+	if workers <= 0 {
+		workers = defaultSendWorkers
+	}
+
+	return &SendWorkerPool{
+		sendJobRepository:    sendJobRepo,
+		newsletterRepository: newsletterRepo,
+		subscriberRepository: subscriberRepo,
+		deliveryRepository:   deliveryRepo,
+		attachmentRepository: attachmentRepo,
+		emailSender:          emailSender,
+		unsubscribeTokens:    unsubscribeTokens,
+		publicURLs:           publicURLs,
+		suppressions:         suppressions,
+		bounceTracking:       bounceTracking,
+		openTracking:         openTracking,
+		workers:              workers,
+		batchSize:            defaultSendBatchSize,
+		pollInterval:         defaultSendPollInterval,
+		leaseDuration:        defaultSendJobLease,
+	}
+}
+
+// Start launches the workers. They stop polling for new jobs once ctx is done.
+func (p *SendWorkerPool) Start(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	for i := 0; i < p.workers; i++ {
+		workerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		go p.run(ctx, workerID)
+	}
+}
+
+func (p *SendWorkerPool) run(ctx context.Context, workerID string) {
+	for {
+		job, err := p.sendJobRepository.ClaimNextSendJob(workerID, p.leaseDuration)
+		if err != nil {
+			fmt.Printf("Error claiming send job: %s\n", err.Error())
+		}
+
+		if job != nil {
+			if err := p.ProcessJob(job); errors.Is(err, domain.ErrSendJobLeaseLost) {
+				fmt.Printf("Send job %s was claimed by another worker, stopping\n", job.ID.Hex())
+			} else if err != nil {
+				fmt.Printf("Error processing send job %s: %s\n", job.ID.Hex(), err.Error())
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// ProcessJob sends the job's newsletter to every subscriber after the job's
+// resume point, skipping those on the suppression list. Errors reading from
+// the database are returned and leave the job running, so it is retried once
+// its lease expires. When the sender runs out of budget the job is paused
+// until the sender's next window. Progress is saved after every subscriber, and
+// processing stops with domain.ErrSendJobLeaseLost as soon as another worker
+// has claimed the job, so no subscriber is sent the newsletter twice.
+func (p *SendWorkerPool) ProcessJob(job *domain.SendJob) error {
+	if job.StartedAt.IsZero() {
+		job.StartedAt = time.Now()
+	}
+	job.ResumeAt = time.Time{}
+
+	newsletter, err := p.newsletterRepository.GetNewsletterByID(job.NewsletterID)
+	if err != nil {
+		return p.failJob(job, err)
+	}
+
+	tmpl, err := render.Parse(newsletter.Content)
+	if err != nil {
+		return p.failJob(job, err)
+	}
+
+	var textTmpl *render.Template
+	if newsletter.TextContent != "" {
+		if textTmpl, err = render.ParseText(newsletter.TextContent); err != nil {
+			return p.failJob(job, err)
+		}
+	}
+
+	attachments, err := openAttachments(p.attachmentRepository, newsletter.Attachments)
+	if err != nil {
+		return p.failJob(job, err)
+	}
+
+	for {
+		subscribers, err := p.subscriberRepository.GetSubscribersByCategoryAfter(newsletter.Category, job.LastSubscriberID, p.batchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(subscribers) == 0 {
+			break
+		}
+
+		emails := make([]string, len(subscribers))
+		for i, subscriber := range subscribers {
+			emails[i] = subscriber.Email
+		}
+		suppressed, err := p.suppressions.Suppressed(emails, newsletter.Category)
+		if err != nil {
+			return err
+		}
+
+		for _, subscriber := range subscribers {
+			if suppression, ok := suppressed[subscriber.Email]; ok {
+				if err := p.skip(job, subscriber, suppression); err != nil {
+					return err
+				}
+			} else if err := p.deliver(job, newsletter, tmpl, textTmpl, subscriber, attachments); err != nil {
+				var rateLimitErr *domain.RateLimitError
+				if errors.As(err, &rateLimitErr) {
+					return p.pauseJob(job, rateLimitErr.ResumeAt)
+				}
+				if isMessageRejection(err) {
+					return p.failJob(job, err)
+				}
+				return err
+			}
+
+			job.LastSubscriberID = subscriber.ID
+			if err := p.saveProgress(job); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	job.Status = domain.SendJobCompleted
+	job.CompletedAt = now
+	job.UpdatedAt = now
+	return p.sendJobRepository.UpdateSendJob(*job)
+}
+
+// deliver sends the newsletter to one subscriber and records the outcome in
+// the delivery log. Only errors writing the log, rate limit errors and
+// permanent rejections of the sender or the message, which every other
+// subscriber would get too, are returned; a failed send is recorded on the
+// delivery and counted on the job, and the address is suppressed when the
+// server refused the recipient for good.
+// Retrying transient errors is left to the email sender, which reports how
+// many attempts it made. A rate-limited delivery stays queued. The text part
+// is rendered from textTmpl when the newsletter has a text version, and
+// generated from the HTML otherwise, before the tracking pixel is added.
+func (p *SendWorkerPool) deliver(job *domain.SendJob, newsletter *domain.Newsletter, tmpl, textTmpl *render.Template, subscriber domain.Subscriber, attachments []*domain.Attachment) error {
+	delivery := domain.Delivery{
+		NewsletterID: job.NewsletterID,
+		SubscriberID: subscriber.ID,
+		JobID:        job.ID,
+		Email:        subscriber.Email,
+	}
+	var openToken string
+	if p.openTracking.Enabled() {
+		openToken = p.openTracking.NewToken()
+		delivery.OpenTokens = []string{openToken}
+	}
+	if err := p.deliveryRepository.QueueDelivery(delivery); err != nil {
+		return err
+	}
+
+	unsubscribeToken, err := p.unsubscribeTokens.Issue(subscriber)
+	if err != nil {
+		job.Failed++
+		return p.deliveryRepository.UpdateDeliveryStatus(job.NewsletterID, subscriber.ID, domain.DeliveryFailed, err.Error(), 0)
+	}
+
+	data := p.renderData(newsletter, subscriber, unsubscribeToken)
+	content, err := tmpl.Render(data)
+	if err != nil {
+		job.Failed++
+		return p.deliveryRepository.UpdateDeliveryStatus(job.NewsletterID, subscriber.ID, domain.DeliveryFailed, err.Error(), 0)
+	}
+
+	textContent := render.PlainText(content)
+	if textTmpl != nil {
+		if textContent, err = textTmpl.Render(data); err != nil {
+			job.Failed++
+			return p.deliveryRepository.UpdateDeliveryStatus(job.NewsletterID, subscriber.ID, domain.DeliveryFailed, err.Error(), 0)
+		}
+	}
+
+	if openToken != "" {
+		content = p.openTracking.AddPixel(content, subscriber.Category, openToken)
+	}
+
+	message := domain.EmailMessage{
+		Subject:     newsletter.Subject,
+		Body:        content,
+		TextBody:    textContent,
+		To:          []string{subscriber.Email},
+		Attachments: attachments,
+		Headers:     p.unsubscribeTokens.Headers(subscriber.Category, unsubscribeToken),
+		ReturnPath:  p.bounceTracking.ReturnPath(job.NewsletterID, subscriber.ID),
+		Metadata:    p.bounceTracking.Metadata(job.NewsletterID, subscriber.ID),
+	}
+	message.Headers["Message-ID"] = p.bounceTracking.MessageID(job.NewsletterID, subscriber.ID)
+
+	status, errorText, attempts := domain.DeliverySent, "", 1
+	sendErr := p.emailSender.Send(message)
+	if sendErr != nil {
+		var rateLimitErr *domain.RateLimitError
+		if errors.As(sendErr, &rateLimitErr) {
+			return sendErr
+		}
+
+		fmt.Printf("Error sending newsletter to %s: %s\n", subscriber.Email, sendErr.Error())
+		status, errorText, attempts = domain.DeliveryFailed, sendErr.Error(), domain.SendAttempts(sendErr)
+		job.Failed++
+	} else {
+		job.Sent++
+	}
+
+	if err := p.deliveryRepository.UpdateDeliveryStatus(job.NewsletterID, subscriber.ID, status, errorText, attempts); err != nil {
+		return err
+	}
+
+	if isMessageRejection(sendErr) {
+		return sendErr
+	}
+	// A refused recipient, such as an unknown mailbox, would fail again on
+	// every send, so the address is suppressed like a hard bounce.
+	if isRecipientRejection(sendErr) {
+		return p.suppressions.Suppress(subscriber.Email, "", domain.SuppressionHardBounce, SuppressionSourceSend, errorText)
+	}
+	return nil
+}
+
+// isRecipientRejection reports whether err is a permanent refusal of RCPT TO
+// that means the address is bad, the same way classifyBounce tells hard
+// bounces from soft ones.
+func isRecipientRejection(err error) bool {
+	var sendErr *domain.SendError
+	if !errors.As(err, &sendErr) || !sendErr.Permanent || sendErr.Phase != domain.SendPhaseRecipient {
+		return false
+	}
+	_, subject, _ := strings.Cut(sendErr.Status, ".")
+	return !isSoftFailureSubject(subject)
+}
+
+// isMessageRejection reports whether err is a permanent refusal of MAIL FROM
+// or DATA, which is about the sender or the newsletter rather than the
+// subscriber.
+func isMessageRejection(err error) bool {
+	var sendErr *domain.SendError
+	return errors.As(err, &sendErr) && sendErr.Permanent &&
+		(sendErr.Phase == domain.SendPhaseSender || sendErr.Phase == domain.SendPhaseMessage)
+}
+
+// skip records that a suppressed subscriber was not sent the newsletter.
+func (p *SendWorkerPool) skip(job *domain.SendJob, subscriber domain.Subscriber, suppression domain.Suppression) error {
+	delivery := domain.Delivery{
+		NewsletterID: job.NewsletterID,
+		SubscriberID: subscriber.ID,
+		JobID:        job.ID,
+		Email:        subscriber.Email,
+	}
+	if err := p.deliveryRepository.QueueDelivery(delivery); err != nil {
+		return err
+	}
+
+	job.Suppressed++
+	return p.deliveryRepository.UpdateDeliveryStatus(job.NewsletterID, subscriber.ID, domain.DeliverySuppressed, "suppressed: "+string(suppression.Reason), 0)
+}
+
+func (p *SendWorkerPool) saveProgress(job *domain.SendJob) error {
+	now := time.Now()
+	job.UpdatedAt = now
+	job.LeaseExpiresAt = now.Add(p.leaseDuration)
+	return p.sendJobRepository.UpdateSendJob(*job)
+}
+
+// pauseJob releases the job until resumeAt, when it can be claimed again.
+func (p *SendWorkerPool) pauseJob(job *domain.SendJob, resumeAt time.Time) error {
+	fmt.Printf("Send job %s paused until %s\n", job.ID.Hex(), resumeAt.Format(time.RFC3339))
+
+	job.Status = domain.SendJobPaused
+	job.ResumeAt = resumeAt
+	job.LeaseExpiresAt = time.Time{}
+	job.UpdatedAt = time.Now()
+	return p.sendJobRepository.UpdateSendJob(*job)
+}
+
+func (p *SendWorkerPool) failJob(job *domain.SendJob, cause error) error {
+	now := time.Now()
+	job.Status = domain.SendJobFailed
+	job.Error = cause.Error()
+	job.CompletedAt = now
+	job.UpdatedAt = now
+	if err := p.sendJobRepository.UpdateSendJob(*job); err != nil {
+		return err
+	}
+	return cause
+}
+
+func (p *SendWorkerPool) renderData(newsletter *domain.Newsletter, subscriber domain.Subscriber, unsubscribeToken string) render.Data {
+	urls := p.publicURLs.For(subscriber.Category)
+	return render.Data{
+		Subscriber:       subscriber,
+		Newsletter:       *newsletter,
+		UnsubscribeURL:   urls.UnsubscribeURL(unsubscribeToken),
+		PreferencesURL:   urls.PreferencesURL(unsubscribeToken),
+		UnsubscribeToken: unsubscribeToken,
+		HostDomain:       urls.HostDomain(),
+		Date:             time.Now(),
+		Attributes:       subscriber.Attributes,
+	}
+}
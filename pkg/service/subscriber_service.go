@@ -1,34 +1,174 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/domain/ports"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var _ ports.SubscriberServicePort = (*SubscriberServiceImpl)(nil)
 
+var (
+	ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+	ErrExpiredUnsubscribeToken = errors.New("unsubscribe token has expired")
+
+	ErrInvalidConfirmationToken = errors.New("invalid confirmation token")
+	ErrExpiredConfirmationToken = errors.New("confirmation token has expired")
+)
+
 type SubscriberServiceImpl struct {
 	subscriberRepository ports.SubscriberRepositoryPort
+	emailSender          ports.EmailSender
+	unsubscribeTokens    *UnsubscribeTokens
+	confirmations        *SubscriptionConfirmations
+	suppressions         *SuppressionService
 }
 
-func NewSubscriberService(subscriberRepo ports.SubscriberRepositoryPort) ports.SubscriberServicePort {
+func NewSubscriberService(
+	subscriberRepo ports.SubscriberRepositoryPort,
+	emailSender ports.EmailSender,
+	unsubscribeTokens *UnsubscribeTokens,
+	confirmations *SubscriptionConfirmations,
+	suppressions *SuppressionService,
+) ports.SubscriberServicePort {
 	return &SubscriberServiceImpl{
 		subscriberRepository: subscriberRepo,
+		emailSender:          emailSender,
+		unsubscribeTokens:    unsubscribeTokens,
+		confirmations:        confirmations,
+		suppressions:         suppressions,
 	}
 }
 
-func (s *SubscriberServiceImpl) Subscribe(email string, category string) error {
+// Subscribe stores a subscriber. In categories that require double opt-in the
+// subscriber is stored as pending and sent a confirmation link; subscribing
+// again while pending sends a new link instead of storing a duplicate. When
+// the link cannot be sent, the new pending subscriber is removed so that
+// subscribing again starts over.
+// Suppressed addresses cannot subscribe, except that an address that
+// unsubscribed from a double opt-in category may subscribe to it again: the
+// confirmation shows it is wanted, and lifts the suppression.
+func (s *SubscriberServiceImpl) Subscribe(email string, category string, attributes map[string]string) error {
+	suppressions, err := s.suppressions.Check(email, category)
+	if err != nil {
+		return err
+	}
+	for _, suppression := range suppressions {
+		resubscribing := suppression.Reason == domain.SuppressionUnsubscribed && suppression.Category == category
+		if !resubscribing || !s.confirmations.Required(category) {
+			return ErrSubscriberSuppressed
+		}
+	}
+
 	subscriber := domain.Subscriber{
 		Email:            email,
 		SubscriptionDate: time.Now(),
 		Category:         category,
+		Attributes:       attributes,
+		Status:           domain.SubscriberActive,
+	}
+
+	if !s.confirmations.Required(category) {
+		return s.subscriberRepository.SaveSubscriber(subscriber)
+	}
+
+	existing, err := s.subscriberRepository.GetSubscriberByEmailAndCategory(email, category)
+	if err == nil && existing != nil && existing.Status == domain.SubscriberPending {
+		return s.sendConfirmation(*existing)
 	}
-	return s.subscriberRepository.SaveSubscriber(subscriber)
+
+	expiresAt := s.confirmations.ExpiresAt()
+	subscriber.ID = primitive.NewObjectID()
+	subscriber.Status = domain.SubscriberPending
+	subscriber.ExpiresAt = &expiresAt
+	if err := s.subscriberRepository.SaveSubscriber(subscriber); err != nil {
+		return err
+	}
+
+	if err := s.sendConfirmation(subscriber); err != nil {
+		if deleteErr := s.subscriberRepository.DeleteSubscriberByEmail(email, category); deleteErr != nil {
+			fmt.Printf("Error removing pending subscriber %s: %s\n", email, deleteErr.Error())
+		}
+		return err
+	}
+	return nil
 }
 
+func (s *SubscriberServiceImpl) sendConfirmation(subscriber domain.Subscriber) error {
+	confirmationToken, err := s.confirmations.Issue(subscriber)
+	if err != nil {
+		return err
+	}
+
+	message, err := s.confirmations.Message(subscriber, confirmationToken)
+	if err != nil {
+		return err
+	}
+
+	return s.emailSender.Send(message)
+}
+
+// ConfirmSubscription activates the pending subscriber named by a signed
+// confirmation token. Confirming twice succeeds; a subscriber removed after
+// its confirmation window is reported as an expired token.
+func (s *SubscriberServiceImpl) ConfirmSubscription(confirmationToken string) error {
+	subscriberID, err := s.confirmations.Verify(confirmationToken)
+	if err != nil {
+		return err
+	}
+
+	subscriber, err := s.subscriberRepository.GetSubscriberByID(subscriberID)
+	if err != nil {
+		return err
+	}
+
+	if subscriber == nil {
+		return ErrExpiredConfirmationToken
+	}
+
+	if subscriber.Status != domain.SubscriberPending {
+		return nil
+	}
+
+	if err := s.subscriberRepository.ConfirmSubscriber(subscriber.ID); err != nil {
+		return err
+	}
+
+	return s.suppressions.Lift(subscriber.Email, subscriber.Category, domain.SuppressionUnsubscribed)
+}
+
+// Unsubscribe removes the subscriber and suppresses the address in the
+// category, so it is not sent that category again if it is added back.
 func (s *SubscriberServiceImpl) Unsubscribe(email, category string) error {
-	return s.subscriberRepository.DeleteSubscriberByEmail(email, category)
+	if err := s.subscriberRepository.DeleteSubscriberByEmail(email, category); err != nil {
+		return err
+	}
+
+	return s.suppressions.Suppress(email, category, domain.SuppressionUnsubscribed, SuppressionSourceUnsubscribe, "")
+}
+
+// UnsubscribeByToken unsubscribes the subscriber named by a signed unsubscribe
+// token. Following a link for a subscriber that is already gone succeeds.
+func (s *SubscriberServiceImpl) UnsubscribeByToken(unsubscribeToken string) error {
+	subscriberID, category, err := s.unsubscribeTokens.Verify(unsubscribeToken)
+	if err != nil {
+		return err
+	}
+
+	subscriber, err := s.subscriberRepository.GetSubscriberByID(subscriberID)
+	if err != nil {
+		return err
+	}
+
+	if subscriber == nil {
+		return nil
+	}
+
+	return s.Unsubscribe(subscriber.Email, category)
 }
 
 func (s *SubscriberServiceImpl) GetSubscriberByEmail(email, category string) (*domain.Subscriber, error) {
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"html/template"
+	"newsletter-app/pkg/config"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/token"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	confirmationTokenPurpose = "confirm"
+	defaultConfirmationTTL   = 48 * time.Hour
+	confirmationEmailSubject = "Please confirm your subscription"
+	allDoubleOptInCategories = "*"
+)
+
+var confirmationEmailTemplate = template.Must(template.New("confirmation").Parse(
+	`<p>Please confirm your subscription to {{.Category}} by following this link:</p>` +
+		`<p><a href="{{.URL}}">Confirm my subscription</a></p>` +
+		`<p>If you did not ask to subscribe, ignore this email and you will not hear from us again.</p>`,
+))
+
+// SubscriptionConfirmations decides which categories need double opt-in and
+// issues the signed tokens used in confirmation links. A pending subscriber
+// and its confirmation link expire after the same TTL.
+type SubscriptionConfirmations struct {
+	signer        *token.Signer
+	publicURLs    *config.PublicURLs
+	ttl           time.Duration
+	categories    map[string]bool
+	allCategories bool
+}
+
+// NewSubscriptionConfirmations requires double opt-in for the given
+// categories, or for every category when they include "*". Links are built
+// from publicURLs.
+func NewSubscriptionConfirmations(signer *token.Signer, publicURLs *config.PublicURLs, ttl time.Duration, categories []string) *SubscriptionConfirmations {
+	if ttl <= 0 {
+		ttl = defaultConfirmationTTL
+	}
+
+	confirmations := &SubscriptionConfirmations{
+		signer:     signer,
+		publicURLs: publicURLs,
+		ttl:        ttl,
+		categories: make(map[string]bool),
+	}
+	for _, category := range categories {
+		category = strings.TrimSpace(category)
+		switch category {
+		case "":
+		case allDoubleOptInCategories:
+			confirmations.allCategories = true
+		default:
+			confirmations.categories[category] = true
+		}
+	}
+	return confirmations
+}
+
+// NewSubscriptionConfirmationsFromEnv reads doubleOptInCategories, a comma
+// separated list of categories, and subscriptionConfirmationTTL.
+func NewSubscriptionConfirmationsFromEnv(signer *token.Signer, publicURLs *config.PublicURLs) *SubscriptionConfirmations {
+	ttl, _ := time.ParseDuration(os.Getenv("subscriptionConfirmationTTL"))
+	categories := strings.Split(os.Getenv("doubleOptInCategories"), ",")
+	return NewSubscriptionConfirmations(signer, publicURLs, ttl, categories)
+}
+
+// Required reports whether subscribing to category needs a confirmation.
+func (c *SubscriptionConfirmations) Required(category string) bool {
+	return c.allCategories || c.categories[category]
+}
+
+// ExpiresAt is when a subscriber left pending now is removed.
+func (c *SubscriptionConfirmations) ExpiresAt() time.Time {
+	return time.Now().Add(c.ttl)
+}
+
+func (c *SubscriptionConfirmations) Issue(subscriber domain.Subscriber) (string, error) {
+	return c.signer.Sign(token.Claims{
+		Purpose:  confirmationTokenPurpose,
+		Subject:  subscriber.ID.Hex(),
+		Category: subscriber.Category,
+	}, c.ttl)
+}
+
+// Verify returns the subscriber ID of a valid token. It returns
+// ErrInvalidConfirmationToken or ErrExpiredConfirmationToken otherwise.
+func (c *SubscriptionConfirmations) Verify(confirmationToken string) (string, error) {
+	claims, err := c.signer.Verify(confirmationToken, confirmationTokenPurpose)
+	if err != nil {
+		if errors.Is(err, token.ErrExpiredToken) {
+			return "", ErrExpiredConfirmationToken
+		}
+		return "", ErrInvalidConfirmationToken
+	}
+	return claims.Subject, nil
+}
+
+// URL returns the confirmation link for a token issued for category.
+func (c *SubscriptionConfirmations) URL(category, confirmationToken string) string {
+	return c.publicURLs.For(category).ConfirmURL(confirmationToken)
+}
+
+// Message builds the confirmation email sent to a pending subscriber.
+func (c *SubscriptionConfirmations) Message(subscriber domain.Subscriber, confirmationToken string) (domain.EmailMessage, error) {
+	var body strings.Builder
+	err := confirmationEmailTemplate.Execute(&body, map[string]string{
+		"Category": subscriber.Category,
+		"URL":      c.URL(subscriber.Category, confirmationToken),
+	})
+	if err != nil {
+		return domain.EmailMessage{}, err
+	}
+
+	return domain.EmailMessage{
+		Subject: confirmationEmailSubject,
+		Body:    body.String(),
+		To:      []string{subscriber.Email},
+	}, nil
+}
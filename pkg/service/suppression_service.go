@@ -0,0 +1,291 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/Dtos/response"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var _ ports.SuppressionServicePort = (*SuppressionService)(nil)
+
+var (
+	ErrSubscriberSuppressed     = errors.New("address is on the suppression list")
+	ErrSuppressionNotFound      = errors.New("suppression not found")
+	ErrInvalidSuppression       = errors.New("a suppression needs a valid email address or address hash")
+	ErrInvalidSuppressionReason = errors.New("invalid suppression reason")
+)
+
+const (
+	defaultSuppressionPageSize = 20
+	maxSuppressionPageSize     = 100
+	// suppressionBatchSize bounds the suppressions written at once by an import.
+	suppressionBatchSize = 1000
+)
+
+// Sources of the suppressions added by this application.
+const (
+	SuppressionSourceAPI         = "api"
+	SuppressionSourceImport      = "import"
+	SuppressionSourceUnsubscribe = "unsubscribe"
+	SuppressionSourceBounce      = "bounce"
+	SuppressionSourceComplaint   = "complaint"
+	SuppressionSourceSend        = "send"
+)
+
+var addressHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// addressLikePattern matches anything that looks like an email address in a
+// suppression detail, such as the recipient quoted by a bounce diagnostic.
+var addressLikePattern = regexp.MustCompile(`[^\s<>()\[\],;:"']+@[^\s<>()\[\],;:"']+`)
+
+// redactedAddress replaces the addresses removed from suppression details.
+const redactedAddress = "[address]"
+
+// SuppressionService keeps the list of addresses that must not receive
+// newsletters, checked before subscribing an address and before every
+// delivery. Addresses are stored as the hex SHA-256 of the address in lower
+// case, or its HMAC-SHA256 when a key is set, and are redacted from the
+// details, so the list does not hold the addresses themselves.
+type SuppressionService struct {
+	suppressionRepository ports.SuppressionRepositoryPort
+	hashKey               []byte
+}
+
+func NewSuppressionService(suppressionRepo ports.SuppressionRepositoryPort, hashKey []byte) *SuppressionService {
+	return &SuppressionService{
+		suppressionRepository: suppressionRepo,
+		hashKey:               hashKey,
+	}
+}
+
+// NewSuppressionServiceFromEnv reads suppressionHashKey, which is required:
+// plain SHA-256 hashes of addresses are easily reversed by hashing a list of
+// known addresses. Changing the key makes the stored hashes match no address,
+// so it must not change once the list is in use.
+func NewSuppressionServiceFromEnv(suppressionRepo ports.SuppressionRepositoryPort) (*SuppressionService, error) {
+	hashKey := os.Getenv("suppressionHashKey")
+	if hashKey == "" {
+		return nil, errors.New("suppressionHashKey is required")
+	}
+	return NewSuppressionService(suppressionRepo, []byte(hashKey)), nil
+}
+
+// HashAddress returns the hash under which an email address is suppressed.
+func (s *SuppressionService) HashAddress(email string) string {
+	normalized := []byte(strings.ToLower(strings.TrimSpace(email)))
+	if len(s.hashKey) == 0 {
+		sum := sha256.Sum256(normalized)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write(normalized)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// addressHash returns the hash of address, which is an email address or
+// already a hash.
+func (s *SuppressionService) addressHash(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if hash := strings.ToLower(address); addressHashPattern.MatchString(hash) {
+		return hash, nil
+	}
+	if !IsValidEmail(address) {
+		return "", ErrInvalidSuppression
+	}
+	return s.HashAddress(address), nil
+}
+
+// Suppress adds email to the suppression list for category, or for every
+// category when category is empty.
+func (s *SuppressionService) Suppress(email, category string, reason domain.SuppressionReason, source, detail string) error {
+	return s.suppressionRepository.SaveSuppressions([]domain.Suppression{{
+		AddressHash: s.HashAddress(email),
+		Category:    category,
+		Reason:      reason,
+		Source:      source,
+		Detail:      redactAddresses(detail),
+	}})
+}
+
+// redactAddresses replaces the email addresses in a suppression detail,
+// which often quotes a server reply naming the recipient.
+func redactAddresses(detail string) string {
+	return addressLikePattern.ReplaceAllString(detail, redactedAddress)
+}
+
+// Check returns the suppressions that keep email from receiving newsletters
+// of category.
+func (s *SuppressionService) Check(email, category string) ([]domain.Suppression, error) {
+	return s.suppressionRepository.FindSuppressions([]string{s.HashAddress(email)}, category)
+}
+
+// Suppressed returns the email addresses among emails that must not receive
+// newsletters of category, with the suppression that applies to each.
+func (s *SuppressionService) Suppressed(emails []string, category string) (map[string]domain.Suppression, error) {
+	hashes := make([]string, len(emails))
+	for i, email := range emails {
+		hashes[i] = s.HashAddress(email)
+	}
+
+	suppressions, err := s.suppressionRepository.FindSuppressions(hashes, category)
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string]domain.Suppression, len(suppressions))
+	for _, suppression := range suppressions {
+		byHash[suppression.AddressHash] = suppression
+	}
+
+	suppressed := make(map[string]domain.Suppression)
+	for i, email := range emails {
+		if suppression, ok := byHash[hashes[i]]; ok {
+			suppressed[email] = suppression
+		}
+	}
+	return suppressed, nil
+}
+
+// Lift removes the suppression of email in category if it has the given
+// reason.
+func (s *SuppressionService) Lift(email, category string, reason domain.SuppressionReason) error {
+	suppressions, err := s.Check(email, category)
+	if err != nil {
+		return err
+	}
+
+	for _, suppression := range suppressions {
+		if suppression.Category == category && suppression.Reason == reason {
+			_, err := s.suppressionRepository.DeleteSuppressions(suppression.AddressHash, category)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SuppressionService) AddSuppression(suppressionRequest request.SuppressionRequest) (*domain.Suppression, error) {
+	suppression, err := s.suppressionFromRequest(suppressionRequest, SuppressionSourceAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.suppressionRepository.SaveSuppressions([]domain.Suppression{suppression}); err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+// ImportSuppressions adds many addresses at once. Every entry is checked
+// before any is stored, so an invalid entry rejects the whole import.
+func (s *SuppressionService) ImportSuppressions(suppressionRequests []request.SuppressionRequest) (*response.SuppressionImportResponse, error) {
+	suppressions := make([]domain.Suppression, len(suppressionRequests))
+	for i, suppressionRequest := range suppressionRequests {
+		suppression, err := s.suppressionFromRequest(suppressionRequest, SuppressionSourceImport)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		suppressions[i] = suppression
+	}
+
+	for start := 0; start < len(suppressions); start += suppressionBatchSize {
+		end := min(start+suppressionBatchSize, len(suppressions))
+		if err := s.suppressionRepository.SaveSuppressions(suppressions[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &response.SuppressionImportResponse{Imported: len(suppressions)}, nil
+}
+
+func (s *SuppressionService) suppressionFromRequest(suppressionRequest request.SuppressionRequest, source string) (domain.Suppression, error) {
+	address := suppressionRequest.Email
+	if address == "" {
+		address = suppressionRequest.AddressHash
+	}
+	hash, err := s.addressHash(address)
+	if err != nil {
+		return domain.Suppression{}, err
+	}
+
+	reason := suppressionRequest.Reason
+	if reason == "" {
+		reason = domain.SuppressionManual
+	}
+	if !reason.IsValid() {
+		return domain.Suppression{}, fmt.Errorf("%w: %q", ErrInvalidSuppressionReason, reason)
+	}
+
+	return domain.Suppression{
+		AddressHash: hash,
+		Category:    strings.TrimSpace(suppressionRequest.Category),
+		Reason:      reason,
+		Source:      source,
+		Detail:      redactAddresses(suppressionRequest.Detail),
+	}, nil
+}
+
+// GetSuppressions lists the suppression list, optionally only the
+// suppressions of one address, category or reason.
+func (s *SuppressionService) GetSuppressions(address, category string, reason domain.SuppressionReason, page, pageSize int) (*response.SuppressionsResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultSuppressionPageSize
+	}
+	if pageSize > maxSuppressionPageSize {
+		pageSize = maxSuppressionPageSize
+	}
+
+	filter := domain.SuppressionFilter{Category: category, Reason: reason}
+	if address != "" {
+		hash, err := s.addressHash(address)
+		if err != nil {
+			return nil, err
+		}
+		filter.AddressHash = hash
+	}
+
+	suppressions, total, err := s.suppressionRepository.GetSuppressions(filter, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.SuppressionsResponse{
+		Suppressions: suppressions,
+		Page:         page,
+		PageSize:     pageSize,
+		Total:        total,
+	}, nil
+}
+
+// DeleteSuppressions removes address from the suppression list in category,
+// or in every category when category is empty.
+func (s *SuppressionService) DeleteSuppressions(address, category string) error {
+	hash, err := s.addressHash(address)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.suppressionRepository.DeleteSuppressions(hash, category)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+func (s *SuppressionService) ExportSuppressions(fn func(domain.Suppression) error) error {
+	return s.suppressionRepository.EachSuppression(fn)
+}
@@ -0,0 +1,147 @@
+// Package token issues and verifies the HMAC-signed tokens embedded in links
+// sent to subscribers. Tokens are signed with one active key and verified
+// against every configured key, so keys can be rotated without breaking links
+// that were already sent.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// Claims is the signed content of a token. Purpose keeps a token issued for
+// one kind of link from being accepted by another.
+type Claims struct {
+	Purpose   string `json:"p"`
+	Subject   string `json:"s"`
+	Category  string `json:"c,omitempty"`
+	ExpiresAt int64  `json:"e,omitempty"`
+}
+
+type Signer struct {
+	keys        map[string][]byte
+	activeKeyID string
+}
+
+// NewSigner returns a signer that signs with activeKeyID and accepts tokens
+// signed with any of keys.
+func NewSigner(activeKeyID string, keys map[string][]byte) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", activeKeyID)
+	}
+	for keyID, secret := range keys {
+		if keyID == "" || strings.Contains(keyID, ".") {
+			return nil, fmt.Errorf("invalid signing key ID %q", keyID)
+		}
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("signing key %q must be at least 32 bytes long", keyID)
+		}
+	}
+
+	return &Signer{keys: keys, activeKeyID: activeKeyID}, nil
+}
+
+// NewSignerFromEnv reads the keys from tokenSigningKeys, a comma separated
+// list of keyID:secret pairs, and the key to sign with from tokenActiveKeyId.
+// When tokenActiveKeyId is unset the first key in the list is used.
+func NewSignerFromEnv() (*Signer, error) {
+	keys := make(map[string][]byte)
+	activeKeyID := os.Getenv("tokenActiveKeyId")
+
+	for i, pair := range strings.Split(os.Getenv("tokenSigningKeys"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		keyID, secret, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, fmt.Errorf("tokenSigningKeys: entry %d is not a keyID:secret pair", i+1)
+		}
+		keys[keyID] = []byte(secret)
+
+		if activeKeyID == "" {
+			activeKeyID = keyID
+		}
+	}
+
+	signer, err := NewSigner(activeKeyID, keys)
+	if err != nil {
+		return nil, fmt.Errorf("tokenSigningKeys: %w", err)
+	}
+	return signer, nil
+}
+
+// Sign returns a URL-safe token for claims, expiring after ttl when ttl is positive.
+func (s *Signer) Sign(claims Claims, ttl time.Duration) (string, error) {
+	if ttl > 0 {
+		claims.ExpiresAt = time.Now().Add(ttl).Unix()
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(payload) + "." + s.activeKeyID
+	return signed + "." + s.signature(s.keys[s.activeKeyID], signed), nil
+}
+
+// Verify checks the signature, purpose and expiry of a token and returns its claims.
+func (s *Signer) Verify(token, purpose string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	secret, ok := s.keys[parts[1]]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	expected := s.signature(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt != 0 && time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+func (s *Signer) signature(secret []byte, signed string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
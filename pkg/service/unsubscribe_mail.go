@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"newsletter-app/pkg/domain/ports"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultUnsubscribePollInterval = time.Minute
+
+// unsubscribeMailSubject starts the subject of the mailto alternative of the
+// List-Unsubscribe header, followed by the unsubscribe token.
+const unsubscribeMailSubject = "unsubscribe"
+
+// UnsubscribeMailProcessor reads the mail sent to the mailto address of the
+// List-Unsubscribe header from a local mailbox, and unsubscribes the
+// subscriber named by the signed token in its subject, like following the
+// unsubscribe link. Anyone can send mail, so the sender and the body are
+// ignored and only a valid token unsubscribes anyone.
+type UnsubscribeMailProcessor struct {
+	mailbox      ports.Mailbox
+	subscribers  ports.SubscriberServicePort
+	pollInterval time.Duration
+}
+
+func NewUnsubscribeMailProcessor(mailbox ports.Mailbox, subscribers ports.SubscriberServicePort, pollInterval time.Duration) *UnsubscribeMailProcessor {
+	if pollInterval <= 0 {
+		pollInterval = defaultUnsubscribePollInterval
+	}
+	return &UnsubscribeMailProcessor{
+		mailbox:      mailbox,
+		subscribers:  subscribers,
+		pollInterval: pollInterval,
+	}
+}
+
+// NewUnsubscribeMailProcessorFromEnv reads unsubscribePollInterval (defaults
+// to 1m).
+func NewUnsubscribeMailProcessorFromEnv(mailbox ports.Mailbox, subscribers ports.SubscriberServicePort) *UnsubscribeMailProcessor {
+	pollInterval, _ := time.ParseDuration(os.Getenv("unsubscribePollInterval"))
+	return NewUnsubscribeMailProcessor(mailbox, subscribers, pollInterval)
+}
+
+// Start reads the mailbox every poll interval until ctx is done.
+func (p *UnsubscribeMailProcessor) Start(ctx context.Context) {
+	go func() {
+		for {
+			if err := p.mailbox.Process(p.ProcessMessage); err != nil {
+				fmt.Printf("Error processing unsubscribe mail: %s\n", err.Error())
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.pollInterval):
+			}
+		}
+	}()
+}
+
+// ProcessMessage handles one message of the unsubscribe mailbox. Messages
+// without an unsubscribe token in their subject, and tokens that are invalid
+// or have expired, are skipped. Only errors unsubscribing are returned, so
+// that the message is processed again.
+func (p *UnsubscribeMailProcessor) ProcessMessage(message []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		fmt.Printf("Error parsing unsubscribe mail: %s\n", err.Error())
+		return nil
+	}
+
+	unsubscribeToken, ok := unsubscribeTokenOf(msg.Header.Get("Subject"))
+	if !ok {
+		return nil
+	}
+
+	err = p.subscribers.UnsubscribeByToken(unsubscribeToken)
+	if errors.Is(err, ErrInvalidUnsubscribeToken) || errors.Is(err, ErrExpiredUnsubscribeToken) {
+		fmt.Printf("Ignoring unsubscribe mail: %s\n", err.Error())
+		return nil
+	}
+	return err
+}
+
+// unsubscribeTokenOf finds the token following "unsubscribe" in a subject,
+// which mail clients may have encoded or prefixed with "Re:".
+func unsubscribeTokenOf(subject string) (string, bool) {
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+
+	fields := strings.Fields(subject)
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.EqualFold(fields[i], unsubscribeMailSubject) {
+			return fields[i+1], true
+		}
+	}
+	return "", false
+}
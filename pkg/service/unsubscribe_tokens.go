@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"newsletter-app/pkg/config"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/token"
+	"os"
+	"strings"
+	"time"
+)
+
+const unsubscribeTokenPurpose = "unsubscribe"
+
+// UnsubscribeTokens issues the signed tokens used in unsubscribe links and
+// verifies them when a link is followed. A token names the subscriber and the
+// category, so it cannot be forged from an email address.
+type UnsubscribeTokens struct {
+	signer     *token.Signer
+	publicURLs *config.PublicURLs
+	mailbox    string
+	ttl        time.Duration
+}
+
+// NewUnsubscribeTokens returns unsubscribe tokens signed by signer that expire
+// after ttl, or never when ttl is zero. Links are built from publicURLs, and
+// mailbox, when set, is offered as the mailto alternative in the
+// List-Unsubscribe header.
+func NewUnsubscribeTokens(signer *token.Signer, publicURLs *config.PublicURLs, mailbox string, ttl time.Duration) *UnsubscribeTokens {
+	return &UnsubscribeTokens{
+		signer:     signer,
+		publicURLs: publicURLs,
+		mailbox:    addressOf(mailbox),
+		ttl:        ttl,
+	}
+}
+
+// NewUnsubscribeTokensFromEnv reads unsubscribeTokenTTL and unsubscribeMailbox,
+// which defaults to the emailSender address.
+func NewUnsubscribeTokensFromEnv(signer *token.Signer, publicURLs *config.PublicURLs) *UnsubscribeTokens {
+	ttl, _ := time.ParseDuration(os.Getenv("unsubscribeTokenTTL"))
+
+	mailbox := os.Getenv("unsubscribeMailbox")
+	if mailbox == "" {
+		mailbox = os.Getenv("emailSender")
+	}
+
+	return NewUnsubscribeTokens(signer, publicURLs, mailbox, ttl)
+}
+
+func (t *UnsubscribeTokens) Issue(subscriber domain.Subscriber) (string, error) {
+	return t.signer.Sign(token.Claims{
+		Purpose:  unsubscribeTokenPurpose,
+		Subject:  subscriber.ID.Hex(),
+		Category: subscriber.Category,
+	}, t.ttl)
+}
+
+// Verify returns the subscriber ID and category of a valid token. It returns
+// ErrInvalidUnsubscribeToken or ErrExpiredUnsubscribeToken otherwise.
+func (t *UnsubscribeTokens) Verify(unsubscribeToken string) (string, string, error) {
+	claims, err := t.signer.Verify(unsubscribeToken, unsubscribeTokenPurpose)
+	if err != nil {
+		if errors.Is(err, token.ErrExpiredToken) {
+			return "", "", ErrExpiredUnsubscribeToken
+		}
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+	return claims.Subject, claims.Category, nil
+}
+
+// URL returns the unsubscribe link for a token issued for category.
+func (t *UnsubscribeTokens) URL(category, unsubscribeToken string) string {
+	return t.publicURLs.For(category).UnsubscribeURL(unsubscribeToken)
+}
+
+// Headers returns the List-Unsubscribe headers for a token: a mailto address
+// whose subject carries the token, read back by the UnsubscribeMailProcessor,
+// and the RFC 8058 one-click URL. Mail providers POST
+// "List-Unsubscribe=One-Click" to that URL, which unsubscribes without any
+// further interaction, while link scanners that GET it change nothing.
+// RFC 8058 requires an https URL, so it is only offered, along with the
+// List-Unsubscribe-Post header, when the API base URL of the category is
+// https.
+func (t *UnsubscribeTokens) Headers(category, unsubscribeToken string) map[string]string {
+	headers := make(map[string]string)
+	var targets []string
+	if t.mailbox != "" {
+		targets = append(targets, "<mailto:"+t.mailbox+"?subject="+url.PathEscape(unsubscribeMailSubject+" "+unsubscribeToken)+">")
+	}
+
+	oneClickURL := t.publicURLs.For(category).OneClickUnsubscribeURL(unsubscribeToken)
+	if strings.HasPrefix(oneClickURL, "https://") {
+		targets = append(targets, "<"+oneClickURL+">")
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	if len(targets) > 0 {
+		headers["List-Unsubscribe"] = strings.Join(targets, ", ")
+	}
+	return headers
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// mailerSendEvents maps the MailerSend activity types to events.
+var mailerSendEvents = map[string]EventType{
+	"delivered":      EventDelivered,
+	"hard_bounced":   EventHardBounce,
+	"soft_bounced":   EventSoftBounce,
+	"spam_complaint": EventComplaint,
+	"opened":         EventOpen,
+}
+
+// MailerSend reads MailerSend activity webhooks, signed with the HMAC-SHA256
+// of the body under the webhook's signing secret in the Signature header.
+type MailerSend struct {
+	secret []byte
+}
+
+func NewMailerSend(secret string) *MailerSend {
+	return &MailerSend{secret: []byte(secret)}
+}
+
+type mailerSendWebhook struct {
+	Type string `json:"type"`
+	Data struct {
+		Type      string `json:"type"`
+		CreatedAt string `json:"created_at"`
+		Email     struct {
+			Tags      []string `json:"tags"`
+			Recipient struct {
+				Email string `json:"email"`
+			} `json:"recipient"`
+		} `json:"email"`
+		Morph *struct {
+			Reason    string `json:"reason"`
+			UserAgent string `json:"user_agent"`
+		} `json:"morph"`
+	} `json:"data"`
+}
+
+func (m *MailerSend) Events(header http.Header, body []byte) ([]Event, error) {
+	signature, err := hex.DecodeString(strings.TrimSpace(header.Get("Signature")))
+	if err != nil || len(signature) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload mailerSendWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+
+	activity := payload.Data.Type
+	if activity == "" {
+		activity = strings.TrimPrefix(payload.Type, "activity.")
+	}
+	eventType, ok := mailerSendEvents[activity]
+	if !ok {
+		return nil, nil
+	}
+
+	event := Event{
+		Type:       eventType,
+		Email:      payload.Data.Email.Recipient.Email,
+		Tags:       payload.Data.Email.Tags,
+		OccurredAt: parseTime(payload.Data.CreatedAt),
+	}
+	if payload.Data.Morph != nil {
+		event.Diagnostic = payload.Data.Morph.Reason
+		event.UserAgent = payload.Data.Morph.UserAgent
+		event.Status = enhancedStatus("", event.Diagnostic)
+	}
+	if eventType == EventComplaint {
+		event.FeedbackType = "abuse"
+	}
+	return []Event{event}, nil
+}
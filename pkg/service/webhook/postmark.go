@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// postmarkHardBounces and postmarkSoftBounces sort the Postmark bounce types.
+// Other types, such as autoresponders, are not bounces.
+var (
+	postmarkHardBounces = map[string]bool{"HardBounce": true, "BadEmailAddress": true}
+	postmarkSoftBounces = map[string]bool{"SoftBounce": true, "Transient": true, "DnsError": true}
+)
+
+// Postmark reads Postmark webhooks. Postmark does not sign them, so the
+// webhook URL carries basic auth credentials that every request must present.
+type Postmark struct {
+	user     string
+	password string
+}
+
+func NewPostmark(user, password string) *Postmark {
+	return &Postmark{user: user, password: password}
+}
+
+type postmarkWebhook struct {
+	RecordType  string
+	Type        string
+	Email       string
+	Recipient   string
+	Description string
+	Details     string
+	UserAgent   string
+	BouncedAt   string
+	DeliveredAt string
+	ReceivedAt  string
+	Metadata    map[string]string
+}
+
+func (p *Postmark) Events(header http.Header, body []byte) ([]Event, error) {
+	request := http.Request{Header: header}
+	user, password, ok := request.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(p.user)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(p.password)) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload postmarkWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+
+	event := Event{Email: payload.Email}
+	if event.Email == "" {
+		event.Email = payload.Recipient
+	}
+	if delivery := payload.Metadata[DeliveryMetadataKey]; delivery != "" {
+		event.Tags = []string{delivery}
+	}
+
+	switch payload.RecordType {
+	case "Delivery":
+		event.Type = EventDelivered
+		event.OccurredAt = parseTime(payload.DeliveredAt)
+	case "Bounce":
+		switch {
+		case postmarkHardBounces[payload.Type]:
+			event.Type = EventHardBounce
+		case postmarkSoftBounces[payload.Type]:
+			event.Type = EventSoftBounce
+		default:
+			return nil, nil
+		}
+		event.Diagnostic = payload.Details
+		if event.Diagnostic == "" {
+			event.Diagnostic = payload.Description
+		}
+		event.Status = enhancedStatus("", payload.Details)
+		event.OccurredAt = parseTime(payload.BouncedAt)
+	case "SpamComplaint":
+		event.Type = EventComplaint
+		event.FeedbackType = "abuse"
+		event.OccurredAt = parseTime(payload.BouncedAt)
+	case "Open":
+		event.Type = EventOpen
+		event.UserAgent = payload.UserAgent
+		event.OccurredAt = parseTime(payload.ReceivedAt)
+	default:
+		return nil, nil
+	}
+	return []Event{event}, nil
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SendGrid reads the SendGrid Event Webhook with signature verification
+// enabled: the ECDSA P-256 signature of the timestamp followed by the body,
+// in the X-Twilio-Email-Event-Webhook-Signature and
+// X-Twilio-Email-Event-Webhook-Timestamp headers. Requests whose timestamp
+// is more than MaxTimestampSkew away are refused as replays.
+type SendGrid struct {
+	publicKey *ecdsa.PublicKey
+	// Now is the clock timestamps are checked against (defaults to time.Now).
+	Now func() time.Time
+}
+
+// NewSendGrid takes the verification key shown by SendGrid, a base64 DER
+// public key.
+func NewSendGrid(publicKey string) (*SendGrid, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("sendGridWebhookPublicKey: %w", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("sendGridWebhookPublicKey: %w", err)
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("sendGridWebhookPublicKey: not an ECDSA public key")
+	}
+	return &SendGrid{publicKey: ecdsaKey}, nil
+}
+
+type sendGridEvent struct {
+	Email     string `json:"email"`
+	Timestamp int64  `json:"timestamp"`
+	Event     string `json:"event"`
+	// Type tells a bounce (bounce) from a block (blocked).
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	Response  string `json:"response"`
+	Status    string `json:"status"`
+	UserAgent string `json:"useragent"`
+	// Delivery is the custom argument named by DeliveryMetadataKey.
+	Delivery string `json:"delivery"`
+}
+
+func (s *SendGrid) Events(header http.Header, body []byte) ([]Event, error) {
+	signature, err := base64.StdEncoding.DecodeString(header.Get("X-Twilio-Email-Event-Webhook-Signature"))
+	if err != nil || len(signature) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	timestamp := header.Get("X-Twilio-Email-Event-Webhook-Timestamp")
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.VerifyASN1(s.publicKey, digest[:], signature) {
+		return nil, ErrInvalidSignature
+	}
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if err := checkTimestamp(s.Now, time.Unix(sentAt, 0)); err != nil {
+		return nil, err
+	}
+
+	var payload []sendGridEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+
+	var events []Event
+	for _, sendGridEvent := range payload {
+		event := Event{
+			Email:      sendGridEvent.Email,
+			Diagnostic: sendGridEvent.Reason,
+			OccurredAt: time.Unix(sendGridEvent.Timestamp, 0).UTC(),
+		}
+		if sendGridEvent.Delivery != "" {
+			event.Tags = []string{sendGridEvent.Delivery}
+		}
+
+		switch sendGridEvent.Event {
+		case "delivered":
+			event.Type = EventDelivered
+		case "bounce":
+			// Blocks are rejections of the message, such as for its content
+			// or the sending IP, and say nothing about the address.
+			event.Type = EventHardBounce
+			if sendGridEvent.Type == "blocked" {
+				event.Type = EventSoftBounce
+			}
+			event.Status = enhancedStatus(sendGridEvent.Status, sendGridEvent.Reason)
+		case "deferred":
+			event.Type = EventSoftBounce
+			event.Diagnostic = sendGridEvent.Response
+			event.Status = enhancedStatus(sendGridEvent.Status, sendGridEvent.Response)
+		case "spamreport":
+			event.Type = EventComplaint
+			event.FeedbackType = "abuse"
+		case "open":
+			event.Type = EventOpen
+			event.UserAgent = sendGridEvent.UserAgent
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,305 @@
+package webhook
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snsHostPattern matches the hosts SNS signing certificates and subscription
+// confirmations are served from.
+var snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// maxCertificateSize bounds a downloaded signing certificate.
+const maxCertificateSize = 64 << 10
+
+// SES reads the SES event notifications delivered by an SNS HTTPS
+// subscription. Each SNS message is signed with the key of an AWS
+// certificate, downloaded from SNS and kept, and only messages of the given
+// topics are accepted. Messages published more than MaxTimestampSkew ago are
+// refused as replays. Subscription confirmations are confirmed.
+type SES struct {
+	topicARNs map[string]bool
+	client    *http.Client
+	// Now is the clock timestamps are checked against (defaults to time.Now).
+	Now func() time.Time
+
+	mu           sync.Mutex
+	certificates map[string]*x509.Certificate
+}
+
+func NewSES(topicARNs []string, client *http.Client) *SES {
+	topics := make(map[string]bool, len(topicARNs))
+	for _, topicARN := range topicARNs {
+		topics[topicARN] = true
+	}
+	return &SES{topicARNs: topics, client: client, certificates: make(map[string]*x509.Certificate)}
+}
+
+type snsMessage struct {
+	Type             string
+	MessageId        string
+	Token            string
+	TopicArn         string
+	Subject          *string
+	Message          string
+	SubscribeURL     string
+	Timestamp        string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		Timestamp   string              `json:"timestamp"`
+		Destination []string            `json:"destination"`
+		Tags        map[string][]string `json:"tags"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string `json:"bounceType"`
+		Timestamp         string `json:"timestamp"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		UserAgent             string `json:"userAgent"`
+		Timestamp             string `json:"timestamp"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Timestamp  string   `json:"timestamp"`
+		Recipients []string `json:"recipients"`
+	} `json:"delivery"`
+	Open *struct {
+		Timestamp string `json:"timestamp"`
+		UserAgent string `json:"userAgent"`
+	} `json:"open"`
+}
+
+func (s *SES) Events(header http.Header, body []byte) ([]Event, error) {
+	var message snsMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+	if !s.topicARNs[message.TopicArn] {
+		return nil, ErrInvalidSignature
+	}
+	if err := s.verify(message); err != nil {
+		return nil, err
+	}
+	if err := checkTimestamp(s.Now, parseTime(message.Timestamp)); err != nil {
+		return nil, err
+	}
+
+	switch message.Type {
+	case "SubscriptionConfirmation":
+		return nil, s.confirmSubscription(message.SubscribeURL)
+	case "Notification":
+		return sesEvents(message.Message)
+	}
+	return nil, nil
+}
+
+// verify checks the signature of an SNS message, over its fields in the
+// order SNS signs them.
+func (s *SES) verify(message snsMessage) error {
+	fields := []string{"Message", message.Message, "MessageId", message.MessageId}
+	switch message.Type {
+	case "Notification":
+		if message.Subject != nil {
+			fields = append(fields, "Subject", *message.Subject)
+		}
+		fields = append(fields, "Timestamp", message.Timestamp, "TopicArn", message.TopicArn, "Type", message.Type)
+	case "SubscriptionConfirmation", "UnsubscribeConfirmation":
+		fields = append(fields, "SubscribeURL", message.SubscribeURL, "Timestamp", message.Timestamp,
+			"Token", message.Token, "TopicArn", message.TopicArn, "Type", message.Type)
+	default:
+		return fmt.Errorf("%w: unknown message type %q", ErrInvalidPayload, message.Type)
+	}
+	signed := []byte(strings.Join(fields, "\n") + "\n")
+
+	var hash crypto.Hash
+	var digest []byte
+	switch message.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(signed)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(signed)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return ErrInvalidSignature
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	certificate, err := s.certificate(message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok || rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// certificate returns the signing certificate at certURL, which must be
+// served by SNS over HTTPS.
+func (s *SES) certificate(certURL string) (*x509.Certificate, error) {
+	if !isSNSURL(certURL) || !strings.HasSuffix(certURL, ".pem") {
+		return nil, ErrInvalidSignature
+	}
+
+	s.mu.Lock()
+	certificate, ok := s.certificates[certURL]
+	s.mu.Unlock()
+	if ok {
+		return certificate, nil
+	}
+
+	resp, err := s.client.Get(certURL)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: downloading the SNS signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook: downloading the SNS signing certificate: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCertificateSize))
+	if err != nil {
+		return nil, fmt.Errorf("webhook: downloading the SNS signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("webhook: the SNS signing certificate is not PEM")
+	}
+	certificate, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: parsing the SNS signing certificate: %w", err)
+	}
+
+	s.mu.Lock()
+	s.certificates[certURL] = certificate
+	s.mu.Unlock()
+	return certificate, nil
+}
+
+func (s *SES) confirmSubscription(subscribeURL string) error {
+	if !isSNSURL(subscribeURL) {
+		return fmt.Errorf("%w: SubscribeURL is not an SNS URL", ErrInvalidPayload)
+	}
+	resp, err := s.client.Get(subscribeURL)
+	if err != nil {
+		return fmt.Errorf("webhook: confirming the SNS subscription: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webhook: confirming the SNS subscription: %s", resp.Status)
+	}
+	return nil
+}
+
+func isSNSURL(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	return err == nil && parsed.Scheme == "https" && snsHostPattern.MatchString(parsed.Hostname())
+}
+
+// sesEvents reads an SES notification, sent either as a notification of the
+// identity (notificationType) or by event publishing (eventType).
+func sesEvents(message string) ([]Event, error) {
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, err.Error())
+	}
+	tags := notification.Mail.Tags[DeliveryMetadataKey]
+
+	notificationType := notification.NotificationType
+	if notificationType == "" {
+		notificationType = notification.EventType
+	}
+
+	var events []Event
+	switch {
+	case notificationType == "Bounce" && notification.Bounce != nil:
+		// Transient and Undetermined bounces may succeed on a later send.
+		eventType := EventSoftBounce
+		if notification.Bounce.BounceType == "Permanent" {
+			eventType = EventHardBounce
+		}
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			events = append(events, Event{
+				Type:       eventType,
+				Email:      recipient.EmailAddress,
+				Tags:       tags,
+				Status:     enhancedStatus(recipient.Status, recipient.DiagnosticCode),
+				Diagnostic: recipient.DiagnosticCode,
+				OccurredAt: parseTime(notification.Bounce.Timestamp),
+			})
+		}
+
+	case notificationType == "Complaint" && notification.Complaint != nil:
+		feedbackType := notification.Complaint.ComplaintFeedbackType
+		if feedbackType == "" {
+			feedbackType = "abuse"
+		}
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			events = append(events, Event{
+				Type:         EventComplaint,
+				Email:        recipient.EmailAddress,
+				Tags:         tags,
+				FeedbackType: feedbackType,
+				UserAgent:    notification.Complaint.UserAgent,
+				OccurredAt:   parseTime(notification.Complaint.Timestamp),
+			})
+		}
+
+	case notificationType == "Delivery" && notification.Delivery != nil:
+		for _, recipient := range notification.Delivery.Recipients {
+			events = append(events, Event{
+				Type:       EventDelivered,
+				Email:      recipient,
+				Tags:       tags,
+				OccurredAt: parseTime(notification.Delivery.Timestamp),
+			})
+		}
+
+	case notificationType == "Open" && notification.Open != nil:
+		for _, recipient := range notification.Mail.Destination {
+			events = append(events, Event{
+				Type:       EventOpen,
+				Email:      recipient,
+				Tags:       tags,
+				UserAgent:  notification.Open.UserAgent,
+				OccurredAt: parseTime(notification.Open.Timestamp),
+			})
+		}
+	}
+	return events, nil
+}
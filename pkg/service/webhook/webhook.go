@@ -0,0 +1,158 @@
+// Package webhook verifies and parses the event webhooks of the email
+// providers, turning their payloads into provider independent delivery
+// events.
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for requests whose signature or
+	// credentials do not check out.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrInvalidPayload is returned for payloads that cannot be read.
+	ErrInvalidPayload = errors.New("webhook: invalid payload")
+)
+
+// MaxTimestampSkew is how far the signed timestamp of a request may be from
+// the current time, so that a captured request cannot be replayed later.
+const MaxTimestampSkew = 5 * time.Minute
+
+// Providers whose webhooks are understood, named as in emailProvider.
+const (
+	ProviderMailerSend = "mailersend"
+	ProviderSendGrid   = "sendgrid"
+	ProviderSES        = "ses"
+	ProviderPostmark   = "postmark"
+)
+
+// DeliveryMetadataKey is the metadata key newsletter messages carry their
+// delivery key in, so that events can be linked back to the delivery.
+const DeliveryMetadataKey = "delivery"
+
+// EventType is what happened to a message.
+type EventType string
+
+const (
+	EventDelivered  EventType = "delivered"
+	EventHardBounce EventType = "hard_bounce"
+	EventSoftBounce EventType = "soft_bounce"
+	EventComplaint  EventType = "complaint"
+	EventOpen       EventType = "open"
+)
+
+// Event is one delivery event reported by a provider.
+type Event struct {
+	Type  EventType
+	Email string
+	// Tags are the values the delivery key may be in: the delivery metadata
+	// of the message, or every tag of a MailerSend message.
+	Tags []string
+	// Status is the enhanced status code of a bounce, such as 5.1.1, when
+	// the provider reports it.
+	Status     string
+	Diagnostic string
+	// FeedbackType is the feedback type of a complaint, such as abuse.
+	FeedbackType string
+	UserAgent    string
+	OccurredAt   time.Time
+}
+
+// Provider checks that a webhook request comes from the provider and returns
+// the events in it. It returns ErrInvalidSignature for requests that do not,
+// and ErrInvalidPayload for payloads it cannot read.
+type Provider interface {
+	Events(header http.Header, body []byte) ([]Event, error)
+}
+
+// NewProvidersFromEnv returns the providers whose webhooks are configured:
+// MailerSend with mailerSendWebhookSecret, SendGrid with
+// sendGridWebhookPublicKey, SES with sesSnsTopicArns and Postmark with both
+// postmarkWebhookUser and postmarkWebhookPassword.
+func NewProvidersFromEnv() (map[string]Provider, error) {
+	providers := make(map[string]Provider)
+
+	if secret := os.Getenv("mailerSendWebhookSecret"); secret != "" {
+		providers[ProviderMailerSend] = NewMailerSend(secret)
+	}
+
+	if publicKey := os.Getenv("sendGridWebhookPublicKey"); publicKey != "" {
+		sendGrid, err := NewSendGrid(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		providers[ProviderSendGrid] = sendGrid
+	}
+
+	if topicARNs := splitList(os.Getenv("sesSnsTopicArns")); len(topicARNs) > 0 {
+		providers[ProviderSES] = NewSES(topicARNs, &http.Client{Timeout: 10 * time.Second})
+	}
+
+	// Requests are only authenticated by these credentials, so half of them
+	// would let anyone post events.
+	user, password := os.Getenv("postmarkWebhookUser"), os.Getenv("postmarkWebhookPassword")
+	if (user == "") != (password == "") {
+		return nil, errors.New("postmarkWebhookUser and postmarkWebhookPassword must be set together")
+	}
+	if user != "" {
+		providers[ProviderPostmark] = NewPostmark(user, password)
+	}
+
+	return providers, nil
+}
+
+// checkTimestamp returns ErrInvalidSignature when the signed time a request
+// was sent at is missing or more than MaxTimestampSkew away from now.
+func checkTimestamp(now func() time.Time, sentAt time.Time) error {
+	if now == nil {
+		now = time.Now
+	}
+	skew := now().Sub(sentAt)
+	if sentAt.IsZero() || skew > MaxTimestampSkew || skew < -MaxTimestampSkew {
+		return fmt.Errorf("%w: timestamp %s is outside the replay window", ErrInvalidSignature, sentAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// statusPattern finds an enhanced status code in an SMTP reply such as
+// "550 5.1.1 The email account that you tried to reach does not exist".
+var statusPattern = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+
+// enhancedStatus returns the status given by the provider, or else the one
+// in the SMTP reply.
+func enhancedStatus(status, diagnostic string) string {
+	if match := statusPattern.FindStringSubmatch(status); match != nil {
+		return match[1]
+	}
+	if match := statusPattern.FindStringSubmatch(diagnostic); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// parseTime reads the RFC 3339 timestamps of the providers, returning the
+// zero time for missing or unreadable ones.
+func parseTime(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/domain/ports"
+	"newsletter-app/pkg/service/Dtos/response"
+	"newsletter-app/pkg/service/webhook"
+	"time"
+)
+
+var _ ports.WebhookServicePort = (*WebhookService)(nil)
+
+var ErrUnknownWebhookProvider = errors.New("unknown or unconfigured webhook provider")
+
+// WebhookService applies the events that API providers report through their
+// webhooks to the delivery log and the suppression list, the way the bounce
+// and complaint mailboxes do for SMTP: bounces and complaints go through the
+// BounceProcessor and the ComplaintService, and deliveries and opens are
+// recorded on the delivery. Events are linked to their delivery by the
+// metadata set by BounceTracking.
+type WebhookService struct {
+	providers          map[string]webhook.Provider
+	deliveryRepository ports.DeliveryRepositoryPort
+	bounces            *BounceProcessor
+	complaints         *ComplaintService
+	bounceTracking     *BounceTracking
+}
+
+func NewWebhookService(
+	providers map[string]webhook.Provider,
+	deliveryRepo ports.DeliveryRepositoryPort,
+	bounces *BounceProcessor,
+	complaints *ComplaintService,
+	bounceTracking *BounceTracking,
+) *WebhookService {
+	return &WebhookService{
+		providers:          providers,
+		deliveryRepository: deliveryRepo,
+		bounces:            bounces,
+		complaints:         complaints,
+		bounceTracking:     bounceTracking,
+	}
+}
+
+// NewWebhookServiceFromEnv reads the webhook settings of every provider, see
+// webhook.NewProvidersFromEnv. Providers without settings are not accepted.
+func NewWebhookServiceFromEnv(deliveryRepo ports.DeliveryRepositoryPort, bounces *BounceProcessor, complaints *ComplaintService, bounceTracking *BounceTracking) (*WebhookService, error) {
+	providers, err := webhook.NewProvidersFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhookService(providers, deliveryRepo, bounces, complaints, bounceTracking), nil
+}
+
+// HandleWebhook checks a webhook request of provider and applies its events.
+// It returns webhook.ErrInvalidSignature for requests that do not come from
+// the provider, and webhook.ErrInvalidPayload for payloads it cannot read.
+func (s *WebhookService) HandleWebhook(provider string, header http.Header, body []byte) (*response.WebhookResponse, error) {
+	webhookProvider, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownWebhookProvider
+	}
+
+	events, err := webhookProvider.Events(header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if err := s.apply(provider, event); err != nil {
+			return nil, err
+		}
+	}
+	return &response.WebhookResponse{Events: len(events)}, nil
+}
+
+func (s *WebhookService) apply(provider string, event webhook.Event) error {
+	occurredAt := event.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	newsletterID, subscriberID, matched := s.bounceTracking.MatchEvent(event)
+
+	switch event.Type {
+	case webhook.EventDelivered:
+		if matched {
+			return s.deliveryRepository.RecordDelivered(newsletterID, subscriberID, occurredAt)
+		}
+
+	case webhook.EventOpen:
+		if matched {
+			userAgentClass, appleMPP := ClassifyOpen(event.UserAgent, "")
+			return s.deliveryRepository.RecordOpen(newsletterID, subscriberID, domain.Open{
+				UserAgentClass: userAgentClass,
+				AppleMPP:       appleMPP,
+				ReportedBy:     provider,
+				OpenedAt:       occurredAt,
+			})
+		}
+
+	case webhook.EventHardBounce, webhook.EventSoftBounce:
+		bounceType := domain.BounceSoft
+		if event.Type == webhook.EventHardBounce {
+			bounceType = domain.BounceHard
+		}
+		return s.bounces.RecordBounce(newsletterID, subscriberID, domain.Bounce{
+			Type:       bounceType,
+			Recipient:  event.Email,
+			Status:     event.Status,
+			Diagnostic: event.Diagnostic,
+			ReportedBy: provider,
+			ReportedAt: occurredAt,
+		})
+
+	case webhook.EventComplaint:
+		if !matched {
+			fmt.Printf("Dropping %s complaint about no known delivery (%s)\n", provider, event.Email)
+			return nil
+		}
+		_, err := s.complaints.RecordComplaint(newsletterID, subscriberID, domain.Complaint{
+			FeedbackType: event.FeedbackType,
+			UserAgent:    event.UserAgent,
+			ReportedBy:   provider,
+			ReportedAt:   occurredAt,
+		})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "newsletter-app/pkg/api/v1"
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/Dtos/response"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAdminKey = "test-admin-key"
+
+// stubSuppressionService answers every call with an empty result.
+type stubSuppressionService struct{}
+
+func (stubSuppressionService) AddSuppression(suppression request.SuppressionRequest) (*domain.Suppression, error) {
+	return &domain.Suppression{}, nil
+}
+
+func (stubSuppressionService) ImportSuppressions(suppressions []request.SuppressionRequest) (*response.SuppressionImportResponse, error) {
+	return &response.SuppressionImportResponse{}, nil
+}
+
+func (stubSuppressionService) GetSuppressions(address, category string, reason domain.SuppressionReason, page, pageSize int) (*response.SuppressionsResponse, error) {
+	return &response.SuppressionsResponse{}, nil
+}
+
+func (stubSuppressionService) DeleteSuppressions(address, category string) error {
+	return nil
+}
+
+func (stubSuppressionService) ExportSuppressions(fn func(domain.Suppression) error) error {
+	return nil
+}
+
+func newTestRouter() *mux.Router {
+	r := mux.NewRouter()
+	v1.RegisterRoutes(r, testAdminKey, v1.Services{Suppressions: stubSuppressionService{}})
+	return r
+}
+
+// adminRoutes are the routes that act on any address.
+var adminRoutes = []struct{ method, path string }{
+	{http.MethodGet, "/api/v1/suppressions"},
+	{http.MethodPost, "/api/v1/suppressions"},
+	{http.MethodPost, "/api/v1/suppressions/import"},
+	{http.MethodGet, "/api/v1/suppressions/export"},
+	{http.MethodDelete, "/api/v1/suppressions/reader@example.com"},
+	{http.MethodDelete, "/api/v1/unsubscribe/reader@example.com/Tech"},
+	{http.MethodPost, "/api/v1/complaints"},
+}
+
+func TestAdminRoutesRequireTheAdminKey(t *testing.T) {
+	router := newTestRouter()
+
+	for _, route := range adminRoutes {
+		for name, authorization := range map[string]string{"no key": "", "wrong key": "Bearer other-key", "not bearer": testAdminKey} {
+			req := httptest.NewRequest(route.method, route.path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, "%s %s with %s", route.method, route.path, name)
+		}
+	}
+}
+
+func TestSuppressionRoutesServeTheAdmin(t *testing.T) {
+	router := newTestRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/suppressions", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
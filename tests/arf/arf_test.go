@@ -0,0 +1,75 @@
+package arf_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/service/arf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSample(t *testing.T, name string) *arf.Report {
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	report, err := arf.Parse(raw)
+	require.NoError(t, err)
+	return report
+}
+
+func TestParseYahooComplaintWithRedactedRecipient(t *testing.T) {
+	report := parseSample(t, "yahoo_abuse.eml")
+
+	assert.Equal(t, arf.FeedbackAbuse, report.FeedbackType)
+	assert.True(t, report.IsComplaint())
+	assert.Equal(t, "Yahoo!-Mail-Feedback/2.0", report.UserAgent)
+	assert.Equal(t, "bounces+6601d2e4e4b0a1b2c3d4e900-6601d2e4e4b0a1b2c3d4e901@news.example.com", report.OriginalReturnPath())
+	assert.Empty(t, report.OriginalRcptTo)
+	assert.Equal(t, []string{"redacted@yahoo.com"}, report.Recipients())
+	assert.Equal(t, "198.51.100.23", report.SourceIP)
+	assert.True(t, report.ArrivalDate.Equal(time.Date(2024, 3, 21, 9, 2, 13, 0, time.UTC)))
+	assert.Equal(t, "6601d2e4e4b0a1b2c3d4e900.6601d2e4e4b0a1b2c3d4e901@news.example.com", report.OriginalMessageID())
+}
+
+func TestParseOutlookComplaintWithTheOriginalMessage(t *testing.T) {
+	report := parseSample(t, "outlook_jmrp.eml")
+
+	assert.True(t, report.IsComplaint())
+	assert.Equal(t, "Hotmail FBL", report.UserAgent)
+	assert.Equal(t, "jmrp.outlook.com", report.ReportingMTA)
+	assert.Equal(t, "news@news.example.com", report.OriginalReturnPath())
+	assert.Equal(t, []string{"reader@outlook.com"}, report.Recipients())
+	assert.Equal(t, "6603a1f0e4b0a1b2c3d4ea00.6603a1f0e4b0a1b2c3d4ea01@news.example.com", report.OriginalMessageID())
+}
+
+func TestParseComplaintWithOnlyTheOriginalHeaders(t *testing.T) {
+	report := parseSample(t, "rfc5965_headers_only.eml")
+
+	assert.True(t, report.IsComplaint())
+	assert.Equal(t, "mail.example.net", report.ReportingMTA)
+	assert.Equal(t, []string{"Some.One@example.net"}, report.Recipients())
+	assert.Equal(t, "6604b2c1e4b0a1b2c3d4eb00.6604b2c1e4b0a1b2c3d4eb01@news.example.com", report.OriginalMessageID())
+}
+
+func TestParseNotSpamReportIsNotAComplaint(t *testing.T) {
+	report := parseSample(t, "not_spam.eml")
+
+	assert.Equal(t, arf.FeedbackNotSpam, report.FeedbackType)
+	assert.False(t, report.IsComplaint())
+}
+
+func TestParseRejectsMessagesWithoutAFeedbackReport(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("..", "dsn", "testdata", "postfix_user_unknown.eml"))
+	require.NoError(t, err)
+
+	_, err = arf.Parse(raw)
+	assert.True(t, errors.Is(err, arf.ErrNotARF))
+
+	_, err = arf.Parse([]byte("Subject: Hello\r\n\r\nJust saying hi.\r\n"))
+	assert.True(t, errors.Is(err, arf.ErrNotARF))
+}
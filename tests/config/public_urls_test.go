@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	"testing"
+
+	"newsletter-app/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicURLsDefaultToLocalDevelopment(t *testing.T) {
+	publicURLs, err := config.NewPublicURLs(config.URLs{}, nil)
+	require.NoError(t, err)
+
+	urls := publicURLs.For("Tech")
+	assert.Equal(t, "http://localhost:4200/", urls.HostDomain())
+	assert.Equal(t, "http://localhost:8080/api/v1/unsubscribe/abc", urls.UnsubscribeURL("abc"))
+	assert.Equal(t, "http://localhost:8080/api/v1/unsubscribe/one-click/abc", urls.OneClickUnsubscribeURL("abc"))
+	assert.Equal(t, "http://localhost:4200/preferences/abc", urls.PreferencesURL("abc"))
+	assert.Equal(t, "http://localhost:8080/api/v1/confirm/abc", urls.ConfirmURL("abc"))
+	assert.Equal(t, "http://localhost:8080/t/o/abc.gif", urls.OpenPixelURL("abc"))
+}
+
+func TestPublicURLsNormalizeSlashes(t *testing.T) {
+	publicURLs, err := config.NewPublicURLs(config.URLs{
+		FrontendBaseURL: "https://example.com/app/",
+		APIBaseURL:      "https://api.example.com/",
+		PreferencesPath: "/account/preferences",
+	}, nil)
+	require.NoError(t, err)
+
+	urls := publicURLs.For("Tech")
+	assert.Equal(t, "https://example.com/app/", urls.HostDomain())
+	assert.Equal(t, "https://api.example.com/api/v1/unsubscribe/abc", urls.UnsubscribeURL("abc"))
+	assert.Equal(t, "https://example.com/app/account/preferences/abc", urls.PreferencesURL("abc"))
+}
+
+func TestPublicURLsCategoryOverrides(t *testing.T) {
+	publicURLs, err := config.NewPublicURLs(
+		config.URLs{FrontendBaseURL: "https://example.com", APIBaseURL: "https://api.example.com"},
+		map[string]config.URLs{"Science": {FrontendBaseURL: "https://science.example.com"}},
+	)
+	require.NoError(t, err)
+
+	science := publicURLs.For("Science")
+	assert.Equal(t, "https://science.example.com/", science.HostDomain())
+	assert.Equal(t, "https://api.example.com/api/v1/unsubscribe/abc", science.UnsubscribeURL("abc"))
+	assert.Equal(t, "https://example.com/", publicURLs.For("Tech").HostDomain())
+}
+
+func TestPublicURLsRejectInvalidBaseURLs(t *testing.T) {
+	for _, baseURL := range []string{"example.com", "ftp://example.com", "https://example.com/?a=b"} {
+		_, err := config.NewPublicURLs(config.URLs{FrontendBaseURL: baseURL}, nil)
+		assert.Error(t, err, baseURL)
+	}
+
+	_, err := config.NewPublicURLs(config.URLs{}, map[string]config.URLs{"Tech": {APIBaseURL: "/api"}})
+	assert.Error(t, err)
+}
+
+func TestPublicURLsFromEnv(t *testing.T) {
+	t.Setenv("publicFrontendUrl", "https://example.com")
+	t.Setenv("apiBaseUrl", "https://api.example.com")
+	t.Setenv("publicUrlOverrides", `{"Science": {"frontendBaseUrl": "https://science.example.com"}}`)
+
+	publicURLs, err := config.NewPublicURLsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://science.example.com/", publicURLs.For("Science").HostDomain())
+	assert.Equal(t, "https://example.com/", publicURLs.For("Tech").HostDomain())
+
+	t.Setenv("publicUrlOverrides", `{"Science": `)
+	_, err = config.NewPublicURLsFromEnv()
+	assert.Error(t, err)
+}
+
+func TestPublicURLsFromEnvRequireBaseURLsOutsideDevelopment(t *testing.T) {
+	t.Setenv("publicFrontendUrl", "")
+	t.Setenv("apiBaseUrl", "https://api.example.com")
+
+	_, err := config.NewPublicURLsFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("appEnv", "development")
+	t.Setenv("apiBaseUrl", "")
+	publicURLs, err := config.NewPublicURLsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:4200/", publicURLs.For("Tech").HostDomain())
+}
@@ -0,0 +1,125 @@
+package dsn_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"newsletter-app/pkg/service/dsn"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSample(t *testing.T, name string) *dsn.Report {
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	report, err := dsn.Parse(raw)
+	require.NoError(t, err)
+	return report
+}
+
+func TestParsePostfixUserUnknown(t *testing.T) {
+	report := parseSample(t, "postfix_user_unknown.eml")
+
+	assert.Equal(t, []string{"bounces+65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7@news.example.com"}, report.To)
+	assert.Equal(t, "mail.news.example.com", report.ReportingMTA)
+	assert.Equal(t, "65f1c2a9e4b0a1b2c3d4e5f6.65f1c2a9e4b0a1b2c3d4e5f7@news.example.com", report.OriginalMessageID())
+	require.Len(t, report.Recipients, 1)
+
+	recipient := report.Recipients[0]
+	assert.Equal(t, "nobody@example.org", recipient.FinalRecipient)
+	assert.Equal(t, "nobody@example.org", recipient.Address())
+	assert.Equal(t, "failed", recipient.Action)
+	assert.Equal(t, "5.1.1", recipient.Status)
+	assert.Equal(t, byte('5'), recipient.Class())
+	assert.Equal(t, "1.1", recipient.Subject())
+	assert.Equal(t, "mx1.example.org", recipient.RemoteMTA)
+	assert.Equal(t, "550 5.1.1 <nobody@example.org>: Recipient address rejected: User unknown in virtual mailbox table", recipient.DiagnosticCode)
+}
+
+func TestParsePostfixDelayWarning(t *testing.T) {
+	report := parseSample(t, "postfix_delayed.eml")
+
+	require.Len(t, report.Recipients, 1)
+	recipient := report.Recipients[0]
+	assert.Equal(t, "slow@example.net", recipient.Address())
+	assert.Equal(t, "delayed", recipient.Action)
+	assert.Equal(t, "4.4.1", recipient.Status)
+	assert.Equal(t, "65f2a1b0e4b0a1b2c3d4e600.65f2a1b0e4b0a1b2c3d4e601@news.example.com", report.OriginalMessageID())
+}
+
+func TestParseExchangeOnlineRecipientNotFound(t *testing.T) {
+	report := parseSample(t, "exchange_online_not_found.eml")
+
+	assert.Equal(t, "AM0PR02MB5000.eurprd02.prod.outlook.com", report.ReportingMTA)
+	assert.Equal(t, "65f40e11e4b0a1b2c3d4e700.65f40e11e4b0a1b2c3d4e701@news.example.com", report.OriginalMessageID())
+	require.Len(t, report.Recipients, 1)
+	assert.Equal(t, "former.employee@contoso.com", report.Recipients[0].Address())
+	assert.Equal(t, "5.1.10", report.Recipients[0].Status)
+}
+
+func TestParseGmailOverQuotaWithBase64Report(t *testing.T) {
+	report := parseSample(t, "gmail_over_quota.eml")
+
+	assert.Equal(t, []string{"news@news.example.com"}, report.To)
+	assert.Equal(t, "googlemail.com", report.ReportingMTA)
+	assert.Equal(t, "65f5b3d1e4b0a1b2c3d4e800.65f5b3d1e4b0a1b2c3d4e801@news.example.com", report.OriginalMessageID())
+	require.Len(t, report.Recipients, 1)
+
+	recipient := report.Recipients[0]
+	assert.Equal(t, "full.inbox@gmail.com", recipient.Address())
+	assert.Equal(t, "failed", recipient.Action)
+	assert.Equal(t, "5.2.2", recipient.Status)
+	assert.Contains(t, recipient.DiagnosticCode, "inbox is out of storage space")
+}
+
+func TestParseEximTakesTheStatusFromTheDiagnosticCode(t *testing.T) {
+	report := parseSample(t, "exim_unrouteable.eml")
+
+	assert.Equal(t, "relay.example.com", report.ReportingMTA)
+	assert.Equal(t, "65f6c4e2e4b0a1b2c3d4e900.65f6c4e2e4b0a1b2c3d4e901@news.example.com", report.OriginalMessageID())
+	require.Len(t, report.Recipients, 1)
+	assert.Equal(t, "someone@gone.example", report.Recipients[0].Address())
+	assert.Equal(t, "5.0.0", report.Recipients[0].Status)
+	assert.Equal(t, "550 No such user here", report.Recipients[0].DiagnosticCode)
+}
+
+func TestParseRejectsMessagesWithoutDeliveryStatus(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "out_of_office.eml"))
+	require.NoError(t, err)
+
+	_, err = dsn.Parse(raw)
+	assert.ErrorIs(t, err, dsn.ErrNotDSN)
+}
+
+func TestParseRecoversTheStatusOfIncompleteReports(t *testing.T) {
+	raw := []byte("From: MAILER-DAEMON@example.com\r\n" +
+		"To: news@news.example.com\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		"Reporting-MTA: dns; mx.example.com\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; <first@example.com>\r\n" +
+		"Action: failed\r\n" +
+		"Diagnostic-Code: smtp; 554 delivery error: dd This user doesn't have an account\r\n" +
+		"\r\n" +
+		"Final-Recipient: rfc822; second@example.com\r\n" +
+		"Action: Failed (permanent)\r\n" +
+		"Status: 5.0.0 (permanent failure)\r\n" +
+		"Diagnostic-Code: smtp; 550-5.7.1 Message rejected as spam\r\n" +
+		"--b--\r\n")
+
+	report, err := dsn.Parse(raw)
+	require.NoError(t, err)
+	require.Len(t, report.Recipients, 2)
+	assert.Equal(t, "first@example.com", report.Recipients[0].Address())
+	assert.Equal(t, "5.0.0", report.Recipients[0].Status)
+	assert.Equal(t, "failed", report.Recipients[1].Action)
+	assert.Equal(t, "5.7.1", report.Recipients[1].Status)
+	assert.Empty(t, report.OriginalMessageID())
+}
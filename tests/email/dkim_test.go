@@ -0,0 +1,143 @@
+package email_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/infrastructure/adapters/email"
+
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey stores key as a PKCS#8 PEM file and returns its path.
+func writeKey(t *testing.T, key crypto.Signer) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "dkim.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+// dnsRecord returns the DKIM TXT record publishing the public half of key.
+func dnsRecord(t *testing.T, key crypto.Signer) string {
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(public)
+		require.NoError(t, err)
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public)
+	}
+	t.Fatalf("unsupported key type %T", key)
+	return ""
+}
+
+// verify checks the DKIM signatures of a raw message against records, a map
+// from selector._domainkey.domain names to TXT records.
+func verify(t *testing.T, message string, records map[string]string) []*dkim.Verification {
+	verifications, err := dkim.VerifyWithOptions(strings.NewReader(message), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if record, ok := records[domain]; ok {
+				return []string{record}, nil
+			}
+			return nil, fmt.Errorf("no TXT record for %s", domain)
+		},
+	})
+	require.NoError(t, err)
+	return verifications
+}
+
+func TestPooledEmailSenderSignsWithDKIM(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	for name, key := range map[string]crypto.Signer{"rsa": rsaKey, "ed25519": ed25519Key} {
+		t.Run(name, func(t *testing.T) {
+			dkimKey, err := email.LoadDKIMKey("example.com", "news", writeKey(t, key))
+			require.NoError(t, err)
+			signer, err := email.NewDKIMSigner([]email.DKIMKey{dkimKey}, nil)
+			require.NoError(t, err)
+
+			server := newSMTPServer(t)
+			sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1, MessagesPerConnection: 100, IdleTimeout: time.Minute}, signer)
+			defer sender.Close()
+
+			message := testMessage
+			message.TextBody = "Hello"
+			message.Headers = map[string]string{"List-Unsubscribe": "<https://api.example.com/api/v1/unsubscribe/abc>"}
+			require.NoError(t, sender.Send(message))
+
+			verifications := verify(t, lastData(server), map[string]string{"news._domainkey.example.com": dnsRecord(t, key)})
+			require.Len(t, verifications, 1)
+			assert.NoError(t, verifications[0].Err)
+			assert.Equal(t, "example.com", verifications[0].Domain)
+			assert.Contains(t, verifications[0].HeaderKeys, "List-Unsubscribe")
+
+			tampered := strings.Replace(lastData(server), "Subject: News", "Subject: Other", 1)
+			verifications = verify(t, tampered, map[string]string{"news._domainkey.example.com": dnsRecord(t, key)})
+			require.Len(t, verifications, 1)
+			assert.Error(t, verifications[0].Err)
+		})
+	}
+}
+
+func TestDKIMSignerSkipsOtherDomains(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := email.NewDKIMSigner([]email.DKIMKey{{Domain: "example.com", Selector: "news", Signer: key}}, nil)
+	require.NoError(t, err)
+
+	message := []byte("From: newsletter@example.org\r\nSubject: News\r\n\r\nHello\r\n")
+	signed, err := signer.Sign("newsletter@example.org", message)
+	require.NoError(t, err)
+	assert.Equal(t, message, signed)
+}
+
+func TestNewDKIMSignerValidatesConfiguration(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	dkimKey := email.DKIMKey{Domain: "example.com", Selector: "news", Signer: key}
+
+	_, err = email.NewDKIMSigner([]email.DKIMKey{dkimKey}, []string{"Subject"})
+	assert.Error(t, err, "From must be signed")
+
+	_, err = email.NewDKIMSigner([]email.DKIMKey{dkimKey, dkimKey}, nil)
+	assert.Error(t, err, "one key per domain")
+}
+
+func TestNewDKIMSignerFromEnv(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Setenv("dkimKeys", "")
+	signer, err := email.NewDKIMSignerFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, signer)
+
+	t.Setenv("dkimKeys", "example.com:news:"+writeKey(t, key)+", example.org:news:"+writeKey(t, key))
+	t.Setenv("dkimHeaders", "From, Subject")
+	signer, err = email.NewDKIMSignerFromEnv()
+	require.NoError(t, err)
+	assert.NotNil(t, signer)
+
+	t.Setenv("dkimKeys", "example.com:news:/does/not/exist.pem")
+	_, err = email.NewDKIMSignerFromEnv()
+	assert.Error(t, err)
+}
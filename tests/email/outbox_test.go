@@ -0,0 +1,133 @@
+package email_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var outboxMessages = []domain.EmailMessage{
+	{
+		Subject: "First issue",
+		Body:    "<p>Hello</p>",
+		To:      []string{"reader@example.com"},
+		Attachments: []*domain.Attachment{
+			{Name: "notes.txt", Type: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("notes"))},
+		},
+	},
+	{
+		// A body line starting with "From " must survive the mbox format.
+		Subject:  "Second issue",
+		Body:     "<p>Hi</p>",
+		TextBody: "Hi\n\nFrom the editor\n>From the archive",
+		To:       []string{"reader@example.com"},
+		Headers:  map[string]string{"List-Unsubscribe-Post": "List-Unsubscribe=One-Click"},
+	},
+}
+
+func TestOutboxEmailSenderStoresMessages(t *testing.T) {
+	tests := map[string]string{
+		email.OutboxEML:     "outbox",
+		email.OutboxMbox:    "outbox.mbox",
+		email.OutboxMaildir: "Maildir",
+	}
+
+	for format, name := range tests {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			sender, err := email.NewOutboxEmailSender("Newsletter <newsletter@example.com>", format, path)
+			require.NoError(t, err)
+
+			for _, message := range outboxMessages {
+				require.NoError(t, sender.Send(message))
+			}
+
+			ids, err := sender.ListOutboxMessages()
+			require.NoError(t, err)
+			require.Len(t, ids, 2)
+
+			first, err := sender.GetOutboxMessage(ids[0])
+			require.NoError(t, err)
+			assert.Contains(t, string(first), "Subject: First issue")
+			assert.Contains(t, string(first), `filename="notes.txt"`)
+			assert.Contains(t, string(first), base64.StdEncoding.EncodeToString([]byte("notes")))
+
+			second, err := sender.GetOutboxMessage(ids[1])
+			require.NoError(t, err)
+			assert.Contains(t, string(second), "Subject: Second issue")
+			assert.Contains(t, string(second), "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+			assert.Contains(t, string(second), "\r\nFrom the editor\r\n>From the archive")
+		})
+	}
+}
+
+func TestOutboxEmailSenderQuotesFromLinesInMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.mbox")
+	sender, err := email.NewOutboxEmailSender("newsletter@example.com", email.OutboxMbox, path)
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Send(outboxMessages[1]))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "From newsletter@example.com "))
+	assert.Contains(t, string(data), "\n>From the editor\n>>From the archive\n")
+}
+
+func TestOutboxEmailSenderWritesMaildirNew(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := email.NewOutboxEmailSender("newsletter@example.com", email.OutboxMaildir, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Send(outboxMessages[0]))
+
+	newMessages, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	assert.Len(t, newMessages, 1)
+
+	tmpMessages, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmpMessages)
+}
+
+func TestOutboxEmailSenderRejectsPathsOutsideTheOutbox(t *testing.T) {
+	parent := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(parent, "secret.eml"), []byte("Subject: secret\r\n\r\n"), 0o600))
+
+	sender, err := email.NewOutboxEmailSender("newsletter@example.com", email.OutboxEML, filepath.Join(parent, "outbox"))
+	require.NoError(t, err)
+
+	for _, id := range []string{"../secret.eml", "..", "", "missing.eml"} {
+		raw, err := sender.GetOutboxMessage(id)
+		assert.NoError(t, err, id)
+		assert.Nil(t, raw, id)
+	}
+}
+
+func TestNewOutboxEmailSenderRejectsBadSettings(t *testing.T) {
+	_, err := email.NewOutboxEmailSender("newsletter@example.com", "pst", t.TempDir())
+	assert.Error(t, err)
+
+	_, err = email.NewOutboxEmailSender("newsletter@example.com", email.OutboxEML, "")
+	assert.Error(t, err)
+}
+
+func TestNewEmailSenderFromEnvSelectsOutbox(t *testing.T) {
+	t.Setenv("emailProvider", "outbox")
+	t.Setenv("emailSender", "newsletter@example.com")
+	t.Setenv("outboxFormat", "maildir")
+	t.Setenv("outboxPath", t.TempDir())
+
+	sender, err := email.NewEmailSenderFromEnv()
+
+	require.NoError(t, err)
+	assert.IsType(t, &email.OutboxEmailSender{}, sender)
+}
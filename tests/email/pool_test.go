@@ -0,0 +1,167 @@
+package email_test
+
+import (
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/infrastructure/adapters/email"
+	"newsletter-app/pkg/infrastructure/adapters/email/smtptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSMTPServer starts an SMTP capture server that is closed with the test.
+func newSMTPServer(t testing.TB) *smtptest.Server {
+	server := smtptest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// plainDialer connects to server without TLS or authentication.
+func plainDialer(t testing.TB, server *smtptest.Server) email.Dialer {
+	dialer, err := email.NewSMTPDialer(email.SMTPConfig{Host: server.Host(), Port: server.Port(), TLSMode: email.TLSNone})
+	require.NoError(t, err)
+	return dialer
+}
+
+// lastData returns the last message server received, or "".
+func lastData(server *smtptest.Server) string {
+	if message := server.LastMessage(); message != nil {
+		return string(message.Data)
+	}
+	return ""
+}
+
+func TestPooledEmailSenderReusesConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 2, MessagesPerConnection: 100, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sender.Send(testMessage))
+	}
+
+	assert.Len(t, server.Messages(), 10)
+	assert.Equal(t, 1, server.Connections())
+}
+
+func TestPooledEmailSenderSetsMessageHeaders(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1, MessagesPerConnection: 100, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	message := testMessage
+	message.Headers = map[string]string{
+		"List-Unsubscribe":      "<mailto:unsubscribe@example.com?subject=unsubscribe%20abc>, <https://api.example.com/api/v1/unsubscribe/one-click/abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	require.NoError(t, sender.Send(message))
+
+	parsed, err := mail.ReadMessage(strings.NewReader(lastData(server)))
+	require.NoError(t, err)
+	assert.Equal(t, message.Headers["List-Unsubscribe"], parsed.Header.Get("List-Unsubscribe"))
+	assert.Equal(t, "List-Unsubscribe=One-Click", parsed.Header.Get("List-Unsubscribe-Post"))
+	assert.Equal(t, "newsletter@example.com", parsed.Header.Get("From"))
+	assert.Equal(t, "News", parsed.Header.Get("Subject"))
+}
+
+func TestPooledEmailSenderSendsTextAlternative(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1, MessagesPerConnection: 100, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	message := testMessage
+	message.TextBody = "Hello"
+	require.NoError(t, sender.Send(message))
+
+	parsed, err := server.LastMessage().Parse()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(parsed.Header.Get("Content-Type"), "multipart/alternative;"))
+
+	var contentTypes, bodies []string
+	for _, part := range parsed.Parts {
+		contentTypes = append(contentTypes, part.ContentType)
+		bodies = append(bodies, string(part.Body))
+	}
+
+	assert.Equal(t, []string{"text/plain", "text/html"}, contentTypes)
+	assert.Equal(t, []string{"Hello", "<p>Hello</p>"}, bodies)
+}
+
+func TestPooledEmailSenderRotatesConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1, MessagesPerConnection: 3, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, sender.Send(testMessage))
+	}
+
+	assert.Len(t, server.Messages(), 7)
+	assert.Equal(t, 3, server.Connections())
+}
+
+func TestPooledEmailSenderRedialsStaleConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1, MessagesPerConnection: 100, IdleTimeout: 10 * time.Millisecond}, nil)
+	defer sender.Close()
+
+	require.NoError(t, sender.Send(testMessage))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, sender.Send(testMessage))
+
+	assert.Equal(t, 2, server.Connections())
+}
+
+func TestPooledEmailSenderBoundsOpenConnections(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 3, MessagesPerConnection: 1000, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, sender.Send(testMessage))
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, server.Messages(), 50)
+	assert.LessOrEqual(t, server.Connections(), 3)
+}
+
+func BenchmarkPooledEmailSender(b *testing.B) {
+	server := newSMTPServer(b)
+	sender := email.NewPooledEmailSender(plainDialer(b, server), "newsletter@example.com", email.PoolConfig{Size: 4, MessagesPerConnection: 100, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := sender.Send(testMessage); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkDialPerMessage(b *testing.B) {
+	server := newSMTPServer(b)
+	sender := email.NewPooledEmailSender(plainDialer(b, server), "newsletter@example.com", email.PoolConfig{Size: 4, MessagesPerConnection: 1, IdleTimeout: time.Minute}, nil)
+	defer sender.Close()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := sender.Send(testMessage); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,414 @@
+package email_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email"
+	"newsletter-app/pkg/infrastructure/adapters/email/sigv4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var providerMessage = domain.EmailMessage{
+	Subject:  "Weekly news",
+	Body:     "<p>Hello</p>",
+	TextBody: "Hello",
+	To:       []string{"reader@example.com"},
+	Headers:  map[string]string{"List-Unsubscribe-Post": "List-Unsubscribe=One-Click"},
+	Attachments: []*domain.Attachment{
+		{Name: "notes.txt", Type: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("notes"))},
+	},
+}
+
+// providerAPI is an httptest stand-in of a provider API that records the last
+// request and answers with a fixed status and body.
+type providerAPI struct {
+	*httptest.Server
+	status  int
+	header  http.Header
+	body    string
+	request *http.Request
+	payload []byte
+}
+
+func newProviderAPI(t *testing.T, status int, body string) *providerAPI {
+	api := &providerAPI{status: status, body: body, header: http.Header{}}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.request = r
+		api.payload, _ = io.ReadAll(r.Body)
+		for name, values := range api.header {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(api.status)
+		io.WriteString(w, api.body)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (a *providerAPI) config() email.APIConfig {
+	return email.APIConfig{From: "newsletter@example.com", APIKey: "key", BaseURL: a.URL, Client: a.Client()}
+}
+
+func (a *providerAPI) decode(t *testing.T) map[string]interface{} {
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(a.payload, &payload))
+	return payload
+}
+
+func requireSendError(t *testing.T, err error) *domain.SendError {
+	var sendErr *domain.SendError
+	require.True(t, errors.As(err, &sendErr), "expected a SendError, got %v", err)
+	return sendErr
+}
+
+func TestMailerSendEmailSenderSendsMessage(t *testing.T) {
+	api := newProviderAPI(t, http.StatusAccepted, "")
+
+	require.NoError(t, email.NewMailerSendEmailSender(api.config()).Send(providerMessage))
+
+	assert.Equal(t, "/v1/email", api.request.URL.Path)
+	assert.Equal(t, "Bearer key", api.request.Header.Get("Authorization"))
+	assert.JSONEq(t, `{
+		"from": {"email": "newsletter@example.com"},
+		"to": [{"email": "reader@example.com"}],
+		"subject": "Weekly news",
+		"html": "<p>Hello</p>",
+		"text": "Hello",
+		"headers": [{"name": "List-Unsubscribe-Post", "value": "List-Unsubscribe=One-Click"}],
+		"attachments": [{"content": "bm90ZXM=", "filename": "notes.txt", "disposition": "attachment"}]
+	}`, string(api.payload))
+}
+
+func TestMailerSendEmailSenderMapsValidationErrors(t *testing.T) {
+	api := newProviderAPI(t, http.StatusUnprocessableEntity,
+		`{"message": "The to.0.email must be a valid email address.", "errors": {"to.0.email": ["The to.0.email must be a valid email address."]}}`)
+
+	err := email.NewMailerSendEmailSender(api.config()).Send(providerMessage)
+
+	sendErr := requireSendError(t, err)
+	assert.True(t, sendErr.Permanent)
+	assert.Equal(t, http.StatusUnprocessableEntity, sendErr.Code)
+
+	var providerErr *email.ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, "mailersend", providerErr.Provider)
+	assert.Contains(t, providerErr.Message, "to.0.email: The to.0.email must be a valid email address.")
+}
+
+func TestSendGridEmailSenderSendsMessage(t *testing.T) {
+	api := newProviderAPI(t, http.StatusAccepted, "")
+
+	require.NoError(t, email.NewSendGridEmailSender(api.config()).Send(providerMessage))
+
+	assert.Equal(t, "/v3/mail/send", api.request.URL.Path)
+	assert.Equal(t, "Bearer key", api.request.Header.Get("Authorization"))
+	assert.JSONEq(t, `{
+		"personalizations": [{"to": [{"email": "reader@example.com"}]}],
+		"from": {"email": "newsletter@example.com"},
+		"subject": "Weekly news",
+		"content": [{"type": "text/plain", "value": "Hello"}, {"type": "text/html", "value": "<p>Hello</p>"}],
+		"headers": {"List-Unsubscribe-Post": "List-Unsubscribe=One-Click"},
+		"attachments": [{"content": "bm90ZXM=", "type": "text/plain", "filename": "notes.txt", "disposition": "attachment"}]
+	}`, string(api.payload))
+}
+
+func TestSendGridEmailSenderMapsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		permanent bool
+	}{
+		{"bad request", http.StatusBadRequest, true},
+		{"bad API key", http.StatusUnauthorized, false},
+		{"rate limited", http.StatusTooManyRequests, false},
+		{"server error", http.StatusInternalServerError, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := newProviderAPI(t, test.status, `{"errors": [{"message": "Does not contain a valid address.", "field": "personalizations.0.to.0.email"}]}`)
+
+			err := email.NewSendGridEmailSender(api.config()).Send(providerMessage)
+
+			sendErr := requireSendError(t, err)
+			assert.Equal(t, test.permanent, sendErr.Permanent)
+			assert.Equal(t, test.status, sendErr.Code)
+			assert.Contains(t, err.Error(), "personalizations.0.to.0.email: Does not contain a valid address.")
+		})
+	}
+}
+
+func TestPostmarkEmailSenderSendsMessage(t *testing.T) {
+	api := newProviderAPI(t, http.StatusOK, `{"ErrorCode": 0, "Message": "OK"}`)
+
+	require.NoError(t, email.NewPostmarkEmailSender(api.config(), "").Send(providerMessage))
+
+	assert.Equal(t, "/email", api.request.URL.Path)
+	assert.Equal(t, "key", api.request.Header.Get("X-Postmark-Server-Token"))
+	assert.JSONEq(t, `{
+		"From": "newsletter@example.com",
+		"To": "reader@example.com",
+		"Subject": "Weekly news",
+		"HtmlBody": "<p>Hello</p>",
+		"TextBody": "Hello",
+		"Headers": [{"Name": "List-Unsubscribe-Post", "Value": "List-Unsubscribe=One-Click"}],
+		"Attachments": [{"Name": "notes.txt", "Content": "bm90ZXM=", "ContentType": "text/plain"}],
+		"MessageStream": "broadcasts"
+	}`, string(api.payload))
+}
+
+func TestProviderSendersMarkInlineAttachments(t *testing.T) {
+	message := providerMessage
+	message.Body = `<img src="cid:logo">`
+	message.Attachments = []*domain.Attachment{
+		{Name: "logo.png", Type: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png")), ContentID: "logo"},
+	}
+
+	tests := map[string]struct {
+		sender     func(email.APIConfig) email.EmailSender
+		attachment string
+	}{
+		"mailersend": {
+			sender:     func(config email.APIConfig) email.EmailSender { return email.NewMailerSendEmailSender(config) },
+			attachment: `{"content": "cG5n", "filename": "logo.png", "disposition": "inline", "id": "logo"}`,
+		},
+		"sendgrid": {
+			sender:     func(config email.APIConfig) email.EmailSender { return email.NewSendGridEmailSender(config) },
+			attachment: `{"content": "cG5n", "type": "image/png", "filename": "logo.png", "disposition": "inline", "content_id": "logo"}`,
+		},
+		"postmark": {
+			sender:     func(config email.APIConfig) email.EmailSender { return email.NewPostmarkEmailSender(config, "") },
+			attachment: `{"Name": "logo.png", "Content": "cG5n", "ContentType": "image/png", "ContentID": "cid:logo"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			api := newProviderAPI(t, http.StatusOK, `{"ErrorCode": 0, "Message": "OK"}`)
+
+			require.NoError(t, test.sender(api.config()).Send(message))
+
+			var payload map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(api.payload, &payload))
+			attachments := payload["attachments"]
+			if attachments == nil {
+				attachments = payload["Attachments"]
+			}
+			assert.JSONEq(t, "["+test.attachment+"]", string(attachments))
+		})
+	}
+}
+
+func TestProviderSendersAttachMetadata(t *testing.T) {
+	message := providerMessage
+	message.Metadata = map[string]string{"delivery": "65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7"}
+
+	tests := map[string]struct {
+		sender   func(email.APIConfig) email.EmailSender
+		field    string
+		metadata string
+	}{
+		"mailersend": {
+			sender:   func(config email.APIConfig) email.EmailSender { return email.NewMailerSendEmailSender(config) },
+			field:    "tags",
+			metadata: `["65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7"]`,
+		},
+		"sendgrid": {
+			sender:   func(config email.APIConfig) email.EmailSender { return email.NewSendGridEmailSender(config) },
+			field:    "custom_args",
+			metadata: `{"delivery": "65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7"}`,
+		},
+		"postmark": {
+			sender:   func(config email.APIConfig) email.EmailSender { return email.NewPostmarkEmailSender(config, "") },
+			field:    "Metadata",
+			metadata: `{"delivery": "65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			api := newProviderAPI(t, http.StatusOK, `{"ErrorCode": 0, "Message": "OK"}`)
+
+			require.NoError(t, test.sender(api.config()).Send(message))
+
+			var payload map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(api.payload, &payload))
+			assert.JSONEq(t, test.metadata, string(payload[test.field]))
+		})
+	}
+
+	t.Run("ses", func(t *testing.T) {
+		api := newProviderAPI(t, http.StatusOK, `{"MessageId": "0100018c"}`)
+
+		require.NoError(t, newTestSESSender(api).Send(message))
+
+		var payload map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(api.payload, &payload))
+		assert.JSONEq(t, `[{"Name": "delivery", "Value": "65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7"}]`, string(payload["EmailTags"]))
+	})
+}
+
+func TestPostmarkEmailSenderMapsErrorCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		permanent bool
+	}{
+		{"inactive recipient", `{"ErrorCode": 406, "Message": "You tried to send to a recipient that has been marked as inactive."}`, true},
+		{"unconfirmed sender signature", `{"ErrorCode": 401, "Message": "Sender signature not confirmed."}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := newProviderAPI(t, http.StatusUnprocessableEntity, test.body)
+
+			err := email.NewPostmarkEmailSender(api.config(), "newsletters").Send(providerMessage)
+
+			sendErr := requireSendError(t, err)
+			assert.Equal(t, test.permanent, sendErr.Permanent)
+			assert.Equal(t, "newsletters", api.decode(t)["MessageStream"])
+		})
+	}
+}
+
+func newTestSESSender(api *providerAPI) *email.SESEmailSender {
+	return email.NewSESEmailSender(email.SESConfig{
+		From:        "newsletter@example.com",
+		Region:      "eu-west-1",
+		Credentials: sigv4.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+		BaseURL:     api.URL,
+		Client:      api.Client(),
+		Now:         func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) },
+	})
+}
+
+func TestSESEmailSenderSendsRawMessage(t *testing.T) {
+	api := newProviderAPI(t, http.StatusOK, `{"MessageId": "0100018c"}`)
+
+	require.NoError(t, newTestSESSender(api).Send(providerMessage))
+
+	assert.Equal(t, "/v2/email/outbound-emails", api.request.URL.Path)
+	assert.Equal(t, "20240102T030405Z", api.request.Header.Get("X-Amz-Date"))
+	assert.True(t, strings.HasPrefix(api.request.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature="))
+
+	var payload struct {
+		FromEmailAddress string
+		Destination      struct{ ToAddresses []string }
+		Content          struct{ Raw struct{ Data []byte } }
+	}
+	require.NoError(t, json.Unmarshal(api.payload, &payload))
+	assert.Equal(t, "newsletter@example.com", payload.FromEmailAddress)
+	assert.Equal(t, []string{"reader@example.com"}, payload.Destination.ToAddresses)
+
+	raw := string(payload.Content.Raw.Data)
+	assert.Contains(t, raw, "Subject: Weekly news")
+	assert.Contains(t, raw, "List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+	assert.Contains(t, raw, "multipart/alternative")
+	assert.Contains(t, raw, `filename="notes.txt"`)
+}
+
+func TestSESEmailSenderMapsErrorTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		errorType string
+		permanent bool
+	}{
+		{"rejected message", http.StatusBadRequest, "MessageRejected", true},
+		{"sending paused", http.StatusBadRequest, "SendingPausedException", false},
+		{"throttled", http.StatusTooManyRequests, "TooManyRequestsException", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := newProviderAPI(t, test.status, `{"message": "Email address is not verified."}`)
+			api.header.Set("X-Amzn-ErrorType", test.errorType+":http://internal.amazon.com/coral/com.amazonaws.sesv2/")
+
+			err := newTestSESSender(api).Send(providerMessage)
+
+			sendErr := requireSendError(t, err)
+			assert.Equal(t, test.permanent, sendErr.Permanent)
+
+			var providerErr *email.ProviderError
+			require.True(t, errors.As(err, &providerErr))
+			assert.Equal(t, test.errorType, providerErr.Code)
+			assert.Equal(t, "Email address is not verified.", providerErr.Message)
+		})
+	}
+}
+
+func TestProviderNetworkErrorsAreTransient(t *testing.T) {
+	api := newProviderAPI(t, http.StatusAccepted, "")
+	config := api.config()
+	api.Close()
+
+	err := email.NewSendGridEmailSender(config).Send(providerMessage)
+
+	sendErr := requireSendError(t, err)
+	assert.False(t, sendErr.Permanent)
+}
+
+func TestNewEmailSenderFromEnvSelectsProvider(t *testing.T) {
+	t.Setenv("emailSender", "newsletter@example.com")
+	t.Setenv("emailProviderApiKey", "key")
+	t.Setenv("emailProviderTimeout", "")
+	t.Setenv("sesRegion", "eu-west-1")
+	t.Setenv("sesAccessKeyId", "AKIDEXAMPLE")
+	t.Setenv("sesSecretAccessKey", "secret")
+
+	tests := map[string]interface{}{
+		"mailersend": &email.MailerSendEmailSender{},
+		"SendGrid":   &email.SendGridEmailSender{},
+		"postmark":   &email.PostmarkEmailSender{},
+		"ses":        &email.SESEmailSender{},
+	}
+
+	for provider, want := range tests {
+		t.Run(provider, func(t *testing.T) {
+			t.Setenv("emailProvider", provider)
+
+			sender, err := email.NewEmailSenderFromEnv()
+
+			require.NoError(t, err)
+			assert.IsType(t, want, sender)
+		})
+	}
+}
+
+func TestNewEmailSenderFromEnvRejectsBadSettings(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown provider":   {"emailProvider": "carrier-pigeon"},
+		"missing API key":    {"emailProvider": "sendgrid", "emailProviderApiKey": ""},
+		"bad timeout":        {"emailProvider": "postmark", "emailProviderTimeout": "soon"},
+		"missing SES region": {"emailProvider": "ses", "sesRegion": "", "AWS_REGION": ""},
+	}
+
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("emailSender", "newsletter@example.com")
+			t.Setenv("emailProviderApiKey", "key")
+			t.Setenv("emailProviderTimeout", "")
+			t.Setenv("sesRegion", "eu-west-1")
+			t.Setenv("sesAccessKeyId", "AKIDEXAMPLE")
+			t.Setenv("sesSecretAccessKey", "secret")
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+
+			_, err := email.NewEmailSenderFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package email_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitStore counts like the MongoDB repository, in memory.
+type rateLimitStore struct {
+	mu     sync.Mutex
+	counts map[string]int
+	err    error
+}
+
+func newRateLimitStore() *rateLimitStore {
+	return &rateLimitStore{counts: map[string]int{}}
+}
+
+func (s *rateLimitStore) TakeRateLimit(key string, limit int, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if s.counts[key] >= limit {
+		return false, nil
+	}
+	s.counts[key]++
+	return true, nil
+}
+
+func (s *rateLimitStore) ReleaseRateLimit(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts[key] > 0 {
+		s.counts[key]--
+	}
+	return nil
+}
+
+func TestRateLimitedEmailSenderWaitsForNextSecond(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRateLimitedEmailSender(mockSender, email.RateLimits{PerSecond: 2}, nil)
+
+	var sentAt []time.Time
+	mockSender.On("Send", testMessage).
+		Run(func(mock.Arguments) { sentAt = append(sentAt, time.Now()) }).
+		Return(nil)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sender.Send(testMessage))
+	}
+
+	require.Len(t, sentAt, 3)
+	assert.True(t, sentAt[2].Truncate(time.Second).After(sentAt[0].Truncate(time.Second)),
+		"the third message must go out in a later one-second window")
+}
+
+func TestRateLimitedEmailSenderPausesWhenDailyBudgetIsExhausted(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRateLimitedEmailSender(mockSender, email.RateLimits{PerDay: 2}, nil)
+
+	mockSender.On("Send", testMessage).Return(nil)
+
+	require.NoError(t, sender.Send(testMessage))
+	require.NoError(t, sender.Send(testMessage))
+	err := sender.Send(testMessage)
+
+	var rateLimitErr *domain.RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, time.Now().UTC().Truncate(24*time.Hour).Add(24*time.Hour), rateLimitErr.ResumeAt.UTC())
+	mockSender.AssertNumberOfCalls(t, "Send", 2)
+}
+
+func TestRateLimitedEmailSenderSharesBudgetsThroughStore(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	store := newRateLimitStore()
+	limits := email.RateLimits{PerHour: 5, PerDay: 2}
+
+	mockSender.On("Send", testMessage).Return(nil)
+
+	// A second sender stands for a restart, or another instance.
+	require.NoError(t, email.NewRateLimitedEmailSender(mockSender, limits, store).Send(testMessage))
+	require.NoError(t, email.NewRateLimitedEmailSender(mockSender, limits, store).Send(testMessage))
+	err := email.NewRateLimitedEmailSender(mockSender, limits, store).Send(testMessage)
+
+	var rateLimitErr *domain.RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, time.Now().UTC().Truncate(24*time.Hour).Add(24*time.Hour), rateLimitErr.ResumeAt.UTC())
+	mockSender.AssertNumberOfCalls(t, "Send", 2)
+
+	// The hour taken by the refused message is given back
+	hourKey := "hour:" + time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339)
+	assert.Equal(t, 2, store.counts[hourKey])
+}
+
+func TestRateLimitedEmailSenderPausesWhenStoreFails(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	store := newRateLimitStore()
+	store.err = errors.New("connection refused")
+	sender := email.NewRateLimitedEmailSender(mockSender, email.RateLimits{PerDay: 2}, store)
+
+	err := sender.Send(testMessage)
+
+	var rateLimitErr *domain.RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), rateLimitErr.ResumeAt, 5*time.Second)
+	mockSender.AssertNotCalled(t, "Send", mock.Anything)
+}
+
+func TestRetryingEmailSenderDoesNotRetryRateLimitErrors(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRetryingEmailSender(mockSender, testRetryPolicy)
+
+	rateLimitErr := &domain.RateLimitError{ResumeAt: time.Now().Add(time.Hour)}
+	mockSender.On("Send", testMessage).Return(rateLimitErr)
+
+	err := sender.Send(testMessage)
+	assert.Equal(t, rateLimitErr, err)
+	mockSender.AssertNumberOfCalls(t, "Send", 1)
+}
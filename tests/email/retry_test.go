@@ -0,0 +1,100 @@
+package email_test
+
+import (
+	"errors"
+	"net/textproto"
+	"syscall"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEmailSender struct {
+	mock.Mock
+}
+
+func (m *MockEmailSender) Send(message domain.EmailMessage) error {
+	args := m.Called(message)
+	return args.Error(0)
+}
+
+var testMessage = domain.EmailMessage{Subject: "News", Body: "<p>Hello</p>", To: []string{"test@example.com"}}
+
+var testRetryPolicy = email.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestClassifySMTPError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      int
+		permanent bool
+	}{
+		{"mailbox busy", &textproto.Error{Code: 450, Msg: "4.2.1 Mailbox busy"}, 450, false},
+		{"unknown user", &textproto.Error{Code: 550, Msg: "5.1.1 User unknown"}, 550, true},
+		{"connection reset", syscall.ECONNRESET, 0, false},
+		{"invalid recipient", errors.New(`gomail: invalid address "nobody": mail: missing @ in addr-spec`), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendErr := email.ClassifySMTPError(tt.err)
+			assert.Equal(t, tt.code, sendErr.Code)
+			assert.Equal(t, tt.permanent, sendErr.Permanent)
+			assert.ErrorIs(t, sendErr, tt.err)
+		})
+	}
+}
+
+func TestRetryingEmailSenderRetriesTransientErrors(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRetryingEmailSender(mockSender, testRetryPolicy)
+
+	mockSender.On("Send", testMessage).
+		Return(&textproto.Error{Code: 421, Msg: "Service not available"}).Once()
+	mockSender.On("Send", testMessage).Return(nil).Once()
+
+	err := sender.Send(testMessage)
+	assert.NoError(t, err)
+	mockSender.AssertNumberOfCalls(t, "Send", 2)
+}
+
+func TestRetryingEmailSenderStopsOnPermanentErrors(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRetryingEmailSender(mockSender, testRetryPolicy)
+
+	mockSender.On("Send", testMessage).
+		Return(&textproto.Error{Code: 550, Msg: "5.1.1 User unknown"})
+
+	err := sender.Send(testMessage)
+	assert.True(t, domain.IsPermanentSendError(err))
+	assert.Equal(t, 1, domain.SendAttempts(err))
+	mockSender.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestRetryingEmailSenderGivesUpAfterMaxAttempts(t *testing.T) {
+	mockSender := new(MockEmailSender)
+	sender := email.NewRetryingEmailSender(mockSender, testRetryPolicy)
+
+	mockSender.On("Send", testMessage).Return(syscall.ECONNRESET)
+
+	err := sender.Send(testMessage)
+	assert.False(t, domain.IsPermanentSendError(err))
+	assert.Equal(t, 3, domain.SendAttempts(err))
+	mockSender.AssertNumberOfCalls(t, "Send", 3)
+}
+
+func TestRetryPolicyBackoffIsCapped(t *testing.T) {
+	policy := email.RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 1; attempt <= 40; attempt++ {
+		delay := policy.Backoff(attempt)
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+	assert.LessOrEqual(t, policy.Backoff(1), 100*time.Millisecond)
+}
@@ -0,0 +1,46 @@
+package email_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/infrastructure/adapters/email/sigv4"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exampleCredentials = sigv4.Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// The get-vanilla case of the AWS Signature Version 4 test suite.
+func TestSigV4SignsGetVanilla(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	sigv4.Sign(req, nil, exampleCredentials, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestSigV4SignsSessionToken(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://email.eu-west-1.amazonaws.com/v2/email/outbound-emails", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	credentials := exampleCredentials
+	credentials.SessionToken = "session"
+	sigv4.Sign(req, []byte("{}"), credentials, "eu-west-1", "ses", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/20240102/eu-west-1/ses/aws4_request")
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,")
+}
@@ -0,0 +1,270 @@
+package email_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/infrastructure/adapters/email"
+	"newsletter-app/pkg/infrastructure/adapters/email/smtptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sendThrough(t *testing.T, server *smtptest.Server, mode email.TLSMode, clientTLS *tls.Config) error {
+	dialer, err := email.NewSMTPDialer(email.SMTPConfig{
+		Host:      server.Host(),
+		Port:      server.Port(),
+		TLSMode:   mode,
+		TLSConfig: clientTLS,
+		Timeout:   time.Second,
+	})
+	require.NoError(t, err)
+
+	sender := email.NewPooledEmailSender(dialer, "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+	return sender.Send(testMessage)
+}
+
+// newPlainSMTPServer starts a server that does not offer STARTTLS.
+func newPlainSMTPServer(t *testing.T) *smtptest.Server {
+	server := smtptest.NewUnstartedServer()
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSMTPDialerUpgradesWithStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+
+	require.NoError(t, sendThrough(t, server, email.TLSStartTLS, server.ClientTLSConfig()))
+	assert.True(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerRequiresStartTLS(t *testing.T) {
+	server := newPlainSMTPServer(t)
+
+	err := sendThrough(t, server, email.TLSStartTLS, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "STARTTLS")
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPDialerOpportunisticSendsWithoutStartTLS(t *testing.T) {
+	server := newPlainSMTPServer(t)
+
+	require.NoError(t, sendThrough(t, server, email.TLSOpportunistic, nil))
+	require.NotNil(t, server.LastMessage())
+	assert.False(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerOpportunisticUpgradesWhenOffered(t *testing.T) {
+	server := newSMTPServer(t)
+
+	require.NoError(t, sendThrough(t, server, email.TLSOpportunistic, server.ClientTLSConfig()))
+	assert.True(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerNoneIgnoresStartTLS(t *testing.T) {
+	server := newSMTPServer(t)
+
+	require.NoError(t, sendThrough(t, server, email.TLSNone, nil))
+	assert.False(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerImplicitTLS(t *testing.T) {
+	server := smtptest.NewTLSServer()
+	t.Cleanup(server.Close)
+
+	require.NoError(t, sendThrough(t, server, email.TLSImplicit, server.ClientTLSConfig()))
+	assert.True(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerVerifiesCertificates(t *testing.T) {
+	server := newSMTPServer(t)
+
+	err := sendThrough(t, server, email.TLSStartTLS, &tls.Config{RootCAs: x509.NewCertPool()})
+	require.Error(t, err)
+	assert.Empty(t, server.Messages())
+
+	require.NoError(t, sendThrough(t, server, email.TLSStartTLS, &tls.Config{InsecureSkipVerify: true}))
+	assert.True(t, server.LastMessage().TLS)
+}
+
+func TestSMTPDialerRejectsUnknownTLSMode(t *testing.T) {
+	_, err := email.NewSMTPDialer(email.SMTPConfig{Host: "127.0.0.1", Port: 25, TLSMode: "sometimes"})
+	assert.Error(t, err)
+}
+
+// newStalledSMTPServer starts a server that answers the first replies lines
+// it is sent, the greeting first, and then stops answering without closing
+// the connection.
+func newStalledSMTPServer(t *testing.T, replies ...string) *net.TCPAddr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for i, reply := range replies {
+					if i > 0 {
+						if _, err := reader.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					conn.Write([]byte(reply + "\r\n"))
+				}
+				<-done
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr)
+}
+
+func stalledDialer(t *testing.T, addr *net.TCPAddr) *email.SMTPDialer {
+	dialer, err := email.NewSMTPDialer(email.SMTPConfig{
+		Host:           addr.IP.String(),
+		Port:           addr.Port,
+		TLSMode:        email.TLSNone,
+		CommandTimeout: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return dialer
+}
+
+func TestSMTPDialerTimesOutWaitingForTheGreeting(t *testing.T) {
+	dialer := stalledDialer(t, newStalledSMTPServer(t))
+
+	start := time.Now()
+	_, err := dialer.Dial()
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSMTPSenderTimesOutWhenTheServerStopsAnswering(t *testing.T) {
+	dialer := stalledDialer(t, newStalledSMTPServer(t, "220 smtp.example.com ESMTP", "250 smtp.example.com"))
+	sender, err := dialer.Dial()
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = sender.Send("newsletter@example.com", []string{"reader@example.com"}, nil)
+	require.Error(t, err)
+	assert.Error(t, sender.Close())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func setSMTPEnv(t *testing.T, env map[string]string) {
+	defaults := map[string]string{
+		"smtpServer":                "smtp.example.com",
+		"smtpPort":                  "587",
+		"emailSender":               "newsletter@example.com",
+		"emailPass":                 "secret",
+		"smtpTLSMode":               "",
+		"smtpTLSCAFile":             "",
+		"smtpTLSMinVersion":         "",
+		"smtpTLSInsecureSkipVerify": "",
+		"smtpCommandTimeout":        "",
+	}
+	for key, value := range env {
+		defaults[key] = value
+	}
+	for key, value := range defaults {
+		t.Setenv(key, value)
+	}
+}
+
+func TestNewSMTPConfigFromEnvDefaults(t *testing.T) {
+	setSMTPEnv(t, nil)
+
+	config, err := email.NewSMTPConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com", config.Host)
+	assert.Equal(t, 587, config.Port)
+	assert.Equal(t, email.TLSStartTLS, config.TLSMode)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.TLSConfig.MinVersion)
+	assert.False(t, config.TLSConfig.InsecureSkipVerify)
+}
+
+func TestNewSMTPConfigFromEnvUsesImplicitTLSOnPort465(t *testing.T) {
+	setSMTPEnv(t, map[string]string{"smtpPort": "465"})
+
+	config, err := email.NewSMTPConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.Equal(t, email.TLSImplicit, config.TLSMode)
+}
+
+func TestNewSMTPConfigFromEnvReadsTLSSettings(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, bundle, 0o600))
+
+	setSMTPEnv(t, map[string]string{
+		"smtpTLSMode":               "Opportunistic",
+		"smtpTLSCAFile":             caFile,
+		"smtpTLSMinVersion":         "1.3",
+		"smtpTLSInsecureSkipVerify": "true",
+		"smtpCommandTimeout":        "30s",
+	})
+
+	config, err := email.NewSMTPConfigFromEnv()
+
+	require.NoError(t, err)
+	assert.Equal(t, email.TLSOpportunistic, config.TLSMode)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.TLSConfig.MinVersion)
+	assert.True(t, config.TLSConfig.InsecureSkipVerify)
+	assert.Equal(t, 30*time.Second, config.CommandTimeout)
+
+	_, err = server.Certificate().Verify(x509.VerifyOptions{Roots: config.TLSConfig.RootCAs})
+	assert.NoError(t, err)
+}
+
+func TestNewSMTPConfigFromEnvRejectsBadSettings(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := map[string]map[string]string{
+		"missing server":       {"smtpServer": ""},
+		"bad port":             {"smtpPort": "smtp"},
+		"unknown mode":         {"smtpTLSMode": "sometimes"},
+		"unknown version":      {"smtpTLSMinVersion": "1.4"},
+		"missing CA file":      {"smtpTLSCAFile": filepath.Join(t.TempDir(), "missing.pem")},
+		"CA file not PEM":      {"smtpTLSCAFile": notPEM},
+		"skip verify not bool": {"smtpTLSInsecureSkipVerify": "sure"},
+	}
+
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			setSMTPEnv(t, env)
+
+			_, err := email.NewSMTPConfigFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package email_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/email"
+	"newsletter-app/pkg/infrastructure/adapters/email/smtptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthSMTPServer starts a server that requires the given user and offers
+// the given AUTH mechanisms.
+func newAuthSMTPServer(t *testing.T, mechanisms ...string) *smtptest.Server {
+	server := smtptest.NewUnstartedServer()
+	server.Users = map[string]string{"newsletter@example.com": "secret"}
+	server.AuthMechanisms = mechanisms
+	server.StartTLS = true
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sendAs(t *testing.T, server *smtptest.Server, username, password string, message domain.EmailMessage) error {
+	dialer, err := email.NewSMTPDialer(email.SMTPConfig{
+		Host:      server.Host(),
+		Port:      server.Port(),
+		Username:  username,
+		Password:  password,
+		TLSMode:   email.TLSStartTLS,
+		TLSConfig: server.ClientTLSConfig(),
+		Timeout:   time.Second,
+	})
+	require.NoError(t, err)
+
+	sender := email.NewPooledEmailSender(dialer, "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+	return sender.Send(message)
+}
+
+func TestSMTPEmailSenderAuthenticates(t *testing.T) {
+	for _, mechanism := range []string{"PLAIN", "LOGIN", "CRAM-MD5"} {
+		t.Run(mechanism, func(t *testing.T) {
+			server := newAuthSMTPServer(t, mechanism)
+
+			require.NoError(t, sendAs(t, server, "newsletter@example.com", "secret", testMessage))
+
+			message := server.LastMessage()
+			require.NotNil(t, message)
+			assert.Equal(t, "newsletter@example.com", message.AuthUser)
+			assert.True(t, message.TLS)
+		})
+	}
+}
+
+func TestSMTPEmailSenderRejectsWrongPassword(t *testing.T) {
+	server := newAuthSMTPServer(t, "PLAIN")
+
+	err := sendAs(t, server, "newsletter@example.com", "wrong", testMessage)
+
+	require.Error(t, err)
+	assert.False(t, domain.IsPermanentSendError(err))
+	assert.Empty(t, server.Messages())
+}
+
+func TestSMTPEmailSenderRequiresTLSWhenServerDoes(t *testing.T) {
+	server := smtptest.NewUnstartedServer()
+	server.StartTLS = true
+	server.RequireTLS = true
+	server.Start()
+	t.Cleanup(server.Close)
+
+	require.Error(t, sendThrough(t, server, email.TLSNone, nil))
+	assert.Empty(t, server.Messages())
+
+	require.NoError(t, sendThrough(t, server, email.TLSStartTLS, server.ClientTLSConfig()))
+	assert.Len(t, server.Messages(), 1)
+}
+
+func TestSMTPEmailSenderClassifiesRecipientRejections(t *testing.T) {
+	tests := map[string]struct {
+		reply     smtptest.Reply
+		permanent bool
+	}{
+		"unknown mailbox": {smtptest.Reply{Code: 550, Text: "5.1.1 No such user"}, true},
+		"greylisted":      {smtptest.Reply{Code: 451, Text: "4.7.1 Try again later"}, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := smtptest.NewUnstartedServer()
+			server.RecipientReply = func(string) smtptest.Reply { return test.reply }
+			server.Start()
+			t.Cleanup(server.Close)
+
+			err := sendThrough(t, server, email.TLSNone, nil)
+
+			require.Error(t, err)
+			assert.Equal(t, test.permanent, domain.IsPermanentSendError(err))
+			assert.Empty(t, server.Messages())
+		})
+	}
+}
+
+func TestSMTPEmailSenderTellsWhichCommandWasRefused(t *testing.T) {
+	recipientServer := smtptest.NewUnstartedServer()
+	recipientServer.RecipientReply = func(string) smtptest.Reply {
+		return smtptest.Reply{Code: 550, Text: "5.1.1 No such user"}
+	}
+	recipientServer.Start()
+	t.Cleanup(recipientServer.Close)
+
+	dataServer := smtptest.NewUnstartedServer()
+	dataServer.DataReply = func(smtptest.Message) smtptest.Reply {
+		return smtptest.Reply{Code: 554, Text: "5.7.1 Message rejected as spam"}
+	}
+	dataServer.Start()
+	t.Cleanup(dataServer.Close)
+
+	var sendErr *domain.SendError
+	require.True(t, errors.As(sendThrough(t, recipientServer, email.TLSNone, nil), &sendErr))
+	assert.Equal(t, domain.SendPhaseRecipient, sendErr.Phase)
+	assert.Equal(t, "5.1.1", sendErr.Status)
+	assert.True(t, sendErr.Permanent)
+
+	require.True(t, errors.As(sendThrough(t, dataServer, email.TLSNone, nil), &sendErr))
+	assert.Equal(t, domain.SendPhaseMessage, sendErr.Phase)
+	assert.Equal(t, "5.7.1", sendErr.Status)
+	assert.True(t, sendErr.Permanent)
+}
+
+func TestSMTPEmailSenderDeliversPartsAndAttachments(t *testing.T) {
+	server := newSMTPServer(t)
+
+	message := domain.EmailMessage{
+		Subject:  "Café news",
+		Body:     "<p>Hello</p>",
+		TextBody: "Hello",
+		To:       []string{"reader@example.com", "other@example.com"},
+		Attachments: []*domain.Attachment{
+			{Name: "notes.txt", Type: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("notes"))},
+		},
+	}
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+	require.NoError(t, sender.Send(message))
+
+	received := server.LastMessage()
+	require.NotNil(t, received)
+	assert.Equal(t, "newsletter@example.com", received.From)
+	assert.Equal(t, []string{"reader@example.com", "other@example.com"}, received.To)
+	assert.Equal(t, "Café news", received.Header("Subject"))
+
+	parsed, err := received.Parse()
+	require.NoError(t, err)
+	require.NotNil(t, parsed.Body("text/plain"))
+	assert.Equal(t, "Hello", string(parsed.Body("text/plain").Body))
+	require.NotNil(t, parsed.Body("text/html"))
+	assert.Equal(t, "<p>Hello</p>", string(parsed.Body("text/html").Body))
+
+	attachments := parsed.Attachments()
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "notes.txt", attachments[0].Filename)
+	assert.Equal(t, "notes", string(attachments[0].Body))
+}
+
+func TestSMTPEmailSenderUsesTheReturnPathAsEnvelopeSender(t *testing.T) {
+	server := newSMTPServer(t)
+
+	message := domain.EmailMessage{
+		Subject:    "News",
+		Body:       "<p>Hello</p>",
+		To:         []string{"reader@example.com"},
+		Headers:    map[string]string{"Message-ID": "<1.2@example.com>"},
+		ReturnPath: "bounces+1-2@example.com",
+	}
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+	require.NoError(t, sender.Send(message))
+
+	received := server.LastMessage()
+	require.NotNil(t, received)
+	assert.Equal(t, "bounces+1-2@example.com", received.From)
+	assert.Equal(t, "newsletter@example.com", received.Header("From"))
+	assert.Equal(t, "<1.2@example.com>", received.Header("Message-ID"))
+}
+
+func TestSMTPEmailSenderEmbedsInlineAttachments(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+
+	message := testMessage
+	message.Body = `<p><img src="cid:logo@example.com" alt="Logo"></p>`
+	message.Attachments = []*domain.Attachment{
+		{Name: "logo.png", Type: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png")), ContentID: "logo@example.com"},
+		{Name: "notes.txt", Type: "text/plain", Data: base64.StdEncoding.EncodeToString([]byte("notes"))},
+	}
+	require.NoError(t, sender.Send(message))
+
+	parsed, err := server.LastMessage().Parse()
+	require.NoError(t, err)
+
+	var logo *smtptest.Part
+	for i := range parsed.Parts {
+		if parsed.Parts[i].Filename == "logo.png" {
+			logo = &parsed.Parts[i]
+		}
+	}
+	require.NotNil(t, logo)
+	assert.False(t, logo.Attachment)
+	assert.Equal(t, "<logo@example.com>", logo.Header.Get("Content-ID"))
+	assert.Contains(t, logo.Header.Get("Content-Disposition"), "inline")
+	assert.Equal(t, "png", string(logo.Body))
+
+	attachments := parsed.Attachments()
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "notes.txt", attachments[0].Filename)
+}
+
+func TestSMTPEmailSenderStreamsStoredAttachments(t *testing.T) {
+	server := newSMTPServer(t)
+	sender := email.NewPooledEmailSender(plainDialer(t, server), "newsletter@example.com", email.PoolConfig{Size: 1}, nil)
+	defer sender.Close()
+
+	opened := 0
+	message := testMessage
+	message.Attachments = []*domain.Attachment{{
+		ID:   "65a1b2c3d4e5f60718293a4b",
+		Name: "report.pdf",
+		Type: "application/pdf",
+		Content: func() (io.ReadCloser, error) {
+			opened++
+			return io.NopCloser(strings.NewReader("%PDF-1")), nil
+		},
+	}}
+	require.NoError(t, sender.Send(message))
+	assert.Equal(t, 1, opened)
+
+	parsed, err := server.LastMessage().Parse()
+	require.NoError(t, err)
+	attachments := parsed.Attachments()
+	require.Len(t, attachments, 1)
+	assert.Equal(t, "report.pdf", attachments[0].Filename)
+	assert.Equal(t, "%PDF-1", string(attachments[0].Body))
+
+	message.Attachments[0].Content = func() (io.ReadCloser, error) {
+		return nil, errors.New("file not found")
+	}
+	err = sender.Send(message)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "report.pdf")
+	assert.Len(t, server.Messages(), 1)
+}
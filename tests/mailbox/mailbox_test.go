@@ -0,0 +1,149 @@
+package mailbox_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/infrastructure/adapters/mailbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect returns a handler that records messages, and fails on the message
+// equal to failOn.
+func collect(messages *[]string, failOn string) func([]byte) error {
+	return func(message []byte) error {
+		if failOn != "" && string(message) == failOn {
+			return errors.New("storage unavailable")
+		}
+		*messages = append(*messages, string(message))
+		return nil
+	}
+}
+
+func TestMaildirMarksProcessedMessagesSeen(t *testing.T) {
+	dir := t.TempDir()
+	box, err := mailbox.NewMaildir(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "1710338531.M1P1.host"), []byte("first"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cur", "1710338532.M2P1.host:2,F"), []byte("second"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cur", "1710338530.M0P1.host:2,S"), []byte("read"), 0o644))
+	past := filepath.Join(dir, "new", "1710338531.M1P1.host")
+	require.NoError(t, os.Chtimes(past, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	var messages []string
+	require.NoError(t, box.Process(collect(&messages, "")))
+	assert.Equal(t, []string{"first", "second"}, messages)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.FileExists(t, filepath.Join(dir, "cur", "1710338531.M1P1.host:2,S"))
+	assert.FileExists(t, filepath.Join(dir, "cur", "1710338532.M2P1.host:2,FS"))
+
+	messages = nil
+	require.NoError(t, box.Process(collect(&messages, "")))
+	assert.Empty(t, messages)
+}
+
+func TestMaildirLeavesMessagesThatFailed(t *testing.T) {
+	dir := t.TempDir()
+	box, err := mailbox.NewMaildir(dir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "1.M1P1.host"), []byte("bounce"), 0o644))
+
+	var messages []string
+	assert.Error(t, box.Process(collect(&messages, "bounce")))
+	assert.FileExists(t, filepath.Join(dir, "new", "1.M1P1.host"))
+
+	require.NoError(t, box.Process(collect(&messages, "")))
+	assert.Equal(t, []string{"bounce"}, messages)
+}
+
+const mboxContent = "From MAILER-DAEMON Wed Mar 13 14:02:11 2024\n" +
+	"Subject: first\n" +
+	"\n" +
+	">From the mail system\n" +
+	"\n" +
+	"From MAILER-DAEMON Wed Mar 13 14:03:11 2024\n" +
+	"Subject: second\n" +
+	"\n" +
+	"Body\n" +
+	"\n" +
+	"From MAILER-DAEMON Wed Mar 13 14:04:11 2024\n" +
+	"Subject: third\n" +
+	"\n" +
+	"Body\n" +
+	"\n"
+
+func TestMboxRemovesProcessedMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bounces")
+	require.NoError(t, os.WriteFile(path, []byte(mboxContent), 0o600))
+	box, err := mailbox.NewMbox(path)
+	require.NoError(t, err)
+
+	var messages []string
+	err = box.Process(collect(&messages, "Subject: third\n\nBody\n"))
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"Subject: first\n\nFrom the mail system\n",
+		"Subject: second\n\nBody\n",
+	}, messages)
+
+	remaining, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "From MAILER-DAEMON Wed Mar 13 14:04:11 2024\nSubject: third\n\nBody\n\n", string(remaining))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.NoFileExists(t, path+".lock")
+
+	messages = nil
+	require.NoError(t, box.Process(collect(&messages, "")))
+	assert.Equal(t, []string{"Subject: third\n\nBody\n"}, messages)
+	remaining, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestMboxWaitsForTheDotlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bounces")
+	require.NoError(t, os.WriteFile(path, []byte(mboxContent), 0o600))
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0o644))
+	box, err := mailbox.NewMbox(path)
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- box.Process(func([]byte) error { return nil }) }()
+
+	select {
+	case <-done:
+		t.Fatal("processed the mbox while it was locked")
+	case <-time.After(300 * time.Millisecond):
+	}
+	require.NoError(t, os.Remove(path+".lock"))
+	require.NoError(t, <-done)
+
+	remaining, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestMboxThatDoesNotExistYetIsEmpty(t *testing.T) {
+	box, err := mailbox.NewMbox(filepath.Join(t.TempDir(), "bounces"))
+	require.NoError(t, err)
+
+	var messages []string
+	require.NoError(t, box.Process(collect(&messages, "")))
+	assert.Empty(t, messages)
+}
+
+func TestNewMailboxRejectsUnknownFormats(t *testing.T) {
+	_, err := mailbox.NewMailbox("pst", t.TempDir())
+	assert.Error(t, err)
+}
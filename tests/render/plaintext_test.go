@@ -0,0 +1,52 @@
+package render_test
+
+import (
+	"testing"
+
+	"newsletter-app/pkg/service/render"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainTextKeepsHeadingsLinksAndLists(t *testing.T) {
+	content := `<html><head><title>Weekly</title><style>p { color: red; }</style></head><body>
+<h1>Weekly  news</h1>
+<p>Hello <b>Ada</b>,<br>read the <a href="https://example.com/post">latest post</a>.</p>
+<h2>Topics</h2>
+<ul><li>Go</li><li>MongoDB<ul><li>Indexes</li></ul></li></ul>
+<ol><li>First</li><li>Second</li></ol>
+<p><a href="https://example.com/unsubscribe/abc">https://example.com/unsubscribe/abc</a></p>
+</body></html>`
+
+	expected := `Weekly news
+===========
+
+Hello Ada,
+read the latest post (https://example.com/post).
+
+Topics
+------
+
+- Go
+- MongoDB
+  - Indexes
+
+1. First
+2. Second
+
+https://example.com/unsubscribe/abc
+`
+	assert.Equal(t, expected, render.PlainText(content))
+}
+
+func TestPlainTextDecodesEntitiesAndSkipsScripts(t *testing.T) {
+	content := `<p>Fish &amp; chips &lt;today&gt;</p><script>alert(1)</script><p><img src="logo.png" alt="Logo"> <a href="#top">Back to top</a></p>`
+
+	assert.Equal(t, "Fish & chips <today>\n\nLogo Back to top\n", render.PlainText(content))
+}
+
+func TestPlainTextLaysOutTables(t *testing.T) {
+	content := `<table><tr><th>Name</th><th>Price</th></tr><tr><td>Book</td><td>10</td></tr></table><hr><p>Thanks</p>`
+
+	assert.Equal(t, "Name Price\nBook 10\n\n----------\n\nThanks\n", render.PlainText(content))
+}
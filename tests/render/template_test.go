@@ -0,0 +1,104 @@
+package render_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service/render"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testData() render.Data {
+	return render.Data{
+		Subscriber:       domain.Subscriber{Email: "ada@example.com", Category: "Tech"},
+		Newsletter:       domain.Newsletter{Name: "Weekly", Subject: "This week"},
+		UnsubscribeURL:   "https://example.com/unsubscribe/abc",
+		UnsubscribeToken: "abc",
+		HostDomain:       "https://example.com/",
+		Date:             time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC),
+		Attributes:       map[string]string{"first_name": "Ada"},
+	}
+}
+
+func TestRenderExposesSubscriberNewsletterAndAttributes(t *testing.T) {
+	tmpl, err := render.Parse(`<h1>{{.Newsletter.Name}}</h1><p>Hi {{index .Attributes "first_name"}} ({{.Subscriber.Email}}), {{.Date.Format "2 Jan 2006"}}</p><a href="{{.UnsubscribeURL}}">Unsubscribe</a>`)
+	require.NoError(t, err)
+
+	content, err := tmpl.Render(testData())
+	require.NoError(t, err)
+	assert.Equal(t, `<h1>Weekly</h1><p>Hi Ada (ada@example.com), 5 Mar 2024</p><a href="https://example.com/unsubscribe/abc">Unsubscribe</a>`, content)
+}
+
+func TestRenderDefaultsMissingAttributes(t *testing.T) {
+	tmpl, err := render.Parse(`Hi {{index .Attributes "nickname" | default "reader"}}`)
+	require.NoError(t, err)
+
+	content, err := tmpl.Render(testData())
+	require.NoError(t, err)
+	assert.Equal(t, "Hi reader", content)
+}
+
+func TestRenderEscapesValues(t *testing.T) {
+	tmpl, err := render.Parse(`<p>{{index .Attributes "first_name"}}</p><a href="{{index .Attributes "site"}}">site</a>`)
+	require.NoError(t, err)
+
+	data := testData()
+	data.Attributes = map[string]string{"first_name": "<script>alert(1)</script>", "site": "javascript:alert(1)"}
+
+	content, err := tmpl.Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p><a href="#ZgotmplZ">site</a>`, content)
+}
+
+func TestRenderSupportsLegacyPlaceholders(t *testing.T) {
+	tmpl, err := render.Parse(`<a href="{hostDomain}unsubscribe/{email}">Unsubscribe</a>`)
+	require.NoError(t, err)
+
+	content, err := tmpl.Render(testData())
+	require.NoError(t, err)
+	assert.Equal(t, `<a href="https://example.com/unsubscribe/abc">Unsubscribe</a>`, content)
+}
+
+func TestParseReportsLineOfSyntaxErrors(t *testing.T) {
+	_, err := render.Parse("<p>Hello</p>\n<p>{{.Subscriber.Email</p>\n")
+
+	var templateErr *render.TemplateError
+	require.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Contains(t, templateErr.Error(), "line 2")
+}
+
+func TestParseReportsUnknownFields(t *testing.T) {
+	_, err := render.Parse("<p>Hello</p>\n\n<p>{{.Subscriber.Nickname}}</p>")
+
+	var templateErr *render.TemplateError
+	require.True(t, errors.As(err, &templateErr))
+	assert.Equal(t, 3, templateErr.Line)
+	assert.Contains(t, templateErr.Message, "Nickname")
+}
+
+func TestParseTextDoesNotEscapeValues(t *testing.T) {
+	tmpl, err := render.ParseText("Hi {{index .Attributes \"first_name\"}} & welcome.\nUnsubscribe: {{.UnsubscribeURL}}\nHome: {hostDomain}")
+	require.NoError(t, err)
+
+	data := testData()
+	data.Attributes = map[string]string{"first_name": "<Ada>"}
+
+	content, err := tmpl.Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Hi <Ada> & welcome.\nUnsubscribe: https://example.com/unsubscribe/abc\nHome: https://example.com/", content)
+}
+
+func TestParseTextReportsErrorsInTheTextVersion(t *testing.T) {
+	_, err := render.ParseText("Hello\n{{.Subscriber.Nickname}}")
+
+	var templateErr *render.TemplateError
+	require.True(t, errors.As(err, &templateErr))
+	assert.True(t, templateErr.Text)
+	assert.Equal(t, 2, templateErr.Line)
+	assert.Contains(t, templateErr.Error(), "invalid text template at line 2")
+}
@@ -0,0 +1,242 @@
+package scanner_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/infrastructure/adapters/scanner"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eicar is the EICAR anti-malware test file, which every scanner reports.
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands like clamd: it finds the EICAR test
+// file and refuses streams over maxLength, when it is set.
+type fakeClamd struct {
+	listener  net.Listener
+	maxLength int
+	// silent makes it never reply.
+	silent bool
+
+	mu       sync.Mutex
+	commands []string
+	streams  [][]byte
+}
+
+func newFakeClamd(t *testing.T, network, address string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	require.NoError(t, err)
+	clamd := &fakeClamd{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go clamd.serve()
+	return clamd
+}
+
+func (c *fakeClamd) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		go c.handle(conn)
+	}
+}
+
+func (c *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+
+	command, err := readUntil(conn, 0)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	c.commands = append(c.commands, command)
+	c.mu.Unlock()
+	if command != "zINSTREAM" {
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+
+	var stream bytes.Buffer
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		if length == 0 {
+			break
+		}
+		if _, err := io.CopyN(&stream, conn, int64(length)); err != nil {
+			return
+		}
+		if c.maxLength > 0 && stream.Len() > c.maxLength {
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+	}
+
+	c.mu.Lock()
+	c.streams = append(c.streams, stream.Bytes())
+	c.mu.Unlock()
+
+	if c.silent {
+		time.Sleep(time.Second)
+		return
+	}
+	if bytes.Contains(stream.Bytes(), []byte(eicar)) {
+		io.WriteString(conn, "stream: Win.Test.EICAR_HDB-1 FOUND\x00")
+		return
+	}
+	io.WriteString(conn, "stream: OK\x00")
+}
+
+func (c *fakeClamd) lastStream() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.streams) == 0 {
+		return nil
+	}
+	return c.streams[len(c.streams)-1]
+}
+
+func readUntil(r io.Reader, delimiter byte) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := r.Read(b); err != nil {
+			return "", err
+		}
+		if b[0] == delimiter {
+			return string(line), nil
+		}
+		line = append(line, b[0])
+	}
+}
+
+func TestClamdScannerReportsCleanContent(t *testing.T) {
+	clamd := newFakeClamd(t, "tcp", "127.0.0.1:0")
+	clamdScanner := scanner.NewClamdScanner("tcp", clamd.listener.Addr().String(), time.Second)
+
+	// More than one chunk, to check the content is streamed whole.
+	content := strings.Repeat("newsletter ", 20000)
+	scan, err := clamdScanner.Scan(strings.NewReader(content))
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentScanClean, scan.Status)
+	assert.Equal(t, scanner.ScannerClamd, scan.Scanner)
+	assert.Equal(t, content, string(clamd.lastStream()))
+}
+
+func TestClamdScannerReportsInfectedContent(t *testing.T) {
+	clamd := newFakeClamd(t, "unix", filepath.Join(t.TempDir(), "clamd.sock"))
+	clamdScanner := scanner.NewClamdScanner("unix", clamd.listener.Addr().String(), time.Second)
+
+	scan, err := clamdScanner.Scan(strings.NewReader(eicar))
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentScanInfected, scan.Status)
+	assert.Equal(t, "Win.Test.EICAR_HDB-1", scan.Signature)
+}
+
+func TestClamdScannerScansEmptyContent(t *testing.T) {
+	clamd := newFakeClamd(t, "tcp", "127.0.0.1:0")
+	clamdScanner := scanner.NewClamdScanner("tcp", clamd.listener.Addr().String(), time.Second)
+
+	scan, err := clamdScanner.Scan(strings.NewReader(""))
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentScanClean, scan.Status)
+	clamd.mu.Lock()
+	defer clamd.mu.Unlock()
+	assert.Equal(t, []string{"zINSTREAM"}, clamd.commands)
+}
+
+func TestClamdScannerReportsStreamLimit(t *testing.T) {
+	clamd := newFakeClamd(t, "tcp", "127.0.0.1:0")
+	clamd.maxLength = 100 << 10
+	clamdScanner := scanner.NewClamdScanner("tcp", clamd.listener.Addr().String(), time.Second)
+
+	_, err := clamdScanner.Scan(bytes.NewReader(make([]byte, 4<<20)))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "INSTREAM size limit exceeded")
+}
+
+func TestClamdScannerFailsWhenClamdIsUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	_, err = scanner.NewClamdScanner("tcp", address, time.Second).Scan(strings.NewReader("notes"))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "clamd")
+}
+
+func TestClamdScannerTimesOut(t *testing.T) {
+	clamd := newFakeClamd(t, "tcp", "127.0.0.1:0")
+	clamd.silent = true
+	clamdScanner := scanner.NewClamdScanner("tcp", clamd.listener.Addr().String(), 100*time.Millisecond)
+
+	start := time.Now()
+	_, err := clamdScanner.Scan(strings.NewReader("notes"))
+
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("gridfs: chunk missing")
+}
+
+func TestClamdScannerFailsWhenContentCannotBeRead(t *testing.T) {
+	clamd := newFakeClamd(t, "tcp", "127.0.0.1:0")
+	clamdScanner := scanner.NewClamdScanner("tcp", clamd.listener.Addr().String(), time.Second)
+
+	_, err := clamdScanner.Scan(failingReader{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "gridfs: chunk missing")
+}
+
+func TestNewAttachmentScannerFromEnv(t *testing.T) {
+	t.Setenv("attachmentScanner", "")
+	noop, err := scanner.NewAttachmentScannerFromEnv()
+	require.NoError(t, err)
+	scan, err := noop.Scan(strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentScanSkipped, scan.Status)
+
+	clamd := newFakeClamd(t, "unix", filepath.Join(t.TempDir(), "clamd.sock"))
+	t.Setenv("attachmentScanner", "clamd")
+	t.Setenv("clamdAddress", clamd.listener.Addr().String())
+	clamdScanner, err := scanner.NewAttachmentScannerFromEnv()
+	require.NoError(t, err)
+	scan, err = clamdScanner.Scan(strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, domain.AttachmentScanInfected, scan.Status)
+
+	t.Setenv("clamdTimeout", "soon")
+	_, err = scanner.NewAttachmentScannerFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("attachmentScanner", "sophos")
+	_, err = scanner.NewAttachmentScannerFromEnv()
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/request"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func requireAttachmentError(t *testing.T, err error, code string) *service.AttachmentError {
+	t.Helper()
+	var attachmentErr *service.AttachmentError
+	require.True(t, errors.As(err, &attachmentErr), "expected an AttachmentError, got %v", err)
+	assert.Equal(t, code, attachmentErr.Code)
+	return attachmentErr
+}
+
+func TestAttachmentPolicyFromEnv(t *testing.T) {
+	t.Setenv("attachmentMaxSize", "1024")
+	t.Setenv("attachmentMaxMessageSize", "not a number")
+	t.Setenv("attachmentAllowedTypes", " application/pdf, IMAGE/* ,")
+
+	policy := service.NewAttachmentPolicyFromEnv()
+
+	assert.Equal(t, int64(1024), policy.MaxSize)
+	assert.Equal(t, int64(20<<20), policy.MaxMessageSize)
+	assert.Equal(t, []string{"application/pdf", "image/*"}, policy.AllowedTypes)
+}
+
+func TestAttachmentPolicyFromEnvAllowsEveryType(t *testing.T) {
+	t.Setenv("attachmentAllowedTypes", "*")
+
+	policy := service.NewAttachmentPolicyFromEnv()
+
+	assert.Empty(t, policy.AllowedTypes)
+	assert.Equal(t, int64(10<<20), policy.MaxSize)
+}
+
+func TestUploadAttachmentRejectsContentOverMaxSize(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{MaxSize: 1000})
+
+	// The limit is only found out while the content is streamed to the
+	// repository, which gives up on the upload.
+	_, err := attachmentService.UploadAttachment("notes.txt", "text/plain", strings.NewReader(strings.Repeat("a", 1001)))
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTooLarge)
+	assert.Equal(t, "notes.txt", attachmentErr.Attachment)
+	assert.Equal(t, int64(1000), attachmentErr.Limit)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestUploadAttachmentRejectsTypeNotAllowed(t *testing.T) {
+	t.Setenv("attachmentAllowedTypes", "")
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.NewAttachmentPolicyFromEnv())
+
+	_, err := attachmentService.UploadAttachment("setup.exe", "", strings.NewReader("MZ\x90\x00\x03\x00\x00\x00"))
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTypeDenied)
+	assert.Equal(t, "setup.exe", attachmentErr.Attachment)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestUploadAttachmentRejectsSVGByDefault(t *testing.T) {
+	t.Setenv("attachmentAllowedTypes", "")
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.NewAttachmentPolicyFromEnv())
+
+	svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`
+	_, err := attachmentService.UploadAttachment("logo.svg", "image/svg+xml", strings.NewReader(svg))
+
+	requireAttachmentError(t, err, service.AttachmentTypeDenied)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestUploadAttachmentRejectsContentNotMatchingItsType(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{})
+
+	_, err := attachmentService.UploadAttachment("report.pdf", "application/pdf", strings.NewReader(pngHeader))
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTypeMismatch)
+	assert.Equal(t, "application/pdf", attachmentErr.Type)
+	assert.Equal(t, "image/png", attachmentErr.DetectedType)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestUploadAttachmentDetectsTypeOfGenericContent(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{AllowedTypes: []string{"image/*"}})
+
+	stored := &domain.Attachment{ID: "65a1b2c3d4e5f60718293a4e", Name: "photo", Type: "image/png"}
+	mockAttachmentRepo.On("SaveAttachment", domain.Attachment{Name: "photo", Type: "image/png"}, pngHeader).Return(stored, nil)
+
+	attachment, err := attachmentService.UploadAttachment("photo", "application/octet-stream", strings.NewReader(pngHeader))
+
+	require.NoError(t, err)
+	assert.Equal(t, stored, attachment)
+	mockAttachmentRepo.AssertExpectations(t)
+}
+
+func TestUploadAttachmentAcceptsTextBasedTypes(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{AllowedTypes: []string{"text/csv", "text/calendar"}})
+
+	mockAttachmentRepo.On("SaveAttachment", mock.Anything, mock.Anything).Return(storedReport, nil)
+
+	_, err := attachmentService.UploadAttachment("list.csv", "text/csv", strings.NewReader("email,name\nana@example.com,Ana\n"))
+	require.NoError(t, err)
+	_, err = attachmentService.UploadAttachment("event.ics", "", strings.NewReader("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"))
+	require.NoError(t, err)
+}
+
+func TestSaveNewsletterRejectsBase64AttachmentOverMaxSize(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{MaxSize: 4}, newCleanScanner())
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []domain.Attachment{{Name: "notes.txt", Type: "text/plain", Data: "bm90ZXM="}},
+	})
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTooLarge)
+	assert.Equal(t, int64(5), attachmentErr.Size)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+	mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+}
+
+func TestSaveNewsletterRejectsBase64AttachmentNotMatchingItsType(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Attachments: []domain.Attachment{
+			{Name: "logo.png", Data: base64.StdEncoding.EncodeToString([]byte("%PDF-1.7\n"))},
+		},
+	})
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTypeMismatch)
+	assert.Equal(t, "image/png", attachmentErr.Type)
+	assert.Equal(t, "application/pdf", attachmentErr.DetectedType)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestSaveNewsletterRejectsAttachmentsOverMessageSize(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{MaxSize: 6, MaxMessageSize: 10}, newCleanScanner())
+
+	mockAttachmentRepo.On("GetAttachment", storedReport.ID).Return(storedReport, nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Attachments: []domain.Attachment{
+			{ID: storedReport.ID},
+			{Name: "notes.txt", Data: "bm90ZXM="},
+		},
+	})
+
+	attachmentErr := requireAttachmentError(t, err, service.MessageTooLarge)
+	assert.Equal(t, int64(11), attachmentErr.Size)
+	assert.Equal(t, int64(10), attachmentErr.Limit)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+	mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+}
+
+func TestUpdateNewsletterChecksUploadedAttachmentsAgainstPolicy(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{AllowedTypes: []string{"image/*"}}, newCleanScanner())
+
+	id := primitive.NewObjectID()
+	mockNewsletterRepo.On("GetNewsletterByID", id.Hex()).Return(&domain.Newsletter{ID: id}, nil)
+	mockAttachmentRepo.On("GetAttachment", storedReport.ID).Return(storedReport, nil)
+
+	err := newsletterService.UpdateNewsletter(request.UpdateNewsletterRequest{
+		ID:          id,
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []request.Attachment{{ID: storedReport.ID}},
+	})
+
+	attachmentErr := requireAttachmentError(t, err, service.AttachmentTypeDenied)
+	assert.Equal(t, "report.pdf", attachmentErr.Attachment)
+	mockNewsletterRepo.AssertNotCalled(t, "UpdateNewsletter", mock.Anything)
+}
@@ -0,0 +1,128 @@
+package service_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MockAttachmentScanner struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentScanner) Scan(content io.Reader) (*domain.AttachmentScan, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(string(data))
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.AttachmentScan), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// newCleanScanner returns a scanner that finds every attachment clean.
+func newCleanScanner() *MockAttachmentScanner {
+	scanner := new(MockAttachmentScanner)
+	scanner.On("Scan", mock.Anything).Return(&domain.AttachmentScan{Status: domain.AttachmentScanClean, Scanner: "mock"}, nil).Maybe()
+	return scanner
+}
+
+func TestSaveNewsletterStoresScanResults(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	mockScanner := new(MockAttachmentScanner)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, mockScanner)
+
+	stored := &domain.Attachment{ID: "65a1b2c3d4e5f60718293a4c", Name: "notes.txt", Type: "text/plain", Size: 5}
+	mockAttachmentRepo.On("GetAttachment", storedReport.ID).Return(storedReport, nil)
+	mockAttachmentRepo.On("OpenAttachment", storedReport.ID).Return("%PDF-1", nil)
+	mockAttachmentRepo.On("SaveAttachment", mock.MatchedBy(func(attachment domain.Attachment) bool {
+		return attachment.Scan != nil && attachment.Scan.Status == domain.AttachmentScanClean
+	}), "notes").Return(stored, nil)
+	mockScanner.On("Scan", "%PDF-1").Return(&domain.AttachmentScan{Status: domain.AttachmentScanInfected, Scanner: "mock", Signature: "Eicar-Signature"}, nil)
+	mockScanner.On("Scan", "notes").Return(&domain.AttachmentScan{Status: domain.AttachmentScanClean, Scanner: "mock"}, nil)
+	mockNewsletterRepo.On("SaveNewsletter", mock.Anything).Return(nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Attachments: []domain.Attachment{
+			{ID: storedReport.ID},
+			{Name: "notes.txt", Type: "text/plain", Data: "bm90ZXM="},
+		},
+	})
+
+	require.NoError(t, err)
+	saved := mockNewsletterRepo.Calls[0].Arguments.Get(0).(domain.Newsletter)
+	require.Len(t, saved.Attachments, 2)
+	require.NotNil(t, saved.Attachments[0].Scan)
+	assert.Equal(t, domain.AttachmentScanInfected, saved.Attachments[0].Scan.Status)
+	assert.Equal(t, "Eicar-Signature", saved.Attachments[0].Scan.Signature)
+	assert.False(t, saved.Attachments[0].Scan.ScannedAt.IsZero())
+	mockScanner.AssertExpectations(t)
+	mockAttachmentRepo.AssertExpectations(t)
+}
+
+func TestSaveNewsletterKeepsAttachmentsTheScannerFailedOn(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	mockScanner := new(MockAttachmentScanner)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, mockScanner)
+
+	mockAttachmentRepo.On("GetAttachment", storedReport.ID).Return(storedReport, nil)
+	mockAttachmentRepo.On("OpenAttachment", storedReport.ID).Return("%PDF-1", nil)
+	mockScanner.On("Scan", "%PDF-1").Return(nil, errors.New("clamd: connection refused"))
+	mockNewsletterRepo.On("SaveNewsletter", mock.Anything).Return(nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []domain.Attachment{{ID: storedReport.ID}},
+	})
+
+	require.NoError(t, err)
+	saved := mockNewsletterRepo.Calls[0].Arguments.Get(0).(domain.Newsletter)
+	require.NotNil(t, saved.Attachments[0].Scan)
+	assert.Equal(t, domain.AttachmentScanFailed, saved.Attachments[0].Scan.Status)
+	assert.Equal(t, "clamd: connection refused", saved.Attachments[0].Scan.Error)
+}
+
+func TestSendNewsletterRefusesAttachmentsThatDidNotPassTheScan(t *testing.T) {
+	tests := []struct {
+		name string
+		scan *domain.AttachmentScan
+		err  error
+	}{
+		{"infected", &domain.AttachmentScan{Status: domain.AttachmentScanInfected, Signature: "Eicar-Signature"}, service.ErrAttachmentInfected},
+		{"failed", &domain.AttachmentScan{Status: domain.AttachmentScanFailed, Error: "clamd: timeout"}, service.ErrAttachmentNotScanned},
+		{"never scanned", nil, service.ErrAttachmentNotScanned},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockNewsletterRepo := new(MockNewsletterRepository)
+			mockSendJobRepo := new(MockSendJobRepository)
+			newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), mockSendJobRepo, new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+			report := *storedReport
+			report.Scan = test.scan
+			newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Content: "<p>Hello</p>", Attachments: []domain.Attachment{report}}
+			mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+
+			_, err := newsletterService.SendNewsletter(newsletter.ID.Hex())
+
+			assert.ErrorIs(t, err, test.err)
+			mockSendJobRepo.AssertNotCalled(t, "SaveSendJob", mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package service_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAttachmentRepository struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentRepository) SaveAttachment(attachment domain.Attachment, content io.Reader) (*domain.Attachment, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	args := m.Called(attachment, string(data))
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Attachment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAttachmentRepository) GetAttachment(id string) (*domain.Attachment, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Attachment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAttachmentRepository) OpenAttachment(id string) (io.ReadCloser, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return io.NopCloser(strings.NewReader(args.String(0))), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+var storedReport = &domain.Attachment{ID: "65a1b2c3d4e5f60718293a4b", Name: "report.pdf", Type: "application/pdf", Size: 6, Checksum: "checksum"}
+
+func TestUploadAttachmentGuessesContentType(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{})
+
+	mockAttachmentRepo.On("SaveAttachment", domain.Attachment{Name: "report.pdf", Type: "application/pdf"}, "%PDF-1").Return(storedReport, nil)
+
+	attachment, err := attachmentService.UploadAttachment("report.pdf", "", strings.NewReader("%PDF-1"))
+
+	require.NoError(t, err)
+	assert.Equal(t, storedReport, attachment)
+	mockAttachmentRepo.AssertExpectations(t)
+}
+
+func TestUploadAttachmentRequiresName(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{})
+
+	_, err := attachmentService.UploadAttachment(" ", "text/plain", strings.NewReader("notes"))
+
+	assert.ErrorIs(t, err, service.ErrAttachmentNameRequired)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
+
+func TestOpenAttachmentNotFound(t *testing.T) {
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	attachmentService := service.NewAttachmentService(mockAttachmentRepo, service.AttachmentPolicy{})
+
+	mockAttachmentRepo.On("GetAttachment", "missing").Return(nil, nil)
+
+	_, _, err := attachmentService.OpenAttachment("missing")
+
+	assert.True(t, errors.Is(err, service.ErrAttachmentNotFound))
+	mockAttachmentRepo.AssertNotCalled(t, "OpenAttachment", mock.Anything)
+}
+
+func TestSaveNewsletterResolvesUploadedAttachments(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	mockAttachmentRepo.On("GetAttachment", storedReport.ID).Return(storedReport, nil)
+	mockAttachmentRepo.On("OpenAttachment", storedReport.ID).Return("%PDF-1", nil)
+	mockNewsletterRepo.On("SaveNewsletter", mock.Anything).Return(nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []domain.Attachment{{ID: storedReport.ID, Name: "ignored.pdf"}},
+	})
+
+	require.NoError(t, err)
+	saved := mockNewsletterRepo.Calls[0].Arguments.Get(0).(domain.Newsletter)
+	require.Len(t, saved.Attachments, 1)
+	saved.Attachments[0].Scan = nil
+	assert.Equal(t, []domain.Attachment{*storedReport}, saved.Attachments)
+}
+
+func TestSaveNewsletterMovesBase64AttachmentsToStorage(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	stored := &domain.Attachment{ID: "65a1b2c3d4e5f60718293a4c", Name: "notes.txt", Type: "text/plain", Size: 5, Checksum: "checksum"}
+	mockAttachmentRepo.On("SaveAttachment", mock.MatchedBy(func(attachment domain.Attachment) bool {
+		return attachment.Name == "notes.txt" && attachment.Type == "text/plain"
+	}), "notes").Return(stored, nil)
+	mockNewsletterRepo.On("SaveNewsletter", mock.Anything).Return(nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []domain.Attachment{{Name: "notes.txt", Type: "text/plain", Data: "bm90ZXM="}},
+	})
+
+	require.NoError(t, err)
+	saved := mockNewsletterRepo.Calls[0].Arguments.Get(0).(domain.Newsletter)
+	require.Len(t, saved.Attachments, 1)
+	assert.Equal(t, stored.ID, saved.Attachments[0].ID)
+	assert.Empty(t, saved.Attachments[0].Data)
+}
+
+func TestSaveNewsletterRejectsUnknownAttachmentsBeforeStoringAny(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	mockAttachmentRepo.On("GetAttachment", "65a1b2c3d4e5f60718293a4d").Return(nil, nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Attachments: []domain.Attachment{
+			{Name: "notes.txt", Data: "bm90ZXM="},
+			{ID: "65a1b2c3d4e5f60718293a4d"},
+		},
+	})
+
+	assert.ErrorIs(t, err, service.ErrAttachmentNotFound)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+	mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+}
+
+func TestSaveNewsletterRejectsInvalidBase64(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Attachments: []domain.Attachment{{Name: "notes.txt", Data: "not base64!"}},
+	})
+
+	assert.ErrorIs(t, err, service.ErrInvalidAttachment)
+	mockAttachmentRepo.AssertNotCalled(t, "SaveAttachment", mock.Anything, mock.Anything)
+}
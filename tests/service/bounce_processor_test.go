@@ -0,0 +1,191 @@
+package service_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/dsn"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestBounceTracking returns bounces to bounces@news.example.com, the
+// address of the DSN samples in tests/dsn/testdata.
+func newTestBounceTracking() *service.BounceTracking {
+	return service.NewBounceTracking("Bounces <bounces@news.example.com>", "news.example.com")
+}
+
+func readDSNSample(t *testing.T, name string) []byte {
+	raw, err := os.ReadFile(filepath.Join("..", "dsn", "testdata", name))
+	require.NoError(t, err)
+	return raw
+}
+
+func mustObjectID(t *testing.T, hex string) primitive.ObjectID {
+	id, err := primitive.ObjectIDFromHex(hex)
+	require.NoError(t, err)
+	return id
+}
+
+func TestBounceTrackingTagsDeliveries(t *testing.T) {
+	tracking := newTestBounceTracking()
+	subscriberID := mustObjectID(t, "65f1c2a9e4b0a1b2c3d4e5f7")
+
+	assert.Equal(t, "bounces+65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7@news.example.com", tracking.ReturnPath("65f1c2a9e4b0a1b2c3d4e5f6", subscriberID))
+	assert.Equal(t, "<65f1c2a9e4b0a1b2c3d4e5f6.65f1c2a9e4b0a1b2c3d4e5f7@news.example.com>", tracking.MessageID("65f1c2a9e4b0a1b2c3d4e5f6", subscriberID))
+
+	withoutVERP := service.NewBounceTracking("", "news.example.com")
+	assert.Empty(t, withoutVERP.ReturnPath("65f1c2a9e4b0a1b2c3d4e5f6", subscriberID))
+}
+
+func TestBounceTrackingMatchesVERPAddressesAndMessageIDs(t *testing.T) {
+	tracking := newTestBounceTracking()
+
+	newsletterID, subscriberID, ok := tracking.Match(&dsn.Report{To: []string{"bounces+65f1c2a9e4b0a1b2c3d4e5f6-65f1c2a9e4b0a1b2c3d4e5f7@news.example.com"}})
+	require.True(t, ok)
+	assert.Equal(t, "65f1c2a9e4b0a1b2c3d4e5f6", newsletterID)
+	assert.Equal(t, "65f1c2a9e4b0a1b2c3d4e5f7", subscriberID.Hex())
+
+	report, err := dsn.Parse(readDSNSample(t, "gmail_over_quota.eml"))
+	require.NoError(t, err)
+	newsletterID, subscriberID, ok = tracking.Match(report)
+	require.True(t, ok)
+	assert.Equal(t, "65f5b3d1e4b0a1b2c3d4e800", newsletterID)
+	assert.Equal(t, "65f5b3d1e4b0a1b2c3d4e801", subscriberID.Hex())
+
+	_, _, ok = tracking.Match(&dsn.Report{To: []string{"bounces+not-a-delivery@news.example.com", "news@news.example.com"}})
+	assert.False(t, ok)
+
+	_, _, ok = service.NewBounceTracking("", "other.example.com").Match(report)
+	assert.False(t, ok)
+}
+
+func TestProcessJobTagsMessagesForBounces(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "Hello"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	var message domain.EmailMessage
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).
+		Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+		Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+
+	assert.Equal(t, "bounces+"+newsletter.ID.Hex()+"-"+subscriber.ID.Hex()+"@news.example.com", message.ReturnPath)
+	assert.Equal(t, "<"+newsletter.ID.Hex()+"."+subscriber.ID.Hex()+"@news.example.com>", message.Headers["Message-ID"])
+	assert.NotEmpty(t, message.Headers["List-Unsubscribe"])
+}
+
+func TestProcessHardBounceMarksTheDeliveryAndSuppressesTheAddress(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	processor := service.NewBounceProcessor(nil, mockDeliveryRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+	subscriberID := mustObjectID(t, "65f1c2a9e4b0a1b2c3d4e5f7")
+	mockDeliveryRepo.On("RecordBounce", "65f1c2a9e4b0a1b2c3d4e5f6", subscriberID, mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceHard &&
+			bounce.Recipient == "nobody@example.org" &&
+			bounce.Status == "5.1.1" &&
+			bounce.ReportedBy == "mail.news.example.com" &&
+			!bounce.ReportedAt.IsZero()
+	})).Return(&domain.Delivery{Email: "Nobody@example.org"}, nil)
+	mockSuppressionRepo.On("SaveSuppressions", mock.MatchedBy(func(suppressions []domain.Suppression) bool {
+		return len(suppressions) == 1 &&
+			suppressions[0].AddressHash == sha256Hex("nobody@example.org") &&
+			suppressions[0].Category == "" &&
+			suppressions[0].Reason == domain.SuppressionHardBounce &&
+			suppressions[0].Source == service.SuppressionSourceBounce &&
+			suppressions[0].Detail == "5.1.1 550 5.1.1 <[address]>: Recipient address rejected: User unknown in virtual mailbox table"
+	})).Return(nil)
+
+	require.NoError(t, processor.ProcessMessage(readDSNSample(t, "postfix_user_unknown.eml")))
+	mockDeliveryRepo.AssertExpectations(t)
+	mockSuppressionRepo.AssertExpectations(t)
+}
+
+func TestProcessSoftBouncesAreOnlyCounted(t *testing.T) {
+	for _, sample := range []string{"gmail_over_quota.eml", "postfix_delayed.eml"} {
+		t.Run(sample, func(t *testing.T) {
+			mockDeliveryRepo := new(MockDeliveryRepository)
+			mockSuppressionRepo := new(MockSuppressionRepository)
+			processor := service.NewBounceProcessor(nil, mockDeliveryRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+			mockDeliveryRepo.On("RecordBounce", mock.Anything, mock.Anything, mock.MatchedBy(func(bounce domain.Bounce) bool {
+				return bounce.Type == domain.BounceSoft
+			})).Return(&domain.Delivery{}, nil)
+
+			require.NoError(t, processor.ProcessMessage(readDSNSample(t, sample)))
+			mockDeliveryRepo.AssertNumberOfCalls(t, "RecordBounce", 1)
+			mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+		})
+	}
+}
+
+func TestProcessUnmatchedHardBounceIsOnlyRecordedForReview(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	processor := service.NewBounceProcessor(nil, mockDeliveryRepo, service.NewSuppressionService(mockSuppressionRepo, nil), service.NewBounceTracking("", "other.example.com"), 0)
+
+	mockDeliveryRepo.On("RecordUnmatchedBounce", mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceHard && bounce.Recipient == "someone@gone.example"
+	})).Return(nil)
+
+	require.NoError(t, processor.ProcessMessage(readDSNSample(t, "exim_unrouteable.eml")))
+	mockDeliveryRepo.AssertNotCalled(t, "RecordBounce", mock.Anything, mock.Anything, mock.Anything)
+	mockDeliveryRepo.AssertExpectations(t)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestProcessHardBounceOfAMissingDeliveryIsOnlyRecordedForReview(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	processor := service.NewBounceProcessor(nil, mockDeliveryRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+	mockDeliveryRepo.On("RecordBounce", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	mockDeliveryRepo.On("RecordUnmatchedBounce", mock.Anything).Return(nil)
+
+	require.NoError(t, processor.ProcessMessage(readDSNSample(t, "postfix_user_unknown.eml")))
+	mockDeliveryRepo.AssertExpectations(t)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestProcessMessageSkipsMailThatIsNotABounce(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	processor := service.NewBounceProcessor(nil, mockDeliveryRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+	assert.NoError(t, processor.ProcessMessage(readDSNSample(t, "out_of_office.eml")))
+	assert.NoError(t, processor.ProcessMessage([]byte("not a message")))
+	mockDeliveryRepo.AssertNotCalled(t, "RecordBounce", mock.Anything, mock.Anything, mock.Anything)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestProcessMessageReturnsStorageErrors(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	processor := service.NewBounceProcessor(nil, mockDeliveryRepo, newEmptySuppressions(), newTestBounceTracking(), 0)
+
+	mockDeliveryRepo.On("RecordBounce", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	assert.Error(t, processor.ProcessMessage(readDSNSample(t, "exchange_online_not_found.eml")))
+}
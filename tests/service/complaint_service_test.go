@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func readARFSample(t *testing.T, name string) []byte {
+	raw, err := os.ReadFile(filepath.Join("..", "arf", "testdata", name))
+	require.NoError(t, err)
+	return raw
+}
+
+func isComplaintSuppression(email string) interface{} {
+	return mock.MatchedBy(func(suppressions []domain.Suppression) bool {
+		return len(suppressions) == 1 &&
+			suppressions[0].AddressHash == sha256Hex(email) &&
+			suppressions[0].Category == "" &&
+			suppressions[0].Reason == domain.SuppressionComplaint &&
+			suppressions[0].Source == service.SuppressionSourceComplaint
+	})
+}
+
+func TestIngestComplaintMatchedByVERPUnsubscribesTheDeliveryAddress(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	complaintService := service.NewComplaintService(nil, mockDeliveryRepo, mockSubscriberRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+	subscriberID := mustObjectID(t, "6601d2e4e4b0a1b2c3d4e901")
+	mockDeliveryRepo.On("RecordComplaint", "6601d2e4e4b0a1b2c3d4e900", subscriberID, mock.MatchedBy(func(complaint domain.Complaint) bool {
+		return complaint.FeedbackType == "abuse" &&
+			complaint.UserAgent == "Yahoo!-Mail-Feedback/2.0" &&
+			!complaint.ReportedAt.IsZero()
+	})).Return(&domain.Delivery{Email: "reader@yahoo.com"}, nil)
+	mockSubscriberRepo.On("DeleteSubscriberByEmail", "reader@yahoo.com", "").Return(nil)
+	mockSuppressionRepo.On("SaveSuppressions", isComplaintSuppression("reader@yahoo.com")).Return(nil)
+
+	result, err := complaintService.IngestComplaint(readARFSample(t, "yahoo_abuse.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, &response.ComplaintResponse{FeedbackType: "abuse", NewsletterID: "6601d2e4e4b0a1b2c3d4e900", Suppressed: 1}, result)
+	mockDeliveryRepo.AssertExpectations(t)
+	mockSubscriberRepo.AssertExpectations(t)
+	mockSuppressionRepo.AssertExpectations(t)
+}
+
+func TestIngestComplaintAboutAMissingDeliveryIsDropped(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	complaintService := service.NewComplaintService(nil, mockDeliveryRepo, mockSubscriberRepo, service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), 0)
+
+	mockDeliveryRepo.On("RecordComplaint", "6603a1f0e4b0a1b2c3d4ea00", mustObjectID(t, "6603a1f0e4b0a1b2c3d4ea01"), mock.Anything).Return(nil, nil)
+
+	result, err := complaintService.IngestComplaint(readARFSample(t, "outlook_jmrp.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Suppressed)
+	mockSubscriberRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestIngestUnmatchedComplaintIsDropped(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	complaintService := service.NewComplaintService(nil, mockDeliveryRepo, mockSubscriberRepo, service.NewSuppressionService(mockSuppressionRepo, nil), service.NewBounceTracking("", "other.example.com"), 0)
+
+	result, err := complaintService.IngestComplaint(readARFSample(t, "rfc5965_headers_only.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, &response.ComplaintResponse{FeedbackType: "abuse"}, result)
+	mockDeliveryRepo.AssertNotCalled(t, "RecordComplaint", mock.Anything, mock.Anything, mock.Anything)
+	mockSubscriberRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestIngestNotSpamReportChangesNothing(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	complaintService := service.NewComplaintService(nil, mockDeliveryRepo, mockSubscriberRepo, newEmptySuppressions(), newTestBounceTracking(), 0)
+
+	result, err := complaintService.IngestComplaint(readARFSample(t, "not_spam.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, &response.ComplaintResponse{FeedbackType: "not-spam"}, result)
+	mockDeliveryRepo.AssertNotCalled(t, "RecordComplaint", mock.Anything, mock.Anything, mock.Anything)
+	mockSubscriberRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+}
+
+func TestIngestComplaintRejectsMessagesThatAreNotAbuseReports(t *testing.T) {
+	complaintService := service.NewComplaintService(nil, new(MockDeliveryRepository), new(MockSubscriberRepository), newEmptySuppressions(), newTestBounceTracking(), 0)
+
+	_, err := complaintService.IngestComplaint(readDSNSample(t, "postfix_user_unknown.eml"))
+	assert.True(t, errors.Is(err, service.ErrInvalidComplaint))
+
+	assert.NoError(t, complaintService.ProcessMessage(readDSNSample(t, "out_of_office.eml")))
+}
+
+func TestProcessComplaintReturnsStorageErrors(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	complaintService := service.NewComplaintService(nil, mockDeliveryRepo, new(MockSubscriberRepository), newEmptySuppressions(), newTestBounceTracking(), 0)
+
+	mockDeliveryRepo.On("RecordComplaint", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	assert.Error(t, complaintService.ProcessMessage(readARFSample(t, "yahoo_abuse.eml")))
+}
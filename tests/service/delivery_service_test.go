@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MockDeliveryRepository struct {
+	mock.Mock
+}
+
+func (m *MockDeliveryRepository) QueueDelivery(delivery domain.Delivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *MockDeliveryRepository) UpdateDeliveryStatus(newsletterID string, subscriberID primitive.ObjectID, status domain.DeliveryStatus, errorText string, attempts int) error {
+	args := m.Called(newsletterID, subscriberID, status, errorText, attempts)
+	return args.Error(0)
+}
+
+func (m *MockDeliveryRepository) GetDeliveries(filter domain.DeliveryFilter, page, pageSize int) ([]domain.Delivery, int64, error) {
+	args := m.Called(filter, page, pageSize)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Delivery), args.Get(1).(int64), args.Error(2)
+	}
+	return nil, 0, args.Error(2)
+}
+
+func (m *MockDeliveryRepository) CountDeliveriesByStatus(newsletterID string) (map[domain.DeliveryStatus]int, error) {
+	args := m.Called(newsletterID)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[domain.DeliveryStatus]int), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDeliveryRepository) RecordBounce(newsletterID string, subscriberID primitive.ObjectID, bounce domain.Bounce) (*domain.Delivery, error) {
+	args := m.Called(newsletterID, subscriberID, bounce)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Delivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDeliveryRepository) RecordUnmatchedBounce(bounce domain.Bounce) error {
+	args := m.Called(bounce)
+	return args.Error(0)
+}
+
+func (m *MockDeliveryRepository) CountSoftBounces(newsletterID string) (int, error) {
+	args := m.Called(newsletterID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDeliveryRepository) RecordComplaint(newsletterID string, subscriberID primitive.ObjectID, complaint domain.Complaint) (*domain.Delivery, error) {
+	args := m.Called(newsletterID, subscriberID, complaint)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Delivery), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockDeliveryRepository) CountComplaints(newsletterID string) (int, error) {
+	args := m.Called(newsletterID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockDeliveryRepository) RecordDelivered(newsletterID string, subscriberID primitive.ObjectID, deliveredAt time.Time) error {
+	args := m.Called(newsletterID, subscriberID, deliveredAt)
+	return args.Error(0)
+}
+
+func (m *MockDeliveryRepository) RecordOpen(newsletterID string, subscriberID primitive.ObjectID, open domain.Open) error {
+	args := m.Called(newsletterID, subscriberID, open)
+	return args.Error(0)
+}
+
+func (m *MockDeliveryRepository) RecordOpenByToken(openToken string, open domain.Open) (bool, error) {
+	args := m.Called(openToken, open)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeliveryRepository) CountOpens(newsletterID string) (int, int, error) {
+	args := m.Called(newsletterID)
+	return args.Int(0), args.Int(1), args.Error(2)
+}
+
+func TestGetDeliveriesAppliesPaginationDefaults(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	deliveryService := service.NewDeliveryService(mockDeliveryRepo)
+
+	filter := domain.DeliveryFilter{NewsletterID: "1", Status: domain.DeliveryFailed}
+	deliveries := []domain.Delivery{{Email: "test@example.com", Status: domain.DeliveryFailed, Error: "550 mailbox unavailable"}}
+
+	mockDeliveryRepo.On("GetDeliveries", filter, 1, 20).Return(deliveries, int64(1), nil)
+	mockDeliveryRepo.On("CountDeliveriesByStatus", "1").Return(map[domain.DeliveryStatus]int{
+		domain.DeliverySent:   3,
+		domain.DeliveryFailed: 1,
+	}, nil)
+	mockDeliveryRepo.On("CountSoftBounces", "1").Return(0, nil)
+	mockDeliveryRepo.On("CountComplaints", "1").Return(0, nil)
+	mockDeliveryRepo.On("CountOpens", "1").Return(0, 0, nil)
+
+	result, err := deliveryService.GetDeliveries(filter, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, deliveries, result.Deliveries)
+	assert.Equal(t, 1, result.Page)
+	assert.Equal(t, 20, result.PageSize)
+	assert.Equal(t, int64(1), result.Total)
+	assert.Equal(t, domain.DeliverySummary{Total: 4, Sent: 3, Failed: 1}, result.Summary)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestGetDeliverySummary(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	deliveryService := service.NewDeliveryService(mockDeliveryRepo)
+
+	mockDeliveryRepo.On("CountDeliveriesByStatus", "1").Return(map[domain.DeliveryStatus]int{
+		domain.DeliveryQueued:  2,
+		domain.DeliverySent:    5,
+		domain.DeliveryBounced: 1,
+	}, nil)
+	mockDeliveryRepo.On("CountSoftBounces", "1").Return(3, nil)
+	mockDeliveryRepo.On("CountComplaints", "1").Return(1, nil)
+	mockDeliveryRepo.On("CountOpens", "1").Return(2, 7, nil)
+
+	summary, err := deliveryService.GetDeliverySummary("1")
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.DeliverySummary{Total: 8, Queued: 2, Sent: 5, Bounced: 1, SoftBounces: 3, Complaints: 1, ComplaintRate: 0.2, UniqueOpens: 2, TotalOpens: 7, OpenRate: 0.4}, summary)
+}
@@ -3,10 +3,14 @@ package service_test
 import (
 	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/request"
+	"newsletter-app/pkg/service/render"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -55,18 +59,66 @@ func (m *MockSubscriberRepository) GetSubscribersByCategory(category string) ([]
 	return args.Get(0).([]domain.Subscriber), args.Error(1)
 }
 
+func (m *MockSubscriberRepository) GetSubscribersByCategoryAfter(category string, afterID primitive.ObjectID, limit int) ([]domain.Subscriber, error) {
+	args := m.Called(category, afterID, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Subscriber), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockSendJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockSendJobRepository) SaveSendJob(job domain.SendJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockSendJobRepository) GetSendJobByID(jobID string) (*domain.SendJob, error) {
+	args := m.Called(jobID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.SendJob), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSendJobRepository) ClaimNextSendJob(workerID string, leaseDuration time.Duration) (*domain.SendJob, error) {
+	args := m.Called(workerID, leaseDuration)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.SendJob), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSendJobRepository) UpdateSendJob(job domain.SendJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
 type MockEmailSender struct {
 	mock.Mock
 }
 
-func (m *MockEmailSender) Send(subject, body string, to []string, attachments []*domain.Attachment) error {
-	args := m.Called(subject, body, to, attachments)
+func (m *MockEmailSender) Send(message domain.EmailMessage) error {
+	args := m.Called(message)
 	return args.Error(0)
 }
 
+// messageTo matches a message sent to email alone, and with body when one is given.
+func messageTo(email string, body ...string) interface{} {
+	return mock.MatchedBy(func(message domain.EmailMessage) bool {
+		if len(body) > 0 && message.Body != body[0] {
+			return false
+		}
+		return len(message.To) == 1 && message.To[0] == email
+	})
+}
+
 func TestSaveNewsletter(t *testing.T) {
 	mockNewsletterRepo := new(MockNewsletterRepository)
-	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository))
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
 
 	newNewsletter := domain.Newsletter{
 		ID:       primitive.NewObjectID(),
@@ -81,9 +133,142 @@ func TestSaveNewsletter(t *testing.T) {
 	mockNewsletterRepo.AssertExpectations(t)
 }
 
+func TestSaveNewsletterRejectsInvalidTemplate(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+	newNewsletter := domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Content:  "<p>Hello</p>\n<p>{{.Subscriber.Email</p>",
+	}
+
+	err := newsletterService.SaveNewsletter(newNewsletter)
+
+	var templateErr *render.TemplateError
+	assert.ErrorAs(t, err, &templateErr)
+	assert.Equal(t, 2, templateErr.Line)
+	mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+}
+
+func TestSaveNewsletterRejectsInvalidTextTemplate(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+	newNewsletter := domain.Newsletter{
+		Name:        "Test Newsletter",
+		Category:    "Tech",
+		Content:     "<p>Hello</p>",
+		TextContent: "Hello {{.Subscriber.Email",
+	}
+
+	err := newsletterService.SaveNewsletter(newNewsletter)
+
+	var templateErr *render.TemplateError
+	assert.ErrorAs(t, err, &templateErr)
+	assert.True(t, templateErr.Text)
+	mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+}
+
+func TestSaveNewsletterAcceptsInlineAttachments(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), mockAttachmentRepo, service.AttachmentPolicy{}, newCleanScanner())
+
+	logo := &domain.Attachment{ID: "65a1b2c3d4e5f60718293a01", Name: "logo.png", Type: "image/png", Size: 3}
+	banner := &domain.Attachment{ID: "65a1b2c3d4e5f60718293a02", Name: "banner.png", Type: "image/png", Size: 3}
+	mockAttachmentRepo.On("GetAttachment", logo.ID).Return(logo, nil)
+	mockAttachmentRepo.On("GetAttachment", banner.ID).Return(banner, nil)
+	mockAttachmentRepo.On("OpenAttachment", mock.Anything).Return("png", nil)
+	mockNewsletterRepo.On("SaveNewsletter", mock.Anything).Return(nil)
+
+	err := newsletterService.SaveNewsletter(domain.Newsletter{
+		Name:     "Test Newsletter",
+		Category: "Tech",
+		Content:  `<img src="cid:logo%40example.com"><div style="background: url(CID:banner)"></div>`,
+		Attachments: []domain.Attachment{
+			{ID: logo.ID, ContentID: "logo@example.com"},
+			{ID: banner.ID, ContentID: "banner"},
+		},
+	})
+
+	require.NoError(t, err)
+	saved := mockNewsletterRepo.Calls[0].Arguments.Get(0).(domain.Newsletter)
+	require.Len(t, saved.Attachments, 2)
+	assert.Equal(t, "logo@example.com", saved.Attachments[0].ContentID)
+	assert.Equal(t, "logo.png", saved.Attachments[0].Name)
+	assert.Equal(t, "banner", saved.Attachments[1].ContentID)
+}
+
+func TestSaveNewsletterRejectsBadContentIDs(t *testing.T) {
+	tests := map[string]struct {
+		content     string
+		attachments []domain.Attachment
+		err         error
+	}{
+		"unknown reference": {
+			content: `<img src="cid:logo">`,
+			err:     service.ErrUnknownContentID,
+		},
+		"reference to a download": {
+			content:     `<img src="cid:notes.txt">`,
+			attachments: []domain.Attachment{{Name: "notes.txt", Data: "bm90ZXM="}},
+			err:         service.ErrUnknownContentID,
+		},
+		"duplicate": {
+			content: `<img src="cid:logo">`,
+			attachments: []domain.Attachment{
+				{Name: "logo.png", Data: "cG5n", ContentID: "logo"},
+				{Name: "logo2.png", Data: "cG5n", ContentID: "logo"},
+			},
+			err: service.ErrDuplicateContentID,
+		},
+		"invalid": {
+			content:     `<p>Hello</p>`,
+			attachments: []domain.Attachment{{Name: "logo.png", Data: "cG5n", ContentID: "<logo>"}},
+			err:         service.ErrInvalidContentID,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockNewsletterRepo := new(MockNewsletterRepository)
+			newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+			err := newsletterService.SaveNewsletter(domain.Newsletter{Name: "Test Newsletter", Category: "Tech", Content: test.content, Attachments: test.attachments})
+
+			assert.ErrorIs(t, err, test.err)
+			mockNewsletterRepo.AssertNotCalled(t, "SaveNewsletter", mock.Anything)
+		})
+	}
+}
+
+func TestUpdateNewsletterRejectsUnknownContentID(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+	id := primitive.NewObjectID()
+	existing := &domain.Newsletter{
+		ID:          id,
+		Content:     `<img src="cid:logo">`,
+		Attachments: []domain.Attachment{{Name: "logo.png", Data: "cG5n", ContentID: "logo"}},
+	}
+	mockNewsletterRepo.On("GetNewsletterByID", id.Hex()).Return(existing, nil)
+
+	// Replacing the attachments without the logo leaves the reference dangling.
+	err := newsletterService.UpdateNewsletter(request.UpdateNewsletterRequest{
+		ID:          id,
+		Content:     `<img src="cid:logo">`,
+		Attachments: []request.Attachment{{Name: "notes.txt", Data: "bm90ZXM="}},
+	})
+
+	assert.ErrorIs(t, err, service.ErrUnknownContentID)
+	mockNewsletterRepo.AssertNotCalled(t, "UpdateNewsletter", mock.Anything)
+}
+
 func TestGetNewsletterByCategory(t *testing.T) {
 	mockNewsletterRepo := new(MockNewsletterRepository)
-	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository))
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
 
 	mockNewsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech"}
 	mockNewsletterRepo.On("GetNewsletterByCategory", "Tech").Return(mockNewsletter, nil)
@@ -96,7 +281,7 @@ func TestGetNewsletterByCategory(t *testing.T) {
 
 func TestGetNewsletterByID(t *testing.T) {
 	mockNewsletterRepo := new(MockNewsletterRepository)
-	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository))
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
 
 	mockNewsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech"}
 	mockNewsletterRepo.On("GetNewsletterByID", mockNewsletter.ID.Hex()).Return(mockNewsletter, nil)
@@ -109,7 +294,7 @@ func TestGetNewsletterByID(t *testing.T) {
 
 func TestGetNewsletters(t *testing.T) {
 	mockNewsletterRepo := new(MockNewsletterRepository)
-	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository))
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
 
 	newsletters := []domain.Newsletter{
 		{ID: primitive.NewObjectID(), Category: "Tech"},
@@ -126,7 +311,7 @@ func TestGetNewsletters(t *testing.T) {
 
 func TestDeleteNewsletter(t *testing.T) {
 	mockNewsletterRepo := new(MockNewsletterRepository)
-	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository))
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, new(MockSubscriberRepository), new(MockSendJobRepository), new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
 
 	mockNewsletterRepo.On("DeleteNewsletterByID", "1").Return(nil)
 
@@ -134,3 +319,38 @@ func TestDeleteNewsletter(t *testing.T) {
 	assert.NoError(t, err)
 	mockNewsletterRepo.AssertExpectations(t)
 }
+
+func TestSendNewsletterQueuesJob(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockSendJobRepo := new(MockSendJobRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, mockSubscriberRepo, mockSendJobRepo, new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Content: "<p>Hello</p>"}
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, 1).
+		Return([]domain.Subscriber{{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}}, nil)
+	mockSendJobRepo.On("SaveSendJob", mock.MatchedBy(func(job domain.SendJob) bool {
+		return job.NewsletterID == newsletter.ID.Hex() && job.Status == domain.SendJobQueued && !job.ID.IsZero()
+	})).Return(nil)
+
+	job, err := newsletterService.SendNewsletter(newsletter.ID.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, domain.SendJobQueued, job.Status)
+	mockSendJobRepo.AssertExpectations(t)
+}
+
+func TestSendNewsletterWithoutSubscribers(t *testing.T) {
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockSendJobRepo := new(MockSendJobRepository)
+	newsletterService := service.NewNewsletterService(mockNewsletterRepo, mockSubscriberRepo, mockSendJobRepo, new(MockAttachmentRepository), service.AttachmentPolicy{}, newCleanScanner())
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Content: "<p>Hello</p>"}
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, 1).Return([]domain.Subscriber{}, nil)
+
+	_, err := newsletterService.SendNewsletter(newsletter.ID.Hex())
+	assert.ErrorIs(t, err, service.ErrNoSubscribers)
+	mockSendJobRepo.AssertNotCalled(t, "SaveSendJob", mock.Anything)
+}
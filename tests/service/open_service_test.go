@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var pixelURLPattern = regexp.MustCompile(`<img src="https://api\.example\.com/t/o/([^"]+)\.gif"`)
+
+func newTestOpenTracking(t *testing.T, enabled bool) *service.OpenTracking {
+	return service.NewOpenTracking(newTestPublicURLs(t), enabled)
+}
+
+func TestProcessJobAddsATrackingPixelThatRecordsOpens(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	openTracking := newTestOpenTracking(t, true)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), openTracking, 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<html><body><p>Hello</p></BODY></html>"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	var message domain.EmailMessage
+	var queued domain.Delivery
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).
+		Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+		Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).
+		Run(func(args mock.Arguments) { queued = args.Get(0).(domain.Delivery) }).
+		Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+
+	match := pixelURLPattern.FindStringSubmatch(message.Body)
+	require.NotNil(t, match, message.Body)
+	assert.True(t, strings.HasSuffix(message.Body, `"></BODY></html>`), "the pixel is the last element of the body")
+	assert.NotContains(t, message.TextBody, "/t/o/")
+	assert.Equal(t, []string{match[1]}, queued.OpenTokens, "the token is stored on the delivery")
+
+	// The token is opaque: it holds neither the newsletter nor the subscriber,
+	// even once decoded.
+	decoded, err := base64.RawURLEncoding.DecodeString(match[1])
+	require.NoError(t, err)
+	for _, value := range []string{match[1], string(decoded)} {
+		assert.NotContains(t, value, subscriber.Email)
+		assert.NotContains(t, value, subscriber.ID.Hex())
+		assert.NotContains(t, value, newsletter.ID.Hex())
+	}
+
+	mockDeliveryRepo.On("RecordOpenByToken", match[1], mock.MatchedBy(func(open domain.Open) bool {
+		return open.UserAgentClass == domain.UserAgentMobile &&
+			!open.AppleMPP &&
+			open.ReportedBy == service.OpenReportedByPixel &&
+			time.Since(open.OpenedAt) < time.Minute
+	})).Return(true, nil)
+
+	openService := service.NewOpenService(mockDeliveryRepo, openTracking)
+	userAgent := "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0 Mobile Safari/537.36"
+	require.NoError(t, openService.RecordPixelOpen(match[1], userAgent, "198.51.100.7"))
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestProcessJobWithoutOpenTrackingAddsNoPixel(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", mock.MatchedBy(func(message domain.EmailMessage) bool {
+		return message.Body == "<p>Hello</p>"
+	})).Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+	mockEmailSender.AssertExpectations(t)
+}
+
+func TestAddPixelAppendsToContentWithoutABody(t *testing.T) {
+	openTracking := newTestOpenTracking(t, true)
+
+	content := openTracking.AddPixel("<p>Hello</p>", "Science", "token")
+	assert.Equal(t, `<p>Hello</p><img src="https://api.example.com/t/o/token.gif" width="1" height="1" alt="" style="display:block;width:1px;height:1px;border:0">`, content)
+}
+
+func TestRecordPixelOpenRejectsOtherTokens(t *testing.T) {
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	openTracking := newTestOpenTracking(t, true)
+	openService := service.NewOpenService(mockDeliveryRepo, openTracking)
+
+	unsubscribeToken, err := newTestUnsubscribeTokens(t).Issue(domain.Subscriber{ID: primitive.NewObjectID(), Category: "Tech"})
+	require.NoError(t, err)
+
+	for _, openToken := range []string{"", "not-a-token", unsubscribeToken} {
+		assert.ErrorIs(t, openService.RecordPixelOpen(openToken, "Mozilla/5.0", ""), service.ErrInvalidOpenToken)
+	}
+	mockDeliveryRepo.AssertNotCalled(t, "RecordOpenByToken", mock.Anything, mock.Anything)
+
+	unknownToken := openTracking.NewToken()
+	mockDeliveryRepo.On("RecordOpenByToken", unknownToken, mock.Anything).Return(false, nil)
+	assert.ErrorIs(t, openService.RecordPixelOpen(unknownToken, "Mozilla/5.0", ""), service.ErrInvalidOpenToken)
+}
+
+func TestClassifyOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		remoteIP  string
+		class     domain.UserAgentClass
+		appleMPP  bool
+	}{
+		{"Apple MPP prefetch", "Mozilla/5.0", "203.0.113.9", domain.UserAgentUnknown, true},
+		{"Apple network", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148", "17.58.101.4", domain.UserAgentMobile, true},
+		{"Gmail image proxy", "Mozilla/5.0 (Windows NT 5.1; rv:11.0) Gecko Firefox/11.0 (via ggpht.com GoogleImageProxy)", "66.249.84.1", domain.UserAgentProxy, false},
+		{"Yahoo image proxy", "YahooMailProxy; https://help.yahoo.com/kb/yahoo-mail-proxy-SLN28749.html", "", domain.UserAgentProxy, false},
+		{"Outlook", "Microsoft Outlook 16.0.17328; Pro", "", domain.UserAgentDesktop, false},
+		{"Apple Mail on a Mac", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)", "203.0.113.9", domain.UserAgentDesktop, false},
+		{"Android", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0 Mobile Safari/537.36", "", domain.UserAgentMobile, false},
+		{"link checker", "curl/8.5.0", "", domain.UserAgentBot, false},
+		{"no user agent", "", "", domain.UserAgentUnknown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, appleMPP := service.ClassifyOpen(tt.userAgent, tt.remoteIP)
+			assert.Equal(t, tt.class, class)
+			assert.Equal(t, tt.appleMPP, appleMPP)
+		})
+	}
+}
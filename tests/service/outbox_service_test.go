@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockOutboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepository) ListOutboxMessages() ([]string, error) {
+	args := m.Called()
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockOutboxRepository) GetOutboxMessage(id string) ([]byte, error) {
+	args := m.Called(id)
+	if args.Get(0) != nil {
+		return args.Get(0).([]byte), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func capturedMessage(subject string) []byte {
+	return []byte(strings.ReplaceAll(fmt.Sprintf(`From: newsletter@example.com
+To: reader@example.com, other@example.com
+Subject: %s
+Date: Tue, 02 Jan 2024 03:04:05 +0000
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=outer
+
+--outer
+Content-Type: multipart/alternative; boundary=inner
+
+--inner
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+Caf=C3=A9 news
+--inner
+Content-Type: text/html; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+<p>Caf=C3=A9 news</p>
+--inner--
+
+--outer
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+Content-Transfer-Encoding: base64
+
+bm90ZXM=
+--outer--
+`, subject), "\n", "\r\n"))
+}
+
+func TestGetOutboxMessagesListsNewestFirst(t *testing.T) {
+	mockOutboxRepo := new(MockOutboxRepository)
+	outboxService := service.NewOutboxService(mockOutboxRepo)
+
+	mockOutboxRepo.On("ListOutboxMessages").Return([]string{"1", "2", "3"}, nil)
+	mockOutboxRepo.On("GetOutboxMessage", "2").Return(capturedMessage("=?UTF-8?q?Caf=C3=A9?="), nil)
+	mockOutboxRepo.On("GetOutboxMessage", "1").Return(capturedMessage("Oldest"), nil)
+
+	result, err := outboxService.GetOutboxMessages(2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	require.Len(t, result.Messages, 1)
+
+	message := result.Messages[0]
+	assert.Equal(t, "2", message.ID)
+	assert.Equal(t, "Café", message.Subject)
+	assert.Equal(t, "newsletter@example.com", message.From)
+	assert.Equal(t, []string{"reader@example.com", "other@example.com"}, message.To)
+	assert.Equal(t, 2024, message.Date.Year())
+	mockOutboxRepo.AssertNotCalled(t, "GetOutboxMessage", "3")
+}
+
+func TestGetOutboxMessageDecodesBodiesAndAttachments(t *testing.T) {
+	mockOutboxRepo := new(MockOutboxRepository)
+	outboxService := service.NewOutboxService(mockOutboxRepo)
+
+	mockOutboxRepo.On("GetOutboxMessage", "1").Return(capturedMessage("Weekly"), nil)
+
+	detail, err := outboxService.GetOutboxMessage("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Weekly", detail.Subject)
+	assert.Equal(t, "Café news", strings.TrimSpace(detail.TextBody))
+	assert.Equal(t, "<p>Café news</p>", strings.TrimSpace(detail.HTMLBody))
+	assert.Equal(t, []string{"1.0"}, detail.Headers["Mime-Version"])
+	require.Len(t, detail.Attachments, 1)
+	assert.Equal(t, "notes.txt", detail.Attachments[0].Name)
+	assert.Equal(t, "text/plain", detail.Attachments[0].ContentType)
+	assert.Equal(t, 5, detail.Attachments[0].Size)
+}
+
+func TestGetOutboxMessageNotFound(t *testing.T) {
+	mockOutboxRepo := new(MockOutboxRepository)
+	outboxService := service.NewOutboxService(mockOutboxRepo)
+
+	mockOutboxRepo.On("GetOutboxMessage", "missing").Return(nil, nil)
+
+	_, err := outboxService.GetOutboxMessage("missing")
+	assert.True(t, errors.Is(err, service.ErrOutboxMessageNotFound))
+}
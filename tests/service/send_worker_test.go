@@ -0,0 +1,474 @@
+package service_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestProcessJobSendsToEverySubscriber(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<a href=\"{hostDomain}unsubscribe/{email}\">Unsubscribe</a>"}
+	first := domain.Subscriber{ID: primitive.NewObjectID(), Email: "first@example.com", Category: "Tech"}
+	second := domain.Subscriber{ID: primitive.NewObjectID(), Email: "second@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{first, second}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", second.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", mock.MatchedBy(func(message domain.EmailMessage) bool {
+		return message.Subject == "News" && message.To[0] == "first@example.com" &&
+			strings.HasPrefix(message.Body, "<a href=\"https://example.com/unsubscribe/") && !strings.Contains(message.Body, "first@example.com")
+	})).Return(nil)
+	mockEmailSender.On("Send", messageTo("second@example.com")).Return(errors.New("mailbox unavailable"))
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), first.ID, domain.DeliverySent, "", 1).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), second.ID, domain.DeliveryFailed, "mailbox unavailable", 1).Return(nil)
+
+	err := pool.ProcessJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.SendJobCompleted, job.Status)
+	assert.Equal(t, 1, job.Sent)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, second.ID, job.LastSubscriberID)
+	mockEmailSender.AssertExpectations(t)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestProcessJobLinksToSignedUnsubscribeURL(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, unsubscribeTokens, newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<a href=\"{{.UnsubscribeURL}}\">Unsubscribe</a>"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	var message domain.EmailMessage
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).
+		Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+		Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+
+	prefix := "<a href=\"https://api.example.com/api/v1/unsubscribe/"
+	require.True(t, strings.HasPrefix(message.Body, prefix))
+	unsubscribeToken := strings.TrimSuffix(strings.TrimPrefix(message.Body, prefix), "\">Unsubscribe</a>")
+	subscriberID, category, err := unsubscribeTokens.Verify(unsubscribeToken)
+	require.NoError(t, err)
+	assert.Equal(t, subscriber.ID.Hex(), subscriberID)
+	assert.Equal(t, "Tech", category)
+
+	assert.Equal(t, "<mailto:unsubscribe@example.com?subject=unsubscribe%20"+unsubscribeToken+">, <https://api.example.com/api/v1/unsubscribe/one-click/"+unsubscribeToken+">", message.Headers["List-Unsubscribe"])
+	assert.Equal(t, "List-Unsubscribe=One-Click", message.Headers["List-Unsubscribe-Post"])
+}
+
+func TestProcessJobUsesPublicURLsOfTheCategory(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Science", Subject: "News", Content: `<a href="{hostDomain}">Home</a> <a href="{{.PreferencesURL}}">Preferences</a>`}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Science"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	var message domain.EmailMessage
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Science", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Science", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).
+		Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+		Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+
+	assert.True(t, strings.HasPrefix(message.Body, `<a href="https://science.example.com/">Home</a> <a href="https://science.example.com/preferences/`))
+	assert.Contains(t, message.Headers["List-Unsubscribe"], "<https://api.example.com/api/v1/unsubscribe/one-click/")
+}
+
+func TestProcessJobSendsTextAlternative(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		textContent string
+		expected    string
+	}{
+		{name: "generated", expected: "Hello\n=====\n\nUnsubscribe (https://api.example.com/api/v1/unsubscribe/"},
+		{name: "hand-written", textContent: "Hello {{.Subscriber.Email}}", expected: "Hello test@example.com"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockSendJobRepo := new(MockSendJobRepository)
+			mockNewsletterRepo := new(MockNewsletterRepository)
+			mockSubscriberRepo := new(MockSubscriberRepository)
+			mockDeliveryRepo := new(MockDeliveryRepository)
+			mockEmailSender := new(MockEmailSender)
+			pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+			newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: `<h1>Hello</h1><a href="{{.UnsubscribeURL}}">Unsubscribe</a>`, TextContent: tc.textContent}
+			subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+			job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+			var message domain.EmailMessage
+			mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+			mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+			mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+			mockEmailSender.On("Send", messageTo("test@example.com")).
+				Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+				Return(nil)
+			mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+			mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+			mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+			require.NoError(t, pool.ProcessJob(job))
+			assert.True(t, strings.HasPrefix(message.TextBody, tc.expected), message.TextBody)
+			assert.True(t, strings.HasPrefix(message.Body, "<h1>Hello</h1>"))
+		})
+	}
+}
+
+func TestProcessJobResumesAfterLastSubscriber(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	alreadySent := primitive.NewObjectID()
+	remaining := domain.Subscriber{ID: primitive.NewObjectID(), Email: "remaining@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning, LastSubscriberID: alreadySent, Sent: 1}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", alreadySent, mock.Anything).Return([]domain.Subscriber{remaining}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", remaining.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("remaining@example.com", "<p>Hello</p>")).Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), remaining.ID, domain.DeliverySent, "", 1).Return(nil)
+
+	err := pool.ProcessJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, job.Sent)
+	assert.Equal(t, domain.SendJobCompleted, job.Status)
+	mockEmailSender.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestProcessJobFailsWhenNewsletterIsMissing(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, new(MockSubscriberRepository), new(MockDeliveryRepository), new(MockAttachmentRepository), new(MockEmailSender), newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: primitive.NewObjectID().Hex(), Status: domain.SendJobRunning}
+	mockNewsletterRepo.On("GetNewsletterByID", job.NewsletterID).Return(nil, errors.New("not found"))
+	mockSendJobRepo.On("UpdateSendJob", mock.MatchedBy(func(updated domain.SendJob) bool {
+		return updated.Status == domain.SendJobFailed && updated.Error == "not found"
+	})).Return(nil)
+
+	err := pool.ProcessJob(job)
+	assert.Error(t, err)
+	mockSendJobRepo.AssertExpectations(t)
+}
+
+func TestProcessJobRecordsSenderAttempts(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "busy@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+	sendErr := &domain.SendError{Code: 421, Attempts: 3, Err: errors.New("421 try again later")}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockEmailSender.On("Send", messageTo("busy@example.com", "<p>Hello</p>")).Return(sendErr)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), subscriber.ID, domain.DeliveryFailed, sendErr.Error(), 3).Return(nil)
+
+	err := pool.ProcessJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, job.Failed)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestProcessJobSuppressesAddressesThatFailPermanently(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	gone := domain.Subscriber{ID: primitive.NewObjectID(), Email: "gone@example.com", Category: "Tech"}
+	busy := domain.Subscriber{ID: primitive.NewObjectID(), Email: "busy@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+	permanentErr := &domain.SendError{Code: 550, Status: "5.1.1", Phase: domain.SendPhaseRecipient, Permanent: true, Attempts: 1, Err: errors.New("550 5.1.1 <gone@example.com>: user unknown")}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{gone, busy}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", busy.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockSuppressionRepo.On("FindSuppressions", mock.Anything, "Tech").Return([]domain.Suppression{}, nil)
+	mockEmailSender.On("Send", messageTo("gone@example.com")).Return(permanentErr)
+	mockEmailSender.On("Send", messageTo("busy@example.com")).Return(&domain.SendError{Code: 421, Attempts: 3, Err: errors.New("421 try again later")})
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, domain.DeliveryFailed, mock.Anything, mock.Anything).Return(nil)
+	mockSuppressionRepo.On("SaveSuppressions", mock.MatchedBy(func(suppressions []domain.Suppression) bool {
+		return len(suppressions) == 1 &&
+			suppressions[0].AddressHash == sha256Hex("gone@example.com") &&
+			suppressions[0].Category == "" &&
+			suppressions[0].Reason == domain.SuppressionHardBounce &&
+			suppressions[0].Source == service.SuppressionSourceSend &&
+			suppressions[0].Detail == "permanent send error: 550 5.1.1 <[address]>: user unknown"
+	})).Return(nil).Once()
+
+	require.NoError(t, pool.ProcessJob(job))
+	assert.Equal(t, 2, job.Failed)
+	mockSuppressionRepo.AssertExpectations(t)
+	mockSuppressionRepo.AssertNumberOfCalls(t, "SaveSuppressions", 1)
+}
+
+func TestProcessJobDoesNotSuppressRecipientsRefusedForPassingReasons(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	full := domain.Subscriber{ID: primitive.NewObjectID(), Email: "full@example.com", Category: "Tech"}
+	blocked := domain.Subscriber{ID: primitive.NewObjectID(), Email: "blocked@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{full, blocked}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", blocked.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockSuppressionRepo.On("FindSuppressions", mock.Anything, "Tech").Return([]domain.Suppression{}, nil)
+	mockEmailSender.On("Send", messageTo("full@example.com")).Return(&domain.SendError{Code: 552, Status: "5.2.2", Phase: domain.SendPhaseRecipient, Permanent: true, Err: errors.New("552 5.2.2 mailbox full")})
+	mockEmailSender.On("Send", messageTo("blocked@example.com")).Return(&domain.SendError{Code: 550, Status: "5.7.1", Phase: domain.SendPhaseRecipient, Permanent: true, Err: errors.New("550 5.7.1 relaying denied")})
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, domain.DeliveryFailed, mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+	assert.Equal(t, 2, job.Failed)
+	assert.Equal(t, domain.SendJobCompleted, job.Status)
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestProcessJobFailsWhenTheMessageIsRefused(t *testing.T) {
+	for _, phase := range []domain.SendPhase{domain.SendPhaseSender, domain.SendPhaseMessage} {
+		t.Run(string(phase), func(t *testing.T) {
+			mockSendJobRepo := new(MockSendJobRepository)
+			mockNewsletterRepo := new(MockNewsletterRepository)
+			mockSubscriberRepo := new(MockSubscriberRepository)
+			mockDeliveryRepo := new(MockDeliveryRepository)
+			mockEmailSender := new(MockEmailSender)
+			mockSuppressionRepo := new(MockSuppressionRepository)
+			pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), service.NewSuppressionService(mockSuppressionRepo, nil), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+			newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+			first := domain.Subscriber{ID: primitive.NewObjectID(), Email: "first@example.com", Category: "Tech"}
+			second := domain.Subscriber{ID: primitive.NewObjectID(), Email: "second@example.com", Category: "Tech"}
+			job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+			refusedErr := &domain.SendError{Code: 554, Status: "5.6.0", Phase: phase, Permanent: true, Err: errors.New("554 5.6.0 message refused")}
+
+			mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+			mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{first, second}, nil)
+			mockSuppressionRepo.On("FindSuppressions", mock.Anything, "Tech").Return([]domain.Suppression{}, nil)
+			mockEmailSender.On("Send", messageTo("first@example.com")).Return(refusedErr)
+			mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+			mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+			mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), first.ID, domain.DeliveryFailed, refusedErr.Error(), 1).Return(nil)
+
+			err := pool.ProcessJob(job)
+			assert.ErrorIs(t, err, refusedErr)
+			assert.Equal(t, domain.SendJobFailed, job.Status)
+			assert.Equal(t, refusedErr.Error(), job.Error)
+			mockEmailSender.AssertNotCalled(t, "Send", messageTo("second@example.com"))
+			mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+		})
+	}
+}
+
+func TestProcessJobStopsWhenTheLeaseIsLost(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	first := domain.Subscriber{ID: primitive.NewObjectID(), Email: "first@example.com", Category: "Tech"}
+	second := domain.Subscriber{ID: primitive.NewObjectID(), Email: "second@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning, WorkerID: "worker-1"}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{first, second}, nil)
+	mockEmailSender.On("Send", messageTo("first@example.com")).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.MatchedBy(func(saved domain.SendJob) bool {
+		return saved.WorkerID == "worker-1"
+	})).Return(domain.ErrSendJobLeaseLost)
+
+	err := pool.ProcessJob(job)
+	assert.ErrorIs(t, err, domain.ErrSendJobLeaseLost)
+	mockEmailSender.AssertNumberOfCalls(t, "Send", 1)
+	mockEmailSender.AssertNotCalled(t, "Send", messageTo("second@example.com"))
+}
+
+func TestProcessJobPausesWhenSendingBudgetIsExhausted(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>"}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+	resumeAt := time.Now().Add(time.Hour)
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com", "<p>Hello</p>")).Return(&domain.RateLimitError{ResumeAt: resumeAt})
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.MatchedBy(func(updated domain.SendJob) bool {
+		return updated.Status == domain.SendJobPaused && updated.ResumeAt.Equal(resumeAt)
+	})).Return(nil)
+
+	err := pool.ProcessJob(job)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.SendJobPaused, job.Status)
+	assert.True(t, job.LastSubscriberID.IsZero(), "the rate-limited subscriber must be retried on resume")
+	mockDeliveryRepo.AssertNotCalled(t, "UpdateDeliveryStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockSendJobRepo.AssertExpectations(t)
+}
+
+func TestProcessJobStreamsStoredAttachments(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, mockAttachmentRepo, mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	report := domain.Attachment{ID: "65a1b2c3d4e5f60718293a4b", Name: "report.pdf", Type: "application/pdf", Size: 6, Scan: &domain.AttachmentScan{Status: domain.AttachmentScanClean}}
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "<p>Hello</p>", Attachments: []domain.Attachment{report}}
+	subscriber := domain.Subscriber{ID: primitive.NewObjectID(), Email: "reader@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockAttachmentRepo.On("GetAttachment", report.ID).Return(&report, nil)
+	mockAttachmentRepo.On("OpenAttachment", report.ID).Return("%PDF-1", nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{subscriber}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", subscriber.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), subscriber.ID, domain.DeliverySent, "", 1).Return(nil)
+
+	var sent domain.EmailMessage
+	mockEmailSender.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(domain.EmailMessage)
+	}).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+	require.Len(t, sent.Attachments, 1)
+	assert.Empty(t, sent.Attachments[0].Data)
+
+	content, err := sent.Attachments[0].Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1", string(data))
+}
+
+func TestProcessJobFailsWhenAnAttachmentIsMissing(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockAttachmentRepo := new(MockAttachmentRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, new(MockSubscriberRepository), new(MockDeliveryRepository), mockAttachmentRepo, mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Content: "<p>Hello</p>", Attachments: []domain.Attachment{{ID: "65a1b2c3d4e5f60718293a4b", Scan: &domain.AttachmentScan{Status: domain.AttachmentScanSkipped}}}}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockAttachmentRepo.On("GetAttachment", "65a1b2c3d4e5f60718293a4b").Return(nil, nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+
+	err := pool.ProcessJob(job)
+
+	assert.ErrorIs(t, err, service.ErrAttachmentNotFound)
+	assert.Equal(t, domain.SendJobFailed, job.Status)
+	mockEmailSender.AssertNotCalled(t, "Send", mock.Anything)
+}
+
+func TestProcessJobFailsWhenAnAttachmentIsInfected(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockEmailSender := new(MockEmailSender)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, new(MockSubscriberRepository), new(MockDeliveryRepository), new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), newEmptySuppressions(), newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	// The newsletter was queued, then updated with an attachment that did not
+	// pass the scan.
+	infected := domain.Attachment{ID: "65a1b2c3d4e5f60718293a4b", Name: "invoice.pdf", Scan: &domain.AttachmentScan{Status: domain.AttachmentScanInfected, Signature: "Eicar-Signature"}}
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Content: "<p>Hello</p>", Attachments: []domain.Attachment{infected}}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+
+	err := pool.ProcessJob(job)
+
+	assert.ErrorIs(t, err, service.ErrAttachmentInfected)
+	assert.Contains(t, err.Error(), "Eicar-Signature")
+	assert.Equal(t, domain.SendJobFailed, job.Status)
+	mockEmailSender.AssertNotCalled(t, "Send", mock.Anything)
+}
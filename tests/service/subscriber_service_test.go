@@ -1,14 +1,20 @@
 package service_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"newsletter-app/pkg/config"
 	domain "newsletter-app/pkg/domain/models"
 	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/token"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type MockSubscriberRepository struct {
@@ -41,26 +47,89 @@ func (m *MockSubscriberRepository) GetSubscribers(email, category string, page,
 	return nil, args.Error(1)
 }
 
+func (m *MockSubscriberRepository) GetSubscriberByID(subscriberID string) (*domain.Subscriber, error) {
+	args := m.Called(subscriberID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*domain.Subscriber), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSubscriberRepository) ConfirmSubscriber(subscriberID primitive.ObjectID) error {
+	args := m.Called(subscriberID)
+	return args.Error(0)
+}
+
+func newTestSigner(t *testing.T) *token.Signer {
+	signer, err := token.NewSigner("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+	require.NoError(t, err)
+	return signer
+}
+
+// newTestPublicURLs links to https://example.com and https://api.example.com,
+// and to https://science.example.com for the Science category.
+func newTestPublicURLs(t *testing.T) *config.PublicURLs {
+	publicURLs, err := config.NewPublicURLs(
+		config.URLs{FrontendBaseURL: "https://example.com", APIBaseURL: "https://api.example.com"},
+		map[string]config.URLs{"Science": {FrontendBaseURL: "https://science.example.com"}},
+	)
+	require.NoError(t, err)
+	return publicURLs
+}
+
+func newTestUnsubscribeTokens(t *testing.T) *service.UnsubscribeTokens {
+	return service.NewUnsubscribeTokens(newTestSigner(t), newTestPublicURLs(t), "unsubscribe@example.com", time.Hour)
+}
+
+func TestUnsubscribeHeadersOfferTheOneClickURLOnlyOverHTTPS(t *testing.T) {
+	publicURLs, err := config.NewPublicURLs(
+		config.URLs{FrontendBaseURL: "https://example.com", APIBaseURL: "https://api.example.com"},
+		map[string]config.URLs{"Local": {APIBaseURL: "http://localhost:8080"}},
+	)
+	require.NoError(t, err)
+	unsubscribeTokens := service.NewUnsubscribeTokens(newTestSigner(t), publicURLs, "Newsletter <unsubscribe@example.com>", time.Hour)
+
+	assert.Equal(t, map[string]string{
+		"List-Unsubscribe":      "<mailto:unsubscribe@example.com?subject=unsubscribe%20abc>, <https://api.example.com/api/v1/unsubscribe/one-click/abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}, unsubscribeTokens.Headers("Tech", "abc"))
+	assert.Equal(t, map[string]string{
+		"List-Unsubscribe": "<mailto:unsubscribe@example.com?subject=unsubscribe%20abc>",
+	}, unsubscribeTokens.Headers("Local", "abc"))
+}
+
+// newTestConfirmations requires double opt-in for the given categories.
+func newTestConfirmations(t *testing.T, doubleOptInCategories ...string) *service.SubscriptionConfirmations {
+	return service.NewSubscriptionConfirmations(newTestSigner(t), newTestPublicURLs(t), time.Hour, doubleOptInCategories)
+}
+
 func TestSubscribe(t *testing.T) {
 	mockRepo := new(MockSubscriberRepository)
-	subscriberService := service.NewSubscriberService(mockRepo)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), newEmptySuppressions())
 
 	subscriber := domain.Subscriber{
 		Email:            "test@example.com",
 		SubscriptionDate: time.Now(),
 		Category:         "Tech",
+		Attributes:       map[string]string{"first_name": "Ada"},
 	}
 
-	mockRepo.On("SaveSubscriber", subscriber).Return(nil)
+	mockRepo.On("SaveSubscriber", mock.MatchedBy(func(saved domain.Subscriber) bool {
+		return saved.Email == subscriber.Email &&
+			saved.Category == subscriber.Category &&
+			saved.Status == domain.SubscriberActive &&
+			saved.Attributes["first_name"] == "Ada" &&
+			!saved.SubscriptionDate.Before(subscriber.SubscriptionDate)
+	})).Return(nil)
 
-	err := subscriberService.Subscribe(subscriber.Email, subscriber.Category)
+	err := subscriberService.Subscribe(subscriber.Email, subscriber.Category, subscriber.Attributes)
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestUnsubscribe(t *testing.T) {
 	mockRepo := new(MockSubscriberRepository)
-	subscriberService := service.NewSubscriberService(mockRepo)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), newEmptySuppressions())
 
 	mockRepo.On("DeleteSubscriberByEmail", "test@example.com", "Tech").Return(nil)
 
@@ -71,7 +140,7 @@ func TestUnsubscribe(t *testing.T) {
 
 func TestGetSubscriberByEmail(t *testing.T) {
 	mockRepo := new(MockSubscriberRepository)
-	subscriberService := service.NewSubscriberService(mockRepo)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), newEmptySuppressions())
 
 	subscriber := &domain.Subscriber{
 		Email:            "test@example.com",
@@ -89,7 +158,7 @@ func TestGetSubscriberByEmail(t *testing.T) {
 
 func TestGetSubscribers(t *testing.T) {
 	mockRepo := new(MockSubscriberRepository)
-	subscriberService := service.NewSubscriberService(mockRepo)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), newEmptySuppressions())
 
 	subscribers := []domain.Subscriber{
 		{Email: "test1@example.com", Category: "Tech"},
@@ -103,3 +172,158 @@ func TestGetSubscribers(t *testing.T) {
 	assert.Equal(t, subscribers, result)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestUnsubscribeByToken(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+
+	subscriber := &domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech"}
+	unsubscribeToken, err := unsubscribeTokens.Issue(*subscriber)
+	require.NoError(t, err)
+
+	mockRepo.On("GetSubscriberByID", subscriber.ID.Hex()).Return(subscriber, nil)
+	mockRepo.On("DeleteSubscriberByEmail", "test@example.com", "Tech").Return(nil)
+
+	err = subscriberService.UnsubscribeByToken(unsubscribeToken)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUnsubscribeByTokenRejectsTamperedTokens(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+
+	unsubscribeToken, err := unsubscribeTokens.Issue(domain.Subscriber{ID: primitive.NewObjectID(), Category: "Tech"})
+	require.NoError(t, err)
+
+	err = subscriberService.UnsubscribeByToken(unsubscribeToken + "x")
+	assert.ErrorIs(t, err, service.ErrInvalidUnsubscribeToken)
+	mockRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+}
+
+func TestUnsubscribeByTokenIgnoresUnknownSubscribers(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+
+	subscriberID := primitive.NewObjectID()
+	unsubscribeToken, err := unsubscribeTokens.Issue(domain.Subscriber{ID: subscriberID, Category: "Tech"})
+	require.NoError(t, err)
+
+	mockRepo.On("GetSubscriberByID", subscriberID.Hex()).Return(nil, nil)
+
+	err = subscriberService.UnsubscribeByToken(unsubscribeToken)
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+}
+
+func TestSubscribeWithDoubleOptInSendsConfirmation(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockEmailSender := new(MockEmailSender)
+	confirmations := newTestConfirmations(t, "Tech")
+	subscriberService := service.NewSubscriberService(mockRepo, mockEmailSender, newTestUnsubscribeTokens(t), confirmations, newEmptySuppressions())
+
+	var saved domain.Subscriber
+	mockRepo.On("GetSubscriberByEmailAndCategory", "test@example.com", "Tech").Return(nil, errors.New("not found"))
+	mockRepo.On("SaveSubscriber", mock.Anything).
+		Run(func(args mock.Arguments) { saved = args.Get(0).(domain.Subscriber) }).
+		Return(nil)
+
+	var message domain.EmailMessage
+	mockEmailSender.On("Send", messageTo("test@example.com")).
+		Run(func(args mock.Arguments) { message = args.Get(0).(domain.EmailMessage) }).
+		Return(nil)
+
+	err := subscriberService.Subscribe("test@example.com", "Tech", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, domain.SubscriberPending, saved.Status)
+	assert.False(t, saved.ID.IsZero())
+	require.NotNil(t, saved.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *saved.ExpiresAt, time.Minute)
+
+	prefix := `<a href="https://api.example.com/api/v1/confirm/`
+	start := strings.Index(message.Body, prefix)
+	require.GreaterOrEqual(t, start, 0)
+	confirmationToken := message.Body[start+len(prefix):]
+	confirmationToken = confirmationToken[:strings.Index(confirmationToken, `"`)]
+	subscriberID, err := confirmations.Verify(confirmationToken)
+	require.NoError(t, err)
+	assert.Equal(t, saved.ID.Hex(), subscriberID)
+}
+
+func TestSubscribeRemovesThePendingSubscriberWhenTheConfirmationFails(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockEmailSender := new(MockEmailSender)
+	subscriberService := service.NewSubscriberService(mockRepo, mockEmailSender, newTestUnsubscribeTokens(t), newTestConfirmations(t, "Tech"), newEmptySuppressions())
+
+	mockRepo.On("GetSubscriberByEmailAndCategory", "test@example.com", "Tech").Return(nil, errors.New("not found"))
+	mockRepo.On("SaveSubscriber", mock.Anything).Return(nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).Return(errors.New("connection refused"))
+	mockRepo.On("DeleteSubscriberByEmail", "test@example.com", "Tech").Return(nil)
+
+	err := subscriberService.Subscribe("test@example.com", "Tech", nil)
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSubscribeWhilePendingResendsConfirmation(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockEmailSender := new(MockEmailSender)
+	subscriberService := service.NewSubscriberService(mockRepo, mockEmailSender, newTestUnsubscribeTokens(t), newTestConfirmations(t, "*"), newEmptySuppressions())
+
+	pending := &domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech", Status: domain.SubscriberPending}
+	mockRepo.On("GetSubscriberByEmailAndCategory", "test@example.com", "Tech").Return(pending, nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).Return(nil)
+
+	err := subscriberService.Subscribe("test@example.com", "Tech", nil)
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "SaveSubscriber", mock.Anything)
+	mockEmailSender.AssertExpectations(t)
+}
+
+func TestConfirmSubscription(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	confirmations := newTestConfirmations(t, "Tech")
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), confirmations, newEmptySuppressions())
+
+	pending := &domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech", Status: domain.SubscriberPending}
+	confirmationToken, err := confirmations.Issue(*pending)
+	require.NoError(t, err)
+
+	mockRepo.On("GetSubscriberByID", pending.ID.Hex()).Return(pending, nil)
+	mockRepo.On("ConfirmSubscriber", pending.ID).Return(nil)
+
+	assert.NoError(t, subscriberService.ConfirmSubscription(confirmationToken))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmSubscriptionOfRemovedSubscriberHasExpired(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	confirmations := newTestConfirmations(t, "Tech")
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), confirmations, newEmptySuppressions())
+
+	subscriberID := primitive.NewObjectID()
+	confirmationToken, err := confirmations.Issue(domain.Subscriber{ID: subscriberID, Category: "Tech"})
+	require.NoError(t, err)
+
+	mockRepo.On("GetSubscriberByID", subscriberID.Hex()).Return(nil, nil)
+
+	err = subscriberService.ConfirmSubscription(confirmationToken)
+	assert.ErrorIs(t, err, service.ErrExpiredConfirmationToken)
+}
+
+func TestConfirmSubscriptionRejectsUnsubscribeTokens(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t, "Tech"), newEmptySuppressions())
+
+	unsubscribeToken, err := unsubscribeTokens.Issue(domain.Subscriber{ID: primitive.NewObjectID(), Category: "Tech"})
+	require.NoError(t, err)
+
+	err = subscriberService.ConfirmSubscription(unsubscribeToken)
+	assert.ErrorIs(t, err, service.ErrInvalidConfirmationToken)
+	mockRepo.AssertNotCalled(t, "ConfirmSubscriber", mock.Anything)
+}
@@ -0,0 +1,300 @@
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/Dtos/request"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MockSuppressionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSuppressionRepository) SaveSuppressions(suppressions []domain.Suppression) error {
+	args := m.Called(suppressions)
+	return args.Error(0)
+}
+
+func (m *MockSuppressionRepository) FindSuppressions(addressHashes []string, category string) ([]domain.Suppression, error) {
+	args := m.Called(addressHashes, category)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Suppression), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSuppressionRepository) GetSuppressions(filter domain.SuppressionFilter, page, pageSize int) ([]domain.Suppression, int64, error) {
+	args := m.Called(filter, page, pageSize)
+	if args.Get(0) != nil {
+		return args.Get(0).([]domain.Suppression), args.Get(1).(int64), args.Error(2)
+	}
+	return nil, 0, args.Error(2)
+}
+
+func (m *MockSuppressionRepository) DeleteSuppressions(addressHash, category string) (int64, error) {
+	args := m.Called(addressHash, category)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSuppressionRepository) EachSuppression(fn func(domain.Suppression) error) error {
+	args := m.Called(fn)
+	return args.Error(0)
+}
+
+// newEmptySuppressions returns a suppression list that suppresses nothing and
+// accepts any new suppression.
+func newEmptySuppressions() *service.SuppressionService {
+	mockRepo := new(MockSuppressionRepository)
+	mockRepo.On("FindSuppressions", mock.Anything, mock.Anything).Return([]domain.Suppression{}, nil).Maybe()
+	mockRepo.On("SaveSuppressions", mock.Anything).Return(nil).Maybe()
+	return service.NewSuppressionService(mockRepo, nil)
+}
+
+func sha256Hex(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashAddressNormalizesTheAddress(t *testing.T) {
+	suppressions := service.NewSuppressionService(new(MockSuppressionRepository), nil)
+
+	assert.Equal(t, sha256Hex("test@example.com"), suppressions.HashAddress(" Test@Example.com "))
+}
+
+func TestHashAddressWithAKeyUsesHMAC(t *testing.T) {
+	keyed := service.NewSuppressionService(new(MockSuppressionRepository), []byte("secret"))
+	otherKey := service.NewSuppressionService(new(MockSuppressionRepository), []byte("other"))
+
+	hash := keyed.HashAddress("test@example.com")
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, sha256Hex("test@example.com"), hash)
+	assert.NotEqual(t, otherKey.HashAddress("test@example.com"), hash)
+	assert.Equal(t, hash, keyed.HashAddress("TEST@example.com"))
+}
+
+func TestSuppressionServiceFromEnvRequiresTheHashKey(t *testing.T) {
+	t.Setenv("suppressionHashKey", "")
+	_, err := service.NewSuppressionServiceFromEnv(new(MockSuppressionRepository))
+	assert.Error(t, err)
+
+	t.Setenv("suppressionHashKey", "secret")
+	suppressions, err := service.NewSuppressionServiceFromEnv(new(MockSuppressionRepository))
+	require.NoError(t, err)
+	assert.Equal(t, service.NewSuppressionService(nil, []byte("secret")).HashAddress("test@example.com"), suppressions.HashAddress("test@example.com"))
+}
+
+func TestSubscribeRejectsSuppressedAddresses(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), suppressions)
+
+	mockSuppressionRepo.On("FindSuppressions", []string{sha256Hex("test@example.com")}, "Tech").
+		Return([]domain.Suppression{{AddressHash: sha256Hex("test@example.com"), Reason: domain.SuppressionHardBounce}}, nil)
+
+	err := subscriberService.Subscribe("test@example.com", "Tech", nil)
+	assert.ErrorIs(t, err, service.ErrSubscriberSuppressed)
+	mockRepo.AssertNotCalled(t, "SaveSubscriber", mock.Anything)
+}
+
+func TestSubscribeAfterUnsubscribingNeedsDoubleOptIn(t *testing.T) {
+	unsubscribed := []domain.Suppression{{AddressHash: sha256Hex("test@example.com"), Category: "Tech", Reason: domain.SuppressionUnsubscribed}}
+
+	mockRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), suppressions)
+	mockSuppressionRepo.On("FindSuppressions", mock.Anything, "Tech").Return(unsubscribed, nil)
+
+	assert.ErrorIs(t, subscriberService.Subscribe("test@example.com", "Tech", nil), service.ErrSubscriberSuppressed)
+
+	mockEmailSender := new(MockEmailSender)
+	subscriberService = service.NewSubscriberService(mockRepo, mockEmailSender, newTestUnsubscribeTokens(t), newTestConfirmations(t, "Tech"), suppressions)
+	mockRepo.On("GetSubscriberByEmailAndCategory", "test@example.com", "Tech").Return(nil, nil)
+	mockRepo.On("SaveSubscriber", mock.MatchedBy(func(saved domain.Subscriber) bool {
+		return saved.Status == domain.SubscriberPending
+	})).Return(nil)
+	mockEmailSender.On("Send", messageTo("test@example.com")).Return(nil)
+
+	assert.NoError(t, subscriberService.Subscribe("test@example.com", "Tech", nil))
+	mockRepo.AssertExpectations(t)
+	mockEmailSender.AssertExpectations(t)
+}
+
+func TestUnsubscribeSuppressesTheAddressInTheCategory(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), newTestConfirmations(t), suppressions)
+
+	mockRepo.On("DeleteSubscriberByEmail", "test@example.com", "Tech").Return(nil)
+	mockSuppressionRepo.On("SaveSuppressions", []domain.Suppression{{
+		AddressHash: sha256Hex("test@example.com"),
+		Category:    "Tech",
+		Reason:      domain.SuppressionUnsubscribed,
+		Source:      service.SuppressionSourceUnsubscribe,
+	}}).Return(nil)
+
+	assert.NoError(t, subscriberService.Unsubscribe("test@example.com", "Tech"))
+	mockSuppressionRepo.AssertExpectations(t)
+}
+
+func TestConfirmSubscriptionLiftsTheUnsubscribeSuppression(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	confirmations := newTestConfirmations(t, "Tech")
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), newTestUnsubscribeTokens(t), confirmations, suppressions)
+
+	pending := &domain.Subscriber{ID: primitive.NewObjectID(), Email: "test@example.com", Category: "Tech", Status: domain.SubscriberPending}
+	confirmationToken, err := confirmations.Issue(*pending)
+	require.NoError(t, err)
+
+	hash := sha256Hex("test@example.com")
+	mockRepo.On("GetSubscriberByID", pending.ID.Hex()).Return(pending, nil)
+	mockRepo.On("ConfirmSubscriber", pending.ID).Return(nil)
+	mockSuppressionRepo.On("FindSuppressions", []string{hash}, "Tech").
+		Return([]domain.Suppression{{AddressHash: hash, Category: "Tech", Reason: domain.SuppressionUnsubscribed}}, nil)
+	mockSuppressionRepo.On("DeleteSuppressions", hash, "Tech").Return(int64(1), nil)
+
+	assert.NoError(t, subscriberService.ConfirmSubscription(confirmationToken))
+	mockSuppressionRepo.AssertExpectations(t)
+}
+
+func TestProcessJobSkipsSuppressedSubscribers(t *testing.T) {
+	mockSendJobRepo := new(MockSendJobRepository)
+	mockNewsletterRepo := new(MockNewsletterRepository)
+	mockSubscriberRepo := new(MockSubscriberRepository)
+	mockDeliveryRepo := new(MockDeliveryRepository)
+	mockEmailSender := new(MockEmailSender)
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+	pool := service.NewSendWorkerPool(mockSendJobRepo, mockNewsletterRepo, mockSubscriberRepo, mockDeliveryRepo, new(MockAttachmentRepository), mockEmailSender, newTestUnsubscribeTokens(t), newTestPublicURLs(t), suppressions, newTestBounceTracking(), newTestOpenTracking(t, false), 1)
+
+	newsletter := &domain.Newsletter{ID: primitive.NewObjectID(), Category: "Tech", Subject: "News", Content: "Hello"}
+	bounced := domain.Subscriber{ID: primitive.NewObjectID(), Email: "bounced@example.com", Category: "Tech"}
+	active := domain.Subscriber{ID: primitive.NewObjectID(), Email: "active@example.com", Category: "Tech"}
+	job := &domain.SendJob{ID: primitive.NewObjectID(), NewsletterID: newsletter.ID.Hex(), Status: domain.SendJobRunning}
+
+	mockNewsletterRepo.On("GetNewsletterByID", newsletter.ID.Hex()).Return(newsletter, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", primitive.NilObjectID, mock.Anything).Return([]domain.Subscriber{bounced, active}, nil)
+	mockSubscriberRepo.On("GetSubscribersByCategoryAfter", "Tech", active.ID, mock.Anything).Return([]domain.Subscriber{}, nil)
+	mockSuppressionRepo.On("FindSuppressions", []string{sha256Hex("bounced@example.com"), sha256Hex("active@example.com")}, "Tech").
+		Return([]domain.Suppression{{AddressHash: sha256Hex("bounced@example.com"), Reason: domain.SuppressionHardBounce}}, nil)
+	mockEmailSender.On("Send", messageTo("active@example.com")).Return(nil)
+	mockSendJobRepo.On("UpdateSendJob", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("QueueDelivery", mock.Anything).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), bounced.ID, domain.DeliverySuppressed, "suppressed: hard_bounce", 0).Return(nil)
+	mockDeliveryRepo.On("UpdateDeliveryStatus", newsletter.ID.Hex(), active.ID, domain.DeliverySent, "", 1).Return(nil)
+
+	require.NoError(t, pool.ProcessJob(job))
+	assert.Equal(t, domain.SendJobCompleted, job.Status)
+	assert.Equal(t, 1, job.Sent)
+	assert.Equal(t, 1, job.Suppressed)
+	mockEmailSender.AssertNumberOfCalls(t, "Send", 1)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestImportSuppressionsSavesInBatches(t *testing.T) {
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+
+	suppressionRequests := make([]request.SuppressionRequest, 1500)
+	for i := range suppressionRequests {
+		suppressionRequests[i] = request.SuppressionRequest{AddressHash: sha256Hex(string(rune('a' + i%26)))}
+	}
+	suppressionRequests[0] = request.SuppressionRequest{Email: "Test@Example.com", Category: "Tech", Reason: domain.SuppressionComplaint}
+
+	var batches [][]domain.Suppression
+	mockSuppressionRepo.On("SaveSuppressions", mock.Anything).
+		Run(func(args mock.Arguments) { batches = append(batches, args.Get(0).([]domain.Suppression)) }).
+		Return(nil)
+
+	imported, err := suppressions.ImportSuppressions(suppressionRequests)
+	require.NoError(t, err)
+	assert.Equal(t, 1500, imported.Imported)
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 1000)
+	assert.Len(t, batches[1], 500)
+	assert.Equal(t, domain.Suppression{
+		AddressHash: sha256Hex("test@example.com"),
+		Category:    "Tech",
+		Reason:      domain.SuppressionComplaint,
+		Source:      service.SuppressionSourceImport,
+	}, batches[0][0])
+	assert.Equal(t, domain.SuppressionManual, batches[1][0].Reason)
+}
+
+func TestSuppressionDetailsDoNotHoldAddresses(t *testing.T) {
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+
+	var saved []domain.Suppression
+	mockSuppressionRepo.On("SaveSuppressions", mock.Anything).
+		Run(func(args mock.Arguments) { saved = append(saved, args.Get(0).([]domain.Suppression)...) }).
+		Return(nil)
+
+	require.NoError(t, suppressions.Suppress("ana@example.com", "", domain.SuppressionHardBounce, service.SuppressionSourceBounce,
+		"5.1.1 550 5.1.1 <Ana@Example.com>: Recipient address rejected (rfc822;ana@example.com, forwarded to ana.b@mail.example.net)"))
+	_, err := suppressions.ImportSuppressions([]request.SuppressionRequest{{Email: "ana@example.com", Detail: "asked by ana@example.com"}})
+	require.NoError(t, err)
+
+	require.Len(t, saved, 2)
+	assert.Equal(t, "5.1.1 550 5.1.1 <[address]>: Recipient address rejected (rfc822;[address], forwarded to [address])", saved[0].Detail)
+	assert.Equal(t, "asked by [address]", saved[1].Detail)
+	for _, suppression := range saved {
+		assert.NotContains(t, suppression.Detail, "@")
+	}
+}
+
+func TestImportSuppressionsRejectsInvalidEntries(t *testing.T) {
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+
+	_, err := suppressions.ImportSuppressions([]request.SuppressionRequest{{Email: "test@example.com"}, {Email: "not an address"}})
+	assert.ErrorIs(t, err, service.ErrInvalidSuppression)
+	assert.ErrorContains(t, err, "entry 2")
+
+	_, err = suppressions.ImportSuppressions([]request.SuppressionRequest{{Email: "test@example.com", Reason: "bored"}})
+	assert.ErrorIs(t, err, service.ErrInvalidSuppressionReason)
+
+	mockSuppressionRepo.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestGetSuppressionsLooksUpAnAddressByItsHash(t *testing.T) {
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+
+	filter := domain.SuppressionFilter{AddressHash: sha256Hex("test@example.com"), Reason: domain.SuppressionHardBounce}
+	found := []domain.Suppression{{AddressHash: filter.AddressHash, Reason: domain.SuppressionHardBounce}}
+	mockSuppressionRepo.On("GetSuppressions", filter, 1, 100).Return(found, int64(1), nil)
+
+	result, err := suppressions.GetSuppressions("test@example.com", "", domain.SuppressionHardBounce, 0, 500)
+	require.NoError(t, err)
+	assert.Equal(t, found, result.Suppressions)
+	assert.Equal(t, 1, result.Page)
+	assert.Equal(t, 100, result.PageSize)
+	assert.Equal(t, int64(1), result.Total)
+}
+
+func TestDeleteSuppressions(t *testing.T) {
+	mockSuppressionRepo := new(MockSuppressionRepository)
+	suppressions := service.NewSuppressionService(mockSuppressionRepo, nil)
+
+	hash := sha256Hex("test@example.com")
+	mockSuppressionRepo.On("DeleteSuppressions", hash, "").Return(int64(2), nil)
+	mockSuppressionRepo.On("DeleteSuppressions", hash, "Tech").Return(int64(0), nil)
+
+	assert.NoError(t, suppressions.DeleteSuppressions(hash, ""))
+	assert.ErrorIs(t, suppressions.DeleteSuppressions("test@example.com", "Tech"), service.ErrSuppressionNotFound)
+	assert.ErrorIs(t, suppressions.DeleteSuppressions("nobody", ""), service.ErrInvalidSuppression)
+}
@@ -0,0 +1,81 @@
+package service_test
+
+import (
+	"errors"
+	"mime"
+	"testing"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func unsubscribeMail(subject string) []byte {
+	return []byte("From: Ana <ana@example.com>\r\n" +
+		"To: unsubscribe@example.com\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"\r\n" +
+		"Please remove me.\r\n")
+}
+
+func TestProcessUnsubscribeMailUnsubscribesTheTokenInTheSubject(t *testing.T) {
+	for name, subject := range map[string]func(token string) string{
+		"plain":   func(token string) string { return "unsubscribe " + token },
+		"reply":   func(token string) string { return "Re: Unsubscribe " + token },
+		"encoded": func(token string) string { return mime.QEncoding.Encode("utf-8", "unsubscribe "+token+" ✓") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockSubscriberRepository)
+			unsubscribeTokens := newTestUnsubscribeTokens(t)
+			subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+			processor := service.NewUnsubscribeMailProcessor(nil, subscriberService, 0)
+
+			subscriber := &domain.Subscriber{ID: primitive.NewObjectID(), Email: "ana@example.com", Category: "Tech"}
+			unsubscribeToken, err := unsubscribeTokens.Issue(*subscriber)
+			require.NoError(t, err)
+
+			mockRepo.On("GetSubscriberByID", subscriber.ID.Hex()).Return(subscriber, nil)
+			mockRepo.On("DeleteSubscriberByEmail", "ana@example.com", "Tech").Return(nil).Once()
+
+			require.NoError(t, processor.ProcessMessage(unsubscribeMail(subject(unsubscribeToken))))
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProcessUnsubscribeMailSkipsMailWithoutAValidToken(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+	processor := service.NewUnsubscribeMailProcessor(nil, subscriberService, 0)
+
+	unsubscribeToken, err := unsubscribeTokens.Issue(domain.Subscriber{ID: primitive.NewObjectID(), Category: "Tech"})
+	require.NoError(t, err)
+
+	assert.NoError(t, processor.ProcessMessage(unsubscribeMail("unsubscribe "+unsubscribeToken+"x")))
+	assert.NoError(t, processor.ProcessMessage(unsubscribeMail("unsubscribe ana@example.com")))
+	assert.NoError(t, processor.ProcessMessage(unsubscribeMail("Out of office")))
+	assert.NoError(t, processor.ProcessMessage([]byte("not a message")))
+	mockRepo.AssertNotCalled(t, "GetSubscriberByID", mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteSubscriberByEmail", mock.Anything, mock.Anything)
+}
+
+func TestProcessUnsubscribeMailReturnsStorageErrors(t *testing.T) {
+	mockRepo := new(MockSubscriberRepository)
+	unsubscribeTokens := newTestUnsubscribeTokens(t)
+	subscriberService := service.NewSubscriberService(mockRepo, new(MockEmailSender), unsubscribeTokens, newTestConfirmations(t), newEmptySuppressions())
+	processor := service.NewUnsubscribeMailProcessor(nil, subscriberService, 0)
+
+	subscriberID := primitive.NewObjectID()
+	unsubscribeToken, err := unsubscribeTokens.Issue(domain.Subscriber{ID: subscriberID, Category: "Tech"})
+	require.NoError(t, err)
+
+	dbErr := errors.New("connection refused")
+	mockRepo.On("GetSubscriberByID", subscriberID.Hex()).Return(nil, dbErr)
+
+	assert.ErrorIs(t, processor.ProcessMessage(unsubscribeMail("unsubscribe "+unsubscribeToken)), dbErr)
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	domain "newsletter-app/pkg/domain/models"
+	"newsletter-app/pkg/service"
+	"newsletter-app/pkg/service/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const webhookNewsletterID = "6610aa01e4b0a1b2c3d4f000"
+
+// readWebhookSample returns a recorded webhook request of tests/webhook.
+func readWebhookSample(t *testing.T, name string) (http.Header, []byte) {
+	body, err := os.ReadFile(filepath.Join("..", "webhook", "testdata", name))
+	require.NoError(t, err)
+
+	rawHeaders, err := os.ReadFile(filepath.Join("..", "webhook", "testdata", "headers.json"))
+	require.NoError(t, err)
+	var headers map[string]map[string]string
+	require.NoError(t, json.Unmarshal(rawHeaders, &headers))
+
+	header := http.Header{}
+	for name, value := range headers[name] {
+		header.Set(name, value)
+	}
+	return header, body
+}
+
+func newTestWebhookProviders(t *testing.T) map[string]webhook.Provider {
+	publicKey, err := os.ReadFile(filepath.Join("..", "webhook", "testdata", "sendgrid_public_key.txt"))
+	require.NoError(t, err)
+	sendGrid, err := webhook.NewSendGrid(string(publicKey))
+	require.NoError(t, err)
+	sendGrid.Now = func() time.Time { return time.Unix(1712048003, 0) }
+	return map[string]webhook.Provider{
+		webhook.ProviderSendGrid: sendGrid,
+		webhook.ProviderPostmark: webhook.NewPostmark("postmark", "webhook-password"),
+	}
+}
+
+type webhookServiceMocks struct {
+	deliveries   *MockDeliveryRepository
+	subscribers  *MockSubscriberRepository
+	suppressions *MockSuppressionRepository
+}
+
+func newTestWebhookService(t *testing.T) (*service.WebhookService, webhookServiceMocks) {
+	mocks := webhookServiceMocks{
+		deliveries:   new(MockDeliveryRepository),
+		subscribers:  new(MockSubscriberRepository),
+		suppressions: new(MockSuppressionRepository),
+	}
+	suppressions := service.NewSuppressionService(mocks.suppressions, nil)
+	tracking := newTestBounceTracking()
+	webhookService := service.NewWebhookService(
+		newTestWebhookProviders(t),
+		mocks.deliveries,
+		service.NewBounceProcessor(nil, mocks.deliveries, suppressions, tracking, 0),
+		service.NewComplaintService(nil, mocks.deliveries, mocks.subscribers, suppressions, tracking, 0),
+		tracking,
+	)
+	return webhookService, mocks
+}
+
+func TestHandleSendGridWebhookAppliesEveryEvent(t *testing.T) {
+	webhookService, mocks := newTestWebhookService(t)
+
+	reader := mustObjectID(t, "6610aa01e4b0a1b2c3d4f002")
+	mocks.deliveries.On("RecordDelivered", webhookNewsletterID, reader, time.Unix(1712046675, 0).UTC()).Return(nil)
+	mocks.deliveries.On("RecordOpen", webhookNewsletterID, reader, domain.Open{
+		UserAgentClass: domain.UserAgentDesktop,
+		ReportedBy:     webhook.ProviderSendGrid,
+		OpenedAt:       time.Unix(1712047200, 0).UTC(),
+	}).Return(nil)
+
+	mocks.deliveries.On("RecordBounce", webhookNewsletterID, mustObjectID(t, "6610aa01e4b0a1b2c3d4f001"), mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceHard &&
+			bounce.Recipient == "gone@example.org" &&
+			bounce.Status == "5.1.1" &&
+			bounce.ReportedBy == webhook.ProviderSendGrid
+	})).Return(&domain.Delivery{Email: "gone@example.org"}, nil)
+	mocks.deliveries.On("RecordBounce", webhookNewsletterID, mustObjectID(t, "6610aa01e4b0a1b2c3d4f003"), mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceSoft && bounce.Status == "5.7.1"
+	})).Return(&domain.Delivery{Email: "strict@example.net"}, nil)
+	mocks.deliveries.On("RecordBounce", webhookNewsletterID, mustObjectID(t, "6610aa01e4b0a1b2c3d4f004"), mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceSoft && bounce.Status == "4.7.1"
+	})).Return(&domain.Delivery{Email: "slow@example.net"}, nil)
+	mocks.suppressions.On("SaveSuppressions", mock.MatchedBy(func(suppressions []domain.Suppression) bool {
+		return len(suppressions) == 1 &&
+			suppressions[0].AddressHash == sha256Hex("gone@example.org") &&
+			suppressions[0].Reason == domain.SuppressionHardBounce &&
+			suppressions[0].Source == service.SuppressionSourceBounce
+	})).Return(nil).Once()
+
+	mocks.deliveries.On("RecordComplaint", webhookNewsletterID, mustObjectID(t, "6610aa01e4b0a1b2c3d4f005"), mock.MatchedBy(func(complaint domain.Complaint) bool {
+		return complaint.FeedbackType == "abuse" && complaint.ReportedBy == webhook.ProviderSendGrid
+	})).Return(&domain.Delivery{Email: "annoyed@example.com"}, nil)
+	mocks.subscribers.On("DeleteSubscriberByEmail", "annoyed@example.com", "").Return(nil)
+	mocks.suppressions.On("SaveSuppressions", isComplaintSuppression("annoyed@example.com")).Return(nil).Once()
+
+	header, body := readWebhookSample(t, "sendgrid_events.json")
+	result, err := webhookService.HandleWebhook(webhook.ProviderSendGrid, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, 6, result.Events)
+	mocks.deliveries.AssertExpectations(t)
+	mocks.subscribers.AssertExpectations(t)
+	mocks.suppressions.AssertExpectations(t)
+}
+
+func TestHandleWebhookOnlyRecordsUnmatchedHardBounces(t *testing.T) {
+	webhookService, mocks := newTestWebhookService(t)
+
+	header, body := readWebhookSample(t, "postmark_bounce.json")
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	delete(payload, "Metadata")
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	mocks.deliveries.On("RecordUnmatchedBounce", mock.MatchedBy(func(bounce domain.Bounce) bool {
+		return bounce.Type == domain.BounceHard &&
+			bounce.Recipient == payload["Email"].(string) &&
+			bounce.ReportedBy == webhook.ProviderPostmark
+	})).Return(nil)
+
+	result, err := webhookService.HandleWebhook(webhook.ProviderPostmark, header, body)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Events)
+	mocks.deliveries.AssertNotCalled(t, "RecordBounce", mock.Anything, mock.Anything, mock.Anything)
+	mocks.deliveries.AssertExpectations(t)
+	mocks.suppressions.AssertNotCalled(t, "SaveSuppressions", mock.Anything)
+}
+
+func TestHandleWebhookRejectsUnknownProviders(t *testing.T) {
+	webhookService, _ := newTestWebhookService(t)
+
+	_, err := webhookService.HandleWebhook(webhook.ProviderMailerSend, http.Header{}, []byte(`{}`))
+	assert.True(t, errors.Is(err, service.ErrUnknownWebhookProvider))
+}
+
+func TestHandleWebhookRejectsBadSignatures(t *testing.T) {
+	webhookService, mocks := newTestWebhookService(t)
+
+	header, body := readWebhookSample(t, "sendgrid_events.json")
+	body = append(body, ' ')
+	_, err := webhookService.HandleWebhook(webhook.ProviderSendGrid, header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+	mocks.deliveries.AssertNotCalled(t, "RecordDelivered", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,100 @@
+package token_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/service/token"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldSecret = []byte("0123456789abcdef0123456789abcdef")
+	newSecret = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer, err := token.NewSigner("k1", map[string][]byte{"k1": oldSecret})
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(token.Claims{Purpose: "unsubscribe", Subject: "abc", Category: "Tech"}, time.Hour)
+	require.NoError(t, err)
+
+	claims, err := signer.Verify(signed, "unsubscribe")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", claims.Subject)
+	assert.Equal(t, "Tech", claims.Category)
+}
+
+func TestVerifyAcceptsTokensSignedWithRotatedKeys(t *testing.T) {
+	oldSigner, err := token.NewSigner("k1", map[string][]byte{"k1": oldSecret})
+	require.NoError(t, err)
+	signed, err := oldSigner.Sign(token.Claims{Purpose: "unsubscribe", Subject: "abc"}, time.Hour)
+	require.NoError(t, err)
+
+	rotated, err := token.NewSigner("k2", map[string][]byte{"k1": oldSecret, "k2": newSecret})
+	require.NoError(t, err)
+	_, err = rotated.Verify(signed, "unsubscribe")
+	assert.NoError(t, err)
+
+	retired, err := token.NewSigner("k2", map[string][]byte{"k2": newSecret})
+	require.NoError(t, err)
+	_, err = retired.Verify(signed, "unsubscribe")
+	assert.ErrorIs(t, err, token.ErrInvalidToken)
+}
+
+func TestVerifyRejectsOtherPurposes(t *testing.T) {
+	signer, err := token.NewSigner("k1", map[string][]byte{"k1": oldSecret})
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(token.Claims{Purpose: "confirm", Subject: "abc"}, time.Hour)
+	require.NoError(t, err)
+
+	_, err = signer.Verify(signed, "unsubscribe")
+	assert.ErrorIs(t, err, token.ErrInvalidToken)
+}
+
+func TestVerifyRejectsExpiredTokens(t *testing.T) {
+	signer, err := token.NewSigner("k1", map[string][]byte{"k1": oldSecret})
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(token.Claims{Purpose: "unsubscribe", Subject: "abc"}, -time.Hour)
+	require.NoError(t, err)
+	_, err = signer.Verify(signed, "unsubscribe")
+	assert.NoError(t, err, "a non-positive ttl means the token does not expire")
+
+	signed, err = signer.Sign(token.Claims{Purpose: "unsubscribe", Subject: "abc", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, 0)
+	require.NoError(t, err)
+	_, err = signer.Verify(signed, "unsubscribe")
+	assert.ErrorIs(t, err, token.ErrExpiredToken)
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	signer, err := token.NewSigner("k1", map[string][]byte{"k1": oldSecret})
+	require.NoError(t, err)
+
+	signed, err := signer.Sign(token.Claims{Purpose: "unsubscribe", Subject: "abc"}, time.Hour)
+	require.NoError(t, err)
+
+	other, err := signer.Sign(token.Claims{Purpose: "unsubscribe", Subject: "xyz"}, time.Hour)
+	require.NoError(t, err)
+
+	parts := strings.Split(signed, ".")
+	otherParts := strings.Split(other, ".")
+	for _, tampered := range []string{
+		otherParts[0] + "." + parts[1] + "." + parts[2],
+		signed[:len(signed)-2],
+		"not-a-token",
+	} {
+		_, err := signer.Verify(tampered, "unsubscribe")
+		assert.ErrorIs(t, err, token.ErrInvalidToken)
+	}
+}
+
+func TestNewSignerRejectsShortKeys(t *testing.T) {
+	_, err := token.NewSigner("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+}
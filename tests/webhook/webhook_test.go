@@ -0,0 +1,328 @@
+package webhook_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"newsletter-app/pkg/service/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mailerSendSecret = "mailersend-signing-secret"
+	snsTopicARN      = "arn:aws:sns:eu-west-1:123456789012:newsletter-ses-events"
+)
+
+// readSample returns a recorded webhook request: its body, and its headers
+// from testdata/headers.json.
+func readSample(t *testing.T, name string) (http.Header, []byte) {
+	body, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+
+	rawHeaders, err := os.ReadFile(filepath.Join("testdata", "headers.json"))
+	require.NoError(t, err)
+	var headers map[string]map[string]string
+	require.NoError(t, json.Unmarshal(rawHeaders, &headers))
+
+	header := http.Header{}
+	for name, value := range headers[name] {
+		header.Set(name, value)
+	}
+	return header, body
+}
+
+func newTestSendGrid(t *testing.T) *webhook.SendGrid {
+	publicKey, err := os.ReadFile(filepath.Join("testdata", "sendgrid_public_key.txt"))
+	require.NoError(t, err)
+	sendGrid, err := webhook.NewSendGrid(string(publicKey))
+	require.NoError(t, err)
+	sendGrid.Now = func() time.Time { return time.Unix(1712048003, 0).Add(time.Minute) }
+	return sendGrid
+}
+
+// fakeSNS answers the requests of the SES provider as SNS would: it serves
+// the signing certificate and records subscription confirmations.
+type fakeSNS struct {
+	requests []string
+}
+
+func (f *fakeSNS) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests = append(f.requests, req.URL.String())
+	body := []byte("<ConfirmSubscriptionResponse/>")
+	if strings.HasSuffix(req.URL.Path, ".pem") {
+		certificate, err := os.ReadFile(filepath.Join("testdata", "sns_signing_cert.pem"))
+		if err != nil {
+			return nil, err
+		}
+		body = certificate
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Header: http.Header{}}, nil
+}
+
+// newTestSES returns an SES provider whose clock is shortly after the
+// notification samples were published.
+func newTestSES() (*webhook.SES, *fakeSNS) {
+	sns := &fakeSNS{}
+	ses := webhook.NewSES([]string{snsTopicARN}, &http.Client{Transport: sns})
+	ses.Now = func() time.Time { return time.Date(2024, 4, 2, 10, 1, 0, 0, time.UTC) }
+	return ses, sns
+}
+
+func TestMailerSendEventsAreVerifiedAndParsed(t *testing.T) {
+	mailerSend := webhook.NewMailerSend(mailerSendSecret)
+
+	header, body := readSample(t, "mailersend_hard_bounced.json")
+	events, err := mailerSend.Events(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, []webhook.Event{{
+		Type:       webhook.EventHardBounce,
+		Email:      "gone@example.org",
+		Tags:       []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f001"},
+		Status:     "5.1.1",
+		Diagnostic: "550 5.1.1 The email account that you tried to reach does not exist.",
+		OccurredAt: time.Date(2024, 4, 2, 8, 31, 16, 0, time.UTC),
+	}}, events)
+
+	header, body = readSample(t, "mailersend_opened.json")
+	events, err = mailerSend.Events(header, body)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventOpen, events[0].Type)
+	assert.Contains(t, events[0].UserAgent, "Chrome/123.0.0.0")
+}
+
+func TestMailerSendRejectsBadSignatures(t *testing.T) {
+	header, body := readSample(t, "mailersend_hard_bounced.json")
+
+	_, err := webhook.NewMailerSend("another-secret").Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	tampered := bytes.Replace(body, []byte("gone@example.org"), []byte("someone@example.org"), 1)
+	_, err = webhook.NewMailerSend(mailerSendSecret).Events(header, tampered)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	header.Del("Signature")
+	_, err = webhook.NewMailerSend(mailerSendSecret).Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+}
+
+func TestSendGridEventsAreVerifiedAndParsed(t *testing.T) {
+	header, body := readSample(t, "sendgrid_events.json")
+
+	events, err := newTestSendGrid(t).Events(header, body)
+	require.NoError(t, err)
+
+	var types []webhook.EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []webhook.EventType{
+		webhook.EventDelivered,
+		webhook.EventHardBounce,
+		webhook.EventSoftBounce,
+		webhook.EventSoftBounce,
+		webhook.EventOpen,
+		webhook.EventComplaint,
+	}, types)
+
+	bounce := events[1]
+	assert.Equal(t, "gone@example.org", bounce.Email)
+	assert.Equal(t, []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f001"}, bounce.Tags)
+	assert.Equal(t, "5.1.1", bounce.Status)
+	assert.Equal(t, time.Unix(1712046676, 0).UTC(), bounce.OccurredAt)
+
+	assert.Equal(t, "5.7.1", events[2].Status, "blocks are soft bounces")
+	assert.Equal(t, "4.7.1", events[3].Status)
+	assert.Equal(t, "451 4.7.1 Greylisted, please try again later", events[3].Diagnostic)
+	assert.Equal(t, "annoyed@example.com", events[5].Email)
+}
+
+func TestSendGridRejectsBadSignatures(t *testing.T) {
+	sendGrid := newTestSendGrid(t)
+	header, body := readSample(t, "sendgrid_events.json")
+
+	tampered := bytes.Replace(body, []byte(`"event":"spamreport"`), []byte(`"event":"delivered"`), 1)
+	_, err := sendGrid.Events(header, tampered)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	header.Set("X-Twilio-Email-Event-Webhook-Timestamp", "1712048004")
+	_, err = sendGrid.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	header.Del("X-Twilio-Email-Event-Webhook-Signature")
+	_, err = sendGrid.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+}
+
+func TestSendGridRejectsReplayedRequests(t *testing.T) {
+	sendGrid := newTestSendGrid(t)
+	header, body := readSample(t, "sendgrid_events.json")
+
+	sendGrid.Now = func() time.Time { return time.Unix(1712048003, 0).Add(webhook.MaxTimestampSkew + time.Second) }
+	_, err := sendGrid.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	sendGrid.Now = func() time.Time { return time.Unix(1712048003, 0).Add(-webhook.MaxTimestampSkew - time.Second) }
+	_, err = sendGrid.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+}
+
+func TestNewSendGridRejectsKeysThatAreNotECDSA(t *testing.T) {
+	_, err := webhook.NewSendGrid("not base64!")
+	assert.Error(t, err)
+
+	_, err = webhook.NewSendGrid("MCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=")
+	assert.Error(t, err)
+}
+
+func TestSESNotificationsAreVerifiedAndParsed(t *testing.T) {
+	ses, sns := newTestSES()
+
+	header, body := readSample(t, "ses_bounce.json")
+	events, err := ses.Events(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, []webhook.Event{{
+		Type:       webhook.EventHardBounce,
+		Email:      "gone@example.org",
+		Tags:       []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f001"},
+		Status:     "5.1.1",
+		Diagnostic: "smtp; 550 5.1.1 user unknown",
+		OccurredAt: time.Date(2024, 4, 2, 8, 31, 16, 512000000, time.UTC),
+	}}, events)
+
+	header, body = readSample(t, "ses_complaint.json")
+	events, err = ses.Events(header, body)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, webhook.EventComplaint, events[0].Type)
+	assert.Equal(t, "annoyed@example.com", events[0].Email)
+	assert.Equal(t, "abuse", events[0].FeedbackType)
+	assert.Equal(t, "Yahoo!-Mail-Feedback/2.0", events[0].UserAgent)
+
+	assert.Equal(t, []string{"https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-01d088a6f77103d0fe307c0069e40ed6.pem"}, sns.requests,
+		"the signing certificate is downloaded once")
+}
+
+func TestSESConfirmsSubscriptions(t *testing.T) {
+	ses, sns := newTestSES()
+	ses.Now = func() time.Time { return time.Date(2024, 4, 1, 12, 0, 30, 0, time.UTC) }
+	header, body := readSample(t, "sns_subscription_confirmation.json")
+
+	events, err := ses.Events(header, body)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	require.Len(t, sns.requests, 2)
+	assert.True(t, strings.HasPrefix(sns.requests[1], "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&"))
+}
+
+func TestSESRejectsBadSignaturesAndOtherTopics(t *testing.T) {
+	header, body := readSample(t, "ses_bounce.json")
+
+	tampered := bytes.Replace(body, []byte("gone@example.org"), []byte("someone@example.org"), 1)
+	ses, _ := newTestSES()
+	_, err := ses.Events(header, tampered)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	otherTopic := webhook.NewSES([]string{"arn:aws:sns:eu-west-1:123456789012:other"}, &http.Client{Transport: &fakeSNS{}})
+	_, err = otherTopic.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	var message map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &message))
+	message["SigningCertURL"] = "https://attacker.example.com/SimpleNotificationService.pem"
+	forged, err := json.Marshal(message)
+	require.NoError(t, err)
+	ses, sns := newTestSES()
+	_, err = ses.Events(header, forged)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+	assert.Empty(t, sns.requests)
+}
+
+func TestSESRejectsReplayedMessages(t *testing.T) {
+	ses, _ := newTestSES()
+	ses.Now = func() time.Time { return time.Date(2024, 4, 2, 10, 0, 1, 0, time.UTC).Add(webhook.MaxTimestampSkew + time.Second) }
+	header, body := readSample(t, "ses_bounce.json")
+
+	_, err := ses.Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+}
+
+func TestPostmarkEventsAreAuthenticatedAndParsed(t *testing.T) {
+	postmark := webhook.NewPostmark("postmark", "webhook-password")
+
+	tests := []struct {
+		sample string
+		event  webhook.Event
+	}{
+		{"postmark_bounce.json", webhook.Event{
+			Type:       webhook.EventHardBounce,
+			Email:      "gone@example.org",
+			Tags:       []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f001"},
+			Status:     "5.1.1",
+			Diagnostic: "smtp;550 5.1.1 The email account that you tried to reach does not exist.",
+			OccurredAt: time.Date(2024, 4, 2, 8, 31, 16, 882025900, time.UTC),
+		}},
+		{"postmark_spam_complaint.json", webhook.Event{
+			Type:         webhook.EventComplaint,
+			Email:        "annoyed@example.com",
+			Tags:         []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f005"},
+			FeedbackType: "abuse",
+			OccurredAt:   time.Date(2024, 4, 2, 10, 0, 0, 0, time.UTC),
+		}},
+		{"postmark_delivery.json", webhook.Event{
+			Type:       webhook.EventDelivered,
+			Email:      "reader@example.com",
+			Tags:       []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f002"},
+			OccurredAt: time.Date(2024, 4, 2, 8, 31, 15, 0, time.UTC),
+		}},
+		{"postmark_open.json", webhook.Event{
+			Type:       webhook.EventOpen,
+			Email:      "reader@example.com",
+			Tags:       []string{"6610aa01e4b0a1b2c3d4f000-6610aa01e4b0a1b2c3d4f002"},
+			UserAgent:  "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko)",
+			OccurredAt: time.Date(2024, 4, 2, 9, 2, 43, 0, time.UTC),
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sample, func(t *testing.T) {
+			header, body := readSample(t, test.sample)
+			events, err := postmark.Events(header, body)
+			require.NoError(t, err)
+			assert.Equal(t, []webhook.Event{test.event}, events)
+		})
+	}
+}
+
+func TestPostmarkRejectsWrongCredentials(t *testing.T) {
+	header, body := readSample(t, "postmark_bounce.json")
+
+	_, err := webhook.NewPostmark("postmark", "another-password").Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+
+	header.Del("Authorization")
+	_, err = webhook.NewPostmark("postmark", "webhook-password").Events(header, body)
+	assert.True(t, errors.Is(err, webhook.ErrInvalidSignature))
+}
+
+func TestProvidersFromEnvNeedBothPostmarkCredentials(t *testing.T) {
+	t.Setenv("postmarkWebhookUser", "postmark")
+	t.Setenv("postmarkWebhookPassword", "")
+	_, err := webhook.NewProvidersFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("postmarkWebhookPassword", "webhook-password")
+	providers, err := webhook.NewProvidersFromEnv()
+	require.NoError(t, err)
+	assert.Contains(t, providers, webhook.ProviderPostmark)
+}